@@ -1,149 +1,405 @@
 package main
 
 import (
-	"context" // Provides functionality to define a deadline or cancellation signal for operations.
-	"fmt"     // Implements formatted I/O functions.
-	"log"     // Implements a simple logging package.
-	"os"      // Provides a platform-independent interface to operating system functionality.
-	"os/signal" // Allows the program to receive notifications from the operating system about incoming signals.
-	"syscall" // Contains an interface to the low-level operating system primitives.
+	"context"       // Provides functionality to define a deadline or cancellation signal for operations.
+	"encoding/json" // Implements encoding and decoding of JSON.
+	"errors"        // Implements functions to manipulate errors.
+	"flag"          // Command-line flag parsing, whose help request ends the program.
+	"fmt"           // Implements formatted I/O functions.
+	"log"           // Implements a simple logging package.
+	"log/slog"      // Structured logging.
+	"os"            // Provides a platform-independent interface to operating system functionality.
+	"os/signal"     // Allows the program to receive notifications from the operating system about incoming signals.
+	"os/user"       // Allows user account lookups by name or id.
+	"strings"       // Implements simple functions to manipulate UTF-8 encoded strings.
+	"syscall"       // Contains an interface to the low-level operating system primitives.
+	"time"          // Provides functionality for measuring and displaying time.
 
-	"github.com/joho/godotenv" // package for loading environment variables from .env files.
+	"github.com/redis/go-redis/v9" // Redis client, for rate limits shared between replicas.
 
 	// Internal packages for the location service application.
+	"locations/internal/archive"
+	"locations/internal/config"
 	"locations/internal/consumer"
 	"locations/internal/db"
-	"locations/internal/http"
-	"locations/internal/producer"
+	"locations/internal/fieldcrypt"
+	"locations/internal/logging"
+	"locations/internal/models"
+	"locations/internal/ratelimit"
+	"locations/internal/tracing"
 )
 
+// commands lists the subcommands, named by the first argument after the flags. Without one, 'all' runs.
+//
+// The service's roles run until the process is told to stop, so that they can be scaled separately:
+// 'serve-api' serves HTTP, gRPC and the MQTT bridge and publishes updates to Kafka, 'consume' stores the updates
+// read from Kafka, and 'all' runs both in one process.
+//
+// The others are one-off operations that exit when done:
+// 'migrate' applies the schema and index migrations.
+// 'replay --from <time|offset> [--partition N]' processes the updates on the topic again from a point in the past.
+// 'dlq-redrive [--idle d] [--max-redrives n]' moves the messages the consumer failed to process back to the topic.
+// 'export-driver <driverID> [reason]' and 'erase-driver <driverID> [reason]' handle data-subject requests.
+// 'archive <cutoff>' moves older history to cold storage and 'restore <from> <to>' loads a date range back.
+// 'rotate-keys' re-wraps encrypted coordinates under the current key and encrypts any left in plaintext.
+var commands = []string{
+	"serve-api", "consume", "all",
+	"migrate", "replay", "dlq-redrive", "export-driver", "erase-driver", "archive", "restore", "rotate-keys",
+}
+
 func main() {
-	// Load environment variables from .env file.
-	// If the .env file is not found, log a fatal error and exit the application.
-	if err := godotenv.Load(); err != nil {
-		log.Fatal("Error loading .env file:", err)
-	}
-
-	// Read environment variables required for the application to connect to MongoDB and Kafka.
-	mongoURI := os.Getenv("MONGODB_URI")       // MongoDB connection string.
-	kafkaBrokers := os.Getenv("KAFKA_BROKERS") // Kafka broker list.
-	kafkaTopic := os.Getenv("KAFKA_TOPIC")     // Kafka topic name.
-	httpAddr := ":8080"                        // HTTP server address.
-
-	// Create a context with cancellation capabilities to manage the lifecycle of the application.
-	// 'context.Background()' returns a non-nil, empty context. It is never canceled, has no values, and has no deadline.
-	// It is typically used by the main function, initialization, and tests, and as the top-level Context for incoming requests.
-	ctx, cancel := context.WithCancel(context.Background())
-
-	// 'defer' is used to ensure that a function call is performed later in a program’s execution, usually for purposes of cleanup.
-	// 'cancel' is a function that, when called, will signal to the context that it should be canceled.
-	// 'defer cancel()' ensures that the 'cancel' function is called when the current function returns, which is 'main' in this case.
-	// This is important for resource cleanup and to signal any goroutines running in parallel to stop execution.
-	defer cancel()
-
-	// This pattern is common in Go for handling graceful shutdowns.
-	// By calling 'cancel', all operations or goroutines that are using 'ctx' will receive a cancellation signal.
-	// They can listen for this signal by checking the 'Done' channel on the 'ctx' object and perform any necessary cleanup operations before terminating.
-	// This allows the application to shut down gracefully and avoid leaving resources in an inconsistent state.
-
-
-	// Initialize a new MongoDB instance with the provided URI.
-	// Log a fatal error and exit if the instance cannot be created.
-	mongoDB, err := db.NewMongoDB(mongoURI)
+	// Load the configuration from its defaults, the optional .env file (or the file named by -config or
+	// CONFIG_FILE), the environment and the flags, in that order of precedence; see the config package.
+	// Arguments after the flags name a subcommand.
+	cfg, args, err := config.Load(os.Args[1:], os.LookupEnv)
+	if errors.Is(err, flag.ErrHelp) {
+		fmt.Fprintf(os.Stderr, "Commands: %s\n", strings.Join(commands, ", "))
+		return
+	}
+	if err != nil {
+		log.Fatal("Error loading configuration: ", err)
+	}
+
+	// Logs are structured, written to stderr as JSON or, with LOG_FORMAT=text, as key=value pairs.
+	// LOG_LEVEL sets the least severe level logged; coordinates only appear in logs at debug level.
+	logger, err := logging.New(os.Stderr, cfg.Log)
+	if err != nil {
+		log.Fatal("Error configuring logging:", err)
+	}
+	// Packages not handed the logger, and libraries logging through the log package, use it too.
+	slog.SetDefault(logger)
+
+	command := "all"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+	logger = logger.With("command", command)
+	// Secrets are masked when the configuration is logged.
+	logger.Info("Loaded configuration", "config", cfg)
+
+	// Every command stops on SIGINT (Ctrl+C) or SIGTERM, which cancel ctx. The roles then stop taking work,
+	// finish what they are doing and return; one-off operations stop where they are.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	err = run(ctx, cfg, logger, command, args)
+	stop()
+	if err != nil {
+		logger.Error("Command failed", "error", err)
+		os.Exit(1)
+	}
+}
+
+// run runs command, returning once it is done or, for the roles, once ctx is canceled and they have stopped.
+func run(ctx context.Context, cfg *config.Config, logger *slog.Logger, command string, args []string) error {
+	var api, consume bool
+	switch command {
+	case "serve-api":
+		api = true
+	case "consume":
+		consume = true
+	case "all":
+		api, consume = true, true
+	case "migrate", "replay", "dlq-redrive", "export-driver", "erase-driver", "archive", "restore", "rotate-keys":
+	default:
+		return fmt.Errorf("unknown command %q; the commands are %s", command, strings.Join(commands, ", "))
+	}
+
+	// Traces follow each update from the HTTP request that reported it, through Kafka, to MongoDB.
+	// TRACING_EXPORTER picks where spans go: "otlp" sends them to the collector at OTEL_EXPORTER_OTLP_ENDPOINT,
+	// "stdout" prints them and "file" appends them to TRACING_FILE. TRACING_SAMPLE_RATIO is the fraction of
+	// new traces kept. Without an exporter nothing is recorded.
+	shutdownTracing, err := tracing.Setup(ctx, cfg.Tracing)
+	if err != nil {
+		return fmt.Errorf("failed to set up tracing: %w", err)
+	}
+	// Spans still buffered are flushed on the way out.
+	defer func() {
+		flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancelFlush()
+		if err := shutdownTracing(flushCtx); err != nil {
+			logger.Error("Error flushing traces", "error", err)
+		}
+	}()
+
+	// These check their arguments before connecting; redriving only needs Kafka.
+	switch command {
+	case "replay":
+		return runReplayCommand(ctx, cfg, logger, args)
+	case "dlq-redrive":
+		return runRedriveCommand(ctx, cfg, logger, args)
+	}
+
+	mongoDB, err := openMongoDB(cfg, logger)
 	if err != nil {
-		log.Fatal("Error creating MongoDB instance:", err)
+		return err
 	}
-	// Ensure the MongoDB connection is closed properly when the application exits.
+	// Ensure the MongoDB connection is closed properly when the command is done.
 	defer func() {
 		if err := mongoDB.Close(); err != nil {
-			log.Println("Error closing MongoDB connection:", err)
+			logger.Error("Error closing MongoDB connection", "error", err)
 		}
 	}()
 
-	// Attempt to create a new MongoDB instance using the provided URI.
-	// 'db.NewMongoDB' is a function that takes a MongoDB URI and returns a new MongoDB instance and an error value.
-	mongoDB, err := db.NewMongoDB(mongoURI)
+	switch command {
+	case "migrate":
+		if err := mongoDB.Migrate(ctx); err != nil {
+			return fmt.Errorf("failed to run migrations: %w", err)
+		}
+		logger.Info("Migrations applied")
+		return nil
+	case "export-driver", "erase-driver":
+		privacyDatabase, err := withArchivedHistory(mongoDB, mongoDB, cfg.Archive, logger)
+		if err != nil {
+			return err
+		}
+		return runPrivacyCommand(ctx, privacyDatabase, command, args)
+	case "archive", "restore":
+		return runArchiveCommand(ctx, mongoDB, cfg.Archive, logger, command, args)
+	case "rotate-keys":
+		result, err := mongoDB.RotateEncryptionKeys(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to rotate encryption keys: %w", err)
+		}
+		fmt.Printf("Rewrapped %d, encrypted %d, skipped %d documents\n", result.Rewrapped, result.Encrypted, result.Skipped)
+		return nil
+	}
+	return serve(ctx, cfg, mongoDB, logger, api, consume)
+}
 
-	// Check if there was an error while creating the MongoDB instance.
-	// If there is an error ('err' is not nil), log a fatal error and exit the application.
-	// 'log.Fatal' function logs the error message and then calls 'os.Exit(1)' to terminate the program.
+// openMongoDB connects to MongoDB as configured.
+func openMongoDB(cfg *config.Config, logger *slog.Logger) (*db.MongoDB, error) {
+	// Stored coordinates are encrypted when a keyfile is configured.
+	// The keyfile lists every key still needed to decrypt existing data; new data uses its 'current' key.
+	var mongoOptions []db.MongoOption
+	if keyfile := cfg.FieldEncryptionKeyfile; keyfile != "" {
+		keys, err := fieldcrypt.LoadKeyfile(keyfile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load field encryption keys: %w", err)
+		}
+		mongoOptions = append(mongoOptions, db.WithFieldEncryption(fieldcrypt.NewCipher(keys), db.DefaultCoarsePrecision))
+	}
+
+	// Heavy read-only queries are served by secondaries so they don't compete with the consumer's writes.
+	// MONGODB_SECONDARY_READS overrides which operations are routed ("none" keeps every read on the primary),
+	// MONGODB_MAX_STALENESS bounds secondary lag, and MONGODB_LOG_QUERIES=true logs where each read went.
+	mongoOptions = append(mongoOptions, db.WithReadRouting(cfg.ReadRouting), db.WithLogger(logger))
+
+	mongoDB, err := db.NewMongoDB(cfg.MongoDBURI, mongoOptions...)
 	if err != nil {
-		log.Fatal("Error creating MongoDB instance:", err)
+		return nil, fmt.Errorf("failed to create MongoDB instance: %w", err)
 	}
+	return mongoDB, nil
+}
 
-	// Defer a function call to ensure the MongoDB connection is closed properly when the application exits.
+// runReplayCommand processes the updates on the topic again from the point given by --from, for instance to
+// rebuild data lost to a bad deploy. It reads outside the consumer group, so running consumers are unaffected.
+func runReplayCommand(ctx context.Context, cfg *config.Config, logger *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet("replay", flag.ContinueOnError)
+	from := flags.String("from", "", "time (2006-01-02T15:04:05Z or 2006-01-02) or offset to replay from")
+	partition := flags.Int("partition", -1, "partition to replay; every partition if negative")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if *from == "" || flags.NArg() > 0 {
+		return fmt.Errorf("usage: replay --from <time|offset> [--partition N]")
+	}
+	start, err := consumer.ParseReplayStart(*from)
+	if err != nil {
+		return err
+	}
+
+	mongoDB, err := openMongoDB(cfg, logger)
+	if err != nil {
+		return err
+	}
 	defer func() {
-		// Attempt to close the MongoDB connection.
-		// 'mongoDB.Close' is a method that cleans up the resources associated with the 'mongoDB' instance.
-		// It returns an error if the closing process encounters any issues.
 		if err := mongoDB.Close(); err != nil {
-			// If there is an error while closing the connection, log the error message.
-			// 'log.Println' function logs the error message but unlike 'log.Fatal', it does not terminate the program.
-			log.Println("Error closing MongoDB connection:", err)
-		}
-	}() // The '()' at the end of the 'defer' statement is used to immediately invoke the function literal.
-
-	// Create a new Kafka producer instance with the specified brokers and topic.
-	// 'producer.NewKafkaProducer' is a function that takes a slice of broker addresses and a topic name,
-	// and returns a new Kafka producer instance that can send messages to the Kafka topic.
-	kafkaProducer := producer.NewKafkaProducer([]string{kafkaBrokers}, kafkaTopic)
-
-	// Start the Kafka consumer in a separate goroutine to process incoming messages.
-	// 'context.WithCancel' creates a new context that is a copy of the parent context (ctx) but with a new done channel.
-	// The done channel is closed when the 'cancelConsumer' function is called, signaling that the context should be canceled.
-	consumerCtx, cancelConsumer := context.WithCancel(ctx)
-	// 'defer cancelConsumer()' ensures that the cancel function for the consumer context is called when the function exits,
-	// which helps to stop the consumer gracefully when the application is shutting down.
-	defer cancelConsumer()
-
-	// 'go' keyword starts a new goroutine, which is a lightweight thread managed by the Go runtime.
-	// Goroutines run concurrently with other functions or goroutines.
-	go func() {
-		// 'consumer.RunKafkaConsumer' is a function that takes a context, a slice of broker addresses, a topic name,
-		// and a MongoDB instance. It listens for messages on the Kafka topic and processes them.
-		// If an error occurs while running the consumer, it will be logged.
-		if err := consumer.RunKafkaConsumer(consumerCtx, []string{kafkaBrokers}, kafkaTopic, mongoDB); err != nil {
-			// 'log.Println' logs the error message but does not terminate the program.
-			// This allows the application to continue running even if the consumer encounters an issue.
-			log.Println("Error running Kafka consumer:", err)
+			logger.Error("Error closing MongoDB connection", "error", err)
 		}
 	}()
+	if err := mongoDB.CheckLivePositionIndex(ctx); err != nil {
+		return err
+	}
+
+	// Replayed updates are stored as the consumer stores them, but are not published to live subscribers,
+	// which only want current positions. The command serves no metrics, so the resilience layer's retries and
+	// rejections are reported with the result instead.
+	resilientDatabase := db.NewResilientDatabase(mongoDB, db.DefaultResilienceConfig(), nil)
+	processor := consumer.NewKafkaMessageProcessor(tracing.NewDatabase(resilientDatabase), nil, logger)
+	result, err := consumer.Replay(ctx, cfg.KafkaBrokers, cfg.KafkaTopic, start, *partition, processor, logger)
+	fmt.Printf("Replayed %d messages, %d failed\n", result.Processed, result.Failed)
+	if stats, ok := resilientDatabase.Stats()["InsertLocationUpdate"]; ok && (stats.Retries > 0 || stats.Rejected > 0) {
+		fmt.Printf("Database writes retried %d times, %d rejected while the circuit breaker was open\n", stats.Retries, stats.Rejected)
+	}
+	if err != nil {
+		return err
+	}
+	if result.Failed > 0 {
+		return fmt.Errorf("%d messages failed to replay", result.Failed)
+	}
+	return nil
+}
 
-	// Start the HTTP server in a separate goroutine to handle incoming HTTP requests.
-	// 'context.WithCancel' creates a new context that can be canceled. This context is derived from 'ctx', which is the main application context.
-	httpCtx, cancelHTTP := context.WithCancel(ctx)
-	// 'defer cancelHTTP()' schedules the 'cancelHTTP' function to be called when the current function exits.
-	// This is used to signal the HTTP server to shutdown gracefully when the application is terminating.
-	defer cancelHTTP()
-
-	go func() {
-		// 'http.RunHTTPServer' is a function that starts an HTTP server listening on the address specified by 'httpAddr'.
-		// The server uses the Kafka producer 'kafkaProducer' for certain operations, such as publishing messages.
-		// The 'httpCtx' is passed to manage the lifecycle of the HTTP server and allow for a graceful shutdown.
-		if err := http.RunHTTPServer(httpCtx, httpAddr, *kafkaProducer); err != nil {
-			// If there is an error while running the HTTP server, it will be logged using 'log.Println'.
-			// This allows the application to continue running and log the error for troubleshooting.
-			log.Println("Error running HTTP server:", err)
+// runRedriveCommand moves the messages on the dead-letter topic back to the topic once whatever made them fail
+// has been fixed. It returns once it has read the messages that were on the dead-letter topic when it began,
+// or when the dead-letter topic has been idle for --idle.
+func runRedriveCommand(ctx context.Context, cfg *config.Config, logger *slog.Logger, args []string) error {
+	flags := flag.NewFlagSet("dlq-redrive", flag.ContinueOnError)
+	idle := flags.Duration("idle", consumer.DefaultRedriveIdle, "how long the dead-letter topic must be idle to count as drained")
+	maxRedrives := flags.Int("max-redrives", consumer.DefaultMaxRedrives, "how many times a message is redriven before it stays on the dead-letter topic")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() > 0 {
+		return fmt.Errorf("usage: dlq-redrive [--idle d] [--max-redrives n]")
+	}
+
+	result, err := consumer.Redrive(ctx, cfg.KafkaBrokers, cfg.KafkaDeadLetterTopic, cfg.KafkaTopic, *idle, *maxRedrives, logger)
+	fmt.Printf("Redrove %d messages from %s to %s, skipped %d redriven too often\n", result.Redriven, cfg.KafkaDeadLetterTopic, cfg.KafkaTopic, result.Skipped)
+	return err
+}
+
+// newRateLimits builds the ingestion rate limit policy. A limit with a zero rate is off. With a Redis URL the
+// buckets live in Redis and hold across replicas; otherwise each replica keeps its own.
+func newRateLimits(config config.RateLimits) (*ratelimit.Policy, error) {
+	newLimiter := func(limit ratelimit.Limit) ratelimit.Limiter {
+		return ratelimit.NewMemoryLimiter(limit)
+	}
+	if config.RedisURL != "" {
+		options, err := redis.ParseURL(config.RedisURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RATE_LIMIT_REDIS_URL: %w", err)
 		}
-	}()
+		client := redis.NewClient(options)
+		newLimiter = func(limit ratelimit.Limit) ratelimit.Limiter {
+			return ratelimit.NewRedisLimiter(client, "locations:ratelimit:", limit)
+		}
+	}
+
+	var drivers, clients ratelimit.Limiter
+	if config.Driver.Rate != 0 {
+		drivers = newLimiter(config.Driver)
+	}
+	if config.Client.Rate != 0 {
+		clients = newLimiter(config.Client)
+	}
+	policy := ratelimit.NewPolicy(drivers, clients)
+	policy.TrustForwardedFor = config.TrustForwardedFor
+	policy.ForwardedForHops = config.ForwardedForHops
+	return policy, nil
+}
+
+// runPrivacyCommand exports or erases a driver's data and writes the result as JSON to stdout.
+func runPrivacyCommand(ctx context.Context, database db.Database, command string, args []string) error {
+	if len(args) < 1 {
+		return fmt.Errorf("usage: %s <driverID> [reason]", command)
+	}
+
+	request := models.PrivacyRequest{DriverID: args[0], RequestedBy: "cli"}
+	if u, err := user.Current(); err == nil {
+		request.RequestedBy = "cli:" + u.Username
+	}
+	if len(args) > 1 {
+		request.Reason = strings.Join(args[1:], " ")
+	}
+
+	var (
+		result any
+		err    error
+	)
+	if command == "erase-driver" {
+		result, err = database.EraseDriverData(ctx, request)
+	} else {
+		result, err = database.ExportDriverData(ctx, request)
+	}
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(result)
+}
+
+// runArchiveCommand archives history older than a cut-off, or restores a date range of archived history.
+// Archives go to the S3-compatible bucket in ARCHIVE_S3_* when ARCHIVE_S3_ENDPOINT is set, otherwise to ARCHIVE_DIR.
+func runArchiveCommand(ctx context.Context, database db.HistoryArchiver, location config.Archive, logger *slog.Logger, command string, args []string) error {
+	store, err := newArchiveStore(location)
+	if err != nil {
+		return err
+	}
+	archiver := archive.NewArchiver(database, store, archive.DefaultBuckets)
+	archiver.Logger = logger
 
+	if command == "archive" {
+		if len(args) != 1 {
+			return fmt.Errorf("usage: archive <cutoff date (2006-01-02) or age (e.g. 2160h)>")
+		}
+		cutoff, err := parseCutoff(args[0], time.Now().UTC())
+		if err != nil {
+			return err
+		}
+		manifest, err := archiver.Archive(ctx, cutoff)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Archived %d location updates into %d files (run %s)\n", manifest.Records, len(manifest.Files), manifest.RunID)
+		return nil
+	}
 
-	// Prepare to handle termination signals (e.g., SIGINT, SIGTERM) for graceful shutdown.
-	// 'make(chan os.Signal, 1)' creates a new channel for receiving 'os.Signal' values with a buffer size of one.
-	signalCh := make(chan os.Signal, 1)
-	// 'signal.Notify' registers the given channel to receive notifications of the specified signals.
-	// In this case, it's set up to receive SIGINT (interrupt from keyboard, Ctrl+C) and SIGTERM (termination signal from the operating system).
-	signal.Notify(signalCh, syscall.SIGINT, syscall.SIGTERM)
-
-	// 'select' statement waits for multiple communication operations and proceeds with the first one that's ready.
-	// Here, it's used to wait for a signal to be received on the 'signalCh' channel.
-	select {
-	case sig := <-signalCh:
-		// When a signal is received, it's assigned to the variable 'sig' and the following code block is executed.
-		fmt.Printf("Received signal %s. Shutting down...\n", sig)
-		// 'cancel()' is called to trigger the cancellation of all contexts created with 'context.WithCancel'.
-		// This includes 'ctx', 'consumerCtx', 'httpCtx', and any other derived contexts.
-		// It signals all goroutines using these contexts to stop their work and helps in the graceful shutdown of the application.
-		cancel()
+	if len(args) != 2 {
+		return fmt.Errorf("usage: restore <from date> <to date>")
+	}
+	from, err := time.Parse("2006-01-02", args[0])
+	if err != nil {
+		return fmt.Errorf("invalid from date: %w", err)
+	}
+	to, err := time.Parse("2006-01-02", args[1])
+	if err != nil {
+		return fmt.Errorf("invalid to date: %w", err)
+	}
+	restored, err := archiver.Restore(ctx, from, to)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Restored %d location updates\n", restored)
+	return nil
+}
+
+// newArchiveStore opens the archive: the S3-compatible bucket in ARCHIVE_S3_* when ARCHIVE_S3_ENDPOINT is set,
+// otherwise ARCHIVE_DIR.
+func newArchiveStore(location config.Archive) (archive.Store, error) {
+	if location.S3.Endpoint != "" {
+		return archive.NewS3Store(location.S3)
+	}
+	return archive.NewLocalStore(location.Dir)
+}
+
+// withArchivedHistory extends the data-subject requests of database to the archive, so that exports include
+// archived history and erasures purge it. A local archive directory that doesn't exist holds nothing yet, so
+// database is returned as it is rather than creating one.
+func withArchivedHistory(database db.Database, history db.HistoryArchiver, location config.Archive, logger *slog.Logger) (db.Database, error) {
+	if location.S3.Endpoint == "" {
+		if _, err := os.Stat(location.Dir); errors.Is(err, os.ErrNotExist) {
+			return database, nil
+		}
+	}
+	store, err := newArchiveStore(location)
+	if err != nil {
+		return nil, err
+	}
+	archiver := archive.NewArchiver(history, store, archive.DefaultBuckets)
+	archiver.Logger = logger
+	return archive.NewDatabase(database, archiver), nil
+}
+
+// parseCutoff accepts either a date, meaning midnight UTC at its start, or an age counted back from now.
+func parseCutoff(value string, now time.Time) (time.Time, error) {
+	if cutoff, err := time.Parse("2006-01-02", value); err == nil {
+		return cutoff, nil
+	}
+	age, err := time.ParseDuration(value)
+	if err != nil || age <= 0 {
+		return time.Time{}, fmt.Errorf("invalid cutoff %q: expected a date like 2006-01-02 or an age like 2160h", value)
 	}
+	return now.Add(-age), nil
 }
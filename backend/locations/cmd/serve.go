@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+
+	"locations/internal/auth"
+	"locations/internal/config"
+	"locations/internal/consumer"
+	"locations/internal/db"
+	"locations/internal/grpc"
+	"locations/internal/health"
+	"locations/internal/http"
+	"locations/internal/lifecycle"
+	"locations/internal/live"
+	"locations/internal/metrics"
+	"locations/internal/mqttbridge"
+	"locations/internal/producer"
+	"locations/internal/tracing"
+	"locations/internal/trips"
+)
+
+// serve runs the API role, the consumer role or both until ctx is canceled, then waits for them to stop.
+// The API takes updates over HTTP, gRPC and MQTT and publishes them to Kafka; the consumer stores what it reads
+// from Kafka. An instance running only the consumer serves its probes and metrics on HTTP_ADDR.
+func serve(ctx context.Context, cfg *config.Config, mongoDB *db.MongoDB, logger *slog.Logger, api, consume bool) error {
+	// Migrations can also run at startup; this is opt-in so that replicas don't all race to apply them on every deploy.
+	if cfg.MigrateOnStart {
+		if err := mongoDB.Migrate(ctx); err != nil {
+			return fmt.Errorf("failed to run migrations: %w", err)
+		}
+	}
+	// The consumer upserts live positions, which is only safe with the unique index from the migrations.
+	if consume {
+		if err := mongoDB.CheckLivePositionIndex(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Prometheus metrics are served at /metrics. The database, producer and message processor are wrapped
+	// so that their calls are timed and their failures counted.
+	serviceMetrics := metrics.New()
+
+	// The service talks to MongoDB through the resilience layer, which adds per-operation deadlines,
+	// retries of transient failures and a circuit breaker so that handlers fail fast during a failover.
+	// Its counters and breaker state are published at /debug/vars, and its retries, rejections and breaker
+	// state at /metrics.
+	// The metrics wrapper sits beneath it, so that each attempt against MongoDB is timed on its own;
+	// the tracing wrapper sits above it, so that each call gets one span however many attempts it took.
+	resilientDatabase := db.NewResilientDatabase(metrics.NewDatabase(mongoDB, serviceMetrics), db.DefaultResilienceConfig(),
+		metrics.NewResilienceObserver(serviceMetrics))
+	database := tracing.NewDatabase(resilientDatabase)
+	expvar.Publish("database", expvar.Func(func() any {
+		return map[string]any{
+			"breaker":      resilientDatabase.BreakerState().String(),
+			"operations":   resilientDatabase.Stats(),
+			"read_routing": mongoDB.ReadRouting(),
+		}
+	}))
+
+	// The producer publishes the API's updates; both roles use it to check that the brokers are reachable.
+	kafkaProducer := producer.NewKafkaProducer(cfg.KafkaBrokers, cfg.KafkaTopic)
+
+	// /readyz fails while MongoDB or Kafka is unreachable, or the consumer has stopped or is more than
+	// CONSUMER_MAX_LAG messages behind; /status shows admins the details of each check. Probes come often and
+	// unauthenticated, so the results are reused for a moment rather than checked on every request.
+	checker := health.NewCachingChecker(health.DefaultCacheTTL)
+	checker.Register("mongodb", health.Ping(mongoDB.Ping))
+	checker.Register("kafka", kafkaProducer.CheckBrokers)
+
+	// Feed live position changes to in-process subscribers such as the /live stream and rider tracking.
+	// A consumer in the same process publishes what it stores straight away; the database feed adds positions
+	// stored by consumers elsewhere. MongoDB pushes them through a change stream; other backends, or standalone
+	// MongoDB servers, are polled. The hub drops whichever copy of a position arrives second.
+	var hub *live.Hub
+	if api {
+		hub = live.NewHub()
+	}
+
+	var components []lifecycle.Component
+	if consume {
+		// The monitor tracks whether the consumer loop is running and how far it lags, for the readiness check.
+		monitor := consumer.NewMonitor()
+		checker.Register("consumer", monitor.Check(cfg.ConsumerMaxLag))
+		// Messages that fail to process are kept on KAFKA_DLQ_TOPIC, from which dlq-redrive moves them back.
+		deadLetters := consumer.NewDeadLetterQueue(cfg.KafkaBrokers, cfg.KafkaDeadLetterTopic)
+		defer deadLetters.Close()
+		var publisher consumer.LocationPublisher
+		if hub != nil {
+			publisher = hub
+		}
+		messageProcessor := metrics.NewMessageProcessor(consumer.NewKafkaMessageProcessor(database, publisher, logger), serviceMetrics)
+		components = append(components, lifecycle.Component{Name: "Kafka consumer", Run: func(ctx context.Context) error {
+			return consumer.RunKafkaConsumer(ctx, cfg.KafkaBrokers, cfg.KafkaTopic, messageProcessor, monitor, deadLetters, logger)
+		}})
+	}
+
+	if api {
+		// Exports and erasures through the admin API also cover history moved to the archive.
+		apiDatabase, err := withArchivedHistory(database, mongoDB, cfg.Archive, logger)
+		if err != nil {
+			return err
+		}
+		apiComponents, err := apiComponents(cfg, kafkaProducer, apiDatabase, hub, checker, serviceMetrics, logger)
+		if err != nil {
+			return err
+		}
+		components = append(components, apiComponents...)
+	} else {
+		components = append(components, lifecycle.Component{Name: "probe server", Run: func(ctx context.Context) error {
+			return http.RunProbeServer(ctx, cfg.HTTPAddr, cfg.AdminToken, checker, serviceMetrics, logger)
+		}})
+	}
+
+	return lifecycle.Run(ctx, logger, components)
+}
+
+// apiComponents returns the components of the API role: the live position feed, the HTTP and gRPC servers and,
+// when a broker is configured, the MQTT bridge.
+func apiComponents(cfg *config.Config, kafkaProducer *producer.KafkaProducer, database db.Database, hub *live.Hub, checker *health.Checker, serviceMetrics *metrics.Metrics, logger *slog.Logger) ([]lifecycle.Component, error) {
+	// The location API requires tokens issued by the users service, signed with HS256 under JWT_HS256_SECRET
+	// or RS256 under the key in JWT_RS256_PUBLIC_KEY_FILE, or with any key in the JWT_JWKS_FILE key set.
+	// Without any of them the API would be open, so it only starts if AUTH_ALLOW_UNAUTHENTICATED says that is meant,
+	// as in development.
+	var verifier *auth.Verifier
+	switch {
+	case cfg.Auth.Enabled():
+		var err error
+		verifier, err = auth.NewVerifier(cfg.Auth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure token verification: %w", err)
+		}
+	case cfg.AllowUnauthenticated:
+		logger.Warn("No JWT keys configured; the location API is open to unauthenticated requests")
+	default:
+		return nil, errors.New("no JWT keys configured; set JWT_HS256_SECRET, JWT_RS256_PUBLIC_KEY_FILE or JWT_JWKS_FILE, or AUTH_ALLOW_UNAUTHENTICATED for development")
+	}
+
+	// Ingestion over HTTP, gRPC and MQTT is rate limited per driver and per client address; see newRateLimits.
+	limits, err := newRateLimits(cfg.RateLimits)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure rate limits: %w", err)
+	}
+	limits.Logger = logger
+
+	// Driver apps authenticate their location streams with their users-service token, or without JWT keys,
+	// with expiring tokens signed by the driver token secret. Browser pages may only open streams from the
+	// origins in STREAM_ALLOWED_ORIGINS.
+	var driverAuth http.DriverAuthenticator
+	switch {
+	case verifier != nil:
+		driverAuth = http.NewJWTDriverTokens(verifier)
+	case cfg.DriverTokenSecret != "":
+		driverAuth = http.NewHMACDriverTokens(cfg.DriverTokenSecret)
+	}
+	// Riders track their trip with tokens issued by the trip service, which also ends trips via the admin API.
+	var tripTracker *trips.Tracker
+	if cfg.TripTokenSecret != "" {
+		tripTracker = trips.NewTracker(cfg.TripTokenSecret)
+	}
+
+	// The gRPC server terminates TLS itself when it has a certificate, and checks tokens like the HTTP server.
+	grpcOptions, err := grpc.ServerOptions(cfg.GRPCTLSCertFile, cfg.GRPCTLSKeyFile, verifier)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure the gRPC server: %w", err)
+	}
+
+	instrumentedProducer := metrics.NewProducer(kafkaProducer, serviceMetrics)
+	components := []lifecycle.Component{
+		{Name: "live position hub", Run: func(ctx context.Context) error {
+			return hub.Run(ctx, database, live.DefaultPollInterval, logger)
+		}},
+		{Name: "HTTP server", Run: func(ctx context.Context) error {
+			return http.RunHTTPServer(ctx, cfg.HTTPAddr, instrumentedProducer, database, cfg.AdminToken, hub, driverAuth, cfg.StreamAllowedOrigins, tripTracker, verifier, limits, checker, serviceMetrics, logger)
+		}},
+		// The gRPC server for internal services and mobile SDKs listens on its own port.
+		{Name: "gRPC server", Run: func(ctx context.Context) error {
+			return grpc.RunGRPCServer(ctx, cfg.GRPCAddr, instrumentedProducer, database, hub, limits, logger, grpcOptions...)
+		}},
+	}
+
+	// Hardware trackers that speak MQTT are bridged into the same Kafka topic when a broker is configured.
+	// MQTT_TOPIC_PATTERN is the subscription, whose '+' level is the driver ID, and MQTT_CLIENT_ID names
+	// the bridge's persistent session.
+	if cfg.MQTT.BrokerURL != "" {
+		mqttConfig := cfg.MQTT
+		mqttConfig.Logger = logger
+		components = append(components, lifecycle.Component{Name: "MQTT bridge", Run: func(ctx context.Context) error {
+			return mqttbridge.Run(ctx, mqttConfig, instrumentedProducer, limits)
+		}})
+	}
+	return components, nil
+}
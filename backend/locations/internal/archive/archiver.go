@@ -0,0 +1,393 @@
+package archive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"locations/internal/db"
+	"locations/internal/logging"
+)
+
+const (
+	// DefaultBuckets is the number of driver-hash partitions per day.
+	DefaultBuckets = 16
+	// dayLayout names day partitions.
+	dayLayout = "2006-01-02"
+	// manifestPrefix is where run manifests are stored. A run's manifest is rewritten each time a day's files
+	// have been uploaded, before that day's history is deleted, so every deleted document is listed in one;
+	// files that no manifest lists are ignored by Restore.
+	manifestPrefix = "manifests/"
+	// restoreBatch is how many records are written per RestoreHistory call.
+	restoreBatch = 1000
+)
+
+// Manifest describes one archive run.
+type Manifest struct {
+	RunID     string    `json:"run_id"`
+	Cutoff    time.Time `json:"cutoff"`
+	CreatedAt time.Time `json:"created_at"`
+	// CompletedAt is set once the run has archived everything before its cutoff.
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	// Buckets is how many driver-hash partitions each day was split into; zero in manifests of older runs.
+	Buckets int         `json:"buckets,omitempty"`
+	Records int64       `json:"records"`
+	Files   []FileEntry `json:"files"`
+	// Purged lists files that PurgeDriver replaced and that are still to be deleted.
+	Purged []string `json:"purged,omitempty"`
+}
+
+// FileEntry describes one compressed NDJSON partition file.
+type FileEntry struct {
+	Path         string    `json:"path"`
+	Day          string    `json:"day"`
+	Bucket       int       `json:"bucket"`
+	Records      int64     `json:"records"`
+	Bytes        int64     `json:"bytes"`
+	SHA256       string    `json:"sha256"`
+	MinTimestamp time.Time `json:"min_timestamp"`
+	MaxTimestamp time.Time `json:"max_timestamp"`
+}
+
+// Archiver moves old location history between the database and cold storage.
+type Archiver struct {
+	database db.HistoryArchiver
+	store    Store
+	buckets  int
+	// Logger receives a summary of each run; nil logs to slog.Default().
+	Logger *slog.Logger
+}
+
+// NewArchiver creates an Archiver that partitions each day into buckets files by driver hash.
+func NewArchiver(database db.HistoryArchiver, store Store, buckets int) *Archiver {
+	if buckets <= 0 {
+		buckets = DefaultBuckets
+	}
+	return &Archiver{database: database, store: store, buckets: buckets}
+}
+
+// partition accumulates one day/bucket file in memory.
+type partition struct {
+	entry  FileEntry
+	buffer bytes.Buffer
+	gzip   *gzip.Writer
+}
+
+// Archive writes all history before cutoff to gzip-compressed NDJSON files partitioned by day and driver hash.
+// Documents are archived as they are stored, so field-encrypted coordinates stay encrypted.
+// Files for a day are uploaded as soon as the scan moves past that day; the manifest is then updated and only
+// then is that day's history deleted from the database, so memory use is bounded by one day's data.
+func (a *Archiver) Archive(ctx context.Context, cutoff time.Time) (*Manifest, error) {
+	now := time.Now().UTC()
+	runID, err := newRunID(now)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{
+		RunID:     runID,
+		Cutoff:    cutoff.UTC(),
+		CreatedAt: now,
+		Buckets:   a.buckets,
+		Files:     []FileEntry{},
+	}
+
+	var (
+		keys       []string
+		deleted    int64
+		currentDay string
+		partitions = make(map[int]*partition)
+	)
+	flush := func() error {
+		if len(partitions) == 0 {
+			return nil
+		}
+		for _, bucket := range sortedBuckets(partitions) {
+			entry, err := a.upload(ctx, partitions[bucket])
+			if err != nil {
+				return err
+			}
+			manifest.Files = append(manifest.Files, entry)
+		}
+		partitions = make(map[int]*partition)
+
+		if err := a.writeManifest(ctx, manifest); err != nil {
+			return err
+		}
+		n, err := a.database.DeleteHistory(ctx, keys)
+		deleted += n
+		if err != nil {
+			return fmt.Errorf("archive %s was written but deleting archived history failed after %d documents: %w", manifest.RunID, deleted, err)
+		}
+		keys = keys[:0]
+		return nil
+	}
+
+	err = a.database.ScanHistoryBefore(ctx, cutoff, func(record db.HistoryRecord) error {
+		day := record.Timestamp.UTC().Format(dayLayout)
+		if day != currentDay {
+			if err := flush(); err != nil {
+				return err
+			}
+			currentDay = day
+		}
+
+		bucket := driverBucket(record.DriverID, a.buckets)
+		p, ok := partitions[bucket]
+		if !ok {
+			p = newPartition(filePath(day, bucket, manifest.RunID), day, bucket)
+			partitions[bucket] = p
+		}
+		if err := p.add(record); err != nil {
+			return err
+		}
+
+		keys = append(keys, record.Key)
+		manifest.Records++
+		return nil
+	})
+	if err == nil {
+		err = flush()
+	}
+	if err == nil && len(manifest.Files) > 0 {
+		completedAt := time.Now().UTC()
+		manifest.CompletedAt = &completedAt
+		err = a.writeManifest(ctx, manifest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to archive history: %w", err)
+	}
+	if manifest.Records > 0 {
+		logging.Or(a.Logger).InfoContext(ctx, "Archived location updates", "records", manifest.Records, "deleted", deleted, "files", len(manifest.Files), "run_id", manifest.RunID)
+	}
+	return manifest, nil
+}
+
+// filePath names the file of a day and bucket written by the run or purge called name.
+func filePath(day string, bucket int, name string) string {
+	return fmt.Sprintf("history/day=%s/bucket=%02d/%s.ndjson.gz", day, bucket, name)
+}
+
+// newPartition starts an empty partition file.
+func newPartition(path, day string, bucket int) *partition {
+	p := &partition{entry: FileEntry{Path: path, Day: day, Bucket: bucket}}
+	p.gzip = gzip.NewWriter(&p.buffer)
+	return p
+}
+
+// add appends record to the partition. Records are added in timestamp order.
+func (p *partition) add(record db.HistoryRecord) error {
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	if _, err := p.gzip.Write(append(line, '\n')); err != nil {
+		return err
+	}
+	if p.entry.Records == 0 {
+		p.entry.MinTimestamp = record.Timestamp
+	}
+	p.entry.Records++
+	p.entry.MaxTimestamp = record.Timestamp
+	return nil
+}
+
+// newRunID names an archive run by its start time and a random suffix, so that runs started in the same
+// second don't overwrite each other's files.
+func newRunID(now time.Time) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", fmt.Errorf("failed to generate archive run ID: %w", err)
+	}
+	return now.Format("20060102T150405Z") + "-" + hex.EncodeToString(suffix), nil
+}
+
+// writeManifest writes, or overwrites, the manifest of a run.
+func (a *Archiver) writeManifest(ctx context.Context, manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := a.store.Put(ctx, manifestPrefix+manifest.RunID+".json", bytes.NewReader(data), int64(len(data))); err != nil {
+		return fmt.Errorf("failed to write archive manifest: %w", err)
+	}
+	return nil
+}
+
+// upload finishes a partition's compression stream, checksums it and writes it to the store.
+func (a *Archiver) upload(ctx context.Context, p *partition) (FileEntry, error) {
+	if err := p.gzip.Close(); err != nil {
+		return FileEntry{}, err
+	}
+	sum := sha256.Sum256(p.buffer.Bytes())
+	p.entry.SHA256 = hex.EncodeToString(sum[:])
+	p.entry.Bytes = int64(p.buffer.Len())
+
+	if err := a.store.Put(ctx, p.entry.Path, bytes.NewReader(p.buffer.Bytes()), p.entry.Bytes); err != nil {
+		return FileEntry{}, fmt.Errorf("failed to write %s: %w", p.entry.Path, err)
+	}
+	return p.entry, nil
+}
+
+// Restore loads every archived file whose day falls within [from, to] back into the database.
+// Each file's checksum is verified before any of its records are written. Records go back under their original
+// keys, so restoring the same range twice leaves one copy of each. Records of drivers whose data was erased
+// after they were recorded are skipped.
+func (a *Archiver) Restore(ctx context.Context, from, to time.Time) (int64, error) {
+	fromDay, toDay := from.UTC().Format(dayLayout), to.UTC().Format(dayLayout)
+
+	manifests, err := a.Manifests(ctx)
+	if err != nil {
+		return 0, err
+	}
+	erasures, err := a.database.Erasures(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var restored, skipped int64
+	for _, manifest := range manifests {
+		for _, entry := range manifest.Files {
+			if entry.Day < fromDay || entry.Day > toDay {
+				continue
+			}
+			n, s, err := a.restoreFile(ctx, entry, erasures)
+			restored += n
+			skipped += s
+			if err != nil {
+				return restored, err
+			}
+		}
+	}
+	if skipped > 0 {
+		logging.Or(a.Logger).InfoContext(ctx, "Skipped archived location updates of erased drivers", "records", skipped)
+	}
+	return restored, nil
+}
+
+// Manifests reads every run manifest in the store, oldest run first.
+func (a *Archiver) Manifests(ctx context.Context) ([]Manifest, error) {
+	paths, err := a.store.List(ctx, manifestPrefix)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list archive manifests: %w", err)
+	}
+
+	manifests := make([]Manifest, 0, len(paths))
+	for _, path := range paths {
+		if !strings.HasSuffix(path, ".json") {
+			continue
+		}
+		r, err := a.store.Get(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		var manifest Manifest
+		err = json.NewDecoder(r).Decode(&manifest)
+		r.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+		manifests = append(manifests, manifest)
+	}
+	return manifests, nil
+}
+
+// restoreFile restores one partition file, skipping erased records.
+// It returns how many records were restored and how many were skipped.
+func (a *Archiver) restoreFile(ctx context.Context, entry FileEntry, erasures map[string]time.Time) (int64, int64, error) {
+	var (
+		restored, skipped int64
+		batch             []db.HistoryRecord
+	)
+	err := a.readRecords(ctx, entry, func(record db.HistoryRecord) error {
+		if erasedAt, ok := erasures[record.DriverID]; ok && record.Timestamp.Before(erasedAt) {
+			skipped++
+			return nil
+		}
+		batch = append(batch, record)
+		if len(batch) == restoreBatch {
+			if err := a.database.RestoreHistory(ctx, batch); err != nil {
+				return err
+			}
+			restored += int64(len(batch))
+			batch = batch[:0]
+		}
+		return nil
+	})
+	if err != nil {
+		return restored, skipped, err
+	}
+	if err := a.database.RestoreHistory(ctx, batch); err != nil {
+		return restored, skipped, err
+	}
+	return restored + int64(len(batch)), skipped, nil
+}
+
+// readRecords downloads a partition file, verifies its checksum before handing out any of its records,
+// and calls handle for each record in order.
+func (a *Archiver) readRecords(ctx context.Context, entry FileEntry, handle func(record db.HistoryRecord) error) error {
+	r, err := a.store.Get(ctx, entry.Path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", entry.Path, err)
+	}
+	data, err := io.ReadAll(r)
+	r.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", entry.Path, err)
+	}
+
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != entry.SHA256 {
+		return fmt.Errorf("checksum mismatch for %s", entry.Path)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("failed to decompress %s: %w", entry.Path, err)
+	}
+	defer gz.Close()
+
+	scanner := bufio.NewScanner(gz)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var record db.HistoryRecord
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return fmt.Errorf("failed to decode record in %s: %w", entry.Path, err)
+		}
+		if err := handle(record); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read records in %s: %w", entry.Path, err)
+	}
+	return nil
+}
+
+// driverBucket assigns a driver to one of buckets partitions.
+func driverBucket(driverID string, buckets int) int {
+	h := fnv.New32a()
+	h.Write([]byte(driverID))
+	return int(h.Sum32() % uint32(buckets))
+}
+
+// sortedBuckets returns the bucket numbers of the open partitions in order, so manifests are deterministic.
+func sortedBuckets(partitions map[int]*partition) []int {
+	buckets := make([]int, 0, len(partitions))
+	for bucket := range partitions {
+		buckets = append(buckets, bucket)
+	}
+	sort.Ints(buckets)
+	return buckets
+}
@@ -0,0 +1,204 @@
+package archive
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"locations/internal/db"
+	"locations/internal/logging"
+	"locations/internal/models"
+)
+
+// runTimeout is how long after it started a run without CompletedAt is taken to be in progress rather than
+// interrupted. PurgeDriver leaves runs in progress alone, since their manifests are still being rewritten.
+const runTimeout = 24 * time.Hour
+
+// Database extends the data-subject requests of a db.Database to archived history: exports include the driver's
+// archived updates and erasures purge them from the archive files.
+type Database struct {
+	db.Database
+	archiver *Archiver
+}
+
+// NewDatabase wraps database so that its data-subject requests cover what archiver has archived.
+func NewDatabase(database db.Database, archiver *Archiver) *Database {
+	return &Database{Database: database, archiver: archiver}
+}
+
+// Unwrap returns the decorated database.
+func (d *Database) Unwrap() db.Database {
+	return d.Database
+}
+
+// ExportDriverData adds the driver's archived history to the database's export.
+func (d *Database) ExportDriverData(ctx context.Context, request models.PrivacyRequest) (*models.DriverDataExport, error) {
+	export, err := d.Database.ExportDriverData(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	export.ArchivedHistory, err = d.archiver.DriverHistory(ctx, request.DriverID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export archived history: %w", err)
+	}
+	return export, nil
+}
+
+// EraseDriverData erases the driver from the database, then purges them from the archive. The database goes
+// first: its erase record keeps the archived history from being restored should the purge fail, and erasing
+// again retries the purge.
+func (d *Database) EraseDriverData(ctx context.Context, request models.PrivacyRequest) (*models.DriverErasureResult, error) {
+	result, err := d.Database.EraseDriverData(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+	result.ArchivedDeleted, err = d.archiver.PurgeDriver(ctx, request.DriverID)
+	if err != nil {
+		return nil, fmt.Errorf("driver %s was erased from the database but purging the archive failed after %d updates: %w", request.DriverID, result.ArchivedDeleted, err)
+	}
+	return result, nil
+}
+
+// DriverHistory returns the driver's archived updates, oldest first. Only the files of the driver's bucket are read.
+func (a *Archiver) DriverHistory(ctx context.Context, driverID string) ([]models.LocationUpdate, error) {
+	manifests, err := a.Manifests(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	history := []models.LocationUpdate{}
+	for _, manifest := range manifests {
+		for _, entry := range manifest.Files {
+			if !holdsDriver(manifest, entry, driverID) {
+				continue
+			}
+			err := a.readRecords(ctx, entry, func(record db.HistoryRecord) error {
+				if record.DriverID != driverID {
+					return nil
+				}
+				update, err := a.database.DecodeHistory(record)
+				if err != nil {
+					return err
+				}
+				history = append(history, update)
+				return nil
+			})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.SliceStable(history, func(i, j int) bool {
+		return history[i].Timestamp.Before(history[j].Timestamp)
+	})
+	return history, nil
+}
+
+// PurgeDriver removes the driver's records from the archive and returns how many were removed.
+// Each file holding the driver is replaced by a copy without them, or dropped if nothing else is left. The
+// manifest is rewritten to list the copies and the replaced files under Purged, and only then are those
+// deleted, so a purge that is interrupted finishes the deletes the next time it runs.
+// It fails while an archive run is in progress, so that the run doesn't overwrite the rewritten manifest.
+func (a *Archiver) PurgeDriver(ctx context.Context, driverID string) (int64, error) {
+	manifests, err := a.Manifests(ctx)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC()
+	for _, manifest := range manifests {
+		if manifest.CompletedAt == nil && now.Sub(manifest.CreatedAt) < runTimeout {
+			return 0, fmt.Errorf("archive run %s is in progress; erase the driver again once it has finished", manifest.RunID)
+		}
+	}
+
+	var purged int64
+	for i := range manifests {
+		n, err := a.purgeRun(ctx, &manifests[i], driverID)
+		purged += n
+		if err != nil {
+			return purged, err
+		}
+	}
+	if purged > 0 {
+		logging.Or(a.Logger).InfoContext(ctx, "Purged archived location updates of an erased driver", "records", purged)
+	}
+	return purged, nil
+}
+
+// purgeRun removes the driver's records from the files of one run.
+func (a *Archiver) purgeRun(ctx context.Context, manifest *Manifest, driverID string) (int64, error) {
+	if err := a.deletePurged(ctx, manifest); err != nil {
+		return 0, err
+	}
+
+	purgeID, err := newRunID(time.Now().UTC())
+	if err != nil {
+		return 0, err
+	}
+	var purged int64
+	files := make([]FileEntry, 0, len(manifest.Files))
+	for _, entry := range manifest.Files {
+		if !holdsDriver(*manifest, entry, driverID) {
+			files = append(files, entry)
+			continue
+		}
+
+		p := newPartition(filePath(entry.Day, entry.Bucket, manifest.RunID+"-purge-"+purgeID), entry.Day, entry.Bucket)
+		var removed int64
+		err := a.readRecords(ctx, entry, func(record db.HistoryRecord) error {
+			if record.DriverID == driverID {
+				removed++
+				return nil
+			}
+			return p.add(record)
+		})
+		if err != nil {
+			return purged, err
+		}
+		if removed == 0 {
+			files = append(files, entry)
+			continue
+		}
+
+		if p.entry.Records > 0 {
+			replacement, err := a.upload(ctx, p)
+			if err != nil {
+				return purged, err
+			}
+			files = append(files, replacement)
+		}
+		manifest.Purged = append(manifest.Purged, entry.Path)
+		manifest.Records -= removed
+		purged += removed
+	}
+	if purged == 0 {
+		return 0, nil
+	}
+
+	manifest.Files = files
+	if err := a.writeManifest(ctx, manifest); err != nil {
+		return 0, err
+	}
+	return purged, a.deletePurged(ctx, manifest)
+}
+
+// deletePurged deletes the files a purge replaced and clears them from the manifest.
+func (a *Archiver) deletePurged(ctx context.Context, manifest *Manifest) error {
+	if len(manifest.Purged) == 0 {
+		return nil
+	}
+	for _, path := range manifest.Purged {
+		if err := a.store.Delete(ctx, path); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", path, err)
+		}
+	}
+	manifest.Purged = nil
+	return a.writeManifest(ctx, manifest)
+}
+
+// holdsDriver reports whether a file of the manifest may hold records of the driver. Manifests of runs from
+// before the bucket count was recorded may hold the driver in any file.
+func holdsDriver(manifest Manifest, entry FileEntry, driverID string) bool {
+	return manifest.Buckets == 0 || entry.Bucket == driverBucket(driverID, manifest.Buckets)
+}
@@ -0,0 +1,175 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Store is where archive files and manifests are written.
+// Paths are slash-separated and relative to the store's root.
+type Store interface {
+	// Put writes the object at path, replacing any existing one.
+	Put(ctx context.Context, path string, r io.Reader, size int64) error
+	// Get opens the object at path for reading.
+	Get(ctx context.Context, path string) (io.ReadCloser, error)
+	// List returns the paths of all objects under prefix, sorted.
+	List(ctx context.Context, prefix string) ([]string, error)
+	// Delete removes the object at path. Deleting an object that doesn't exist is not an error.
+	Delete(ctx context.Context, path string) error
+}
+
+// LocalStore keeps archives in a directory on the local filesystem.
+type LocalStore struct {
+	root string
+}
+
+// NewLocalStore creates a store rooted at dir, creating the directory if needed.
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return &LocalStore{root: dir}, nil
+}
+
+// Put writes the object to a temporary file and renames it into place, so readers never see a partial file.
+func (s *LocalStore) Put(ctx context.Context, path string, r io.Reader, size int64) error {
+	target := filepath.Join(s.root, filepath.FromSlash(path))
+	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// Get opens the object's file.
+func (s *LocalStore) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(s.root, filepath.FromSlash(path)))
+}
+
+// Delete removes the object's file.
+func (s *LocalStore) Delete(ctx context.Context, path string) error {
+	err := os.Remove(filepath.Join(s.root, filepath.FromSlash(path)))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// List walks the directory under prefix.
+func (s *LocalStore) List(ctx context.Context, prefix string) ([]string, error) {
+	var paths []string
+	err := filepath.WalkDir(s.root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || strings.HasPrefix(d.Name(), ".tmp-") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if strings.HasPrefix(rel, prefix) {
+			paths = append(paths, rel)
+		}
+		return nil
+	})
+	sort.Strings(paths)
+	return paths, err
+}
+
+// S3Config configures an S3-compatible store.
+type S3Config struct {
+	Endpoint  string
+	Bucket    string
+	Prefix    string
+	AccessKey string
+	SecretKey string
+	UseSSL    bool
+}
+
+// S3Store keeps archives in a bucket on an S3-compatible endpoint such as AWS S3 or MinIO.
+type S3Store struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Store connects to the endpoint in cfg. The bucket must already exist.
+func NewS3Store(cfg S3Config) (*S3Store, error) {
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	prefix := strings.Trim(cfg.Prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &S3Store{client: client, bucket: cfg.Bucket, prefix: prefix}, nil
+}
+
+// Put uploads the object.
+func (s *S3Store) Put(ctx context.Context, path string, r io.Reader, size int64) error {
+	_, err := s.client.PutObject(ctx, s.bucket, s.prefix+path, r, size, minio.PutObjectOptions{})
+	return err
+}
+
+// Get downloads the object.
+func (s *S3Store) Get(ctx context.Context, path string) (io.ReadCloser, error) {
+	object, err := s.client.GetObject(ctx, s.bucket, s.prefix+path, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	// GetObject is lazy; Stat surfaces a missing object before the caller starts reading.
+	if _, err := object.Stat(); err != nil {
+		object.Close()
+		return nil, err
+	}
+	return object, nil
+}
+
+// Delete removes the object. S3 reports success for a missing key.
+func (s *S3Store) Delete(ctx context.Context, path string) error {
+	return s.client.RemoveObject(ctx, s.bucket, s.prefix+path, minio.RemoveObjectOptions{})
+}
+
+// List lists the objects under prefix.
+func (s *S3Store) List(ctx context.Context, prefix string) ([]string, error) {
+	var paths []string
+	for object := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: s.prefix + prefix, Recursive: true}) {
+		if object.Err != nil {
+			return nil, object.Err
+		}
+		paths = append(paths, strings.TrimPrefix(object.Key, s.prefix))
+	}
+	sort.Strings(paths)
+	return paths, nil
+}
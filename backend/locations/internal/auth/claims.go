@@ -0,0 +1,83 @@
+// Package auth verifies the JWTs the users service issues and answers what their holders may do.
+package auth
+
+import (
+	"context"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Roles and scopes the users service grants.
+const (
+	// RoleDriver is held by driver apps; the token's subject is the driver ID.
+	RoleDriver = "driver"
+	// RoleRider is held by rider apps; a rider on a trip also has the trip claim.
+	RoleRider = "rider"
+	// RoleOps is held by operations staff, who may see every driver.
+	RoleOps = "ops"
+	// ScopeAdmin allows changing stored location history.
+	ScopeAdmin = "locations:admin"
+)
+
+// TripClaim identifies the trip a rider is on and the driver serving it.
+type TripClaim struct {
+	ID       string `json:"id"`
+	DriverID string `json:"driver_id"`
+}
+
+// Claims are the claims of a verified token.
+type Claims struct {
+	jwt.RegisteredClaims
+	Roles []string `json:"roles,omitempty"`
+	// Scope is a space-separated list of OAuth scopes.
+	Scope string     `json:"scope,omitempty"`
+	Trip  *TripClaim `json:"trip,omitempty"`
+}
+
+// HasRole reports whether the token grants role.
+func (c *Claims) HasRole(role string) bool {
+	for _, r := range c.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// HasScope reports whether the token grants scope.
+func (c *Claims) HasScope(scope string) bool {
+	for _, s := range strings.Fields(c.Scope) {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// IsDriver reports whether the token belongs to the driver driverID.
+func (c *Claims) IsDriver(driverID string) bool {
+	return c.HasRole(RoleDriver) && c.Subject != "" && c.Subject == driverID
+}
+
+// CanReadDriver reports whether the holder may see driverID's position:
+// ops staff may see every driver, and a rider the driver serving their trip.
+func (c *Claims) CanReadDriver(driverID string) bool {
+	if c.HasRole(RoleOps) {
+		return true
+	}
+	return c.HasRole(RoleRider) && c.Trip != nil && c.Trip.DriverID != "" && c.Trip.DriverID == driverID
+}
+
+type contextKey struct{}
+
+// NewContext returns a copy of ctx carrying claims.
+func NewContext(ctx context.Context, claims *Claims) context.Context {
+	return context.WithValue(ctx, contextKey{}, claims)
+}
+
+// FromContext returns the claims stored in ctx by NewContext.
+func FromContext(ctx context.Context) (*Claims, bool) {
+	claims, ok := ctx.Value(contextKey{}).(*Claims)
+	return claims, ok
+}
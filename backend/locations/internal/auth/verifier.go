@@ -0,0 +1,237 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// leeway tolerates clock skew between the users service and this one.
+const leeway = 30 * time.Second
+
+// ErrInvalidToken is returned for a token that is malformed, expired, or not signed by a configured key.
+var ErrInvalidToken = errors.New("invalid token")
+
+// Config lists the keys tokens may be signed with and the claims they must carry.
+type Config struct {
+	// HMACSecret verifies HS256 tokens.
+	HMACSecret []byte
+	// RSAPublicKeyFile is a PEM file with a public key verifying RS256 tokens.
+	RSAPublicKeyFile string
+	// JWKSFile is a local JSON Web Key Set with RSA and symmetric keys, matched to tokens by key ID.
+	JWKSFile string
+	// Issuer and Audience, if set, must match the token's iss and aud claims.
+	Issuer   string
+	Audience string
+}
+
+// Enabled reports whether any key is configured.
+func (c Config) Enabled() bool {
+	return len(c.HMACSecret) > 0 || c.RSAPublicKeyFile != "" || c.JWKSFile != ""
+}
+
+// Verifier verifies HS256 and RS256 tokens.
+type Verifier struct {
+	hmacKeys []any
+	rsaKeys  []any
+	// keyIDs maps the key ID of each JWKS key to the key.
+	keyIDs map[string]any
+	// unnamedKeys holds the keys configured outside a JWKS, which have no key ID, by algorithm.
+	unnamedKeys map[string][]any
+	parser      *jwt.Parser
+}
+
+// NewVerifier loads the configured keys.
+func NewVerifier(config Config) (*Verifier, error) {
+	v := &Verifier{keyIDs: map[string]any{}, unnamedKeys: map[string][]any{}}
+	if len(config.HMACSecret) > 0 {
+		v.hmacKeys = append(v.hmacKeys, config.HMACSecret)
+		v.unnamedKeys[jwt.SigningMethodHS256.Alg()] = []any{config.HMACSecret}
+	}
+	if config.RSAPublicKeyFile != "" {
+		data, err := os.ReadFile(config.RSAPublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read RSA public key: %w", err)
+		}
+		key, err := jwt.ParseRSAPublicKeyFromPEM(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse RSA public key: %w", err)
+		}
+		v.rsaKeys = append(v.rsaKeys, key)
+		v.unnamedKeys[jwt.SigningMethodRS256.Alg()] = []any{key}
+	}
+	if config.JWKSFile != "" {
+		keys, err := LoadJWKS(config.JWKSFile)
+		if err != nil {
+			return nil, err
+		}
+		for keyID, key := range keys {
+			v.keyIDs[keyID] = key
+			switch key.(type) {
+			case []byte:
+				v.hmacKeys = append(v.hmacKeys, key)
+			case *rsa.PublicKey:
+				v.rsaKeys = append(v.rsaKeys, key)
+			}
+		}
+	}
+	if len(v.hmacKeys) == 0 && len(v.rsaKeys) == 0 {
+		return nil, errors.New("no token verification keys configured")
+	}
+
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(leeway),
+	}
+	if config.Issuer != "" {
+		options = append(options, jwt.WithIssuer(config.Issuer))
+	}
+	if config.Audience != "" {
+		options = append(options, jwt.WithAudience(config.Audience))
+	}
+	v.parser = jwt.NewParser(options...)
+	return v, nil
+}
+
+// Verify checks token's signature and claims and returns the claims.
+func (v *Verifier) Verify(token string) (*Claims, error) {
+	claims := &Claims{}
+	if _, err := v.parser.ParseWithClaims(token, claims, v.keyFor); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return claims, nil
+}
+
+// keyFor picks the keys that may have signed token. Keys are only ever used with the algorithm of their type,
+// so an RSA public key can't be passed off as an HMAC secret. A token naming a key ID that no JWKS key has, as
+// issuers set one whatever the verifier is configured with, is checked against the keys configured without one.
+func (v *Verifier) keyFor(token *jwt.Token) (any, error) {
+	var candidates []any
+	switch token.Method.Alg() {
+	case jwt.SigningMethodHS256.Alg():
+		candidates = v.hmacKeys
+	case jwt.SigningMethodRS256.Alg():
+		candidates = v.rsaKeys
+	}
+
+	if keyID, ok := token.Header["kid"].(string); ok && keyID != "" {
+		key, ok := v.keyIDs[keyID]
+		if !ok {
+			candidates = v.unnamedKeys[token.Method.Alg()]
+			if len(candidates) == 0 {
+				return nil, fmt.Errorf("unknown key ID %q", keyID)
+			}
+			return verificationKeys(candidates), nil
+		}
+		for _, candidate := range candidates {
+			if sameKey(candidate, key) {
+				return key, nil
+			}
+		}
+		return nil, fmt.Errorf("key %q is not valid for %s", keyID, token.Method.Alg())
+	}
+
+	return verificationKeys(candidates), nil
+}
+
+// verificationKeys lets the parser try each of keys.
+func verificationKeys(keys []any) jwt.VerificationKeySet {
+	set := jwt.VerificationKeySet{Keys: make([]jwt.VerificationKey, len(keys))}
+	for i, key := range keys {
+		set.Keys[i] = key
+	}
+	return set
+}
+
+// sameKey reports whether a and b are the same key.
+func sameKey(a, b any) bool {
+	switch a := a.(type) {
+	case []byte:
+		b, ok := b.([]byte)
+		return ok && string(a) == string(b)
+	case *rsa.PublicKey:
+		b, ok := b.(*rsa.PublicKey)
+		return ok && a.Equal(b)
+	}
+	return false
+}
+
+// jwk is the subset of a JSON Web Key used here.
+type jwk struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+	// N and E are the RSA modulus and exponent.
+	N string `json:"n"`
+	E string `json:"e"`
+	// K is the symmetric key.
+	K string `json:"k"`
+}
+
+// LoadJWKS reads a JSON Web Key Set and returns its RSA public keys and symmetric keys by key ID.
+// Keys of other types, or not meant for signatures, are skipped.
+func LoadJWKS(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS file: %w", err)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for i, key := range set.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+		if key.KeyID == "" {
+			return nil, fmt.Errorf("JWKS key %d has no key ID", i)
+		}
+		if _, ok := keys[key.KeyID]; ok {
+			return nil, fmt.Errorf("JWKS key ID %q is not unique", key.KeyID)
+		}
+		switch key.KeyType {
+		case "RSA":
+			publicKey, err := parseRSAKey(key)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse JWKS key %q: %w", key.KeyID, err)
+			}
+			keys[key.KeyID] = publicKey
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(key.K)
+			if err != nil || len(secret) == 0 {
+				return nil, fmt.Errorf("failed to parse JWKS key %q: invalid k", key.KeyID)
+			}
+			keys[key.KeyID] = secret
+		}
+	}
+	return keys, nil
+}
+
+// parseRSAKey decodes the modulus and exponent of an RSA JWK.
+func parseRSAKey(key jwk) (*rsa.PublicKey, error) {
+	n, err := base64.RawURLEncoding.DecodeString(key.N)
+	if err != nil || len(n) == 0 {
+		return nil, errors.New("invalid modulus")
+	}
+	e, err := base64.RawURLEncoding.DecodeString(key.E)
+	if err != nil || len(e) == 0 || len(e) > 4 {
+		return nil, errors.New("invalid exponent")
+	}
+	exponent := new(big.Int).SetBytes(e).Int64()
+	if exponent < 3 {
+		return nil, errors.New("invalid exponent")
+	}
+	return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent)}, nil
+}
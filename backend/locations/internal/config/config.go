@@ -0,0 +1,363 @@
+// Package config loads the service's configuration. Every setting has a default, which is overridden in turn
+// by an optional config file, an environment variable and a command-line flag, so that a flag wins over the
+// environment and the environment over the file.
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/redis/go-redis/v9"
+
+	"locations/internal/archive"
+	"locations/internal/auth"
+	"locations/internal/consumer"
+	"locations/internal/db"
+	"locations/internal/health"
+	"locations/internal/logging"
+	"locations/internal/mqttbridge"
+	"locations/internal/ratelimit"
+	"locations/internal/tracing"
+)
+
+// DefaultFile is the config file read when none is named. Unlike a named file, it may be missing.
+const DefaultFile = ".env"
+
+// DeadLetterSuffix follows the topic in the name of the default dead-letter topic.
+const DeadLetterSuffix = ".dlq"
+
+// FileVariable names the environment variable that points at the config file; the -config flag overrides it.
+const FileVariable = "CONFIG_FILE"
+
+// Config is the configuration of every part of the service.
+type Config struct {
+	// HTTPAddr and GRPCAddr are the addresses the HTTP and gRPC servers listen on.
+	HTTPAddr string
+	GRPCAddr string
+	// GRPCTLSCertFile and GRPCTLSKeyFile are the PEM certificate and key the gRPC server terminates TLS with;
+	// without them it serves plaintext, for deployments behind a proxy that terminates TLS.
+	GRPCTLSCertFile string
+	GRPCTLSKeyFile  string
+
+	// MongoDBURI is the MongoDB connection string.
+	MongoDBURI string
+	// MigrateOnStart applies pending migrations at startup. It is opt-in so that replicas don't all race to
+	// apply them on every deploy.
+	MigrateOnStart bool
+	// FieldEncryptionKeyfile lists the keys encrypting stored coordinates; empty stores them in plaintext.
+	FieldEncryptionKeyfile string
+	// ReadRouting sends heavy read-only queries to secondaries.
+	ReadRouting db.ReadRoutingConfig
+
+	// KafkaBrokers are the addresses of the Kafka brokers, and KafkaTopic the topic location updates go through.
+	KafkaBrokers []string
+	KafkaTopic   string
+	// KafkaDeadLetterTopic keeps the messages the consumer failed to process. Empty means KafkaTopic with
+	// DeadLetterSuffix.
+	KafkaDeadLetterTopic string
+	// ConsumerMaxLag is how many messages the consumer may fall behind before the instance reports not ready.
+	ConsumerMaxLag int64
+
+	// AdminToken is the bearer token of the admin endpoints; empty disables them.
+	AdminToken string
+	// DriverTokenSecret signs driver app tokens when no JWT keys are configured; empty disables streaming.
+	DriverTokenSecret string
+	// StreamAllowedOrigins are the browser origins that may open a driver location stream besides the service's own.
+	// Driver apps are native clients that send no Origin and are always allowed.
+	StreamAllowedOrigins []string
+	// TripTokenSecret signs rider trip tokens; empty disables trip tracking.
+	TripTokenSecret string
+	// Auth verifies the users service's tokens.
+	Auth auth.Config
+	// AllowUnauthenticated lets the API start without any JWT key, open to every caller. It is only meant for
+	// development; without it the API refuses to start unauthenticated.
+	AllowUnauthenticated bool
+
+	// RateLimits limits ingestion per driver and per client.
+	RateLimits RateLimits
+	// MQTT bridges hardware trackers into Kafka when its BrokerURL is set.
+	MQTT mqttbridge.Config
+	// Tracing selects where spans go.
+	Tracing tracing.Config
+	// Log sets the logger's level and format.
+	Log logging.Config
+	// Archive is where the archive and restore commands keep history, and where data-subject requests find it.
+	Archive Archive
+}
+
+// RateLimits configures ratelimit.Policy. A limit with a zero rate is off.
+type RateLimits struct {
+	Driver ratelimit.Limit
+	Client ratelimit.Limit
+	// RedisURL keeps the buckets in Redis, shared between replicas; empty keeps them in each replica.
+	RedisURL string
+	// TrustForwardedFor takes client addresses from X-Forwarded-For, for use behind a load balancer.
+	TrustForwardedFor bool
+	// ForwardedForHops is how many proxies append to X-Forwarded-For; see ratelimit.Policy.
+	ForwardedForHops int
+}
+
+// Archive is where archived history is kept: the S3-compatible bucket in S3 when its Endpoint is set,
+// otherwise the local directory Dir.
+type Archive struct {
+	Dir string
+	S3  archive.S3Config
+}
+
+// Default returns the configuration used for settings given nowhere else, which suits a local
+// MongoDB and Kafka.
+func Default() *Config {
+	return &Config{
+		HTTPAddr:       ":8080",
+		GRPCAddr:       ":9090",
+		MongoDBURI:     "mongodb://localhost:27017",
+		ReadRouting:    db.DefaultReadRoutingConfig(),
+		KafkaBrokers:   []string{"localhost:9092"},
+		KafkaTopic:     "locations",
+		ConsumerMaxLag: consumer.DefaultMaxLag,
+		RateLimits: RateLimits{
+			Driver:           ratelimit.DefaultDriverLimit,
+			Client:           ratelimit.DefaultClientLimit,
+			ForwardedForHops: 1,
+		},
+		MQTT: mqttbridge.Config{
+			ClientID:     "locations-bridge",
+			TopicPattern: mqttbridge.DefaultTopicPattern,
+			QoS:          1,
+		},
+		Tracing: tracing.Config{
+			ServiceName:    "locations",
+			ServiceVersion: health.Version,
+			SampleRatio:    1,
+		},
+		Log: logging.Config{
+			Level:  slog.LevelInfo,
+			Format: logging.FormatJSON,
+		},
+		Archive: Archive{
+			Dir: "archive",
+			S3:  archive.S3Config{UseSSL: true},
+		},
+	}
+}
+
+// Load builds the configuration from the defaults, the config file, the environment read through lookupEnv
+// and the flags in args, then validates it. It returns the arguments left after the flags.
+// The config file is named by -config or CONFIG_FILE, or else is DefaultFile if that exists. It holds
+// NAME=value lines using the environment variables' names; other names in it are exported to the environment,
+// if not already set, for libraries that read their own, such as OTEL_EXPORTER_OTLP_ENDPOINT.
+// Empty values in the file and the environment count as unset, so that blank variables keep the defaults.
+// Secrets such as ADMIN_API_TOKEN have no flag and are only read from the file and the environment.
+// Loading stops with flag.ErrHelp when args ask for help, after the usage has been printed.
+func Load(args []string, lookupEnv func(string) (string, bool)) (*Config, []string, error) {
+	config := Default()
+	settings := config.settings()
+
+	// Flags are parsed first to find the config file, and applied last.
+	type assignment struct {
+		setting *setting
+		value   string
+	}
+	var (
+		assignments []assignment
+		file        string
+	)
+	flags := flag.NewFlagSet("locations", flag.ContinueOnError)
+	flags.StringVar(&file, "config", "", "config file of NAME=value lines (env "+FileVariable+", default "+DefaultFile+" if present)")
+	for _, s := range settings {
+		if s.secret {
+			continue
+		}
+		s := s
+		usage := s.usage + " (env " + s.name
+		if value := s.display(); value != "" {
+			usage += ", default " + value
+		}
+		flags.Func(s.flagName(), usage+")", func(value string) error {
+			assignments = append(assignments, assignment{setting: s, value: value})
+			return nil
+		})
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if file == "" {
+		file, _ = lookupEnv(FileVariable)
+	}
+	file, values, err := readFile(file)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var errs []error
+	for _, s := range settings {
+		for _, name := range s.names() {
+			value, ok := values[name]
+			if !ok || value == "" {
+				continue
+			}
+			if err := s.value.Set(value); err != nil {
+				errs = append(errs, fmt.Errorf("invalid %s in %s: %w", name, file, err))
+			}
+			break
+		}
+		for _, name := range s.names() {
+			delete(values, name)
+		}
+	}
+	// What's left is for libraries that read the environment themselves.
+	for name, value := range values {
+		if _, set := lookupEnv(name); !set {
+			os.Setenv(name, value)
+		}
+	}
+
+	for _, s := range settings {
+		for _, name := range s.names() {
+			if value, ok := lookupEnv(name); ok && value != "" {
+				if err := s.value.Set(value); err != nil {
+					errs = append(errs, fmt.Errorf("invalid %s in the environment: %w", name, err))
+				}
+				break
+			}
+		}
+	}
+	for _, a := range assignments {
+		if err := a.setting.value.Set(a.value); err != nil {
+			errs = append(errs, fmt.Errorf("invalid -%s flag: %w", a.setting.flagName(), err))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, nil, errors.Join(errs...)
+	}
+
+	if config.KafkaDeadLetterTopic == "" {
+		config.KafkaDeadLetterTopic = config.KafkaTopic + DeadLetterSuffix
+	}
+	if err := config.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return config, flags.Args(), nil
+}
+
+// readFile reads the values in file, returning the name of the file read. An empty name reads DefaultFile
+// if it exists, and otherwise nothing.
+func readFile(file string) (string, map[string]string, error) {
+	if file == "" {
+		if _, err := os.Stat(DefaultFile); err != nil {
+			return "", nil, nil
+		}
+		file = DefaultFile
+	}
+	values, err := godotenv.Read(file)
+	if err != nil {
+		return file, nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+	return file, values, nil
+}
+
+// Validate checks every setting and reports all the problems found.
+func (c *Config) Validate() error {
+	var errs []error
+	check := func(name string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid %s: %w", name, err))
+		}
+	}
+
+	check("HTTP_ADDR", validateAddr(c.HTTPAddr))
+	check("GRPC_ADDR", validateAddr(c.GRPCAddr))
+	if c.HTTPAddr == c.GRPCAddr {
+		errs = append(errs, fmt.Errorf("HTTP_ADDR and GRPC_ADDR are both %s", c.HTTPAddr))
+	}
+	if (c.GRPCTLSCertFile == "") != (c.GRPCTLSKeyFile == "") {
+		check("GRPC_TLS_CERT_FILE and GRPC_TLS_KEY_FILE", errors.New("must be set together"))
+	}
+
+	if !strings.HasPrefix(c.MongoDBURI, "mongodb://") && !strings.HasPrefix(c.MongoDBURI, "mongodb+srv://") {
+		check("MONGODB_URI", errors.New("must start with mongodb:// or mongodb+srv://"))
+	}
+	check("MONGODB_SECONDARY_READS or MONGODB_MAX_STALENESS", c.ReadRouting.Validate())
+
+	if len(c.KafkaBrokers) == 0 {
+		check("KAFKA_BROKERS", errors.New("at least one broker is required"))
+	}
+	for _, broker := range c.KafkaBrokers {
+		check("KAFKA_BROKERS", validateAddr(broker))
+	}
+	if c.KafkaTopic == "" {
+		check("KAFKA_TOPIC", errors.New("a topic is required"))
+	}
+	if c.KafkaDeadLetterTopic == c.KafkaTopic {
+		check("KAFKA_DLQ_TOPIC", errors.New("must differ from KAFKA_TOPIC"))
+	}
+	if c.ConsumerMaxLag < 1 {
+		check("CONSUMER_MAX_LAG", errors.New("must be at least 1"))
+	}
+
+	if c.RateLimits.Driver.Rate != 0 {
+		check("RATE_LIMIT_DRIVER", c.RateLimits.Driver.Validate())
+	}
+	if c.RateLimits.Client.Rate != 0 {
+		check("RATE_LIMIT_CLIENT", c.RateLimits.Client.Validate())
+	}
+	if c.RateLimits.ForwardedForHops < 1 {
+		check("RATE_LIMIT_FORWARDED_FOR_HOPS", errors.New("must be at least 1"))
+	}
+	if c.RateLimits.RedisURL != "" {
+		_, err := redis.ParseURL(c.RateLimits.RedisURL)
+		check("RATE_LIMIT_REDIS_URL", err)
+	}
+
+	if c.MQTT.BrokerURL != "" {
+		if _, err := url.Parse(c.MQTT.BrokerURL); err != nil {
+			check("MQTT_BROKER_URL", err)
+		}
+		if c.MQTT.ClientID == "" {
+			check("MQTT_CLIENT_ID", errors.New("a client ID is required with a broker"))
+		}
+		if c.MQTT.TopicPattern == "" {
+			check("MQTT_TOPIC_PATTERN", errors.New("a topic pattern is required with a broker"))
+		}
+	}
+
+	check("TRACING_*", c.Tracing.Validate())
+	check("LOG_FORMAT", c.Log.Validate())
+
+	if c.Archive.S3.Endpoint != "" && c.Archive.S3.Bucket == "" {
+		check("ARCHIVE_S3_BUCKET", errors.New("a bucket is required with an endpoint"))
+	}
+
+	return errors.Join(errs...)
+}
+
+// LogValue lists the effective value of every setting, by environment variable name, with secrets masked,
+// so that logging a Config never reveals them.
+func (c *Config) LogValue() slog.Value {
+	settings := c.settings()
+	attrs := make([]slog.Attr, len(settings))
+	for i, s := range settings {
+		attrs[i] = slog.String(s.name, s.display())
+	}
+	return slog.GroupValue(attrs...)
+}
+
+// validateAddr checks that addr is a host:port, where the host may be empty.
+func validateAddr(addr string) error {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	if port == "" {
+		return fmt.Errorf("%q has no port", addr)
+	}
+	return nil
+}
@@ -0,0 +1,252 @@
+package config
+
+import (
+	"flag"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"locations/internal/logging"
+)
+
+// masked replaces the value of a secret that is set.
+const masked = "********"
+
+// setting is one configurable value, named by its environment variable. It is read from the config file
+// under the same name and, unless it is a secret, from the flag with the name in lower case and dashes,
+// such as -mongodb-uri.
+type setting struct {
+	name string
+	// aliases are older names still accepted, after name, from the file and the environment.
+	aliases []string
+	usage   string
+	value   flag.Value
+	// secret is masked when shown and has no flag, since arguments are visible to every user of the host.
+	secret bool
+	// mask hides part of the value when it is shown, such as a password in a URL; nil shows it as it is.
+	mask func(string) string
+}
+
+// settings lists every setting, bound to the fields of c.
+func (c *Config) settings() []*setting {
+	return []*setting{
+		{name: "HTTP_ADDR", usage: "address the HTTP server listens on", value: (*stringValue)(&c.HTTPAddr)},
+		{name: "GRPC_ADDR", usage: "address the gRPC server listens on", value: (*stringValue)(&c.GRPCAddr)},
+		{name: "GRPC_TLS_CERT_FILE", usage: "PEM certificate the gRPC server serves TLS with; empty serves plaintext", value: (*stringValue)(&c.GRPCTLSCertFile)},
+		{name: "GRPC_TLS_KEY_FILE", usage: "PEM private key of GRPC_TLS_CERT_FILE", value: (*stringValue)(&c.GRPCTLSKeyFile)},
+
+		{name: "MONGODB_URI", aliases: []string{"MONGO_URL"}, usage: "MongoDB connection string", value: (*stringValue)(&c.MongoDBURI), mask: maskURL},
+		{name: "MONGODB_MIGRATE_ON_START", usage: "apply pending migrations at startup", value: (*boolValue)(&c.MigrateOnStart)},
+		{name: "MONGODB_SECONDARY_READS", usage: `comma-separated operations read from secondaries, or "none"`, value: &listValue{list: &c.ReadRouting.SecondaryOperations, none: "none"}},
+		{name: "MONGODB_MAX_STALENESS", usage: "how far behind the primary a secondary may be to serve reads; 0 for no bound", value: (*durationValue)(&c.ReadRouting.MaxStaleness)},
+		{name: "MONGODB_LOG_QUERIES", usage: "log where each routed read goes", value: (*boolValue)(&c.ReadRouting.LogQueries)},
+		{name: "FIELD_ENCRYPTION_KEYFILE", usage: "keyfile of the keys encrypting stored coordinates", value: (*stringValue)(&c.FieldEncryptionKeyfile)},
+
+		{name: "KAFKA_BROKERS", aliases: []string{"KAFKA_BROKER"}, usage: "comma-separated Kafka broker addresses", value: &listValue{list: &c.KafkaBrokers}},
+		{name: "KAFKA_TOPIC", usage: "Kafka topic of location updates", value: (*stringValue)(&c.KafkaTopic)},
+		{name: "KAFKA_DLQ_TOPIC", usage: "Kafka topic of messages that failed processing; empty adds .dlq to KAFKA_TOPIC", value: (*stringValue)(&c.KafkaDeadLetterTopic)},
+		{name: "CONSUMER_MAX_LAG", usage: "messages the consumer may fall behind before the instance is not ready", value: (*int64Value)(&c.ConsumerMaxLag)},
+
+		{name: "ADMIN_API_TOKEN", usage: "bearer token of the admin endpoints; empty disables them", value: (*stringValue)(&c.AdminToken), secret: true},
+		{name: "DRIVER_TOKEN_SECRET", usage: "secret signing driver app tokens without JWT keys; empty disables streaming", value: (*stringValue)(&c.DriverTokenSecret), secret: true},
+		{name: "STREAM_ALLOWED_ORIGINS", usage: "comma-separated browser origins, such as https://example.com, that may open driver location streams", value: &listValue{list: &c.StreamAllowedOrigins}},
+		{name: "TRIP_TOKEN_SECRET", usage: "secret signing rider trip tokens; empty disables trip tracking", value: (*stringValue)(&c.TripTokenSecret), secret: true},
+		{name: "JWT_HS256_SECRET", usage: "secret verifying HS256 tokens from the users service", value: (*bytesValue)(&c.Auth.HMACSecret), secret: true},
+		{name: "JWT_RS256_PUBLIC_KEY_FILE", usage: "PEM public key verifying RS256 tokens", value: (*stringValue)(&c.Auth.RSAPublicKeyFile)},
+		{name: "JWT_JWKS_FILE", usage: "JSON Web Key Set verifying tokens by key ID", value: (*stringValue)(&c.Auth.JWKSFile)},
+		{name: "JWT_ISSUER", usage: "required iss claim", value: (*stringValue)(&c.Auth.Issuer)},
+		{name: "JWT_AUDIENCE", usage: "required aud claim", value: (*stringValue)(&c.Auth.Audience)},
+		{name: "AUTH_ALLOW_UNAUTHENTICATED", usage: "serve the API open to unauthenticated callers when no JWT key is configured; for development only", value: (*boolValue)(&c.AllowUnauthenticated)},
+
+		{name: "RATE_LIMIT_DRIVER_RATE", usage: "updates per second allowed per driver; 0 turns the limit off", value: (*floatValue)(&c.RateLimits.Driver.Rate)},
+		{name: "RATE_LIMIT_DRIVER_BURST", usage: "updates a driver may send at once", value: (*intValue)(&c.RateLimits.Driver.Burst)},
+		{name: "RATE_LIMIT_CLIENT_RATE", usage: "requests per second allowed per client address; 0 turns the limit off", value: (*floatValue)(&c.RateLimits.Client.Rate)},
+		{name: "RATE_LIMIT_CLIENT_BURST", usage: "requests a client may send at once", value: (*intValue)(&c.RateLimits.Client.Burst)},
+		{name: "RATE_LIMIT_REDIS_URL", usage: "Redis keeping rate limits shared between replicas", value: (*stringValue)(&c.RateLimits.RedisURL), mask: maskURL},
+		{name: "RATE_LIMIT_TRUST_FORWARDED_FOR", usage: "take client addresses from X-Forwarded-For", value: (*boolValue)(&c.RateLimits.TrustForwardedFor)},
+		{name: "RATE_LIMIT_FORWARDED_FOR_HOPS", usage: "proxies appending to X-Forwarded-For; the client is that many entries from the right", value: (*intValue)(&c.RateLimits.ForwardedForHops)},
+
+		{name: "MQTT_BROKER_URL", usage: "MQTT broker trackers publish to; empty disables the bridge", value: (*stringValue)(&c.MQTT.BrokerURL), mask: maskURL},
+		{name: "MQTT_CLIENT_ID", usage: "client ID of the bridge's persistent session", value: (*stringValue)(&c.MQTT.ClientID)},
+		{name: "MQTT_USERNAME", usage: "MQTT username", value: (*stringValue)(&c.MQTT.Username)},
+		{name: "MQTT_PASSWORD", usage: "MQTT password", value: (*stringValue)(&c.MQTT.Password), secret: true},
+		{name: "MQTT_TOPIC_PATTERN", usage: "MQTT subscription, whose '+' level is the driver ID", value: (*stringValue)(&c.MQTT.TopicPattern)},
+
+		{name: "TRACING_EXPORTER", usage: "where spans go: none, otlp, stdout or file", value: (*stringValue)(&c.Tracing.Exporter)},
+		{name: "TRACING_FILE", usage: "file the file exporter appends spans to", value: (*stringValue)(&c.Tracing.File)},
+		{name: "TRACING_SAMPLE_RATIO", usage: "fraction of new traces kept", value: (*floatValue)(&c.Tracing.SampleRatio)},
+
+		{name: "LOG_LEVEL", usage: "least severe level logged: debug, info, warn or error", value: (*levelValue)(&c.Log.Level)},
+		{name: "LOG_FORMAT", usage: "log format: json or text", value: (*stringValue)(&c.Log.Format)},
+
+		{name: "ARCHIVE_DIR", usage: "directory archives are kept in without S3", value: (*stringValue)(&c.Archive.Dir)},
+		{name: "ARCHIVE_S3_ENDPOINT", usage: "S3-compatible endpoint archives are kept at", value: (*stringValue)(&c.Archive.S3.Endpoint)},
+		{name: "ARCHIVE_S3_BUCKET", usage: "archive bucket", value: (*stringValue)(&c.Archive.S3.Bucket)},
+		{name: "ARCHIVE_S3_PREFIX", usage: "prefix of archive objects", value: (*stringValue)(&c.Archive.S3.Prefix)},
+		{name: "ARCHIVE_S3_ACCESS_KEY", usage: "S3 access key", value: (*stringValue)(&c.Archive.S3.AccessKey)},
+		{name: "ARCHIVE_S3_SECRET_KEY", usage: "S3 secret key", value: (*stringValue)(&c.Archive.S3.SecretKey), secret: true},
+		{name: "ARCHIVE_S3_USE_SSL", usage: "reach the S3 endpoint over TLS", value: (*boolValue)(&c.Archive.S3.UseSSL)},
+	}
+}
+
+// names returns the setting's name followed by its aliases.
+func (s *setting) names() []string {
+	return append([]string{s.name}, s.aliases...)
+}
+
+func (s *setting) flagName() string {
+	return strings.ReplaceAll(strings.ToLower(s.name), "_", "-")
+}
+
+// display returns the value as it may be shown, with a secret masked.
+func (s *setting) display() string {
+	value := s.value.String()
+	if s.secret {
+		return maskSecret(value)
+	}
+	if s.mask == nil {
+		return value
+	}
+	return s.mask(value)
+}
+
+func maskSecret(value string) string {
+	if value == "" {
+		return ""
+	}
+	return masked
+}
+
+// maskURL masks the password in a URL, leaving where it points visible.
+func maskURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return maskSecret(value)
+	}
+	return u.Redacted()
+}
+
+type stringValue string
+
+func (v *stringValue) Set(value string) error {
+	*v = stringValue(value)
+	return nil
+}
+
+func (v *stringValue) String() string { return string(*v) }
+
+type boolValue bool
+
+func (v *boolValue) Set(value string) error {
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+
+type intValue int
+
+func (v *intValue) Set(value string) error {
+	i, err := strconv.Atoi(value)
+	if err != nil {
+		return err
+	}
+	*v = intValue(i)
+	return nil
+}
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+type int64Value int64
+
+func (v *int64Value) Set(value string) error {
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return err
+	}
+	*v = int64Value(i)
+	return nil
+}
+
+func (v *int64Value) String() string { return strconv.FormatInt(int64(*v), 10) }
+
+type floatValue float64
+
+func (v *floatValue) Set(value string) error {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return err
+	}
+	*v = floatValue(f)
+	return nil
+}
+
+func (v *floatValue) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+
+type durationValue time.Duration
+
+func (v *durationValue) Set(value string) error {
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	*v = durationValue(d)
+	return nil
+}
+
+func (v *durationValue) String() string { return time.Duration(*v).String() }
+
+type bytesValue []byte
+
+func (v *bytesValue) Set(value string) error {
+	*v = bytesValue(value)
+	return nil
+}
+
+func (v *bytesValue) String() string { return string(*v) }
+
+type levelValue slog.Level
+
+func (v *levelValue) Set(value string) error {
+	level, err := logging.ParseLevel(value)
+	if err != nil {
+		return err
+	}
+	*v = levelValue(level)
+	return nil
+}
+
+func (v *levelValue) String() string { return slog.Level(*v).String() }
+
+// listValue is a comma-separated list. Blank entries are dropped; when none is set, that word means
+// an empty list.
+type listValue struct {
+	list *[]string
+	none string
+}
+
+func (v *listValue) Set(value string) error {
+	*v.list = nil
+	if v.none != "" && value == v.none {
+		return nil
+	}
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			*v.list = append(*v.list, item)
+		}
+	}
+	return nil
+}
+
+func (v *listValue) String() string {
+	if len(*v.list) == 0 {
+		return v.none
+	}
+	return strings.Join(*v.list, ",")
+}
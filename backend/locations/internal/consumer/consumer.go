@@ -1,28 +1,50 @@
 package consumer
 
 import (
-	"context" // Provides functionality to define a deadline or cancellation signal for operations.
-	"fmt"     // Implements formatted I/O functions.
-	"locations/internal/db" // Internal package for database operations.
+	"context"  // Provides functionality to define a deadline or cancellation signal for operations.
+	"fmt"      // Implements formatted I/O functions.
+	"log/slog" // Structured logging.
 
 	"github.com/segmentio/kafka-go" // Kafka library for Go.
+
+	"locations/internal/logging" // Internal package for the service's structured logger.
+	"locations/internal/tracing" // Internal package for OpenTelemetry tracing.
 )
 
+// MessageReader reads messages from a Kafka topic. *kafka.Reader implements it; tests substitute fakes.
+type MessageReader interface {
+	ReadMessage(ctx context.Context) (kafka.Message, error)
+	FetchMessage(ctx context.Context) (kafka.Message, error)
+	CommitMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
+// MessageWriter writes messages to a Kafka topic. *kafka.Writer implements it; tests substitute fakes.
+type MessageWriter interface {
+	WriteMessages(ctx context.Context, msgs ...kafka.Message) error
+	Close() error
+}
+
 // KafkaConsumer struct holds the configuration for a Kafka reader.
 type KafkaConsumer struct {
 	readerConfig kafka.ReaderConfig
+	reader       MessageReader    // Reads the topic instead of a reader made from readerConfig; may be nil.
+	monitor      *Monitor         // Tracks the consume loop for health checks; may be nil.
+	logger       *slog.Logger     // Receives the consume loop's logs.
+	deadLetters  *DeadLetterQueue // Receives messages that fail processing; may be nil.
 }
 
 // NewKafkaConsumer creates a new KafkaConsumer with the specified brokers and topic.
 func NewKafkaConsumer(kafkaBrokers []string, topic string) *KafkaConsumer {
 	return &KafkaConsumer{
 		readerConfig: kafka.ReaderConfig{
-			Brokers:   kafkaBrokers, // List of Kafka broker addresses.
-			Topic:     topic,        // Kafka topic to subscribe to.
-			MinBytes:  10e3,         // Minimum number of bytes to fetch in a single request.
-			MaxBytes:  10e6,         // Maximum number of bytes to fetch in a single request.
-			GroupID:   "location-consumer-group", // Consumer group ID.
+			Brokers:  kafkaBrokers,              // List of Kafka broker addresses.
+			Topic:    topic,                     // Kafka topic to subscribe to.
+			MinBytes: 10e3,                      // Minimum number of bytes to fetch in a single request.
+			MaxBytes: 10e6,                      // Maximum number of bytes to fetch in a single request.
+			GroupID:  "location-consumer-group", // Consumer group ID.
 		},
+		logger: slog.Default(), // Log to the default logger unless RunKafkaConsumer is given one.
 	}
 }
 
@@ -30,26 +52,54 @@ func NewKafkaConsumer(kafkaBrokers []string, topic string) *KafkaConsumer {
 // Once a message is received, it's passed to a message processor which contains the logic for handling the message.
 // This function is designed to run indefinitely until it receives a signal to stop via the context's cancellation.
 func (c *KafkaConsumer) ConsumeLocationUpdates(ctx context.Context, messageProcessor MessageProcessor) {
-	reader := kafka.NewReader(c.readerConfig) // Create a new Kafka reader with the specified configuration.
+	reader := c.reader
+	if reader == nil {
+		reader = kafka.NewReader(c.readerConfig) // Create a new Kafka reader with the specified configuration.
+	}
 	defer reader.Close() // Ensure the reader is closed when the function returns.
 
-	fmt.Println("Location consumer started and listening for updates...")
+	c.logger.InfoContext(ctx, "Location consumer started and listening for updates", "topic", c.readerConfig.Topic)
+	c.monitor.started()
 
 	for {
 		select {
 		case <-ctx.Done(): // Check if the context has been canceled.
-			fmt.Println("Location consumer shutting down...")
+			c.logger.Info("Location consumer shutting down")
+			c.monitor.stopped(ctx.Err())
 			return // Exit the function if the context is canceled.
 		default:
-			msg, err := reader.ReadMessage(ctx) // Read a message from the Kafka topic.
+			// The offset is committed only once the message is processed or dead-lettered, so a message in hand when
+			// the process dies is read again rather than lost.
+			msg, err := reader.FetchMessage(ctx) // Fetch a message from the Kafka topic.
+			if err != nil && ctx.Err() != nil {
+				continue // The read was interrupted by shutdown, which the next iteration handles.
+			}
 			if err != nil {
-				fmt.Println("Error reading Kafka message:", err)
-				return // Exit the function if there's an error reading messages.
+				c.logger.Error("Failed to read Kafka message", "error", err)
+				c.monitor.stopped(err) // Readiness fails from now on, so the instance is taken out of service.
+				return                 // Exit the function if there's an error reading messages.
 			}
+			c.monitor.observe(msg)
 
 			// Process the Kafka message using the provided message processor.
-			if err := messageProcessor.ProcessMessage(ctx, msg); err != nil {
-				fmt.Println("Error processing Kafka message:", err)
+			// The message carries the trace context of the request that published it, so processing continues that trace.
+			// A message being processed when shutdown begins is finished and committed rather than abandoned.
+			processCtx := tracing.ExtractMessage(context.WithoutCancel(ctx), msg)
+			if err := messageProcessor.ProcessMessage(processCtx, msg); err != nil {
+				c.logger.Error("Failed to process Kafka message", "partition", msg.Partition, "offset", msg.Offset, "error", err)
+				// Keep the message on the dead-letter topic so that it can be redriven once the fault is fixed.
+				if err := c.deadLetters.Add(processCtx, msg, err); err != nil {
+					// The message is neither processed nor kept, so it stays uncommitted for the group to deliver
+					// again once this instance is replaced.
+					c.logger.Error("Failed to dead-letter Kafka message", "partition", msg.Partition, "offset", msg.Offset, "error", err)
+					c.monitor.stopped(err)
+					return
+				}
+			}
+			if err := reader.CommitMessages(processCtx, msg); err != nil {
+				c.logger.Error("Failed to commit Kafka message", "partition", msg.Partition, "offset", msg.Offset, "error", err)
+				c.monitor.stopped(err)
+				return
 			}
 		}
 	}
@@ -58,18 +108,47 @@ func (c *KafkaConsumer) ConsumeLocationUpdates(ctx context.Context, messageProce
 // RunKafkaConsumer initializes the necessary components for consuming messages from a Kafka topic.
 // It creates a KafkaConsumer instance, sets up a message processor, and starts the message consumption process.
 // This function is typically called at the start of the application to begin listening for messages.
-func RunKafkaConsumer(ctx context.Context, kafkaBrokers []string, topic string, db db.Database) error {
-	kafkaConsumer := NewKafkaConsumer(kafkaBrokers, topic) // Create a new Kafka consumer.
-	messageProcessor := NewKafkaMessageProcessor(db) // Create a new message processor with the database instance.
+// Messages are handed to messageProcessor, such as the one NewKafkaMessageProcessor returns, possibly wrapped
+// for instrumentation. The loop is tracked by monitor, if it is not nil, and logs to logger, or slog.Default() if it is nil.
+// Messages that fail processing go to deadLetters; nil drops them once logged. Each message is committed after
+// it is processed or dead-lettered, and the loop stops, leaving it uncommitted, if it can be neither.
+// When ctx is canceled it waits for the message being processed, then returns nil.
+// It returns an error if the loop stops before ctx is canceled.
+func RunKafkaConsumer(ctx context.Context, kafkaBrokers []string, topic string, messageProcessor MessageProcessor, monitor *Monitor, deadLetters *DeadLetterQueue, logger *slog.Logger) error {
+	return runConsumer(ctx, NewKafkaConsumer(kafkaBrokers, topic), messageProcessor, monitor, deadLetters, logger)
+}
+
+// RunConsumer is RunKafkaConsumer reading topic from reader, such as a fake in tests. It closes reader when it returns.
+func RunConsumer(ctx context.Context, reader MessageReader, topic string, messageProcessor MessageProcessor, monitor *Monitor, deadLetters *DeadLetterQueue, logger *slog.Logger) error {
+	kafkaConsumer := &KafkaConsumer{readerConfig: kafka.ReaderConfig{Topic: topic}, reader: reader}
+	return runConsumer(ctx, kafkaConsumer, messageProcessor, monitor, deadLetters, logger)
+}
+
+// runConsumer runs kafkaConsumer's consume loop as RunKafkaConsumer describes.
+func runConsumer(ctx context.Context, kafkaConsumer *KafkaConsumer, messageProcessor MessageProcessor, monitor *Monitor, deadLetters *DeadLetterQueue, logger *slog.Logger) error {
+	topic := kafkaConsumer.readerConfig.Topic
+	kafkaConsumer.monitor = monitor
+	kafkaConsumer.logger = logging.Or(logger)
+	kafkaConsumer.deadLetters = deadLetters
 
 	consumerCtx, cancelConsumer := context.WithCancel(ctx) // Create a cancellable context for the consumer.
-	defer cancelConsumer() // Ensure the cancel function is called when the function exits.
+	defer cancelConsumer()                                 // Ensure the cancel function is called when the function exits.
 
-	go kafkaConsumer.ConsumeLocationUpdates(consumerCtx, messageProcessor) // Start consuming messages in a new goroutine.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		kafkaConsumer.ConsumeLocationUpdates(consumerCtx, messageProcessor) // Start consuming messages in a new goroutine.
+	}()
 
 	select {
 	case <-ctx.Done(): // Wait for the parent context to be canceled.
 		cancelConsumer() // Cancel the consumer context to stop consuming messages.
+		<-done           // Wait for the loop to finish the message it is processing.
+	case <-done: // The loop gave up on its own, such as after losing the brokers, or saw ctx canceled first.
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("consumer stopped reading from topic %s", topic)
 	}
 	return nil // Return nil to indicate no error occurred.
 }
@@ -0,0 +1,234 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"locations/internal/logging"
+)
+
+// Headers added to a message when it is dead-lettered, recording where it came from and why it failed.
+// Redrive strips them again.
+const (
+	HeaderDeadLetterError     = "dlq-error"
+	HeaderDeadLetterTopic     = "dlq-topic"
+	HeaderDeadLetterPartition = "dlq-partition"
+	HeaderDeadLetterOffset    = "dlq-offset"
+)
+
+// HeaderRedriveCount counts how many times Redrive has moved a message back to the topic. Unlike the
+// dead-letter headers it stays on the message when it fails again, so that a message that can never be
+// processed, such as invalid JSON, isn't redriven for ever.
+const HeaderRedriveCount = "redrive-count"
+
+// DefaultRedriveIdle is how long Redrive waits for another message before it decides the dead-letter topic is drained.
+const DefaultRedriveIdle = 10 * time.Second
+
+// DefaultMaxRedrives is how many times a message is redriven before Redrive leaves it on the dead-letter topic.
+const DefaultMaxRedrives = 3
+
+// redriveGroupID is the consumer group Redrive reads the dead-letter topic as, so that an interrupted redrive
+// resumes where it stopped.
+const redriveGroupID = "location-dlq-redrive"
+
+// DeadLetterQueue keeps the messages the consumer failed to process on a separate topic, so that they are not
+// lost and can be redriven once the fault is fixed. A nil DeadLetterQueue drops them.
+type DeadLetterQueue struct {
+	writer MessageWriter
+}
+
+// NewDeadLetterQueue creates a DeadLetterQueue writing to topic. Messages keep their key, so a driver's failed
+// updates stay in order.
+func NewDeadLetterQueue(kafkaBrokers []string, topic string) *DeadLetterQueue {
+	return NewDeadLetterQueueWithWriter(kafka.NewWriter(kafka.WriterConfig{
+		Brokers:  kafkaBrokers,
+		Topic:    topic,
+		Balancer: &kafka.Hash{},
+	}))
+}
+
+// NewDeadLetterQueueWithWriter creates a DeadLetterQueue writing to writer, such as a fake in tests.
+func NewDeadLetterQueueWithWriter(writer MessageWriter) *DeadLetterQueue {
+	return &DeadLetterQueue{writer: writer}
+}
+
+// Add writes msg to the dead-letter topic with headers recording where it was read from and cause.
+func (q *DeadLetterQueue) Add(ctx context.Context, msg kafka.Message, cause error) error {
+	if q == nil {
+		return nil
+	}
+
+	headers := append(stripDeadLetterHeaders(msg.Headers),
+		kafka.Header{Key: HeaderDeadLetterError, Value: []byte(cause.Error())},
+		kafka.Header{Key: HeaderDeadLetterTopic, Value: []byte(msg.Topic)},
+		kafka.Header{Key: HeaderDeadLetterPartition, Value: []byte(strconv.Itoa(msg.Partition))},
+		kafka.Header{Key: HeaderDeadLetterOffset, Value: []byte(strconv.FormatInt(msg.Offset, 10))},
+	)
+	if err := q.writer.WriteMessages(ctx, kafka.Message{Key: msg.Key, Value: msg.Value, Headers: headers}); err != nil {
+		return fmt.Errorf("failed to dead-letter message at offset %d of partition %d: %w", msg.Offset, msg.Partition, err)
+	}
+	return nil
+}
+
+// Close flushes and closes the writer.
+func (q *DeadLetterQueue) Close() error {
+	if q == nil {
+		return nil
+	}
+	return q.writer.Close()
+}
+
+// RedriveResult counts the messages a redrive read from the dead-letter topic.
+type RedriveResult struct {
+	// Redriven messages were moved back to the topic.
+	Redriven int
+	// Skipped messages had been redriven the maximum number of times already and stay on the dead-letter topic.
+	Skipped int
+}
+
+// Redrive moves the messages on deadLetterTopic back to topic, for the consumer to process again. It only reads
+// the messages that were on deadLetterTopic when it began: while the fault persists, the consumer dead-letters
+// them again, and those copies are left for the next redrive. Messages already redriven maxRedrives times,
+// or DefaultMaxRedrives for zero, are skipped. Each message is committed once it has been handled, so a redrive
+// that is interrupted resumes where it stopped. Redrive also returns once no message has arrived for idle,
+// or DefaultRedriveIdle for zero, since a partition redriven before has nothing left to read.
+// Progress is logged to logger; nil logs to slog.Default().
+func Redrive(ctx context.Context, kafkaBrokers []string, deadLetterTopic, topic string, idle time.Duration, maxRedrives int, logger *slog.Logger) (RedriveResult, error) {
+	broker, partitions, err := findPartitions(ctx, kafkaBrokers, deadLetterTopic)
+	if err != nil {
+		return RedriveResult{}, err
+	}
+	ends := make(map[int]int64, len(partitions))
+	for _, p := range partitions {
+		_, last, _, err := partitionOffsets(ctx, broker, deadLetterTopic, p.ID, ReplayStart{})
+		if err != nil {
+			return RedriveResult{}, err
+		}
+		ends[p.ID] = last
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:  kafkaBrokers,
+		Topic:    deadLetterTopic,
+		GroupID:  redriveGroupID,
+		MinBytes: 1,
+		MaxBytes: 10e6,
+	})
+	defer reader.Close()
+	writer := kafka.NewWriter(kafka.WriterConfig{
+		Brokers:  kafkaBrokers,
+		Topic:    topic,
+		Balancer: &kafka.Hash{},
+	})
+	defer writer.Close()
+
+	logger = logging.Or(logger).With("dead_letter_topic", deadLetterTopic, "topic", topic)
+	return RedriveMessages(ctx, reader, writer, ends, idle, maxRedrives, logger)
+}
+
+// RedriveMessages is Redrive reading the dead-letter topic from reader and republishing to writer. ends holds
+// the offset after the last message of each of the topic's partitions when the redrive began.
+func RedriveMessages(ctx context.Context, reader MessageReader, writer MessageWriter, ends map[int]int64, idle time.Duration, maxRedrives int, logger *slog.Logger) (RedriveResult, error) {
+	if idle <= 0 {
+		idle = DefaultRedriveIdle
+	}
+	if maxRedrives <= 0 {
+		maxRedrives = DefaultMaxRedrives
+	}
+	logger = logging.Or(logger)
+
+	var result RedriveResult
+	pending := make(map[int]bool, len(ends))
+	for partition, end := range ends {
+		if end > 0 {
+			pending[partition] = true
+		}
+	}
+	// Messages dead-lettered since the redrive began don't count as progress, or a persisting fault would keep
+	// the redrive going for ever.
+	lastProgress := time.Now()
+	for len(pending) > 0 {
+		fetchCtx, cancel := context.WithDeadline(ctx, lastProgress.Add(idle))
+		msg, err := reader.FetchMessage(fetchCtx)
+		cancel()
+		switch {
+		case err == nil:
+		case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+			logger.InfoContext(ctx, "Dead-letter topic idle", "redriven", result.Redriven, "skipped", result.Skipped)
+			return result, nil
+		default:
+			return result, fmt.Errorf("failed to read dead-letter topic: %w", err)
+		}
+
+		end, ok := ends[msg.Partition]
+		if !ok || msg.Offset >= end {
+			delete(pending, msg.Partition)
+			continue
+		}
+		lastProgress = time.Now()
+
+		redrives, _ := strconv.Atoi(headerValue(msg.Headers, HeaderRedriveCount))
+		if redrives >= maxRedrives {
+			result.Skipped++
+			logger.WarnContext(ctx, "Message redriven too often; leaving it on the dead-letter topic", "partition", msg.Partition, "offset", msg.Offset, "redrives", redrives, "cause", headerValue(msg.Headers, HeaderDeadLetterError))
+		} else {
+			republished := kafka.Message{Key: msg.Key, Value: msg.Value, Headers: append(stripRedriveHeaders(msg.Headers),
+				kafka.Header{Key: HeaderRedriveCount, Value: []byte(strconv.Itoa(redrives + 1))},
+			)}
+			if err := writer.WriteMessages(ctx, republished); err != nil {
+				return result, fmt.Errorf("failed to republish dead-lettered message at offset %d: %w", msg.Offset, err)
+			}
+			result.Redriven++
+			logger.DebugContext(ctx, "Redrove message", "partition", msg.Partition, "offset", msg.Offset, "cause", headerValue(msg.Headers, HeaderDeadLetterError))
+		}
+		if err := reader.CommitMessages(ctx, msg); err != nil {
+			return result, fmt.Errorf("failed to commit dead-lettered message at offset %d: %w", msg.Offset, err)
+		}
+		if msg.Offset >= end-1 {
+			delete(pending, msg.Partition)
+		}
+	}
+	logger.InfoContext(ctx, "Dead-letter topic drained", "redriven", result.Redriven, "skipped", result.Skipped)
+	return result, nil
+}
+
+// stripRedriveHeaders returns headers without the dead-letter headers and the redrive count, which Redrive
+// replaces.
+func stripRedriveHeaders(headers []kafka.Header) []kafka.Header {
+	stripped := make([]kafka.Header, 0, len(headers)+1)
+	for _, header := range stripDeadLetterHeaders(headers) {
+		if header.Key != HeaderRedriveCount {
+			stripped = append(stripped, header)
+		}
+	}
+	return stripped
+}
+
+// stripDeadLetterHeaders returns headers without those Add sets, so that a message failing again is recorded
+// afresh rather than twice.
+func stripDeadLetterHeaders(headers []kafka.Header) []kafka.Header {
+	stripped := make([]kafka.Header, 0, len(headers))
+	for _, header := range headers {
+		if !strings.HasPrefix(header.Key, "dlq-") {
+			stripped = append(stripped, header)
+		}
+	}
+	return stripped
+}
+
+// headerValue returns the value of the header called key, or "".
+func headerValue(headers []kafka.Header, key string) string {
+	for _, header := range headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
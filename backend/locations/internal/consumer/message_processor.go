@@ -2,14 +2,18 @@ package consumer
 
 import (
 	"context"
-	"fmt"
-	"log"
 	"encoding/json"
+	"log/slog"
 
 	"github.com/segmentio/kafka-go"
 
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
 	"locations/internal/db"
+	"locations/internal/logging"
 	"locations/internal/models"
+	"locations/internal/tracing"
 )
 
 // MessageProcessor is an interface that defines a single method, ProcessMessage, which takes a context and a Kafka message and returns an error.
@@ -17,39 +21,74 @@ type MessageProcessor interface {
 	ProcessMessage(ctx context.Context, msg kafka.Message) error
 }
 
+// LocationPublisher receives location updates once they have been stored, such as the live position hub.
+type LocationPublisher interface {
+	PublishLocation(update models.LocationUpdate)
+}
+
 // DefaultKafkaMessageProcessor is a struct that implements the MessageProcessor interface and contains a database instance.
 type DefaultKafkaMessageProcessor struct {
-	database db.Database
+	database  db.Database
+	publisher LocationPublisher
+	logger    *slog.Logger
 }
 
 // NewKafkaMessageProcessor is a constructor function that creates a new DefaultKafkaMessageProcessor with the provided database.
-func NewKafkaMessageProcessor(database db.Database) *DefaultKafkaMessageProcessor {
+// Stored updates are also handed to publisher, which may be nil.
+// Failures are logged to logger; nil logs to slog.Default().
+func NewKafkaMessageProcessor(database db.Database, publisher LocationPublisher, logger *slog.Logger) *DefaultKafkaMessageProcessor {
 	return &DefaultKafkaMessageProcessor{
-		database: database,
+		database:  database,
+		publisher: publisher,
+		logger:    logging.Or(logger),
 	}
 }
 
 // ProcessMessage is a method on DefaultKafkaMessageProcessor that handles the processing of Kafka messages.
-func (p *DefaultKafkaMessageProcessor) ProcessMessage(ctx context.Context, msg kafka.Message) error {
-	fmt.Printf("Received Kafka message: %s\n", msg.Value)
+// It is traced in a consumer span, a child of the publisher's span when ctx carries the message's trace context,
+// and the database calls it makes are traced beneath it.
+func (p *DefaultKafkaMessageProcessor) ProcessMessage(ctx context.Context, msg kafka.Message) (err error) {
+	ctx, span := tracing.Tracer().Start(ctx, "ProcessMessage",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithAttributes(
+			semconv.MessagingSystem("kafka"),
+			semconv.MessagingOperationProcess,
+			semconv.MessagingDestinationName(msg.Topic),
+			semconv.MessagingKafkaDestinationPartition(msg.Partition),
+			semconv.MessagingKafkaMessageOffset(int(msg.Offset)),
+			semconv.MessagingKafkaMessageKey(string(msg.Key)), // The driver ID, to find a driver's updates.
+		))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	// The body holds the driver's coordinates, so it is only logged, and redacted, by a logger at debug level.
+	logger := p.logger.With("partition", msg.Partition, "offset", msg.Offset)
+	logger.DebugContext(ctx, "Received Kafka message", "message_body", string(msg.Value))
 
 	// Declare a variable of type LocationUpdate from the models package.
 	var locationUpdate models.LocationUpdate
 	// Unmarshal the JSON-encoded Kafka message into the locationUpdate variable.
-	err := json.Unmarshal(msg.Value, &locationUpdate)
+	err = json.Unmarshal(msg.Value, &locationUpdate)
 	if err != nil {
 		// If there is an error during unmarshaling, log the error and return it.
-		log.Printf("Error parsing location update: %v\n", err)
+		logger.ErrorContext(ctx, "Failed to parse location update", "error", err)
 		return err
 	}
 
 	// Insert the location update into the database using the InsertLocationUpdate method.
 	if err := p.database.InsertLocationUpdate(ctx, locationUpdate); err != nil {
 		// If there is an error during insertion, log the error and return it.
-		log.Printf("Error saving location: %v\n", err)
+		logger.ErrorContext(ctx, "Failed to save location", "driver_id", locationUpdate.DriverID, "error", err)
 		return err
 	}
 
+	// Hand the stored update to in-process subscribers, such as riders tracking the driver.
+	if p.publisher != nil {
+		p.publisher.PublishLocation(locationUpdate)
+	}
+
 	// If everything went well, return nil indicating no error occurred.
 	return nil
 }
@@ -0,0 +1,131 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"locations/internal/health"
+)
+
+// DefaultMaxLag is how many messages the consumer may fall behind before it is reported not ready.
+const DefaultMaxLag = 10000
+
+// lagRetention is how long the lag read from a partition counts. A partition the consumer is assigned and
+// behind on is read continually, so one not read for longer has gone to another consumer in a rebalance, and
+// the lag last seen on it says nothing about this one.
+const lagRetention = 5 * time.Minute
+
+// errNotStarted is reported until the consume loop starts.
+var errNotStarted = errors.New("consumer has not started")
+
+// Monitor tracks the consume loop for health checks: whether it is running, and how far it is behind on each
+// partition it has read from. A nil Monitor tracks nothing.
+type Monitor struct {
+	mu          sync.Mutex
+	running     bool
+	err         error
+	lag         map[int]partitionLag
+	lastMessage time.Time
+	now         func() time.Time
+}
+
+// partitionLag is the lag seen on a partition and when it was seen.
+type partitionLag struct {
+	messages int64
+	at       time.Time
+}
+
+// NewMonitor creates a Monitor for a consume loop that hasn't started yet.
+func NewMonitor() *Monitor {
+	return NewMonitorWithClock(time.Now)
+}
+
+// NewMonitorWithClock is NewMonitor with a custom clock, for tests.
+func NewMonitorWithClock(now func() time.Time) *Monitor {
+	return &Monitor{err: errNotStarted, lag: make(map[int]partitionLag), now: now}
+}
+
+// started records that the consume loop is running. Its partitions are assigned afresh, so the lag seen by an
+// earlier run is forgotten.
+func (m *Monitor) started() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.running, m.err = true, nil
+	m.lag = make(map[int]partitionLag)
+}
+
+// stopped records that the consume loop exited, and why.
+func (m *Monitor) stopped(err error) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.running, m.err = false, err
+}
+
+// observe records a message read from its partition. The message's high watermark is the partition's end as of
+// the fetch that returned it, so what lies between them is the lag.
+func (m *Monitor) observe(msg kafka.Message) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	lag := msg.HighWaterMark - msg.Offset - 1
+	if lag < 0 {
+		lag = 0
+	}
+	m.lastMessage = m.now().UTC()
+	m.lag[msg.Partition] = partitionLag{messages: lag, at: m.lastMessage}
+}
+
+// Check reports the consumer down when its loop isn't running or it has fallen more than maxLag messages
+// behind across the partitions it has read from in the last lagRetention. Partitions not read for longer are
+// forgotten.
+func (m *Monitor) Check(maxLag int64) health.Check {
+	return func(ctx context.Context) (map[string]any, error) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+
+		var total int64
+		partitions := make(map[string]int64, len(m.lag))
+		now := m.now()
+		for partition, lag := range m.lag {
+			if now.Sub(lag.at) > lagRetention {
+				delete(m.lag, partition)
+				continue
+			}
+			partitions[strconv.Itoa(partition)] = lag.messages
+			total += lag.messages
+		}
+		details := map[string]any{
+			"running":       m.running,
+			"lag":           total,
+			"max_lag":       maxLag,
+			"partition_lag": partitions,
+		}
+		if !m.lastMessage.IsZero() {
+			details["last_message_at"] = m.lastMessage
+		}
+
+		switch {
+		case !m.running && m.err != nil:
+			return details, fmt.Errorf("consumer is not running: %w", m.err)
+		case !m.running:
+			return details, errors.New("consumer is not running")
+		case total > maxLag:
+			return details, fmt.Errorf("consumer is %d messages behind, more than %d", total, maxLag)
+		}
+		return details, nil
+	}
+}
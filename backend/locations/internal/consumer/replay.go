@@ -0,0 +1,195 @@
+package consumer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"locations/internal/logging"
+	"locations/internal/tracing"
+)
+
+// DefaultReplayIdle is how long Replay waits for the next message of a partition before it takes the partition
+// as replayed. The offsets before a partition's end need not all be messages: transaction markers and compacted
+// records take offsets too, so the last offset may never be read.
+const DefaultReplayIdle = 10 * time.Second
+
+// ReplayStart is where Replay starts reading each partition: at the first message at or after Time or,
+// if Time is zero, at Offset.
+type ReplayStart struct {
+	Time   time.Time
+	Offset int64
+}
+
+// ParseReplayStart parses an RFC 3339 time, a date meaning midnight UTC at its start, or an offset.
+func ParseReplayStart(value string) (ReplayStart, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return ReplayStart{Time: t}, nil
+	}
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return ReplayStart{Time: t}, nil
+	}
+	offset, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || offset < 0 {
+		return ReplayStart{}, fmt.Errorf("invalid replay start %q: expected a time like 2006-01-02T15:04:05Z, a date or an offset", value)
+	}
+	return ReplayStart{Offset: offset}, nil
+}
+
+// ReplayResult counts the messages a replay handed to the processor.
+type ReplayResult struct {
+	Processed int
+	Failed    int
+}
+
+// Replay hands the messages of topic from start to messageProcessor again, partition by partition, up to the end
+// each partition had when its replay began, or until no message has arrived for DefaultReplayIdle. A negative partition replays every partition; an offset before a
+// partition's first message starts at its first message. Replay reads outside the consumer group, so the
+// group's offsets are untouched. Messages that fail are logged and counted, and the replay goes on.
+// Updates already stored are stored again, as after any redelivery. Progress is logged to logger; nil logs to
+// slog.Default().
+func Replay(ctx context.Context, kafkaBrokers []string, topic string, start ReplayStart, partition int, messageProcessor MessageProcessor, logger *slog.Logger) (ReplayResult, error) {
+	logger = logging.Or(logger).With("topic", topic)
+	var result ReplayResult
+
+	broker, partitions, err := findPartitions(ctx, kafkaBrokers, topic)
+	if err != nil {
+		return result, err
+	}
+	replayed := false
+	for _, p := range partitions {
+		if partition >= 0 && p.ID != partition {
+			continue
+		}
+		if err := replayPartition(ctx, kafkaBrokers, broker, topic, p.ID, start, messageProcessor, logger, &result); err != nil {
+			return result, err
+		}
+		replayed = true
+	}
+	if !replayed {
+		return result, fmt.Errorf("topic %s has no partition %d", topic, partition)
+	}
+	return result, nil
+}
+
+// replayPartition replays one partition, adding to result.
+func replayPartition(ctx context.Context, kafkaBrokers []string, broker, topic string, partition int, start ReplayStart, messageProcessor MessageProcessor, logger *slog.Logger, result *ReplayResult) error {
+	logger = logger.With("partition", partition)
+
+	first, last, offset, err := partitionOffsets(ctx, broker, topic, partition, start)
+	if err != nil {
+		return err
+	}
+	if offset < first {
+		offset = first
+	}
+	if offset >= last {
+		logger.InfoContext(ctx, "Nothing to replay")
+		return nil
+	}
+	logger.InfoContext(ctx, "Replaying partition", "from_offset", offset, "to_offset", last-1)
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   kafkaBrokers,
+		Topic:     topic,
+		Partition: partition,
+		MinBytes:  1,
+		MaxBytes:  10e6,
+	})
+	defer reader.Close()
+	if err := reader.SetOffset(offset); err != nil {
+		return fmt.Errorf("failed to seek partition %d to offset %d: %w", partition, offset, err)
+	}
+
+	replayed, err := ReplayMessages(ctx, reader, last, DefaultReplayIdle, messageProcessor, logger)
+	result.Processed += replayed.Processed
+	result.Failed += replayed.Failed
+	if err != nil {
+		return fmt.Errorf("failed to read partition %d: %w", partition, err)
+	}
+	return nil
+}
+
+// ReplayMessages hands the messages reader reads to messageProcessor until it reads the one before last,
+// reads one at or after last, which it leaves, or reads nothing for idle.
+func ReplayMessages(ctx context.Context, reader MessageReader, last int64, idle time.Duration, messageProcessor MessageProcessor, logger *slog.Logger) (ReplayResult, error) {
+	logger = logging.Or(logger)
+	var result ReplayResult
+	for {
+		readCtx, cancel := context.WithTimeout(ctx, idle)
+		msg, err := reader.ReadMessage(readCtx)
+		cancel()
+		switch {
+		case err == nil:
+		case errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil:
+			logger.InfoContext(ctx, "No more messages to replay", "to_offset", last-1)
+			return result, nil
+		default:
+			return result, err
+		}
+		if msg.Offset >= last {
+			return result, nil
+		}
+		// As in the consume loop, a message being processed is finished even if the replay is interrupted.
+		if err := messageProcessor.ProcessMessage(tracing.ExtractMessage(context.WithoutCancel(ctx), msg), msg); err != nil {
+			result.Failed++
+			logger.ErrorContext(ctx, "Failed to replay message", "offset", msg.Offset, "error", err)
+		} else {
+			result.Processed++
+		}
+		if msg.Offset >= last-1 {
+			return result, nil
+		}
+	}
+}
+
+// findPartitions asks the brokers, in order until one answers, for the partitions of topic, and returns the
+// broker that answered.
+func findPartitions(ctx context.Context, kafkaBrokers []string, topic string) (string, []kafka.Partition, error) {
+	err := errors.New("no brokers configured")
+	for _, broker := range kafkaBrokers {
+		var conn *kafka.Conn
+		conn, err = kafka.DialContext(ctx, "tcp", broker)
+		if err != nil {
+			continue
+		}
+		var partitions []kafka.Partition
+		partitions, err = conn.ReadPartitions(topic)
+		conn.Close()
+		if err == nil {
+			return broker, partitions, nil
+		}
+	}
+	return "", nil, fmt.Errorf("failed to read partitions of %s: %w", topic, err)
+}
+
+// partitionOffsets returns a partition's first offset, the offset after its last message, and the offset
+// start resolves to.
+func partitionOffsets(ctx context.Context, broker, topic string, partition int, start ReplayStart) (first, last, offset int64, err error) {
+	conn, err := kafka.DialLeader(ctx, "tcp", broker, topic, partition)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to connect to the leader of partition %d: %w", partition, err)
+	}
+	defer conn.Close()
+
+	first, last, err = conn.ReadOffsets()
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to read offsets of partition %d: %w", partition, err)
+	}
+	if start.Time.IsZero() {
+		return first, last, start.Offset, nil
+	}
+	offset, err = conn.ReadOffset(start.Time)
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to find offset at %s in partition %d: %w", start.Time.Format(time.RFC3339), partition, err)
+	}
+	if offset < 0 { // No message is that recent.
+		offset = last
+	}
+	return first, last, offset, nil
+}
@@ -0,0 +1,188 @@
+package db
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"locations/internal/models"
+)
+
+// archiveDeleteBatch is how many documents are removed per DeleteMany when archived history is purged.
+const archiveDeleteBatch = 1000
+
+// HistoryArchiver is implemented by backends whose location history can be moved to cold storage.
+type HistoryArchiver interface {
+	// ScanHistoryBefore calls handle for every stored location update with a timestamp before cutoff, oldest first.
+	// The record's key identifies the stored document and is what DeleteHistory takes.
+	ScanHistoryBefore(ctx context.Context, cutoff time.Time, handle func(record HistoryRecord) error) error
+
+	// DeleteHistory removes the documents with the given keys.
+	DeleteHistory(ctx context.Context, keys []string) (int64, error)
+
+	// RestoreHistory writes previously archived documents back into the history unchanged. A record is stored
+	// under its key, replacing the document already there, so restoring the same record twice keeps one copy.
+	RestoreHistory(ctx context.Context, records []HistoryRecord) error
+
+	// Erasures returns when each driver whose data was erased was last erased. Archived history of a driver
+	// from before their erasure must not be restored.
+	Erasures(ctx context.Context) (map[string]time.Time, error)
+
+	// DecodeHistory converts an archived record to the update it stores, decrypting it if needed, so that
+	// archived history can be exported.
+	DecodeHistory(record HistoryRecord) (models.LocationUpdate, error)
+}
+
+// HistoryRecord is a stored location history document as it is archived. The document is kept exactly as stored,
+// so coordinates that are encrypted in the database stay encrypted in the archive; the driver and timestamp,
+// which are stored in plaintext anyway, are copied out so that archives can be partitioned by them.
+type HistoryRecord struct {
+	Key       string    `json:"key"`
+	DriverID  string    `json:"driver_id"`
+	Timestamp time.Time `json:"timestamp"`
+	// Document is the stored document in the backend's own encoding; for MongoDB, canonical extended JSON.
+	Document json.RawMessage `json:"document"`
+}
+
+// ScanHistoryBefore iterates the 'locations' collection in timestamp order up to cutoff, without decrypting it.
+func (db *MongoDB) ScanHistoryBefore(ctx context.Context, cutoff time.Time, handle func(record HistoryRecord) error) error {
+	collection := db.client.Database(databaseName).Collection("locations")
+
+	cursor, err := collection.Find(ctx,
+		bson.M{"timestamp": bson.M{"$lt": cutoff}},
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to scan location history: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		var doc struct {
+			ObjectID  primitive.ObjectID `bson:"_id"`
+			DriverID  string             `bson:"driver_id"`
+			Timestamp time.Time          `bson:"timestamp"`
+		}
+		if err := cursor.Decode(&doc); err != nil {
+			return fmt.Errorf("failed to decode location history: %w", err)
+		}
+		document, err := bson.MarshalExtJSON(cursor.Current, true, false)
+		if err != nil {
+			return fmt.Errorf("failed to encode location history: %w", err)
+		}
+		record := HistoryRecord{Key: doc.ObjectID.Hex(), DriverID: doc.DriverID, Timestamp: doc.Timestamp, Document: document}
+		if err := handle(record); err != nil {
+			return err
+		}
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("failed to scan location history: %w", err)
+	}
+	return nil
+}
+
+// DeleteHistory removes documents from the 'locations' collection by ObjectID.
+// Deleting exactly what was scanned, rather than everything before the cutoff, means that late-arriving
+// updates written while an archive was running are never deleted without having been archived.
+func (db *MongoDB) DeleteHistory(ctx context.Context, keys []string) (int64, error) {
+	collection := db.client.Database(databaseName).Collection("locations")
+
+	var deleted int64
+	for start := 0; start < len(keys); start += archiveDeleteBatch {
+		end := start + archiveDeleteBatch
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		ids := make(bson.A, 0, end-start)
+		for _, key := range keys[start:end] {
+			id, err := primitive.ObjectIDFromHex(key)
+			if err != nil {
+				return deleted, fmt.Errorf("invalid history key %q: %w", key, err)
+			}
+			ids = append(ids, id)
+		}
+
+		res, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return deleted, fmt.Errorf("failed to delete archived history: %w", err)
+		}
+		deleted += res.DeletedCount
+	}
+	return deleted, nil
+}
+
+// RestoreHistory writes archived documents back into the 'locations' collection under their original ObjectIDs.
+// The live position is left alone: restored history is by definition older than the current position.
+func (db *MongoDB) RestoreHistory(ctx context.Context, records []HistoryRecord) error {
+	if len(records) == 0 {
+		return nil
+	}
+	collection := db.client.Database(databaseName).Collection("locations")
+
+	writes := make([]mongo.WriteModel, len(records))
+	for i, record := range records {
+		var doc bson.D
+		if err := bson.UnmarshalExtJSON(record.Document, true, &doc); err != nil {
+			return fmt.Errorf("failed to decode archived document %q: %w", record.Key, err)
+		}
+		id, err := primitive.ObjectIDFromHex(record.Key)
+		if err != nil {
+			return fmt.Errorf("invalid history key %q: %w", record.Key, err)
+		}
+		writes[i] = mongo.NewReplaceOneModel().SetFilter(bson.M{"_id": id}).SetReplacement(doc).SetUpsert(true)
+	}
+	if _, err := collection.BulkWrite(ctx, writes, options.BulkWrite().SetOrdered(false)); err != nil {
+		return fmt.Errorf("failed to restore location history: %w", err)
+	}
+	return nil
+}
+
+// DecodeHistory decodes an archived document as GetLocationByID decodes a stored one.
+func (db *MongoDB) DecodeHistory(record HistoryRecord) (models.LocationUpdate, error) {
+	var doc locationDocument
+	if err := bson.UnmarshalExtJSON(record.Document, true, &doc); err != nil {
+		return models.LocationUpdate{}, fmt.Errorf("failed to decode archived document %q: %w", record.Key, err)
+	}
+	update, err := db.decodeLocation(doc)
+	if err != nil {
+		return models.LocationUpdate{}, fmt.Errorf("failed to decode archived document %q: %w", record.Key, err)
+	}
+	return update, nil
+}
+
+// Erasures reads the erasures recorded in the privacy audit collection.
+func (db *MongoDB) Erasures(ctx context.Context) (map[string]time.Time, error) {
+	collection := db.client.Database(databaseName).Collection(privacyAuditCollection)
+
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"action": "erase"}}},
+		{{Key: "$group", Value: bson.M{"_id": "$driver_id", "at": bson.M{"$max": "$at"}}}},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read erasures: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	erasures := make(map[string]time.Time)
+	for cursor.Next(ctx) {
+		var erasure struct {
+			DriverID string    `bson:"_id"`
+			At       time.Time `bson:"at"`
+		}
+		if err := cursor.Decode(&erasure); err != nil {
+			return nil, fmt.Errorf("failed to decode erasure: %w", err)
+		}
+		erasures[erasure.DriverID] = erasure.At
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read erasures: %w", err)
+	}
+	return erasures, nil
+}
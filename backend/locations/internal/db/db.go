@@ -2,20 +2,56 @@ package db
 
 import (
 	"context"
+	"errors"
 	"locations/internal/models"
 )
 
+var (
+	// ErrNotFound is returned when the requested document does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrVersionConflict is returned when a conditional update's expected version does not match the stored one.
+	ErrVersionConflict = errors.New("version conflict")
+
+	// ErrIDConflict is returned when a location update is inserted under an ID already stored for a different
+	// update, that is, one of another driver or taken at another time.
+	ErrIDConflict = errors.New("location update ID is already in use")
+)
+
+// UpdateResult reports the outcome of an update.
+type UpdateResult struct {
+	MatchedCount  int64
+	ModifiedCount int64
+	// Version is the document's version after the update.
+	Version int64
+}
+
 // Database is an interface that defines the methods for interacting with the database.
 type Database interface {
-	// InsertLocationUpdate inserts a location update into the database.
+	// InsertLocationUpdate inserts a location update into the database. Inserting an update whose ID is already
+	// stored does nothing if it is the same update, and returns ErrIDConflict otherwise.
 	InsertLocationUpdate(ctx context.Context, update models.LocationUpdate) error
 
 	// GetLocationByID retrieves a location update by its ID from the database.
 	GetLocationByID(ctx context.Context, id string) (*models.LocationUpdate, error)
 
+	// GetDriverLocation retrieves the driver's live position, or ErrNotFound if none is stored.
+	GetDriverLocation(ctx context.Context, driverID string) (*models.Driver, error)
+
 	// UpdateLocation updates a location update in the database.
-	UpdateLocation(ctx context.Context, id string, update models.LocationUpdate) error
+	// If expectedVersion is non-zero the update only applies when the stored version matches,
+	// otherwise ErrVersionConflict is returned. ErrNotFound is returned when no document has the ID.
+	UpdateLocation(ctx context.Context, id string, update models.LocationUpdate, expectedVersion int64) (*UpdateResult, error)
 
 	// GetNearbyDrivers retrieves nearby drivers based on the provided latitude and longitude.
 	GetNearbyDrivers(ctx context.Context, latitude, longitude string) ([]models.Driver, error)
-}
\ No newline at end of file
+
+	// ExportDriverData returns all stored data for the driver in the request and records an audit entry.
+	ExportDriverData(ctx context.Context, request models.PrivacyRequest) (*models.DriverDataExport, error)
+
+	// EraseDriverData deletes all stored data for the driver in the request and records an audit entry.
+	EraseDriverData(ctx context.Context, request models.PrivacyRequest) (*models.DriverErasureResult, error)
+
+	// Heatmap aggregates location updates matching the query into geohash cells.
+	Heatmap(ctx context.Context, query models.HeatmapQuery) ([]models.HeatmapCell, error)
+}
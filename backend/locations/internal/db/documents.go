@@ -0,0 +1,181 @@
+package db
+
+import (
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"locations/internal/fieldcrypt"
+	"locations/internal/geo"
+	"locations/internal/logging"
+	"locations/internal/models"
+)
+
+// DefaultCoarsePrecision is the geohash precision of the unencrypted live position kept for geo queries
+// when field encryption is on. Six characters is a cell of roughly 1.2km x 0.6km.
+const DefaultCoarsePrecision = 6
+
+// MongoOption configures optional MongoDB behaviour.
+type MongoOption func(*MongoDB)
+
+// WithLogger logs to logger instead of slog.Default(); nil keeps the default.
+func WithLogger(logger *slog.Logger) MongoOption {
+	return func(db *MongoDB) {
+		db.logger = logging.Or(logger)
+	}
+}
+
+// WithFieldEncryption encrypts stored coordinates with cipher. History documents keep no plaintext
+// coordinates at all; live positions additionally keep the centre of their geohash cell at coarsePrecision
+// in 'location', so that nearby queries still work against the 2dsphere index.
+func WithFieldEncryption(cipher *fieldcrypt.Cipher, coarsePrecision int) MongoOption {
+	return func(db *MongoDB) {
+		db.cipher = cipher
+		db.coarsePrecision = coarsePrecision
+	}
+}
+
+// locationDocument is how a LocationUpdate is stored in the 'locations' collection.
+// Exactly one of the plaintext coordinates or Encrypted is set.
+type locationDocument struct {
+	ID        string               `bson:"id,omitempty"`
+	DriverID  string               `bson:"driver_id"`
+	Latitude  *float64             `bson:"latitude,omitempty"`
+	Longitude *float64             `bson:"longitude,omitempty"`
+	Timestamp time.Time            `bson:"timestamp"`
+	Version   int64                `bson:"version"`
+	Accuracy  float64              `bson:"accuracy,omitempty"`
+	Encrypted *fieldcrypt.Envelope `bson:"enc,omitempty"`
+}
+
+// driverDocument is how a driver's live position is stored in the 'drivers' collection.
+// When Encrypted is set, Location is only the coarse cell centre.
+type driverDocument struct {
+	DriverID  string               `bson:"driver_id"`
+	Location  models.GeoPoint      `bson:"location"`
+	UpdatedAt time.Time            `bson:"updated_at"`
+	StoredAt  time.Time            `bson:"stored_at,omitempty"`
+	Accuracy  float64              `bson:"accuracy,omitempty"`
+	Encrypted *fieldcrypt.Envelope `bson:"enc,omitempty"`
+}
+
+// encodeLocation converts an update to its stored form, encrypting the coordinates if enabled.
+func (db *MongoDB) encodeLocation(update models.LocationUpdate) (locationDocument, error) {
+	doc := locationDocument{
+		ID:        update.ID,
+		DriverID:  update.DriverID,
+		Timestamp: update.Timestamp,
+		Version:   update.Version,
+		Accuracy:  update.Accuracy,
+	}
+	if db.cipher == nil {
+		doc.Latitude, doc.Longitude = &update.Latitude, &update.Longitude
+		return doc, nil
+	}
+
+	envelope, err := db.cipher.Seal(encodeCoordinates(update.Latitude, update.Longitude), locationAssociatedData(update.DriverID, update.Timestamp))
+	if err != nil {
+		return doc, fmt.Errorf("failed to encrypt location: %w", err)
+	}
+	doc.Encrypted = envelope
+	return doc, nil
+}
+
+// decodeLocation converts a stored document back to an update, decrypting the coordinates if needed.
+func (db *MongoDB) decodeLocation(doc locationDocument) (models.LocationUpdate, error) {
+	update := models.LocationUpdate{
+		ID:        doc.ID,
+		DriverID:  doc.DriverID,
+		Timestamp: doc.Timestamp,
+		Version:   doc.Version,
+		Accuracy:  doc.Accuracy,
+	}
+	if doc.Encrypted == nil {
+		if doc.Latitude != nil && doc.Longitude != nil {
+			update.Latitude, update.Longitude = *doc.Latitude, *doc.Longitude
+		}
+		return update, nil
+	}
+
+	latitude, longitude, err := db.openCoordinates(doc.Encrypted, locationAssociatedData(doc.DriverID, doc.Timestamp))
+	if err != nil {
+		return update, err
+	}
+	update.Latitude, update.Longitude = latitude, longitude
+	return update, nil
+}
+
+// encodeDriver converts a live position to its stored form. With encryption on, the precise point is
+// encrypted and the queryable location is replaced by the centre of its coarse geohash cell.
+func (db *MongoDB) encodeDriver(driver models.Driver) (driverDocument, error) {
+	doc := driverDocument{DriverID: driver.DriverID, Location: driver.Location, UpdatedAt: driver.UpdatedAt, StoredAt: driver.StoredAt, Accuracy: driver.Accuracy}
+	if db.cipher == nil || len(driver.Location.Coordinates) != 2 {
+		return doc, nil
+	}
+
+	latitude, longitude := driver.Location.Coordinates[1], driver.Location.Coordinates[0]
+	envelope, err := db.cipher.Seal(encodeCoordinates(latitude, longitude), driverAssociatedData(driver.DriverID, driver.UpdatedAt))
+	if err != nil {
+		return doc, fmt.Errorf("failed to encrypt live position: %w", err)
+	}
+	// The hash was produced by geo.Encode, so it always decodes.
+	bounds, _ := geo.Decode(geo.Encode(latitude, longitude, db.coarsePrecision))
+	coarseLat, coarseLng := bounds.Center()
+
+	doc.Location = models.NewGeoPoint(coarseLat, coarseLng)
+	doc.Encrypted = envelope
+	return doc, nil
+}
+
+// decodeDriver converts a stored live position back, restoring the precise point if it was encrypted.
+func (db *MongoDB) decodeDriver(doc driverDocument) (models.Driver, error) {
+	driver := models.Driver{DriverID: doc.DriverID, Location: doc.Location, UpdatedAt: doc.UpdatedAt, StoredAt: doc.StoredAt, Accuracy: doc.Accuracy}
+	if doc.Encrypted == nil {
+		return driver, nil
+	}
+
+	latitude, longitude, err := db.openCoordinates(doc.Encrypted, driverAssociatedData(doc.DriverID, doc.UpdatedAt))
+	if err != nil {
+		return driver, err
+	}
+	driver.Location = models.NewGeoPoint(latitude, longitude)
+	return driver, nil
+}
+
+// openCoordinates decrypts an envelope holding a latitude/longitude pair.
+func (db *MongoDB) openCoordinates(envelope *fieldcrypt.Envelope, associatedData []byte) (float64, float64, error) {
+	if db.cipher == nil {
+		return 0, 0, fmt.Errorf("document is encrypted with key %q but field encryption is not configured", envelope.KeyID)
+	}
+	plaintext, err := db.cipher.Open(envelope, associatedData)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to decrypt coordinates: %w", err)
+	}
+	if len(plaintext) != 16 {
+		return 0, 0, fmt.Errorf("failed to decrypt coordinates: unexpected length %d", len(plaintext))
+	}
+	latitude := math.Float64frombits(binary.BigEndian.Uint64(plaintext[:8]))
+	longitude := math.Float64frombits(binary.BigEndian.Uint64(plaintext[8:]))
+	return latitude, longitude, nil
+}
+
+// encodeCoordinates packs a latitude/longitude pair into 16 bytes.
+func encodeCoordinates(latitude, longitude float64) []byte {
+	buf := make([]byte, 16)
+	binary.BigEndian.PutUint64(buf[:8], math.Float64bits(latitude))
+	binary.BigEndian.PutUint64(buf[8:], math.Float64bits(longitude))
+	return buf
+}
+
+// locationAssociatedData binds encrypted history coordinates to their driver and time, so they can't be
+// copied onto another document. Timestamps are in milliseconds because that is what MongoDB stores.
+func locationAssociatedData(driverID string, timestamp time.Time) []byte {
+	return []byte(fmt.Sprintf("locations:%s:%d", driverID, timestamp.UnixMilli()))
+}
+
+// driverAssociatedData binds an encrypted live position to its driver and time.
+func driverAssociatedData(driverID string, updatedAt time.Time) []byte {
+	return []byte(fmt.Sprintf("drivers:%s:%d", driverID, updatedAt.UnixMilli()))
+}
@@ -0,0 +1,179 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson"
+
+	"locations/internal/geo"
+	"locations/internal/models"
+)
+
+// heatmapGroup is the shape of a document produced by the heatmap aggregation pipeline.
+type heatmapGroup struct {
+	ID struct {
+		Lat int64 `bson:"lat"`
+		Lng int64 `bson:"lng"`
+	} `bson:"_id"`
+	Count           int64 `bson:"count"`
+	DistinctDrivers int64 `bson:"distinct_drivers"`
+}
+
+// Heatmap aggregates location updates in the query's bounding box and time window into geohash cells.
+// The pipeline buckets points into the geohash grid's row and column indices, which is arithmetic MongoDB can do;
+// the indices are turned into geohash strings here.
+func (db *MongoDB) Heatmap(ctx context.Context, query models.HeatmapQuery) ([]models.HeatmapCell, error) {
+	if db.cipher != nil {
+		return db.heatmapDecrypted(ctx, query)
+	}
+	collection := db.readCollection("Heatmap", "locations")
+	latCells, lngCells := geo.GridSize(query.Precision)
+
+	pipeline := bson.A{
+		bson.M{"$match": bson.M{
+			"timestamp": bson.M{"$gte": query.Since, "$lt": query.Until},
+			"latitude":  bson.M{"$gte": query.MinLatitude, "$lte": query.MaxLatitude},
+			"longitude": bson.M{"$gte": query.MinLongitude, "$lte": query.MaxLongitude},
+		}},
+		bson.M{"$project": bson.M{
+			"driver_id": 1,
+			"lat":       cellIndexExpression("$latitude", 90, 180, latCells),
+			"lng":       cellIndexExpression("$longitude", 180, 360, lngCells),
+		}},
+		bson.M{"$group": bson.M{
+			"_id":     bson.M{"lat": "$lat", "lng": "$lng"},
+			"count":   bson.M{"$sum": 1},
+			"drivers": bson.M{"$addToSet": "$driver_id"},
+		}},
+		bson.M{"$project": bson.M{
+			"count":            1,
+			"distinct_drivers": bson.M{"$size": "$drivers"},
+		}},
+	}
+
+	cursor, err := collection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate heatmap: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	cells := []models.HeatmapCell{}
+	for cursor.Next(ctx) {
+		var group heatmapGroup
+		if err := cursor.Decode(&group); err != nil {
+			return nil, fmt.Errorf("failed to decode heatmap cell: %w", err)
+		}
+		cells = append(cells, heatmapCell(geo.CellGeohash(group.ID.Lat, group.ID.Lng, query.Precision), group.Count, group.DistinctDrivers))
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to aggregate heatmap: %w", err)
+	}
+
+	sortHeatmapCells(cells)
+	return cells, nil
+}
+
+// heatmapDecrypted aggregates in process for encrypted history, whose coordinates MongoDB can't see.
+// Only the time window is filtered server-side, so this reads every update in the window; the window
+// is bounded by the HTTP layer.
+func (db *MongoDB) heatmapDecrypted(ctx context.Context, query models.HeatmapQuery) ([]models.HeatmapCell, error) {
+	collection := db.readCollection("Heatmap", "locations")
+
+	cursor, err := collection.Find(ctx, bson.M{"timestamp": bson.M{"$gte": query.Since, "$lt": query.Until}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to aggregate heatmap: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var updates []models.LocationUpdate
+	for cursor.Next(ctx) {
+		var doc locationDocument
+		if err := cursor.Decode(&doc); err != nil {
+			return nil, fmt.Errorf("failed to decode location: %w", err)
+		}
+		update, err := db.decodeLocation(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to aggregate heatmap: %w", err)
+		}
+		updates = append(updates, update)
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to aggregate heatmap: %w", err)
+	}
+
+	return aggregateHeatmap(updates, query), nil
+}
+
+// cellIndexExpression builds the aggregation expression floor((field + offset) / span * cells), clamped to the last cell.
+// It mirrors geo.CellIndex.
+func cellIndexExpression(field string, offset, span float64, cells int64) bson.M {
+	return bson.M{"$toLong": bson.M{"$min": bson.A{
+		cells - 1,
+		bson.M{"$floor": bson.M{"$multiply": bson.A{
+			bson.M{"$divide": bson.A{bson.M{"$add": bson.A{field, offset}}, span}},
+			cells,
+		}}},
+	}}}
+}
+
+// aggregateHeatmap is the in-process equivalent of the MongoDB heatmap pipeline,
+// for backends that can't aggregate server-side.
+func aggregateHeatmap(updates []models.LocationUpdate, query models.HeatmapQuery) []models.HeatmapCell {
+	type bucket struct {
+		count   int64
+		drivers map[string]struct{}
+	}
+	buckets := make(map[string]*bucket)
+
+	for _, update := range updates {
+		if update.Timestamp.Before(query.Since) || !update.Timestamp.Before(query.Until) {
+			continue
+		}
+		if update.Latitude < query.MinLatitude || update.Latitude > query.MaxLatitude ||
+			update.Longitude < query.MinLongitude || update.Longitude > query.MaxLongitude {
+			continue
+		}
+
+		hash := geo.Encode(update.Latitude, update.Longitude, query.Precision)
+		b, ok := buckets[hash]
+		if !ok {
+			b = &bucket{drivers: make(map[string]struct{})}
+			buckets[hash] = b
+		}
+		b.count++
+		b.drivers[update.DriverID] = struct{}{}
+	}
+
+	cells := make([]models.HeatmapCell, 0, len(buckets))
+	for hash, b := range buckets {
+		cells = append(cells, heatmapCell(hash, b.count, int64(len(b.drivers))))
+	}
+	sortHeatmapCells(cells)
+	return cells
+}
+
+// heatmapCell builds a cell positioned at the centre of its geohash.
+func heatmapCell(hash string, count, distinctDrivers int64) models.HeatmapCell {
+	// The hash was produced by this package, so it always decodes.
+	bounds, _ := geo.Decode(hash)
+	latitude, longitude := bounds.Center()
+	return models.HeatmapCell{
+		Geohash:         hash,
+		Latitude:        latitude,
+		Longitude:       longitude,
+		Count:           count,
+		DistinctDrivers: distinctDrivers,
+	}
+}
+
+// sortHeatmapCells orders cells busiest first, then by geohash so that output is stable.
+func sortHeatmapCells(cells []models.HeatmapCell) {
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i].Count != cells[j].Count {
+			return cells[i].Count > cells[j].Count
+		}
+		return cells[i].Geohash < cells[j].Geohash
+	})
+}
@@ -0,0 +1,164 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"locations/internal/models"
+)
+
+const (
+	// resumeTokensCollection stores the last processed change stream token per watched collection.
+	resumeTokensCollection = "change_stream_tokens"
+	// resumeTokenFlushInterval bounds how often the resume token is written back while events stream in.
+	resumeTokenFlushInterval = time.Second
+)
+
+// ErrChangeStreamsUnsupported is returned by WatchLivePositions when the deployment can't open a change stream,
+// for example a standalone MongoDB server. Callers should fall back to polling.
+var ErrChangeStreamsUnsupported = errors.New("change streams are not supported")
+
+// LivePositionWatcher is implemented by backends that can push live position changes as they happen.
+type LivePositionWatcher interface {
+	// WatchLivePositions calls handle for every change to a driver's live position until ctx is done.
+	WatchLivePositions(ctx context.Context, handle func(models.Driver)) error
+}
+
+// LivePositionLister is implemented by backends that can list live positions changed after a point in time.
+// It is the polling fallback for backends without change notifications.
+type LivePositionLister interface {
+	// LivePositionsSince returns live positions stored strictly after since, by the server's clock, in the order
+	// they were stored. Devices' own timestamps can't be trusted to only move forward, so it doesn't key on them.
+	LivePositionsSince(ctx context.Context, since time.Time) ([]models.Driver, error)
+}
+
+// resumeTokenRecord is the document that persists a change stream's resume token.
+type resumeTokenRecord struct {
+	ID        string    `bson:"_id"`
+	Token     bson.Raw  `bson:"token"`
+	UpdatedAt time.Time `bson:"updated_at"`
+}
+
+// WatchLivePositions opens a change stream on the 'drivers' collection and calls handle for each inserted,
+// replaced or updated live position. The resume token is persisted so that a restarted process continues
+// where the previous one stopped instead of missing changes made while it was down.
+func (db *MongoDB) WatchLivePositions(ctx context.Context, handle func(models.Driver)) error {
+	database := db.client.Database(databaseName)
+	tokens := database.Collection(resumeTokensCollection)
+
+	opts := options.ChangeStream().SetFullDocument(options.UpdateLookup)
+	var saved resumeTokenRecord
+	err := tokens.FindOne(ctx, bson.M{"_id": "drivers"}).Decode(&saved)
+	switch {
+	case err == nil:
+		opts.SetResumeAfter(saved.Token)
+	case !errors.Is(err, mongo.ErrNoDocuments):
+		return fmt.Errorf("failed to load resume token: %w", err)
+	}
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"operationType": bson.M{"$in": bson.A{"insert", "replace", "update"}}}}},
+	}
+	stream, err := database.Collection("drivers").Watch(ctx, pipeline, opts)
+	if err != nil {
+		return classifyChangeStreamError(ctx, tokens, err, db.logger)
+	}
+	defer stream.Close(context.Background())
+
+	lastFlush := time.Now()
+	flush := func(ctx context.Context) {
+		record := resumeTokenRecord{ID: "drivers", Token: stream.ResumeToken(), UpdatedAt: time.Now().UTC()}
+		if record.Token == nil {
+			return
+		}
+		if _, err := tokens.ReplaceOne(ctx, bson.M{"_id": "drivers"}, record, options.Replace().SetUpsert(true)); err != nil {
+			db.logger.ErrorContext(ctx, "Failed to save change stream resume token", "error", err)
+		}
+		lastFlush = time.Now()
+	}
+	defer func() {
+		flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		flush(flushCtx)
+	}()
+
+	for stream.Next(ctx) {
+		var event struct {
+			FullDocument *driverDocument `bson:"fullDocument"`
+		}
+		if err := stream.Decode(&event); err != nil {
+			db.logger.ErrorContext(ctx, "Failed to decode live position change", "error", err)
+			continue
+		}
+		// Updates to a document deleted before the lookup have no full document.
+		if event.FullDocument != nil {
+			driver, err := db.decodeDriver(*event.FullDocument)
+			if err != nil {
+				db.logger.ErrorContext(ctx, "Failed to decode live position change", "error", err)
+				continue
+			}
+			handle(driver)
+		}
+		if time.Since(lastFlush) >= resumeTokenFlushInterval {
+			flush(ctx)
+		}
+	}
+
+	if err := stream.Err(); err != nil && ctx.Err() == nil {
+		return classifyChangeStreamError(ctx, tokens, err, db.logger)
+	}
+	return nil
+}
+
+// classifyChangeStreamError maps server errors that mean "use polling" to ErrChangeStreamsUnsupported.
+// If the saved resume token has fallen off the oplog it is discarded so the next watch starts fresh.
+func classifyChangeStreamError(ctx context.Context, tokens *mongo.Collection, err error, logger *slog.Logger) error {
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		switch {
+		case serverErr.HasErrorCode(40573): // The $changeStream stage is only supported on replica sets.
+			return fmt.Errorf("%w: %v", ErrChangeStreamsUnsupported, err)
+		case serverErr.HasErrorCode(286): // ChangeStreamHistoryLost.
+			logger.WarnContext(ctx, "Change stream resume token is no longer in the oplog; restarting from now")
+			if _, delErr := tokens.DeleteOne(ctx, bson.M{"_id": "drivers"}); delErr != nil {
+				logger.ErrorContext(ctx, "Failed to discard change stream resume token", "error", delErr)
+			}
+		}
+	}
+	return fmt.Errorf("live position change stream failed: %w", err)
+}
+
+// LivePositionsSince returns live positions in the 'drivers' collection stored after since.
+func (db *MongoDB) LivePositionsSince(ctx context.Context, since time.Time) ([]models.Driver, error) {
+	collection := db.readCollection("LivePositionsSince", "drivers")
+
+	cursor, err := collection.Find(ctx,
+		bson.M{"stored_at": bson.M{"$gt": since}},
+		options.Find().SetSort(bson.D{{Key: "stored_at", Value: 1}}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list live positions: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var docs []driverDocument
+	if err := cursor.All(ctx, &docs); err != nil {
+		return nil, fmt.Errorf("failed to list live positions: %w", err)
+	}
+	drivers := make([]models.Driver, 0, len(docs))
+	for _, doc := range docs {
+		driver, err := db.decodeDriver(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list live positions: %w", err)
+		}
+		drivers = append(drivers, driver)
+	}
+	return drivers, nil
+}
@@ -0,0 +1,229 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"locations/internal/geo"
+	"locations/internal/models"
+)
+
+// nearbyRadiusMeters is the radius of the nearby drivers query, shared with the MongoDB backend.
+const nearbyRadiusMeters = 1000
+
+// MemoryDB is an in-process Database for single-instance development and tests.
+// It mirrors the MongoDB backend's behaviour but keeps nothing across restarts.
+type MemoryDB struct {
+	mu       sync.RWMutex
+	history  []models.LocationUpdate
+	drivers  map[string]models.Driver
+	auditLog []models.PrivacyRequest
+}
+
+// NewMemoryDB creates an empty in-memory database.
+func NewMemoryDB() *MemoryDB {
+	return &MemoryDB{drivers: make(map[string]models.Driver)}
+}
+
+// InsertLocationUpdate appends a location update to the history and advances the driver's live position.
+func (m *MemoryDB) InsertLocationUpdate(ctx context.Context, update models.LocationUpdate) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	update.Version = 1
+	stored, ok := m.storedLocation(update.ID)
+	switch {
+	case !ok:
+		m.history = append(m.history, update)
+	case stored.DriverID != update.DriverID || !stored.Timestamp.Equal(update.Timestamp):
+		return fmt.Errorf("%w: %s", ErrIDConflict, update.ID)
+	}
+
+	if current, ok := m.drivers[update.DriverID]; !ok || current.UpdatedAt.Before(update.Timestamp) {
+		m.drivers[update.DriverID] = models.Driver{
+			DriverID:  update.DriverID,
+			Location:  models.NewGeoPoint(update.Latitude, update.Longitude),
+			UpdatedAt: update.Timestamp,
+			StoredAt:  time.Now().UTC(),
+			Accuracy:  update.Accuracy,
+		}
+	}
+	return nil
+}
+
+// storedLocation returns the update stored under id, if any. Updates without an ID are never matched.
+// The caller holds m.mu.
+func (m *MemoryDB) storedLocation(id string) (models.LocationUpdate, bool) {
+	if id == "" {
+		return models.LocationUpdate{}, false
+	}
+	for _, update := range m.history {
+		if update.ID == id {
+			return update, true
+		}
+	}
+	return models.LocationUpdate{}, false
+}
+
+// GetLocationByID returns the location update with the given ID.
+func (m *MemoryDB) GetLocationByID(ctx context.Context, id string) (*models.LocationUpdate, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, update := range m.history {
+		if update.ID == id {
+			return &update, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// GetDriverLocation returns the driver's live position.
+func (m *MemoryDB) GetDriverLocation(ctx context.Context, driverID string) (*models.Driver, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	driver, ok := m.drivers[driverID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &driver, nil
+}
+
+// UpdateLocation sets the location fields of the update with the given ID and increments its version.
+func (m *MemoryDB) UpdateLocation(ctx context.Context, id string, update models.LocationUpdate, expectedVersion int64) (*UpdateResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i := range m.history {
+		stored := &m.history[i]
+		if stored.ID != id {
+			continue
+		}
+		if expectedVersion != 0 && stored.Version != expectedVersion {
+			return &UpdateResult{}, ErrVersionConflict
+		}
+
+		stored.DriverID = update.DriverID
+		stored.Latitude = update.Latitude
+		stored.Longitude = update.Longitude
+		stored.Timestamp = update.Timestamp
+		stored.Accuracy = update.Accuracy
+		stored.Version++
+		return &UpdateResult{MatchedCount: 1, ModifiedCount: 1, Version: stored.Version}, nil
+	}
+	return &UpdateResult{}, ErrNotFound
+}
+
+// GetNearbyDrivers returns the drivers whose live position is within nearbyRadiusMeters of the point.
+func (m *MemoryDB) GetNearbyDrivers(ctx context.Context, latitude, longitude string) ([]models.Driver, error) {
+	lat, err := strconv.ParseFloat(latitude, 64)
+	if err != nil {
+		return nil, errors.New("invalid latitude")
+	}
+	lng, err := strconv.ParseFloat(longitude, 64)
+	if err != nil {
+		return nil, errors.New("invalid longitude")
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var nearby []models.Driver
+	for _, driver := range m.drivers {
+		if len(driver.Location.Coordinates) != 2 {
+			continue
+		}
+		if geo.DistanceMeters(lat, lng, driver.Location.Coordinates[1], driver.Location.Coordinates[0]) <= nearbyRadiusMeters {
+			nearby = append(nearby, driver)
+		}
+	}
+	sort.Slice(nearby, func(i, j int) bool { return nearby[i].DriverID < nearby[j].DriverID })
+	return nearby, nil
+}
+
+// ExportDriverData returns the driver's live position and history.
+func (m *MemoryDB) ExportDriverData(ctx context.Context, request models.PrivacyRequest) (*models.DriverDataExport, error) {
+	if request.DriverID == "" {
+		return nil, errors.New("driver ID is required")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	export := &models.DriverDataExport{
+		DriverID:   request.DriverID,
+		ExportedAt: time.Now().UTC(),
+		History:    []models.LocationUpdate{},
+	}
+	if driver, ok := m.drivers[request.DriverID]; ok {
+		export.LivePosition = &driver
+	}
+	for _, update := range m.history {
+		if update.DriverID == request.DriverID {
+			export.History = append(export.History, update)
+		}
+	}
+	sort.SliceStable(export.History, func(i, j int) bool {
+		return export.History[i].Timestamp.Before(export.History[j].Timestamp)
+	})
+
+	m.auditLog = append(m.auditLog, request)
+	return export, nil
+}
+
+// EraseDriverData removes the driver's live position and history.
+func (m *MemoryDB) EraseDriverData(ctx context.Context, request models.PrivacyRequest) (*models.DriverErasureResult, error) {
+	if request.DriverID == "" {
+		return nil, errors.New("driver ID is required")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	result := &models.DriverErasureResult{DriverID: request.DriverID, ErasedAt: time.Now().UTC()}
+	if _, ok := m.drivers[request.DriverID]; ok {
+		delete(m.drivers, request.DriverID)
+		result.LivePositionDeleted = 1
+	}
+	kept := m.history[:0]
+	for _, update := range m.history {
+		if update.DriverID == request.DriverID {
+			result.HistoryDeleted++
+			continue
+		}
+		kept = append(kept, update)
+	}
+	m.history = kept
+
+	m.auditLog = append(m.auditLog, request)
+	return result, nil
+}
+
+// Heatmap aggregates the history in process.
+func (m *MemoryDB) Heatmap(ctx context.Context, query models.HeatmapQuery) ([]models.HeatmapCell, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	return aggregateHeatmap(m.history, query), nil
+}
+
+// LivePositionsSince returns live positions stored after since, in the order they were stored.
+func (m *MemoryDB) LivePositionsSince(ctx context.Context, since time.Time) ([]models.Driver, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var drivers []models.Driver
+	for _, driver := range m.drivers {
+		if driver.StoredAt.After(since) {
+			drivers = append(drivers, driver)
+		}
+	}
+	sort.Slice(drivers, func(i, j int) bool { return drivers[i].StoredAt.Before(drivers[j].StoredAt) })
+	return drivers, nil
+}
@@ -0,0 +1,470 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"locations/internal/logging"
+)
+
+const (
+	// migrationsCollection records which migrations have been applied.
+	migrationsCollection = "migrations"
+	// migrationLockID is the _id of the lock document that serializes runners across replicas.
+	migrationLockID = "migrations_lock"
+	// migrationLockTTL bounds how long a crashed runner can hold the lock. A running runner renews it every
+	// third of that, however long its steps take.
+	migrationLockTTL = 5 * time.Minute
+	// migrationLockPoll is how often a waiting runner retries the lock.
+	migrationLockPoll = 2 * time.Second
+)
+
+// Migrator is implemented by backends that manage their own schema and indexes.
+type Migrator interface {
+	// Migrate applies every pending migration in order.
+	Migrate(ctx context.Context) error
+}
+
+// Migration is a single versioned schema or index change.
+// Versions must be unique and strictly increasing; applied versions are never run again.
+type Migration struct {
+	Version     int
+	Description string
+	Up          func(ctx context.Context, database *mongo.Database) error
+}
+
+// migrationRecord is the document stored in the migrations collection for each applied migration.
+type migrationRecord struct {
+	Version     int       `bson:"_id"`
+	Description string    `bson:"description"`
+	AppliedAt   time.Time `bson:"applied_at"`
+}
+
+// migrationLock is the lock document held while migrations run.
+type migrationLock struct {
+	ID        string    `bson:"_id"`
+	Owner     string    `bson:"owner"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+// mongoMigrations is the ordered list of migrations for the MongoDB backend.
+// Append new steps to the end; never edit or reorder a step once it has shipped.
+var mongoMigrations = []Migration{
+	{
+		Version:     1,
+		Description: "create 2dsphere index on drivers.location",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			_, err := database.Collection("drivers").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "location", Value: "2dsphere"}},
+				Options: options.Index().SetName("location_2dsphere"),
+			})
+			return err
+		},
+	},
+	{
+		Version:     2,
+		Description: "rename locations.driverid to locations.driver_id",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			// Documents written before LocationUpdate had bson tags used the driver's default field name.
+			_, err := database.Collection("locations").UpdateMany(ctx,
+				bson.M{"driverid": bson.M{"$exists": true}},
+				bson.M{"$rename": bson.M{"driverid": "driver_id"}},
+			)
+			return err
+		},
+	},
+	{
+		Version:     3,
+		Description: "create driver history indexes on locations",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			_, err := database.Collection("locations").Indexes().CreateMany(ctx, []mongo.IndexModel{
+				{
+					Keys:    bson.D{{Key: "id", Value: 1}},
+					Options: options.Index().SetName("id_1").SetSparse(true),
+				},
+				{
+					Keys:    bson.D{{Key: "driver_id", Value: 1}, {Key: "timestamp", Value: -1}},
+					Options: options.Index().SetName("driver_id_1_timestamp_-1"),
+				},
+			})
+			return err
+		},
+	},
+	{
+		Version:     4,
+		Description: "create unique index on drivers.driver_id",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			_, err := database.Collection("drivers").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "driver_id", Value: 1}},
+				Options: options.Index().SetName("driver_id_1").SetUnique(true),
+			})
+			return err
+		},
+	},
+	{
+		Version:     5,
+		Description: "backfill locations.version for optimistic concurrency",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			_, err := database.Collection("locations").UpdateMany(ctx,
+				bson.M{"version": bson.M{"$exists": false}},
+				bson.M{"$set": bson.M{"version": 1}},
+			)
+			return err
+		},
+	},
+	{
+		Version:     6,
+		Description: "create index on drivers.updated_at for live position polling",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			_, err := database.Collection("drivers").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "updated_at", Value: 1}},
+				Options: options.Index().SetName("updated_at_1"),
+			})
+			return err
+		},
+	},
+	{
+		Version:     7,
+		Description: "create index on locations.timestamp for archival",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			_, err := database.Collection("locations").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "timestamp", Value: 1}},
+				Options: options.Index().SetName("timestamp_1"),
+			})
+			return err
+		},
+	},
+	{
+		Version:     8,
+		Description: "create index on drivers.stored_at for live position polling",
+		Up: func(ctx context.Context, database *mongo.Database) error {
+			_, err := database.Collection("drivers").Indexes().CreateOne(ctx, mongo.IndexModel{
+				Keys:    bson.D{{Key: "stored_at", Value: 1}},
+				Options: options.Index().SetName("stored_at_1"),
+			})
+			return err
+		},
+	},
+	{
+		Version:     9,
+		Description: "make the index on locations.id unique",
+		Up:          uniqueLocationIDs,
+	},
+}
+
+// uniqueLocationIDs replaces the sparse id index of migration 3 with a unique one, so that GetLocationByID and
+// UpdateLocation address a single update. Copies of an update stored by consuming it again before inserts were
+// keyed on the ID are removed first. An ID held by different updates can't be resolved automatically, so the
+// migration fails listing them, before changing anything.
+func uniqueLocationIDs(ctx context.Context, database *mongo.Database) error {
+	collection := database.Collection("locations")
+	cursor, err := collection.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$match", Value: bson.M{"id": bson.M{"$type": "string"}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":     "$id",
+			"count":   bson.M{"$sum": 1},
+			"docs":    bson.M{"$push": "$_id"},
+			"drivers": bson.M{"$addToSet": "$driver_id"},
+			"times":   bson.M{"$addToSet": "$timestamp"},
+		}}},
+		{{Key: "$match", Value: bson.M{"count": bson.M{"$gt": 1}}}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return fmt.Errorf("failed to find duplicate location IDs: %w", err)
+	}
+	var duplicates []struct {
+		ID      string   `bson:"_id"`
+		Docs    []any    `bson:"docs"`
+		Drivers []string `bson:"drivers"`
+		Times   []any    `bson:"times"`
+	}
+	if err := cursor.All(ctx, &duplicates); err != nil {
+		return fmt.Errorf("failed to find duplicate location IDs: %w", err)
+	}
+
+	var copies []any
+	var conflicts []string
+	for _, duplicate := range duplicates {
+		if len(duplicate.Drivers) > 1 || len(duplicate.Times) > 1 {
+			conflicts = append(conflicts, duplicate.ID)
+			continue
+		}
+		copies = append(copies, duplicate.Docs[1:]...)
+	}
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		if len(conflicts) > 10 {
+			conflicts = append(conflicts[:10], "...")
+		}
+		return fmt.Errorf("location IDs held by different updates must be changed or removed: %s", strings.Join(conflicts, ", "))
+	}
+	if len(copies) > 0 {
+		if _, err := collection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": copies}}); err != nil {
+			return fmt.Errorf("failed to remove copies of location updates: %w", err)
+		}
+	}
+
+	// The index keeps its name, so it must be dropped before being created unique.
+	if _, err := collection.Indexes().DropOne(ctx, "id_1"); err != nil && !isIndexNotFound(err) {
+		return fmt.Errorf("failed to drop the index on locations.id: %w", err)
+	}
+	_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "id", Value: 1}},
+		// Updates published before IDs were assigned have none.
+		Options: options.Index().SetName("id_1").SetUnique(true).
+			SetPartialFilterExpression(bson.M{"id": bson.M{"$type": "string"}}),
+	})
+	return err
+}
+
+// isIndexNotFound reports whether err is MongoDB's IndexNotFound error.
+func isIndexNotFound(err error) bool {
+	var commandErr mongo.CommandError
+	return errors.As(err, &commandErr) && commandErr.Code == 27
+}
+
+// CheckLivePositionIndex fails unless the 'drivers' collection has the unique driver_id index created by
+// migration 4. upsertLivePosition relies on it: without it, consumers upserting the same driver at the same time
+// both insert, leaving the driver with two live positions. Migrations are opt-in at startup, so roles that write
+// live positions check for it instead of assuming it.
+func (db *MongoDB) CheckLivePositionIndex(ctx context.Context) error {
+	indexes, err := db.client.Database(databaseName).Collection("drivers").Indexes().ListSpecifications(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list indexes on drivers: %w", err)
+	}
+	if !HasUniqueIndex(indexes, "driver_id") {
+		return errors.New("drivers has no unique index on driver_id; run the 'migrate' command or set MONGODB_MIGRATE_ON_START=true")
+	}
+	return nil
+}
+
+// HasUniqueIndex reports whether one of indexes is a unique index on field alone.
+func HasUniqueIndex(indexes []*mongo.IndexSpecification, field string) bool {
+	for _, index := range indexes {
+		if index.Unique == nil || !*index.Unique {
+			continue
+		}
+		keys, err := index.KeysDocument.Elements()
+		if err == nil && len(keys) == 1 && keys[0].Key() == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Migrate applies every pending migration in mongoMigrations.
+// It holds a lock document for the duration of the run so that replicas starting at the same time
+// don't apply the same step twice; runners that lose the race wait for the lock and then find nothing to do.
+func (db *MongoDB) Migrate(ctx context.Context) error {
+	return RunMigrations(ctx, db.client.Database(databaseName), mongoMigrations, migrationLockTTL, db.logger)
+}
+
+// RunMigrations applies the pending steps of migrations against database, logging progress to logger, or
+// slog.Default() if it is nil. The lock it holds expires lockTTL after it was last renewed. Should the lock be
+// lost anyway, such as while the database was unreachable for longer than that, the run stops before recording
+// another step, since a runner that took the lock over may be applying the same ones.
+func RunMigrations(ctx context.Context, database *mongo.Database, migrations []Migration, lockTTL time.Duration, logger *slog.Logger) error {
+	if err := validateMigrations(migrations); err != nil {
+		return err
+	}
+	logger = logging.Or(logger)
+
+	owner := migrationOwner()
+	if err := acquireMigrationLock(ctx, database, owner, lockTTL, logger); err != nil {
+		return err
+	}
+	defer func() {
+		// Release with a fresh context so a cancelled run still frees the lock.
+		releaseCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := releaseMigrationLock(releaseCtx, database, owner); err != nil {
+			logger.Error("Failed to release migration lock", "error", err)
+		}
+	}()
+
+	// The steps run under a context that the heartbeat cancels if it finds the lock lost.
+	ctx, cancel := context.WithCancelCause(ctx)
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		renewMigrationLock(ctx, database, owner, lockTTL, cancel, logger)
+	}()
+	defer func() {
+		cancel(nil)
+		<-heartbeatDone
+	}()
+
+	applied, err := appliedMigrations(ctx, database)
+	if err != nil {
+		return err
+	}
+
+	records := database.Collection(migrationsCollection)
+	for _, migration := range migrations {
+		if applied[migration.Version] {
+			continue
+		}
+
+		logger.InfoContext(ctx, "Applying migration", "version", migration.Version, "description", migration.Description)
+		if err := migration.Up(ctx, database); err != nil {
+			if cause := context.Cause(ctx); cause != nil {
+				err = cause
+			}
+			return fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Description, err)
+		}
+
+		if err := checkMigrationLock(ctx, database, owner); err != nil {
+			return fmt.Errorf("migration %d (%s) was applied but not recorded: %w", migration.Version, migration.Description, err)
+		}
+
+		record := migrationRecord{
+			Version:     migration.Version,
+			Description: migration.Description,
+			AppliedAt:   time.Now().UTC(),
+		}
+		if _, err := records.InsertOne(ctx, record); err != nil {
+			return fmt.Errorf("failed to record migration %d: %w", migration.Version, err)
+		}
+	}
+
+	return nil
+}
+
+// validateMigrations checks that versions are positive and strictly increasing.
+func validateMigrations(migrations []Migration) error {
+	previous := 0
+	for _, migration := range migrations {
+		if migration.Version <= previous {
+			return fmt.Errorf("migration %d is out of order (previous version %d)", migration.Version, previous)
+		}
+		if migration.Up == nil {
+			return fmt.Errorf("migration %d has no Up step", migration.Version)
+		}
+		previous = migration.Version
+	}
+	return nil
+}
+
+// appliedMigrations returns the set of versions already recorded in the migrations collection.
+func appliedMigrations(ctx context.Context, database *mongo.Database) (map[int]bool, error) {
+	cursor, err := database.Collection(migrationsCollection).Find(ctx, bson.M{"_id": bson.M{"$type": "number"}})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	applied := make(map[int]bool)
+	for cursor.Next(ctx) {
+		var record migrationRecord
+		if err := cursor.Decode(&record); err != nil {
+			return nil, fmt.Errorf("failed to decode migration record: %w", err)
+		}
+		applied[record.Version] = true
+	}
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list applied migrations: %w", err)
+	}
+
+	return applied, nil
+}
+
+// acquireMigrationLock blocks until this runner holds the lock document or ctx is done.
+// The lock is taken by upserting over an expired lock; while another runner holds a live lock
+// the filter does not match and the upsert fails with a duplicate key error.
+func acquireMigrationLock(ctx context.Context, database *mongo.Database, owner string, lockTTL time.Duration, logger *slog.Logger) error {
+	collection := database.Collection(migrationsCollection)
+
+	for {
+		now := time.Now().UTC()
+		lock := migrationLock{ID: migrationLockID, Owner: owner, ExpiresAt: now.Add(lockTTL)}
+		_, err := collection.ReplaceOne(ctx,
+			bson.M{"_id": migrationLockID, "expires_at": bson.M{"$lt": now}},
+			lock,
+			options.Replace().SetUpsert(true),
+		)
+		if err == nil {
+			return nil
+		}
+		if !mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("failed to acquire migration lock: %w", err)
+		}
+
+		logger.InfoContext(ctx, "Migration lock is held by another instance, waiting")
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for migration lock: %w", ctx.Err())
+		case <-time.After(min(migrationLockPoll, lockTTL)):
+		}
+	}
+}
+
+// renewMigrationLock extends owner's lock every third of lockTTL until ctx is done. If the lock is no longer
+// owner's, it cancels ctx with errMigrationLockLost; a failed renewal is retried at the next beat.
+func renewMigrationLock(ctx context.Context, database *mongo.Database, owner string, lockTTL time.Duration, cancel context.CancelCauseFunc, logger *slog.Logger) {
+	ticker := time.NewTicker(lockTTL / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		result, err := database.Collection(migrationsCollection).UpdateOne(ctx,
+			bson.M{"_id": migrationLockID, "owner": owner},
+			bson.M{"$set": bson.M{"expires_at": time.Now().UTC().Add(lockTTL)}},
+		)
+		switch {
+		case err != nil && ctx.Err() == nil:
+			logger.WarnContext(ctx, "Failed to renew migration lock", "error", err)
+		case err == nil && result.MatchedCount == 0:
+			cancel(errMigrationLockLost)
+			return
+		}
+	}
+}
+
+// errMigrationLockLost stops a run whose lock expired and was taken by another runner.
+var errMigrationLockLost = errors.New("lost the migration lock to another instance")
+
+// checkMigrationLock fails unless owner still holds a live lock.
+func checkMigrationLock(ctx context.Context, database *mongo.Database, owner string) error {
+	err := database.Collection(migrationsCollection).FindOne(ctx,
+		bson.M{"_id": migrationLockID, "owner": owner, "expires_at": bson.M{"$gt": time.Now().UTC()}},
+	).Err()
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return errMigrationLockLost
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check migration lock: %w", err)
+	}
+	return nil
+}
+
+// releaseMigrationLock removes the lock document if it is still owned by owner.
+func releaseMigrationLock(ctx context.Context, database *mongo.Database, owner string) error {
+	_, err := database.Collection(migrationsCollection).DeleteOne(ctx, bson.M{"_id": migrationLockID, "owner": owner})
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return err
+	}
+	return nil
+}
+
+// migrationOwner identifies this process in the lock document.
+func migrationOwner() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+	return fmt.Sprintf("%s-%d-%d", hostname, os.Getpid(), time.Now().UnixNano())
+}
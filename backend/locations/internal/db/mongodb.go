@@ -1,59 +1,168 @@
 package db
 
 import (
-	"context" // Provides functionality to define a deadline or cancellation signal for operations.
-	"fmt"     // Implements formatted I/O functions.
-	"time"    // Provides functionality for measuring and displaying time.
+	"context"  // Provides functionality to define a deadline or cancellation signal for operations.
+	"errors"   // Implements functions to manipulate errors.
+	"fmt"      // Implements formatted I/O functions.
+	"log/slog" // Structured logging.
+	"strconv"  // Implements conversions to and from string representations of basic data types.
+	"time"     // Provides functionality for measuring and displaying time.
 
-	"go.mongodb.org/mongo-driver/mongo"         // Official MongoDB driver for Go.
-	"go.mongodb.org/mongo-driver/mongo/options" // Provides options to configure the MongoDB driver.
-	"locations/internal/models"                // Internal package for data models.
+	"go.mongodb.org/mongo-driver/bson"           // BSON primitives used to build filters and updates.
+	"go.mongodb.org/mongo-driver/mongo"          // Official MongoDB driver for Go.
+	"go.mongodb.org/mongo-driver/mongo/options"  // Provides options to configure the MongoDB driver.
+	"go.mongodb.org/mongo-driver/mongo/readpref" // Read preferences for routing reads to secondaries.
+	"locations/internal/fieldcrypt"              // Envelope encryption for stored coordinates.
+	"locations/internal/geo"                     // Geohash cells and distances.
+	"locations/internal/models"                  // Internal package for data models.
 )
 
+// databaseName is the MongoDB database that holds the service's collections.
+const databaseName = "database"
+
 // MongoDB wraps the official MongoDB client.
 type MongoDB struct {
 	client *mongo.Client // The client field holds the connection to the MongoDB instance.
+
+	// cipher encrypts stored coordinates when field encryption is enabled; nil stores them in plaintext.
+	cipher *fieldcrypt.Cipher
+	// coarsePrecision is the geohash precision of the queryable live position when encrypting.
+	coarsePrecision int
+	// readPreferences holds the read preference of operations routed away from the primary, by method name.
+	readPreferences map[string]*readpref.ReadPref
+	// logQueries logs where routable operations read from.
+	logQueries bool
+	// logger receives the client's logs.
+	logger *slog.Logger
 }
 
 // NewMongoDB creates a new MongoDB client and establishes a connection to the database.
 // It takes a MongoDB URI and returns a connected MongoDB instance or an error if the connection fails.
-func NewMongoDB(uri string) (*MongoDB, error) {
+func NewMongoDB(uri string, opts ...MongoOption) (*MongoDB, error) {
 	// Set client options using the provided URI.
-	clientOptions := options.Client().ApplyURI(uri)
+	// Server selection is bounded so that operations fail instead of hanging while no primary is reachable.
+	clientOptions := options.Client().ApplyURI(uri).SetServerSelectionTimeout(5 * time.Second)
+
+	// Create a context with a 10-second timeout for connecting and pinging.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
 	// Attempt to connect to MongoDB using the specified client options.
-	client, err := mongo.Connect(context.TODO(), clientOptions)
+	client, err := mongo.Connect(ctx, clientOptions)
 	if err != nil {
 		return nil, err
 	}
 
-	// Create a context with a 10-second timeout for the ping operation.
-	ctx, cancel := context.WithTimeout(context.TODO(), 10*time.Second)
-	defer cancel()
-
 	// Ping the MongoDB server to verify the connection is active.
 	err = client.Ping(ctx, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	fmt.Println("Connected to MongoDB")
-
 	// Return a new MongoDB instance with the established client.
-	return &MongoDB{client: client}, nil
+	db := &MongoDB{client: client, coarsePrecision: DefaultCoarsePrecision, logger: slog.Default()}
+	for _, opt := range opts {
+		opt(db)
+	}
+
+	db.logger.Info("Connected to MongoDB")
+	return db, nil
+}
+
+// Close disconnects the underlying MongoDB client.
+func (db *MongoDB) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	return db.client.Disconnect(ctx)
+}
+
+// Ping checks that the primary is reachable, since the consumer can't store updates without it.
+func (db *MongoDB) Ping(ctx context.Context) error {
+	return db.client.Ping(ctx, readpref.Primary())
 }
 
 // InsertLocationUpdate inserts a location update into the MongoDB database.
-// It validates the update, connects to the 'locations' collection, and inserts the update.
+// It connects to the 'locations' collection and inserts the update, unless one with its ID is already stored,
+// then records it as the driver's live position.
 func (db *MongoDB) InsertLocationUpdate(ctx context.Context, update models.LocationUpdate) error {
 	// Connect to the 'locations' collection in the 'database'.
-	collection := db.client.Database("database").Collection("locations")
+	collection := db.client.Database(databaseName).Collection("locations")
 
-	// Insert the location update into the collection.
-	_, err := collection.InsertOne(ctx, update)
+	// New documents start at version 1 so that 0 can mean "no version expected" in UpdateLocation.
+	update.Version = 1
+
+	doc, err := db.encodeLocation(update)
 	if err != nil {
+		return err
+	}
+
+	// An update with an ID is only inserted if none is stored under it yet, so that consuming it again, as replay
+	// and redrive do, doesn't duplicate history. Updates published before the producer assigned IDs have none.
+	if update.ID == "" {
+		if _, err := collection.InsertOne(ctx, doc); err != nil {
+			return fmt.Errorf("failed to insert location update: %w", err)
+		}
+		return db.upsertLivePosition(ctx, update)
+	}
+
+	// A concurrent insert of the same ID collides with the unique id index instead of matching; either way the
+	// stored update must be this one, since the ID is chosen by the client.
+	result, err := collection.UpdateOne(ctx, bson.M{"id": update.ID}, bson.M{"$setOnInsert": doc}, options.Update().SetUpsert(true))
+	switch {
+	case mongo.IsDuplicateKeyError(err) || err == nil && result.MatchedCount > 0:
+		if err := db.checkSameLocation(ctx, collection, doc); err != nil {
+			return err
+		}
+	case err != nil:
 		return fmt.Errorf("failed to insert location update: %w", err)
 	}
 
+	return db.upsertLivePosition(ctx, update)
+}
+
+// checkSameLocation returns ErrIDConflict unless the update stored under doc's ID has doc's driver and timestamp.
+func (db *MongoDB) checkSameLocation(ctx context.Context, collection *mongo.Collection, doc locationDocument) error {
+	var stored locationDocument
+	err := collection.FindOne(ctx, bson.M{"id": doc.ID},
+		options.FindOne().SetProjection(bson.M{"driver_id": 1, "timestamp": 1})).Decode(&stored)
+	if err != nil {
+		return fmt.Errorf("failed to check stored location update: %w", err)
+	}
+	// Dates are stored to the millisecond.
+	if stored.DriverID != doc.DriverID || !stored.Timestamp.Equal(doc.Timestamp.Truncate(time.Millisecond)) {
+		return fmt.Errorf("%w: %s", ErrIDConflict, doc.ID)
+	}
+	return nil
+}
+
+// upsertLivePosition records the update as the driver's live position in the 'drivers' collection,
+// unless a newer fix is already stored. Updates can arrive out of order, so the filter only matches
+// an older position; when a newer one exists the upsert collides with the unique driver_id index,
+// which is expected and ignored. Roles that store updates check that the index exists at startup;
+// see CheckLivePositionIndex.
+func (db *MongoDB) upsertLivePosition(ctx context.Context, update models.LocationUpdate) error {
+	collection := db.client.Database(databaseName).Collection("drivers")
+
+	driver, err := db.encodeDriver(models.Driver{
+		DriverID:  update.DriverID,
+		Location:  models.NewGeoPoint(update.Latitude, update.Longitude),
+		UpdatedAt: update.Timestamp,
+		StoredAt:  time.Now().UTC(),
+		Accuracy:  update.Accuracy,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = collection.ReplaceOne(ctx,
+		bson.M{"driver_id": update.DriverID, "updated_at": bson.M{"$lt": update.Timestamp}},
+		driver,
+		options.Replace().SetUpsert(true),
+	)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return fmt.Errorf("failed to update live position: %w", err)
+	}
+
 	return nil
 }
 
@@ -61,38 +170,127 @@ func (db *MongoDB) InsertLocationUpdate(ctx context.Context, update models.Locat
 // It connects to the 'locations' collection and searches for the update by ID.
 func (db *MongoDB) GetLocationByID(ctx context.Context, id string) (*models.LocationUpdate, error) {
 	// Connect to the 'locations' collection in the 'database'.
-	collection := db.client.Database("database").Collection("locations")
+	collection := db.client.Database(databaseName).Collection("locations")
 
-	var location models.LocationUpdate
-	// Find the document with the matching ID and decode it into the location variable.
-	err := collection.FindOne(ctx, bson.M{"id": id}).Decode(&location)
+	var doc locationDocument
+	// Find the document with the matching ID and decode it into the doc variable.
+	err := collection.FindOne(ctx, bson.M{"id": id}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed to retrieve location: %w: %w", ErrNotFound, err)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("failed to retrieve location: %w", err)
 	}
 
+	location, err := db.decodeLocation(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve location: %w", err)
+	}
 	return &location, nil
 }
 
+// GetDriverLocation returns the driver's live position from the 'drivers' collection.
+// It always reads from the primary: this is the "where is the driver right now" query.
+func (db *MongoDB) GetDriverLocation(ctx context.Context, driverID string) (*models.Driver, error) {
+	collection := db.client.Database(databaseName).Collection("drivers")
+
+	var doc driverDocument
+	err := collection.FindOne(ctx, bson.M{"driver_id": driverID}).Decode(&doc)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed to retrieve live position: %w: %w", ErrNotFound, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve live position: %w", err)
+	}
+
+	driver, err := db.decodeDriver(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve live position: %w", err)
+	}
+	return &driver, nil
+}
+
 // UpdateLocation updates a location update in the MongoDB database.
-// It connects to the 'locations' collection and replaces the existing document with the new update.
-func (db *MongoDB) UpdateLocation(ctx context.Context, id string, update models.LocationUpdate) error {
+// It sets the location fields of the document with the given ID and increments its version.
+// When expectedVersion is non-zero the filter also matches on the version, so a concurrent
+// writer that got there first causes ErrVersionConflict instead of being silently overwritten.
+func (db *MongoDB) UpdateLocation(ctx context.Context, id string, update models.LocationUpdate, expectedVersion int64) (*UpdateResult, error) {
 	// Connect to the 'locations' collection in the 'database'.
-	collection := db.client.Database("database").Collection("locations")
+	collection := db.client.Database(databaseName).Collection("locations")
 
-	// Replace the existing document with the new update.
-	_, err := collection.ReplaceOne(ctx, bson.M{"id": id}, update)
+	filter := bson.M{"id": id}
+	if expectedVersion != 0 {
+		filter["version"] = expectedVersion
+	}
+	doc, err := db.encodeLocation(update)
 	if err != nil {
-		return fmt.Errorf("failed to update location: %w", err)
+		return nil, err
+	}
+	set := bson.M{
+		"driver_id": update.DriverID,
+		"timestamp": update.Timestamp,
+		"accuracy":  update.Accuracy,
+	}
+	// Whichever representation isn't written is removed, so a document never holds both.
+	var unset bson.M
+	if doc.Encrypted != nil {
+		set["enc"] = doc.Encrypted
+		unset = bson.M{"latitude": "", "longitude": ""}
+	} else {
+		set["latitude"] = update.Latitude
+		set["longitude"] = update.Longitude
+		unset = bson.M{"enc": ""}
+	}
+	change := bson.M{
+		"$set":   set,
+		"$unset": unset,
+		"$inc":   bson.M{"version": 1},
 	}
 
-	return nil
+	// The updated document's version is returned so that every successful write can report its new ETag.
+	var updated struct {
+		Version int64 `bson:"version"`
+	}
+	err = collection.FindOneAndUpdate(ctx, filter, change,
+		options.FindOneAndUpdate().SetReturnDocument(options.After).SetProjection(bson.M{"version": 1}),
+	).Decode(&updated)
+	if err == nil {
+		return &UpdateResult{MatchedCount: 1, ModifiedCount: 1, Version: updated.Version}, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, fmt.Errorf("failed to update location: %w", err)
+	}
+	result := &UpdateResult{}
+
+	// Nothing matched: either the document doesn't exist or its version has moved on.
+	if expectedVersion == 0 {
+		return result, ErrNotFound
+	}
+	count, err := collection.CountDocuments(ctx, bson.M{"id": id})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update location: %w", err)
+	}
+	if count == 0 {
+		return result, ErrNotFound
+	}
+	return result, ErrVersionConflict
 }
 
 // GetNearbyDrivers retrieves nearby drivers based on the provided latitude and longitude from the MongoDB database.
 func (db *MongoDB) GetNearbyDrivers(ctx context.Context, latitude, longitude string) ([]models.Driver, error) {
 	// Connect to the 'drivers' collection in the 'database'.
 	// This establishes a connection to the specific collection where driver data is stored.
-	collection := db.client.Database("database").Collection("drivers")
+	collection := db.readCollection("GetNearbyDrivers", "drivers")
+	lat, lng := parseLatitude(latitude), parseLongitude(longitude)
+
+	// With field encryption only the coarse cell centre is indexed, so the search is widened by the cell's
+	// half-diagonal and the decrypted positions are filtered to the real radius below.
+	maxDistance := float64(nearbyRadiusMeters)
+	if db.cipher != nil {
+		bounds, _ := geo.Decode(geo.Encode(lat, lng, db.coarsePrecision))
+		centerLat, centerLng := bounds.Center()
+		maxDistance += geo.DistanceMeters(centerLat, centerLng, bounds.MaxLat, bounds.MaxLng)
+	}
 
 	// Define a filter to find nearby drivers using the provided latitude and longitude.
 	// The filter uses MongoDB's geospatial query operator '$near' to find documents (drivers)
@@ -102,9 +300,9 @@ func (db *MongoDB) GetNearbyDrivers(ctx context.Context, latitude, longitude str
 			"$near": bson.M{
 				"$geometry": bson.M{
 					"type":        "Point",
-					"coordinates": []float64{parseLongitude(longitude), parseLatitude(latitude)},
+					"coordinates": []float64{lng, lat},
 				},
-				"$maxDistance": 1000, // Maximum distance in meters.
+				"$maxDistance": maxDistance, // Maximum distance in meters.
 			},
 		},
 	}
@@ -122,10 +320,17 @@ func (db *MongoDB) GetNearbyDrivers(ctx context.Context, latitude, longitude str
 	// into the 'Driver' struct which can be used by the application.
 	var nearbyDrivers []models.Driver
 	for cursor.Next(ctx) {
-		var driver models.Driver
-		if err := cursor.Decode(&driver); err != nil {
+		var doc driverDocument
+		if err := cursor.Decode(&doc); err != nil {
 			return nil, err
 		}
+		driver, err := db.decodeDriver(doc)
+		if err != nil {
+			return nil, err
+		}
+		if doc.Encrypted != nil && geo.DistanceMeters(lat, lng, driver.Location.Coordinates[1], driver.Location.Coordinates[0]) > nearbyRadiusMeters {
+			continue
+		}
 		nearbyDrivers = append(nearbyDrivers, driver) // Add the decoded driver to the slice of nearby drivers.
 	}
 
@@ -138,7 +343,6 @@ func (db *MongoDB) GetNearbyDrivers(ctx context.Context, latitude, longitude str
 	return nearbyDrivers, nil
 }
 
-
 // Helper function to parse latitude string to float64.
 func parseLatitude(latitude string) float64 {
 	// Parse latitude string to float64.
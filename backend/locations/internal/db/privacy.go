@@ -0,0 +1,133 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"locations/internal/models"
+)
+
+// privacyAuditCollection holds one record per export or erasure of a driver's data.
+const privacyAuditCollection = "privacy_audit"
+
+// privacyAuditRecord is the document written for each data-subject request.
+type privacyAuditRecord struct {
+	Action      string    `bson:"action"`
+	DriverID    string    `bson:"driver_id"`
+	RequestedBy string    `bson:"requested_by"`
+	Reason      string    `bson:"reason,omitempty"`
+	At          time.Time `bson:"at"`
+	Documents   int64     `bson:"documents"`
+}
+
+// ExportDriverData collects the driver's live position from 'drivers' and full history from 'locations'.
+// History moved to the archive is added by archive.Database.
+// The audit record is written before the data is returned so that an export is never unaccounted for.
+func (db *MongoDB) ExportDriverData(ctx context.Context, request models.PrivacyRequest) (*models.DriverDataExport, error) {
+	if request.DriverID == "" {
+		return nil, errors.New("driver ID is required")
+	}
+
+	export := &models.DriverDataExport{
+		DriverID:   request.DriverID,
+		ExportedAt: time.Now().UTC(),
+		History:    []models.LocationUpdate{},
+	}
+
+	var liveDoc driverDocument
+	err := db.readCollection("ExportDriverData", "drivers").FindOne(ctx, bson.M{"driver_id": request.DriverID}).Decode(&liveDoc)
+	switch {
+	case err == nil:
+		live, err := db.decodeDriver(liveDoc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export live position: %w", err)
+		}
+		export.LivePosition = &live
+	case !errors.Is(err, mongo.ErrNoDocuments):
+		return nil, fmt.Errorf("failed to export live position: %w", err)
+	}
+
+	cursor, err := db.readCollection("ExportDriverData", "locations").Find(ctx,
+		bson.M{"driver_id": request.DriverID},
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: 1}}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to export location history: %w", err)
+	}
+	defer cursor.Close(ctx)
+	var history []locationDocument
+	if err := cursor.All(ctx, &history); err != nil {
+		return nil, fmt.Errorf("failed to export location history: %w", err)
+	}
+	for _, doc := range history {
+		update, err := db.decodeLocation(doc)
+		if err != nil {
+			return nil, fmt.Errorf("failed to export location history: %w", err)
+		}
+		export.History = append(export.History, update)
+	}
+
+	documents := int64(len(export.History))
+	if export.LivePosition != nil {
+		documents++
+	}
+	if err := db.recordPrivacyAudit(ctx, "export", request, documents); err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// EraseDriverData deletes the driver's history from 'locations' and live position from 'drivers'.
+// Audit records are kept: they hold the driver ID and counts but no location data. Archived history is purged
+// by archive.Database; until it is, the erase record is what keeps it from being restored; see Erasures.
+func (db *MongoDB) EraseDriverData(ctx context.Context, request models.PrivacyRequest) (*models.DriverErasureResult, error) {
+	if request.DriverID == "" {
+		return nil, errors.New("driver ID is required")
+	}
+	database := db.client.Database(databaseName)
+	filter := bson.M{"driver_id": request.DriverID}
+
+	history, err := database.Collection("locations").DeleteMany(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to erase location history: %w", err)
+	}
+	live, err := database.Collection("drivers").DeleteMany(ctx, filter)
+	if err != nil {
+		return nil, fmt.Errorf("failed to erase live position: %w", err)
+	}
+
+	result := &models.DriverErasureResult{
+		DriverID:            request.DriverID,
+		ErasedAt:            time.Now().UTC(),
+		HistoryDeleted:      history.DeletedCount,
+		LivePositionDeleted: live.DeletedCount,
+	}
+	if err := db.recordPrivacyAudit(ctx, "erase", request, history.DeletedCount+live.DeletedCount); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// recordPrivacyAudit writes an audit record for a data-subject request.
+func (db *MongoDB) recordPrivacyAudit(ctx context.Context, action string, request models.PrivacyRequest, documents int64) error {
+	record := privacyAuditRecord{
+		Action:      action,
+		DriverID:    request.DriverID,
+		RequestedBy: request.RequestedBy,
+		Reason:      request.Reason,
+		At:          time.Now().UTC(),
+		Documents:   documents,
+	}
+	if _, err := db.client.Database(databaseName).Collection(privacyAuditCollection).InsertOne(ctx, record); err != nil {
+		return fmt.Errorf("failed to record privacy audit: %w", err)
+	}
+	return nil
+}
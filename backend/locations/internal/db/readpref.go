@@ -0,0 +1,114 @@
+package db
+
+import (
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+)
+
+// MinMaxStaleness is the smallest max staleness MongoDB accepts.
+const MinMaxStaleness = 90 * time.Second
+
+// RoutableOperations are the read-only operations that may be sent to secondaries.
+// GetLocationByID is deliberately not one of them: clients read a location back after PUT to get its new
+// ETag, and a lagging secondary would hand them a stale version. Writes always go to the primary.
+var RoutableOperations = []string{"GetNearbyDrivers", "Heatmap", "ExportDriverData", "LivePositionsSince"}
+
+// ReadRoutingConfig selects which read-only operations are served by secondaries.
+type ReadRoutingConfig struct {
+	// SecondaryOperations lists the operations, by method name, read with secondaryPreferred.
+	// Operations not listed, and any not in RoutableOperations, read from the primary.
+	SecondaryOperations []string
+	// MaxStaleness bounds how far behind the primary a secondary may be to serve reads.
+	// Zero means no bound; otherwise it must be at least MinMaxStaleness.
+	MaxStaleness time.Duration
+	// LogQueries logs the read preference of every routed operation.
+	LogQueries bool
+}
+
+// DefaultReadRoutingConfig sends the heavy nearby and heatmap queries to secondaries at most 90 seconds behind.
+func DefaultReadRoutingConfig() ReadRoutingConfig {
+	return ReadRoutingConfig{
+		SecondaryOperations: []string{"GetNearbyDrivers", "Heatmap"},
+		MaxStaleness:        MinMaxStaleness,
+	}
+}
+
+// Validate checks that the configuration names only routable operations and a staleness MongoDB accepts.
+func (c ReadRoutingConfig) Validate() error {
+	for _, operation := range c.SecondaryOperations {
+		if !isRoutable(operation) {
+			return fmt.Errorf("operation %q can't be read from secondaries; routable operations are %v", operation, RoutableOperations)
+		}
+	}
+	if c.MaxStaleness != 0 && c.MaxStaleness < MinMaxStaleness {
+		return fmt.Errorf("max staleness must be at least %s, got %s", MinMaxStaleness, c.MaxStaleness)
+	}
+	return nil
+}
+
+// WithReadRouting routes the configured read-only operations to secondaries.
+// The configuration should have been checked with Validate; invalid entries are ignored.
+func WithReadRouting(config ReadRoutingConfig) MongoOption {
+	return func(db *MongoDB) {
+		var opts []readpref.Option
+		if config.MaxStaleness >= MinMaxStaleness {
+			opts = append(opts, readpref.WithMaxStaleness(config.MaxStaleness))
+		}
+		secondary := readpref.SecondaryPreferred(opts...)
+
+		db.readPreferences = make(map[string]*readpref.ReadPref)
+		for _, operation := range config.SecondaryOperations {
+			if isRoutable(operation) {
+				db.readPreferences[operation] = secondary
+			}
+		}
+		db.logQueries = config.LogQueries
+	}
+}
+
+// readCollection returns the named collection with the read preference configured for operation.
+func (db *MongoDB) readCollection(operation, name string) *mongo.Collection {
+	database := db.client.Database(databaseName)
+	preference, ok := db.readPreferences[operation]
+	if !ok {
+		if db.logQueries {
+			db.logger.Debug("MongoDB read routed", "operation", operation, "collection", name, "read_preference", readpref.PrimaryMode.String())
+		}
+		return database.Collection(name)
+	}
+
+	if db.logQueries {
+		staleness := "unbounded"
+		if maxStaleness, set := preference.MaxStaleness(); set {
+			staleness = maxStaleness.String()
+		}
+		db.logger.Debug("MongoDB read routed", "operation", operation, "collection", name, "read_preference", preference.Mode().String(), "max_staleness", staleness)
+	}
+	return database.Collection(name, options.Collection().SetReadPreference(preference))
+}
+
+// ReadRouting reports the read preference mode used by each routable operation.
+func (db *MongoDB) ReadRouting() map[string]string {
+	routing := make(map[string]string, len(RoutableOperations))
+	for _, operation := range RoutableOperations {
+		routing[operation] = readpref.PrimaryMode.String()
+		if preference, ok := db.readPreferences[operation]; ok {
+			routing[operation] = preference.Mode().String()
+		}
+	}
+	return routing
+}
+
+// isRoutable reports whether operation is in RoutableOperations.
+func isRoutable(operation string) bool {
+	for _, routable := range RoutableOperations {
+		if routable == operation {
+			return true
+		}
+	}
+	return false
+}
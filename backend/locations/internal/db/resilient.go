@@ -0,0 +1,326 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"locations/internal/models"
+	"locations/internal/resilience"
+)
+
+// ErrUnavailable is returned without calling the backend while its circuit breaker is open.
+var ErrUnavailable = errors.New("database unavailable")
+
+// ResilienceConfig tunes the resilience layer.
+type ResilienceConfig struct {
+	// Timeout is the deadline applied to each attempt of an operation.
+	Timeout time.Duration
+	// OperationTimeouts overrides Timeout for individual operations, keyed by method name.
+	OperationTimeouts map[string]time.Duration
+	// MaxAttempts bounds how many times an idempotent operation is tried.
+	MaxAttempts int
+	// RetryBackoff is the wait before the first retry; later waits double.
+	RetryBackoff time.Duration
+	// FailureThreshold is how many consecutive failures open the circuit breaker.
+	FailureThreshold int
+	// OpenDuration is how long the breaker fails fast before probing the backend again.
+	OpenDuration time.Duration
+	// Retryable classifies errors as transient backend failures. It defaults to IsTransientError.
+	Retryable func(error) bool
+}
+
+// DefaultResilienceConfig returns settings suited to a MongoDB replica set, where a failover takes a few seconds.
+func DefaultResilienceConfig() ResilienceConfig {
+	return ResilienceConfig{
+		Timeout: 5 * time.Second,
+		OperationTimeouts: map[string]time.Duration{
+			"Heatmap":          15 * time.Second,
+			"ExportDriverData": 30 * time.Second,
+			"EraseDriverData":  30 * time.Second,
+		},
+		MaxAttempts:      3,
+		RetryBackoff:     100 * time.Millisecond,
+		FailureThreshold: 5,
+		OpenDuration:     10 * time.Second,
+	}
+}
+
+// ResilienceObserver receives the outcome of every operation that passes through the resilience layer.
+type ResilienceObserver interface {
+	// ObserveOperation is called once per operation with the number of attempts made.
+	// Attempts is 0 when the breaker rejected the call.
+	ObserveOperation(operation string, attempts int, duration time.Duration, err error)
+	// ObserveBreakerState is called whenever the circuit breaker changes state.
+	ObserveBreakerState(state resilience.State)
+}
+
+// OperationStats counts the outcomes of one operation.
+type OperationStats struct {
+	Calls    int64 `json:"calls"`
+	Failures int64 `json:"failures"`
+	Retries  int64 `json:"retries"`
+	Rejected int64 `json:"rejected"`
+}
+
+// ResilientDatabase decorates a Database with per-operation deadlines, bounded retries of transient
+// failures for idempotent operations, and a circuit breaker that fails fast while the backend is down.
+type ResilientDatabase struct {
+	inner    Database
+	config   ResilienceConfig
+	breaker  *resilience.Breaker
+	observer ResilienceObserver
+
+	mu    sync.Mutex
+	stats map[string]*OperationStats
+}
+
+// NewResilientDatabase wraps inner. observer may be nil.
+func NewResilientDatabase(inner Database, config ResilienceConfig, observer ResilienceObserver) *ResilientDatabase {
+	if config.Retryable == nil {
+		config.Retryable = IsTransientError
+	}
+	r := &ResilientDatabase{
+		inner:    inner,
+		config:   config,
+		observer: observer,
+		stats:    make(map[string]*OperationStats),
+	}
+	r.breaker = resilience.NewBreaker(config.FailureThreshold, config.OpenDuration, func(from, to resilience.State) {
+		if r.observer != nil {
+			r.observer.ObserveBreakerState(to)
+		}
+	})
+	return r
+}
+
+// Unwrap returns the decorated database.
+func (r *ResilientDatabase) Unwrap() Database {
+	return r.inner
+}
+
+// BreakerState returns the circuit breaker's current state.
+func (r *ResilientDatabase) BreakerState() resilience.State {
+	return r.breaker.State()
+}
+
+// Stats returns a snapshot of per-operation counters.
+func (r *ResilientDatabase) Stats() map[string]OperationStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	snapshot := make(map[string]OperationStats, len(r.stats))
+	for operation, stats := range r.stats {
+		snapshot[operation] = *stats
+	}
+	return snapshot
+}
+
+// InsertLocationUpdate is not retried: a retry after a lost acknowledgement would store the update twice.
+func (r *ResilientDatabase) InsertLocationUpdate(ctx context.Context, update models.LocationUpdate) error {
+	return r.do(ctx, "InsertLocationUpdate", false, func(ctx context.Context) error {
+		return r.inner.InsertLocationUpdate(ctx, update)
+	})
+}
+
+// GetLocationByID is retried on transient failures.
+func (r *ResilientDatabase) GetLocationByID(ctx context.Context, id string) (*models.LocationUpdate, error) {
+	var location *models.LocationUpdate
+	err := r.do(ctx, "GetLocationByID", true, func(ctx context.Context) (err error) {
+		location, err = r.inner.GetLocationByID(ctx, id)
+		return err
+	})
+	return location, err
+}
+
+// GetDriverLocation is retried on transient failures.
+func (r *ResilientDatabase) GetDriverLocation(ctx context.Context, driverID string) (*models.Driver, error) {
+	var driver *models.Driver
+	err := r.do(ctx, "GetDriverLocation", true, func(ctx context.Context) (err error) {
+		driver, err = r.inner.GetDriverLocation(ctx, driverID)
+		return err
+	})
+	return driver, err
+}
+
+// UpdateLocation is not retried: a conditional update that succeeded but lost its acknowledgement
+// would be reported as a version conflict on retry.
+func (r *ResilientDatabase) UpdateLocation(ctx context.Context, id string, update models.LocationUpdate, expectedVersion int64) (*UpdateResult, error) {
+	var result *UpdateResult
+	err := r.do(ctx, "UpdateLocation", false, func(ctx context.Context) (err error) {
+		result, err = r.inner.UpdateLocation(ctx, id, update, expectedVersion)
+		return err
+	})
+	return result, err
+}
+
+// GetNearbyDrivers is retried on transient failures.
+func (r *ResilientDatabase) GetNearbyDrivers(ctx context.Context, latitude, longitude string) ([]models.Driver, error) {
+	var drivers []models.Driver
+	err := r.do(ctx, "GetNearbyDrivers", true, func(ctx context.Context) (err error) {
+		drivers, err = r.inner.GetNearbyDrivers(ctx, latitude, longitude)
+		return err
+	})
+	return drivers, err
+}
+
+// ExportDriverData is retried on transient failures; a retry may write a second audit record.
+func (r *ResilientDatabase) ExportDriverData(ctx context.Context, request models.PrivacyRequest) (*models.DriverDataExport, error) {
+	var export *models.DriverDataExport
+	err := r.do(ctx, "ExportDriverData", true, func(ctx context.Context) (err error) {
+		export, err = r.inner.ExportDriverData(ctx, request)
+		return err
+	})
+	return export, err
+}
+
+// EraseDriverData is retried on transient failures, since deleting twice has the same effect as once.
+func (r *ResilientDatabase) EraseDriverData(ctx context.Context, request models.PrivacyRequest) (*models.DriverErasureResult, error) {
+	var result *models.DriverErasureResult
+	err := r.do(ctx, "EraseDriverData", true, func(ctx context.Context) (err error) {
+		result, err = r.inner.EraseDriverData(ctx, request)
+		return err
+	})
+	return result, err
+}
+
+// Heatmap is retried on transient failures.
+func (r *ResilientDatabase) Heatmap(ctx context.Context, query models.HeatmapQuery) ([]models.HeatmapCell, error) {
+	var cells []models.HeatmapCell
+	err := r.do(ctx, "Heatmap", true, func(ctx context.Context) (err error) {
+		cells, err = r.inner.Heatmap(ctx, query)
+		return err
+	})
+	return cells, err
+}
+
+// do runs one operation through the breaker, deadline and retry policy.
+func (r *ResilientDatabase) do(ctx context.Context, operation string, idempotent bool, fn func(ctx context.Context) error) error {
+	start := time.Now()
+
+	if err := r.breaker.Allow(); err != nil {
+		err = fmt.Errorf("%s: %w", operation, ErrUnavailable)
+		r.record(operation, 0, time.Since(start), err, true)
+		return err
+	}
+
+	attempts := 1
+	if idempotent {
+		attempts = r.config.MaxAttempts
+	}
+	timeout := r.config.Timeout
+	if override, ok := r.config.OperationTimeouts[operation]; ok {
+		timeout = override
+	}
+
+	made := 0
+	err := resilience.Retry(ctx, attempts, r.config.RetryBackoff, r.config.Retryable, func(ctx context.Context) error {
+		made++
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+		return fn(ctx)
+	})
+
+	// A caller that gave up is not evidence that the backend is unhealthy.
+	backendFailed := err != nil && ctx.Err() == nil && r.config.Retryable(err)
+	r.breaker.Record(backendFailed)
+	r.record(operation, made, time.Since(start), err, false)
+	return err
+}
+
+// record updates the counters and notifies the observer.
+func (r *ResilientDatabase) record(operation string, attempts int, duration time.Duration, err error, rejected bool) {
+	r.mu.Lock()
+	stats, ok := r.stats[operation]
+	if !ok {
+		stats = &OperationStats{}
+		r.stats[operation] = stats
+	}
+	stats.Calls++
+	if rejected {
+		stats.Rejected++
+	}
+	if err != nil {
+		stats.Failures++
+	}
+	if attempts > 1 {
+		stats.Retries += int64(attempts - 1)
+	}
+	r.mu.Unlock()
+
+	if r.observer != nil {
+		r.observer.ObserveOperation(operation, attempts, duration, err)
+	}
+}
+
+// transientErrorCodes are MongoDB server error codes seen during elections, failovers and shutdowns.
+var transientErrorCodes = []int{
+	6,     // HostUnreachable
+	7,     // HostNotFound
+	89,    // NetworkTimeout
+	91,    // ShutdownInProgress
+	189,   // PrimarySteppedDown
+	262,   // ExceededTimeLimit
+	9001,  // SocketException
+	10107, // NotWritablePrimary
+	11600, // InterruptedAtShutdown
+	11602, // InterruptedDueToReplStateChange
+	13435, // NotPrimaryNoSecondaryOk
+	13436, // NotPrimaryOrSecondary
+}
+
+// IsTransientError reports whether err is a backend failure that may succeed if retried:
+// a per-attempt deadline, a network error, or a MongoDB failover error.
+// Application errors such as ErrNotFound, ErrVersionConflict and ErrIDConflict are never transient.
+func IsTransientError(err error) bool {
+	if err == nil || errors.Is(err, ErrNotFound) || errors.Is(err, ErrVersionConflict) || errors.Is(err, ErrIDConflict) ||
+		errors.Is(err, context.Canceled) {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) || mongo.IsTimeout(err) || mongo.IsNetworkError(err) ||
+		errors.Is(err, mongo.ErrClientDisconnected) {
+		return true
+	}
+
+	var serverErr mongo.ServerError
+	if errors.As(err, &serverErr) {
+		if serverErr.HasErrorLabel("RetryableWriteError") || serverErr.HasErrorLabel("TransientTransactionError") {
+			return true
+		}
+		for _, code := range transientErrorCodes {
+			if serverErr.HasErrorCode(code) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Unwrapper is implemented by decorators around a Database.
+type Unwrapper interface {
+	Unwrap() Database
+}
+
+// Find returns the first database in the decorator chain that implements T.
+// Use it to reach optional capabilities, such as LivePositionWatcher, that decorators don't forward.
+func Find[T any](database Database) (T, bool) {
+	for database != nil {
+		if capability, ok := database.(T); ok {
+			return capability, true
+		}
+		unwrapper, ok := database.(Unwrapper)
+		if !ok {
+			break
+		}
+		database = unwrapper.Unwrap()
+	}
+	var zero T
+	return zero, false
+}
@@ -0,0 +1,147 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"locations/internal/fieldcrypt"
+	"locations/internal/models"
+)
+
+// ErrEncryptionDisabled is returned by key rotation when no field encryption is configured.
+var ErrEncryptionDisabled = errors.New("field encryption is not configured")
+
+// RotationResult counts the documents touched by a key rotation.
+type RotationResult struct {
+	// Rewrapped documents had their data key re-encrypted under the current key.
+	Rewrapped int64 `json:"rewrapped"`
+	// Encrypted documents were stored in plaintext and are now encrypted.
+	Encrypted int64 `json:"encrypted"`
+	// Skipped documents changed while being rotated; running the rotation again picks them up.
+	Skipped int64 `json:"skipped"`
+}
+
+// KeyRotator is implemented by backends that encrypt stored coordinates.
+type KeyRotator interface {
+	// RotateEncryptionKeys brings every stored document onto the current key-encryption key.
+	RotateEncryptionKeys(ctx context.Context) (*RotationResult, error)
+}
+
+// rotation describes how to bring one stored document onto the current key.
+// It returns the fields to set and unset, and whether the document was plaintext until now.
+type rotation func(raw bson.Raw) (set, unset bson.M, encrypted bool, err error)
+
+// RotateEncryptionKeys re-wraps the data keys of documents encrypted under an older key and encrypts
+// documents written before encryption was enabled. Re-wrapping only replaces the small wrapped key, so this is
+// cheap enough to run online; once it reports nothing rotated, retired keys can be removed from the keyfile.
+func (db *MongoDB) RotateEncryptionKeys(ctx context.Context) (*RotationResult, error) {
+	if db.cipher == nil {
+		return nil, ErrEncryptionDisabled
+	}
+	database := db.client.Database(databaseName)
+	stale := bson.M{"$or": bson.A{
+		bson.M{"enc": bson.M{"$exists": false}},
+		bson.M{"enc.key_id": bson.M{"$ne": db.cipher.CurrentKeyID()}},
+	}}
+	result := &RotationResult{}
+
+	err := db.rotateCollection(ctx, database.Collection("locations"), stale, result, func(raw bson.Raw) (bson.M, bson.M, bool, error) {
+		var doc locationDocument
+		if err := bson.Unmarshal(raw, &doc); err != nil {
+			return nil, nil, false, err
+		}
+		if doc.Encrypted != nil {
+			return db.rewrap(doc.Encrypted)
+		}
+		update, err := db.decodeLocation(doc)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		encoded, err := db.encodeLocation(update)
+		if err != nil {
+			return nil, nil, false, err
+		}
+		return bson.M{"enc": encoded.Encrypted}, bson.M{"latitude": "", "longitude": ""}, true, nil
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to rotate location history: %w", err)
+	}
+
+	err = db.rotateCollection(ctx, database.Collection("drivers"), stale, result, func(raw bson.Raw) (bson.M, bson.M, bool, error) {
+		var doc driverDocument
+		if err := bson.Unmarshal(raw, &doc); err != nil {
+			return nil, nil, false, err
+		}
+		if doc.Encrypted != nil {
+			return db.rewrap(doc.Encrypted)
+		}
+		encoded, err := db.encodeDriver(models.Driver{DriverID: doc.DriverID, Location: doc.Location, UpdatedAt: doc.UpdatedAt, Accuracy: doc.Accuracy})
+		if err != nil {
+			return nil, nil, false, err
+		}
+		return bson.M{"enc": encoded.Encrypted, "location": encoded.Location}, nil, true, nil
+	})
+	if err != nil {
+		return result, fmt.Errorf("failed to rotate live positions: %w", err)
+	}
+
+	return result, nil
+}
+
+// rotateCollection applies rotate to every document matching filter.
+// Each update is conditional on the whole document being unchanged since it was read, because the ciphertext is
+// bound to the driver and timestamp; a document rewritten in the meantime is already current and is skipped.
+func (db *MongoDB) rotateCollection(ctx context.Context, collection *mongo.Collection, filter bson.M, result *RotationResult, rotate rotation) error {
+	cursor, err := collection.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cursor.Close(ctx)
+
+	for cursor.Next(ctx) {
+		set, unset, encrypted, err := rotate(cursor.Current)
+		if err != nil {
+			return err
+		}
+
+		elements, err := cursor.Current.Elements()
+		if err != nil {
+			return err
+		}
+		unchanged := bson.D{}
+		for _, element := range elements {
+			unchanged = append(unchanged, bson.E{Key: element.Key(), Value: element.Value()})
+		}
+		change := bson.M{"$set": set}
+		if len(unset) > 0 {
+			change["$unset"] = unset
+		}
+
+		res, err := collection.UpdateOne(ctx, unchanged, change)
+		if err != nil {
+			return err
+		}
+		switch {
+		case res.MatchedCount == 0:
+			result.Skipped++
+		case encrypted:
+			result.Encrypted++
+		default:
+			result.Rewrapped++
+		}
+	}
+	return cursor.Err()
+}
+
+// rewrap re-wraps an envelope's data key under the current key.
+func (db *MongoDB) rewrap(envelope *fieldcrypt.Envelope) (bson.M, bson.M, bool, error) {
+	rewrapped, _, err := db.cipher.Rewrap(envelope)
+	if err != nil {
+		return nil, nil, false, err
+	}
+	return bson.M{"enc": rewrapped}, nil, false, nil
+}
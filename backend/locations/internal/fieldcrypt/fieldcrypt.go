@@ -0,0 +1,175 @@
+package fieldcrypt
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// dataKeySize is the size of the per-document AES-256 data key.
+const dataKeySize = 32
+
+// ErrDecrypt is returned when a ciphertext can't be authenticated, for example because it was tampered with
+// or is being opened with associated data that doesn't belong to it.
+var ErrDecrypt = errors.New("fieldcrypt: decryption failed")
+
+// KeyProvider supplies key-encryption keys by ID.
+type KeyProvider interface {
+	// CurrentKeyID returns the ID of the key new data keys are wrapped with.
+	CurrentKeyID() string
+	// Key returns the 32-byte key with the given ID.
+	Key(id string) ([]byte, error)
+}
+
+// Envelope is an encrypted field value together with its wrapped data key.
+// Each envelope has its own random data key; only that data key is encrypted with the key-encryption key,
+// so rotating keys means re-wrapping the small data key rather than re-encrypting the value.
+type Envelope struct {
+	KeyID      string `json:"key_id" bson:"key_id"`
+	WrappedKey []byte `json:"dek" bson:"dek"`
+	Nonce      []byte `json:"nonce" bson:"nonce"`
+	Ciphertext []byte `json:"ct" bson:"ct"`
+}
+
+// Cipher encrypts and decrypts field values using envelope encryption with AES-256-GCM.
+type Cipher struct {
+	keys KeyProvider
+}
+
+// NewCipher creates a Cipher that takes its key-encryption keys from keys.
+func NewCipher(keys KeyProvider) *Cipher {
+	return &Cipher{keys: keys}
+}
+
+// CurrentKeyID returns the ID of the key new envelopes are wrapped with.
+func (c *Cipher) CurrentKeyID() string {
+	return c.keys.CurrentKeyID()
+}
+
+// Seal encrypts plaintext under a fresh data key. The associated data is authenticated but not stored;
+// the same value must be passed to Open, which binds the ciphertext to the document it belongs to.
+func (c *Cipher) Seal(plaintext, associatedData []byte) (*Envelope, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+
+	nonce, ciphertext, err := seal(dataKey, plaintext, associatedData)
+	if err != nil {
+		return nil, err
+	}
+
+	keyID := c.keys.CurrentKeyID()
+	wrapped, err := c.wrap(keyID, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Envelope{KeyID: keyID, WrappedKey: wrapped, Nonce: nonce, Ciphertext: ciphertext}, nil
+}
+
+// Open decrypts an envelope produced by Seal with the same associated data.
+func (c *Cipher) Open(envelope *Envelope, associatedData []byte) ([]byte, error) {
+	dataKey, err := c.unwrap(envelope.KeyID, envelope.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	return open(dataKey, envelope.Nonce, envelope.Ciphertext, associatedData)
+}
+
+// Rewrap re-encrypts the envelope's data key under the current key-encryption key.
+// It reports false, and returns the envelope unchanged, when the envelope already uses the current key.
+func (c *Cipher) Rewrap(envelope *Envelope) (*Envelope, bool, error) {
+	current := c.keys.CurrentKeyID()
+	if envelope.KeyID == current {
+		return envelope, false, nil
+	}
+
+	dataKey, err := c.unwrap(envelope.KeyID, envelope.WrappedKey)
+	if err != nil {
+		return nil, false, err
+	}
+	wrapped, err := c.wrap(current, dataKey)
+	if err != nil {
+		return nil, false, err
+	}
+
+	rewrapped := *envelope
+	rewrapped.KeyID = current
+	rewrapped.WrappedKey = wrapped
+	return &rewrapped, true, nil
+}
+
+// wrap encrypts a data key with the key-encryption key keyID. The key ID is the associated data,
+// so a wrapped key can't be passed off as belonging to another key.
+func (c *Cipher) wrap(keyID string, dataKey []byte) ([]byte, error) {
+	kek, err := c.keys.Key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	nonce, ciphertext, err := seal(kek, dataKey, []byte(keyID))
+	if err != nil {
+		return nil, err
+	}
+	return append(nonce, ciphertext...), nil
+}
+
+// unwrap decrypts a data key wrapped by wrap.
+func (c *Cipher) unwrap(keyID string, wrapped []byte) ([]byte, error) {
+	kek, err := c.keys.Key(keyID)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	return open(kek, wrapped[:gcm.NonceSize()], wrapped[gcm.NonceSize():], []byte(keyID))
+}
+
+// seal encrypts with AES-GCM under a random nonce.
+func seal(key, plaintext, associatedData []byte) (nonce, ciphertext []byte, err error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, associatedData), nil
+}
+
+// open decrypts with AES-GCM.
+func open(key, nonce, ciphertext, associatedData []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, associatedData)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
+
+// newGCM creates an AES-GCM AEAD for a 32-byte key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("fieldcrypt: key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
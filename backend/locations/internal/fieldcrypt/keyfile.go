@@ -0,0 +1,84 @@
+package fieldcrypt
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+)
+
+// keyfile is the on-disk format read by LocalKeyProvider:
+//
+//	{"current": "2024-01", "keys": {"2023-07": "<base64 32 bytes>", "2024-01": "<base64 32 bytes>"}}
+//
+// To rotate, add a new key and make it current; keep old keys until everything has been re-wrapped.
+type keyfile struct {
+	Current string            `json:"current"`
+	Keys    map[string]string `json:"keys"`
+}
+
+// LocalKeyProvider serves key-encryption keys from a JSON keyfile.
+// It is meant for development and tests; production keys belong in a KMS.
+type LocalKeyProvider struct {
+	current string
+	keys    map[string][]byte
+}
+
+// LoadKeyfile reads a keyfile from path.
+func LoadKeyfile(path string) (*LocalKeyProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyfile: %w", err)
+	}
+
+	var file keyfile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse keyfile: %w", err)
+	}
+
+	provider := &LocalKeyProvider{current: file.Current, keys: make(map[string][]byte, len(file.Keys))}
+	for id, encoded := range file.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("key %q is not valid base64: %w", id, err)
+		}
+		if len(key) != 32 {
+			return nil, fmt.Errorf("key %q must be 32 bytes, got %d", id, len(key))
+		}
+		provider.keys[id] = key
+	}
+	if _, ok := provider.keys[provider.current]; !ok {
+		return nil, fmt.Errorf("current key %q is not in the keyfile", provider.current)
+	}
+	return provider, nil
+}
+
+// NewLocalKeyProvider creates a provider from keys already in memory.
+func NewLocalKeyProvider(current string, keys map[string][]byte) *LocalKeyProvider {
+	return &LocalKeyProvider{current: current, keys: keys}
+}
+
+// GenerateKey returns a random 32-byte key.
+func GenerateKey() ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// CurrentKeyID returns the keyfile's current key ID.
+func (p *LocalKeyProvider) CurrentKeyID() string {
+	return p.current
+}
+
+// Key returns the key with the given ID.
+func (p *LocalKeyProvider) Key(id string) ([]byte, error) {
+	key, ok := p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("fieldcrypt: unknown key %q", id)
+	}
+	return key, nil
+}
@@ -0,0 +1,120 @@
+package geo
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// base32 is the geohash alphabet.
+const base32 = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+// MaxGeohashPrecision is the longest geohash the package works with.
+// At 9 characters a cell is a few metres across, which is finer than GPS accuracy.
+const MaxGeohashPrecision = 9
+
+// GridSize returns the number of latitude rows and longitude columns that geohashes of the given precision divide the world into.
+// A geohash is an interleaving of longitude and latitude bits, starting with longitude,
+// so for 5*precision bits longitude gets the extra bit when the total is odd.
+func GridSize(precision int) (latCells, lngCells int64) {
+	bits := 5 * precision
+	lngBits := (bits + 1) / 2
+	latBits := bits / 2
+	return int64(1) << latBits, int64(1) << lngBits
+}
+
+// CellIndex returns the row and column of the geohash cell containing the point.
+func CellIndex(latitude, longitude float64, precision int) (latIndex, lngIndex int64) {
+	latCells, lngCells := GridSize(precision)
+	latIndex = clampIndex(int64(math.Floor((latitude+90)/180*float64(latCells))), latCells)
+	lngIndex = clampIndex(int64(math.Floor((longitude+180)/360*float64(lngCells))), lngCells)
+	return latIndex, lngIndex
+}
+
+// CellGeohash returns the geohash of the cell at the given row and column.
+func CellGeohash(latIndex, lngIndex int64, precision int) string {
+	bits := 5 * precision
+	lngBits := (bits + 1) / 2
+	latBits := bits / 2
+
+	var hash strings.Builder
+	hash.Grow(precision)
+
+	value, n := 0, 0
+	lngBit, latBit := lngBits-1, latBits-1
+	for i := 0; i < bits; i++ {
+		value <<= 1
+		if i%2 == 0 {
+			value |= int((lngIndex >> lngBit) & 1)
+			lngBit--
+		} else {
+			value |= int((latIndex >> latBit) & 1)
+			latBit--
+		}
+		n++
+		if n == 5 {
+			hash.WriteByte(base32[value])
+			value, n = 0, 0
+		}
+	}
+	return hash.String()
+}
+
+// Encode returns the geohash of the given precision for a point.
+func Encode(latitude, longitude float64, precision int) string {
+	latIndex, lngIndex := CellIndex(latitude, longitude, precision)
+	return CellGeohash(latIndex, lngIndex, precision)
+}
+
+// Bounds is the bounding box of a geohash cell.
+type Bounds struct {
+	MinLat, MinLng, MaxLat, MaxLng float64
+}
+
+// Center returns the midpoint of the box.
+func (b Bounds) Center() (latitude, longitude float64) {
+	return (b.MinLat + b.MaxLat) / 2, (b.MinLng + b.MaxLng) / 2
+}
+
+// Decode returns the bounding box of a geohash.
+func Decode(hash string) (Bounds, error) {
+	bounds := Bounds{MinLat: -90, MaxLat: 90, MinLng: -180, MaxLng: 180}
+	even := true
+	for _, c := range hash {
+		value := strings.IndexRune(base32, c)
+		if value < 0 {
+			return Bounds{}, fmt.Errorf("invalid geohash character %q", c)
+		}
+		for bit := 4; bit >= 0; bit-- {
+			set := (value>>bit)&1 == 1
+			if even {
+				mid := (bounds.MinLng + bounds.MaxLng) / 2
+				if set {
+					bounds.MinLng = mid
+				} else {
+					bounds.MaxLng = mid
+				}
+			} else {
+				mid := (bounds.MinLat + bounds.MaxLat) / 2
+				if set {
+					bounds.MinLat = mid
+				} else {
+					bounds.MaxLat = mid
+				}
+			}
+			even = !even
+		}
+	}
+	return bounds, nil
+}
+
+// clampIndex keeps points on the north pole or antimeridian inside the last cell.
+func clampIndex(index, cells int64) int64 {
+	if index < 0 {
+		return 0
+	}
+	if index >= cells {
+		return cells - 1
+	}
+	return index
+}
@@ -0,0 +1,85 @@
+package grpc
+
+import (
+	"context"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"locations/internal/auth"
+)
+
+// UnaryAuthInterceptor only lets calls with a valid token from the users service, in the authorization
+// metadata as "Bearer <token>", through to the handler, with the token's claims in the context for authorize.
+func UnaryAuthInterceptor(verifier *auth.Verifier) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, verifier)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamAuthInterceptor is UnaryAuthInterceptor for streaming calls.
+func StreamAuthInterceptor(verifier *auth.Verifier) grpc.StreamServerInterceptor {
+	return func(srv any, stream grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(stream.Context(), verifier)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: stream, ctx: ctx})
+	}
+}
+
+// authenticatedStream is a server stream whose context carries the caller's claims.
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+// authenticate verifies the call's bearer token and returns ctx with its claims.
+func authenticate(ctx context.Context, verifier *auth.Verifier) (context.Context, error) {
+	var header string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if values := md.Get("authorization"); len(values) > 0 {
+			header = values[0]
+		}
+	}
+	token, ok := strings.CutPrefix(header, "Bearer ")
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "a bearer token is required")
+	}
+	claims, err := verifier.Verify(token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "the bearer token is invalid or expired")
+	}
+	return auth.NewContext(ctx, claims), nil
+}
+
+// authorize fails with PermissionDenied unless the caller's claims satisfy allowed. Calls to a server without
+// the auth interceptors carry no claims and are always allowed, as in the HTTP API.
+func authorize(ctx context.Context, allowed func(*auth.Claims) bool) error {
+	claims, ok := auth.FromContext(ctx)
+	if !ok || allowed(claims) {
+		return nil
+	}
+	return status.Error(codes.PermissionDenied, "the token does not allow this call")
+}
+
+// isOps allows operations staff.
+func isOps(claims *auth.Claims) bool {
+	return claims.HasRole(auth.RoleOps)
+}
+
+// isDriverApp allows any driver; which driver IDs they may report is checked per update.
+func isDriverApp(claims *auth.Claims) bool {
+	return claims.HasRole(auth.RoleDriver)
+}
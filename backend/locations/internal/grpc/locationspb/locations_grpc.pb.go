@@ -0,0 +1,331 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v4.24.4
+// source: locations/v1/locations.proto
+
+package locationspb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	LocationService_ReportLocation_FullMethodName    = "/locations.v1.LocationService/ReportLocation"
+	LocationService_ReportLocations_FullMethodName   = "/locations.v1.LocationService/ReportLocations"
+	LocationService_GetDriverLocation_FullMethodName = "/locations.v1.LocationService/GetDriverLocation"
+	LocationService_FindNearbyDrivers_FullMethodName = "/locations.v1.LocationService/FindNearbyDrivers"
+	LocationService_WatchDriver_FullMethodName       = "/locations.v1.LocationService/WatchDriver"
+)
+
+// LocationServiceClient is the client API for LocationService service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type LocationServiceClient interface {
+	// ReportLocation publishes one location update.
+	ReportLocation(ctx context.Context, in *LocationUpdate, opts ...grpc.CallOption) (*ReportLocationResponse, error)
+	// ReportLocations publishes a stream of location updates, keeping their order per driver.
+	// Invalid updates are skipped and reported in the response rather than failing the stream.
+	ReportLocations(ctx context.Context, opts ...grpc.CallOption) (LocationService_ReportLocationsClient, error)
+	// GetDriverLocation returns the driver's latest known position, or NOT_FOUND.
+	GetDriverLocation(ctx context.Context, in *GetDriverLocationRequest, opts ...grpc.CallOption) (*DriverPosition, error)
+	// FindNearbyDrivers returns the drivers near a point.
+	FindNearbyDrivers(ctx context.Context, in *FindNearbyDriversRequest, opts ...grpc.CallOption) (*FindNearbyDriversResponse, error)
+	// WatchDriver streams the driver's current position followed by every change, until the caller cancels.
+	WatchDriver(ctx context.Context, in *WatchDriverRequest, opts ...grpc.CallOption) (LocationService_WatchDriverClient, error)
+}
+
+type locationServiceClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewLocationServiceClient(cc grpc.ClientConnInterface) LocationServiceClient {
+	return &locationServiceClient{cc}
+}
+
+func (c *locationServiceClient) ReportLocation(ctx context.Context, in *LocationUpdate, opts ...grpc.CallOption) (*ReportLocationResponse, error) {
+	out := new(ReportLocationResponse)
+	err := c.cc.Invoke(ctx, LocationService_ReportLocation_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *locationServiceClient) ReportLocations(ctx context.Context, opts ...grpc.CallOption) (LocationService_ReportLocationsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LocationService_ServiceDesc.Streams[0], LocationService_ReportLocations_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &locationServiceReportLocationsClient{stream}
+	return x, nil
+}
+
+type LocationService_ReportLocationsClient interface {
+	Send(*LocationUpdate) error
+	CloseAndRecv() (*ReportLocationsResponse, error)
+	grpc.ClientStream
+}
+
+type locationServiceReportLocationsClient struct {
+	grpc.ClientStream
+}
+
+func (x *locationServiceReportLocationsClient) Send(m *LocationUpdate) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *locationServiceReportLocationsClient) CloseAndRecv() (*ReportLocationsResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(ReportLocationsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *locationServiceClient) GetDriverLocation(ctx context.Context, in *GetDriverLocationRequest, opts ...grpc.CallOption) (*DriverPosition, error) {
+	out := new(DriverPosition)
+	err := c.cc.Invoke(ctx, LocationService_GetDriverLocation_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *locationServiceClient) FindNearbyDrivers(ctx context.Context, in *FindNearbyDriversRequest, opts ...grpc.CallOption) (*FindNearbyDriversResponse, error) {
+	out := new(FindNearbyDriversResponse)
+	err := c.cc.Invoke(ctx, LocationService_FindNearbyDrivers_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *locationServiceClient) WatchDriver(ctx context.Context, in *WatchDriverRequest, opts ...grpc.CallOption) (LocationService_WatchDriverClient, error) {
+	stream, err := c.cc.NewStream(ctx, &LocationService_ServiceDesc.Streams[1], LocationService_WatchDriver_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &locationServiceWatchDriverClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type LocationService_WatchDriverClient interface {
+	Recv() (*DriverPosition, error)
+	grpc.ClientStream
+}
+
+type locationServiceWatchDriverClient struct {
+	grpc.ClientStream
+}
+
+func (x *locationServiceWatchDriverClient) Recv() (*DriverPosition, error) {
+	m := new(DriverPosition)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// LocationServiceServer is the server API for LocationService service.
+// All implementations must embed UnimplementedLocationServiceServer
+// for forward compatibility
+type LocationServiceServer interface {
+	// ReportLocation publishes one location update.
+	ReportLocation(context.Context, *LocationUpdate) (*ReportLocationResponse, error)
+	// ReportLocations publishes a stream of location updates, keeping their order per driver.
+	// Invalid updates are skipped and reported in the response rather than failing the stream.
+	ReportLocations(LocationService_ReportLocationsServer) error
+	// GetDriverLocation returns the driver's latest known position, or NOT_FOUND.
+	GetDriverLocation(context.Context, *GetDriverLocationRequest) (*DriverPosition, error)
+	// FindNearbyDrivers returns the drivers near a point.
+	FindNearbyDrivers(context.Context, *FindNearbyDriversRequest) (*FindNearbyDriversResponse, error)
+	// WatchDriver streams the driver's current position followed by every change, until the caller cancels.
+	WatchDriver(*WatchDriverRequest, LocationService_WatchDriverServer) error
+	mustEmbedUnimplementedLocationServiceServer()
+}
+
+// UnimplementedLocationServiceServer must be embedded to have forward compatible implementations.
+type UnimplementedLocationServiceServer struct {
+}
+
+func (UnimplementedLocationServiceServer) ReportLocation(context.Context, *LocationUpdate) (*ReportLocationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ReportLocation not implemented")
+}
+func (UnimplementedLocationServiceServer) ReportLocations(LocationService_ReportLocationsServer) error {
+	return status.Errorf(codes.Unimplemented, "method ReportLocations not implemented")
+}
+func (UnimplementedLocationServiceServer) GetDriverLocation(context.Context, *GetDriverLocationRequest) (*DriverPosition, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetDriverLocation not implemented")
+}
+func (UnimplementedLocationServiceServer) FindNearbyDrivers(context.Context, *FindNearbyDriversRequest) (*FindNearbyDriversResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method FindNearbyDrivers not implemented")
+}
+func (UnimplementedLocationServiceServer) WatchDriver(*WatchDriverRequest, LocationService_WatchDriverServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchDriver not implemented")
+}
+func (UnimplementedLocationServiceServer) mustEmbedUnimplementedLocationServiceServer() {}
+
+// UnsafeLocationServiceServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to LocationServiceServer will
+// result in compilation errors.
+type UnsafeLocationServiceServer interface {
+	mustEmbedUnimplementedLocationServiceServer()
+}
+
+func RegisterLocationServiceServer(s grpc.ServiceRegistrar, srv LocationServiceServer) {
+	s.RegisterService(&LocationService_ServiceDesc, srv)
+}
+
+func _LocationService_ReportLocation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(LocationUpdate)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LocationServiceServer).ReportLocation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LocationService_ReportLocation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LocationServiceServer).ReportLocation(ctx, req.(*LocationUpdate))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LocationService_ReportLocations_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(LocationServiceServer).ReportLocations(&locationServiceReportLocationsServer{stream})
+}
+
+type LocationService_ReportLocationsServer interface {
+	SendAndClose(*ReportLocationsResponse) error
+	Recv() (*LocationUpdate, error)
+	grpc.ServerStream
+}
+
+type locationServiceReportLocationsServer struct {
+	grpc.ServerStream
+}
+
+func (x *locationServiceReportLocationsServer) SendAndClose(m *ReportLocationsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *locationServiceReportLocationsServer) Recv() (*LocationUpdate, error) {
+	m := new(LocationUpdate)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _LocationService_GetDriverLocation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetDriverLocationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LocationServiceServer).GetDriverLocation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LocationService_GetDriverLocation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LocationServiceServer).GetDriverLocation(ctx, req.(*GetDriverLocationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LocationService_FindNearbyDrivers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(FindNearbyDriversRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(LocationServiceServer).FindNearbyDrivers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: LocationService_FindNearbyDrivers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(LocationServiceServer).FindNearbyDrivers(ctx, req.(*FindNearbyDriversRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _LocationService_WatchDriver_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchDriverRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(LocationServiceServer).WatchDriver(m, &locationServiceWatchDriverServer{stream})
+}
+
+type LocationService_WatchDriverServer interface {
+	Send(*DriverPosition) error
+	grpc.ServerStream
+}
+
+type locationServiceWatchDriverServer struct {
+	grpc.ServerStream
+}
+
+func (x *locationServiceWatchDriverServer) Send(m *DriverPosition) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// LocationService_ServiceDesc is the grpc.ServiceDesc for LocationService service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var LocationService_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "locations.v1.LocationService",
+	HandlerType: (*LocationServiceServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ReportLocation",
+			Handler:    _LocationService_ReportLocation_Handler,
+		},
+		{
+			MethodName: "GetDriverLocation",
+			Handler:    _LocationService_GetDriverLocation_Handler,
+		},
+		{
+			MethodName: "FindNearbyDrivers",
+			Handler:    _LocationService_FindNearbyDrivers_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ReportLocations",
+			Handler:       _LocationService_ReportLocations_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "WatchDriver",
+			Handler:       _LocationService_WatchDriver_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "locations/v1/locations.proto",
+}
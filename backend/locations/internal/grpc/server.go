@@ -0,0 +1,344 @@
+package grpc
+
+//go:generate protoc -I ../../proto --go_out=../.. --go_opt=module=locations --go-grpc_out=../.. --go-grpc_opt=module=locations ../../proto/locations/v1/locations.proto
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"locations/internal/auth"
+	"locations/internal/db"
+	"locations/internal/grpc/locationspb"
+	"locations/internal/live"
+	"locations/internal/logging"
+	"locations/internal/models"
+	"locations/internal/producer"
+	"locations/internal/ratelimit"
+)
+
+const (
+	// staleAfter is the age beyond which a driver's position is flagged as stale, as in the HTTP API.
+	staleAfter = 2 * time.Minute
+	// reportBatchSize is how many streamed updates are published to Kafka in one write.
+	reportBatchSize = 100
+	// shutdownTimeout bounds how long in-flight RPCs get to finish before the server stops them.
+	shutdownTimeout = 5 * time.Second
+)
+
+// Server implements locationspb.LocationServiceServer on top of the Kafka producer, the database and the
+// live position hub that the HTTP API uses. Reports are rate limited like HTTP ingestion. Calls are authorized
+// like their HTTP counterparts when the auth interceptors from ServerOptions put the caller's claims in the context.
+type Server struct {
+	locationspb.UnimplementedLocationServiceServer
+
+	kafkaProducer producer.LocationProducer
+	database      db.Database
+	hub           *live.Hub
+	limits        *ratelimit.Policy
+}
+
+// NewServer creates a Server. A nil limits leaves ingestion unlimited.
+func NewServer(kafkaProducer producer.LocationProducer, database db.Database, hub *live.Hub, limits *ratelimit.Policy) *Server {
+	return &Server{kafkaProducer: kafkaProducer, database: database, hub: hub, limits: limits}
+}
+
+// ReportLocation validates and publishes one location update.
+// Calls beyond the client's or the driver's rate limit fail with ResourceExhausted.
+// Drivers may only report their own position.
+func (s *Server) ReportLocation(ctx context.Context, req *locationspb.LocationUpdate) (*locationspb.ReportLocationResponse, error) {
+	if err := authorize(ctx, isDriverApp); err != nil {
+		return nil, err
+	}
+	if decision := s.limits.AllowClient(ctx, peerAddr(ctx)); !decision.Allowed {
+		return nil, rateLimitError(decision)
+	}
+	update := fromProtoUpdate(req)
+	if err := update.Validate(); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := authorize(ctx, func(claims *auth.Claims) bool { return claims.IsDriver(update.DriverID) }); err != nil {
+		return nil, err
+	}
+	if decision := s.limits.AllowDriver(ctx, update.DriverID); !decision.Allowed {
+		return nil, rateLimitError(decision)
+	}
+	if err := s.kafkaProducer.ProduceLocationUpdate(ctx, update); err != nil {
+		return nil, status.Error(codes.Unavailable, "failed to produce Kafka message")
+	}
+	return &locationspb.ReportLocationResponse{}, nil
+}
+
+// ReportLocations publishes a stream of updates in batches of reportBatchSize, in the order received.
+// Invalid updates are skipped and listed in the response. If publishing fails the stream is aborted;
+// the caller can't tell which of the updates since the last response were published, so it should resend them.
+// A stream counts against the client's rate limit once and every update against its driver's; updates over
+// the driver's limit are rejected, as are updates of drivers other than the caller.
+func (s *Server) ReportLocations(stream locationspb.LocationService_ReportLocationsServer) error {
+	if err := authorize(stream.Context(), isDriverApp); err != nil {
+		return err
+	}
+	claims, authenticated := auth.FromContext(stream.Context())
+	if decision := s.limits.AllowClient(stream.Context(), peerAddr(stream.Context())); !decision.Allowed {
+		return rateLimitError(decision)
+	}
+	response := &locationspb.ReportLocationsResponse{}
+	batch := make([]models.LocationUpdate, 0, reportBatchSize)
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := s.kafkaProducer.ProduceLocationUpdates(stream.Context(), batch); err != nil {
+			return status.Error(codes.Unavailable, "failed to produce Kafka messages")
+		}
+		response.Accepted += int64(len(batch))
+		batch = batch[:0]
+		return nil
+	}
+
+	for index := int64(0); ; index++ {
+		req, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			if err := flush(); err != nil {
+				return err
+			}
+			return stream.SendAndClose(response)
+		}
+		if err != nil {
+			return err
+		}
+
+		update := fromProtoUpdate(req)
+		if err := update.Validate(); err != nil {
+			response.Rejected = append(response.Rejected, &locationspb.RejectedUpdate{Index: index, Error: err.Error()})
+			continue
+		}
+		if authenticated && !claims.IsDriver(update.DriverID) {
+			response.Rejected = append(response.Rejected, &locationspb.RejectedUpdate{Index: index, Error: "driver ID does not match the token"})
+			continue
+		}
+		if decision := s.limits.AllowDriver(stream.Context(), update.DriverID); !decision.Allowed {
+			response.Rejected = append(response.Rejected, &locationspb.RejectedUpdate{Index: index, Error: "rate limit exceeded"})
+			continue
+		}
+		batch = append(batch, update)
+		if len(batch) == reportBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// GetDriverLocation returns the driver's live position to callers who may see the driver.
+func (s *Server) GetDriverLocation(ctx context.Context, req *locationspb.GetDriverLocationRequest) (*locationspb.DriverPosition, error) {
+	if req.DriverId == "" {
+		return nil, status.Error(codes.InvalidArgument, "driver ID is required")
+	}
+	if err := authorize(ctx, func(claims *auth.Claims) bool { return claims.CanReadDriver(req.DriverId) }); err != nil {
+		return nil, err
+	}
+	driver, err := s.database.GetDriverLocation(ctx, req.DriverId)
+	if err != nil {
+		return nil, databaseError(err, "failed to get driver location")
+	}
+	return toProtoPosition(*driver, time.Now().UTC()), nil
+}
+
+// FindNearbyDrivers returns the drivers near the requested point. It is for operations staff.
+func (s *Server) FindNearbyDrivers(ctx context.Context, req *locationspb.FindNearbyDriversRequest) (*locationspb.FindNearbyDriversResponse, error) {
+	if err := authorize(ctx, isOps); err != nil {
+		return nil, err
+	}
+	if req.Latitude < -90 || req.Latitude > 90 || req.Longitude < -180 || req.Longitude > 180 {
+		return nil, status.Error(codes.InvalidArgument, "latitude must be between -90 and 90 and longitude between -180 and 180")
+	}
+	drivers, err := s.database.GetNearbyDrivers(ctx,
+		strconv.FormatFloat(req.Latitude, 'f', -1, 64),
+		strconv.FormatFloat(req.Longitude, 'f', -1, 64),
+	)
+	if err != nil {
+		return nil, databaseError(err, "failed to retrieve nearby drivers")
+	}
+
+	now := time.Now().UTC()
+	response := &locationspb.FindNearbyDriversResponse{Drivers: make([]*locationspb.DriverPosition, 0, len(drivers))}
+	for _, driver := range drivers {
+		response.Drivers = append(response.Drivers, toProtoPosition(driver, now))
+	}
+	return response, nil
+}
+
+// WatchDriver sends the driver's current position, if known, and then every newer one from the hub.
+// The stream ends when the caller cancels or the server shuts down. The caller must be allowed to see the driver.
+func (s *Server) WatchDriver(req *locationspb.WatchDriverRequest, stream locationspb.LocationService_WatchDriverServer) error {
+	if req.DriverId == "" {
+		return status.Error(codes.InvalidArgument, "driver ID is required")
+	}
+	ctx := stream.Context()
+	if err := authorize(ctx, func(claims *auth.Claims) bool { return claims.CanReadDriver(req.DriverId) }); err != nil {
+		return err
+	}
+
+	// Subscribe before reading the current position so that nothing in between is missed.
+	sub := s.hub.Subscribe(req.DriverId)
+	defer sub.Close()
+
+	driver, err := s.database.GetDriverLocation(ctx, req.DriverId)
+	switch {
+	case err == nil:
+		if err := stream.Send(toProtoPosition(*driver, time.Now().UTC())); err != nil {
+			return err
+		}
+	case !errors.Is(err, db.ErrNotFound):
+		return databaseError(err, "failed to get driver location")
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case driver, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.Unavailable, "server shutting down")
+			}
+			if err := stream.Send(toProtoPosition(driver, time.Now().UTC())); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// RunGRPCServer serves the LocationService on addr until ctx is canceled, then stops gracefully:
+// new calls are refused and in-flight ones get shutdownTimeout to finish before they are cut off, after which it
+// returns nil. It logs to logger; nil logs to slog.Default(). options configure the server, such as its
+// transport credentials and interceptors.
+func RunGRPCServer(ctx context.Context, addr string, kafkaProducer producer.LocationProducer, database db.Database, hub *live.Hub, limits *ratelimit.Policy, logger *slog.Logger, options ...grpc.ServerOption) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	server := grpc.NewServer(options...)
+	locationspb.RegisterLocationServiceServer(server, NewServer(kafkaProducer, database, hub, limits))
+
+	shutdown := make(chan struct{})
+	go func() {
+		defer close(shutdown)
+		<-ctx.Done()
+		stopped := make(chan struct{})
+		go func() {
+			server.GracefulStop()
+			close(stopped)
+		}()
+		select {
+		case <-stopped:
+		case <-time.After(shutdownTimeout):
+			// Streams such as WatchDriver only end when their caller cancels, so don't wait for them forever.
+			server.Stop()
+		}
+	}()
+
+	logging.Or(logger).Info("gRPC server listening", "addr", addr)
+	if err := server.Serve(listener); err != nil {
+		return err
+	}
+	// Serve returns as soon as the listener closes; wait for the calls in flight.
+	<-shutdown
+	return nil
+}
+
+// ServerOptions returns the options RunGRPCServer takes: with certFile and keyFile, PEM files of the server's
+// certificate and key, the server serves TLS; without them, plaintext. With a verifier every call needs a token
+// from the users service; a nil verifier leaves the API open, for development setups without the users service.
+func ServerOptions(certFile, keyFile string, verifier *auth.Verifier) ([]grpc.ServerOption, error) {
+	var options []grpc.ServerOption
+	if verifier != nil {
+		options = append(options,
+			grpc.ChainUnaryInterceptor(UnaryAuthInterceptor(verifier)),
+			grpc.ChainStreamInterceptor(StreamAuthInterceptor(verifier)),
+		)
+	}
+	if certFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+		}
+		options = append(options, grpc.Creds(creds))
+	}
+	return options, nil
+}
+
+// databaseError maps a database error to a gRPC status. Transient errors still failing once retries are spent
+// are Unavailable, like an open circuit breaker, so that clients retry.
+func databaseError(err error, message string) error {
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		return status.Error(codes.NotFound, "driver not found")
+	case errors.Is(err, db.ErrUnavailable), errors.Is(err, context.DeadlineExceeded), db.IsTransientError(err):
+		return status.Error(codes.Unavailable, message)
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, message)
+	default:
+		return status.Error(codes.Internal, message)
+	}
+}
+
+// rateLimitError is the status for a call over its rate limit. gRPC has no Retry-After, so the wait is in the message.
+func rateLimitError(decision ratelimit.Decision) error {
+	return status.Errorf(codes.ResourceExhausted, "rate limit exceeded, retry after %s", decision.RetryAfter.Round(time.Millisecond))
+}
+
+// peerAddr returns the caller's IP address, which clients are rate limited by.
+func peerAddr(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// fromProtoUpdate converts a protobuf location update to the model.
+func fromProtoUpdate(req *locationspb.LocationUpdate) models.LocationUpdate {
+	update := models.LocationUpdate{
+		DriverID:  req.DriverId,
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		Accuracy:  req.Accuracy,
+	}
+	if req.Timestamp != nil {
+		update.Timestamp = req.Timestamp.AsTime()
+	}
+	return update
+}
+
+// toProtoPosition converts a live position to its protobuf form as of now.
+func toProtoPosition(driver models.Driver, now time.Time) *locationspb.DriverPosition {
+	position := models.NewDriverPosition(driver, now, staleAfter)
+	return &locationspb.DriverPosition{
+		DriverId:   position.DriverID,
+		Latitude:   position.Latitude,
+		Longitude:  position.Longitude,
+		Accuracy:   position.Accuracy,
+		UpdatedAt:  timestamppb.New(position.UpdatedAt),
+		AgeSeconds: position.AgeSeconds,
+		Stale:      position.Stale,
+	}
+}
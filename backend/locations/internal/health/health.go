@@ -0,0 +1,220 @@
+// Package health checks the service's dependencies for readiness probes and the status page.
+package health
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout bounds each check, so that one hanging dependency can't stall a probe.
+const DefaultTimeout = 2 * time.Second
+
+// DefaultCacheTTL is how long the service reuses a report, so that frequent probes, which need no token,
+// don't ping every dependency on each request.
+const DefaultCacheTTL = 2 * time.Second
+
+// Build information, set at build time with
+// -ldflags "-X locations/internal/health.Version=1.2.3 -X locations/internal/health.Commit=abc123".
+// The commit and build time fall back to the VCS stamp Go embeds in the binary.
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildTime = ""
+)
+
+// Status is the state of a dependency or of the service as a whole.
+type Status string
+
+const (
+	StatusUp   Status = "up"
+	StatusDown Status = "down"
+)
+
+// Check reports whether a dependency is usable, with details for the status page.
+type Check func(ctx context.Context) (details map[string]any, err error)
+
+// Ping adapts a function that only reports an error, such as a database ping, to a Check.
+func Ping(ping func(ctx context.Context) error) Check {
+	return func(ctx context.Context) (map[string]any, error) {
+		return nil, ping(ctx)
+	}
+}
+
+// Result is the outcome of one check.
+type Result struct {
+	Status    Status         `json:"status"`
+	LatencyMs float64        `json:"latency_ms"`
+	Error     string         `json:"error,omitempty"`
+	Details   map[string]any `json:"details,omitempty"`
+}
+
+// BuildInfo identifies the running binary.
+type BuildInfo struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit,omitempty"`
+	BuildTime string `json:"build_time,omitempty"`
+	GoVersion string `json:"go_version"`
+}
+
+// Report is the outcome of every check. The service is up only when every dependency is.
+type Report struct {
+	Status        Status            `json:"status"`
+	Checks        map[string]Result `json:"checks"`
+	Build         BuildInfo         `json:"build"`
+	StartedAt     time.Time         `json:"started_at"`
+	UptimeSeconds float64           `json:"uptime_seconds"`
+}
+
+// Checker runs the registered checks.
+type Checker struct {
+	mu      sync.Mutex
+	checks  map[string]Check
+	timeout time.Duration
+	started time.Time
+	build   BuildInfo
+
+	// cacheTTL is how long a report is reused; 0 runs the checks on every call. refresh lets one caller at a
+	// time run them, so that the others wait for its report instead of checking too.
+	cacheTTL  time.Duration
+	now       func() time.Time
+	refresh   sync.Mutex
+	cached    *Report
+	checkedAt time.Time
+}
+
+// NewChecker creates a Checker with no checks, bounding each by DefaultTimeout. It runs the checks on every call.
+func NewChecker() *Checker {
+	return NewCachingCheckerWithClock(0, time.Now)
+}
+
+// NewCachingChecker creates a Checker like NewChecker that reuses each report for ttl.
+func NewCachingChecker(ttl time.Duration) *Checker {
+	return NewCachingCheckerWithClock(ttl, time.Now)
+}
+
+// NewCachingCheckerWithClock creates a caching Checker that reads the time from now, for tests.
+func NewCachingCheckerWithClock(ttl time.Duration, now func() time.Time) *Checker {
+	return &Checker{
+		checks:   make(map[string]Check),
+		timeout:  DefaultTimeout,
+		started:  now().UTC(),
+		build:    Build(),
+		cacheTTL: ttl,
+		now:      now,
+	}
+}
+
+// Register adds a check under name, replacing any check already registered under it.
+func (c *Checker) Register(name string, check Check) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.checks[name] = check
+	c.cached = nil
+}
+
+// Check runs every check concurrently and reports on all of them, or returns the last report if it is
+// younger than the cache TTL. A report cut short because ctx ended is not reused.
+func (c *Checker) Check(ctx context.Context) Report {
+	if c.cacheTTL <= 0 {
+		return c.checkAll(ctx)
+	}
+	c.refresh.Lock()
+	defer c.refresh.Unlock()
+
+	c.mu.Lock()
+	cached, checkedAt := c.cached, c.checkedAt
+	c.mu.Unlock()
+	now := c.now()
+	if cached != nil && now.Sub(checkedAt) < c.cacheTTL {
+		report := *cached
+		report.UptimeSeconds = now.Sub(c.started).Seconds()
+		return report
+	}
+
+	report := c.checkAll(ctx)
+	if ctx.Err() == nil {
+		c.mu.Lock()
+		c.cached, c.checkedAt = &report, now
+		c.mu.Unlock()
+	}
+	return report
+}
+
+// checkAll runs every check concurrently.
+func (c *Checker) checkAll(ctx context.Context) Report {
+	c.mu.Lock()
+	checks := make(map[string]Check, len(c.checks))
+	for name, check := range c.checks {
+		checks[name] = check
+	}
+	c.mu.Unlock()
+
+	report := Report{
+		Status:        StatusUp,
+		Checks:        make(map[string]Result, len(checks)),
+		Build:         c.build,
+		StartedAt:     c.started,
+		UptimeSeconds: c.now().Sub(c.started).Seconds(),
+	}
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+			result := c.run(ctx, check)
+
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[name] = result
+			if result.Status != StatusUp {
+				report.Status = StatusDown
+			}
+		}(name, check)
+	}
+	wg.Wait()
+	return report
+}
+
+// run runs one check within the timeout.
+func (c *Checker) run(ctx context.Context, check Check) Result {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := check(ctx)
+	result := Result{
+		Status:    StatusUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+		Details:   details,
+	}
+	if err != nil {
+		result.Status = StatusDown
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// Build returns the build information of the running binary.
+func Build() BuildInfo {
+	build := BuildInfo{Version: Version, Commit: Commit, BuildTime: BuildTime, GoVersion: runtime.Version()}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return build
+	}
+	for _, setting := range info.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && build.Commit == "":
+			build.Commit = setting.Value
+		case setting.Key == "vcs.time" && build.BuildTime == "":
+			build.BuildTime = setting.Value
+		}
+	}
+	return build
+}
@@ -0,0 +1,91 @@
+package http
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"locations/internal/db"
+	"locations/internal/models"
+	"locations/internal/trips"
+)
+
+// requireAdminToken wraps a handler so that it only runs for requests carrying the admin bearer token.
+// An empty token disables the wrapped endpoint entirely rather than leaving it open.
+func requireAdminToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if token == "" {
+			writeProblem(w, r, http.StatusForbidden, CodeDisabled, "Admin API is disabled")
+			return
+		}
+
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="locations-admin"`)
+			writeProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "A valid admin token is required")
+			return
+		}
+
+		next(w, r)
+	}
+}
+
+// DriverDataHandler handles data-subject requests for a single driver.
+// GET exports all stored location data for the driver as JSON, DELETE erases it.
+// The caller is recorded in the audit log from the X-Requested-By header, with an optional reason query parameter.
+func DriverDataHandler(w http.ResponseWriter, r *http.Request, database db.Database) {
+	if !requireParams(w, r, "driver_id") {
+		return
+	}
+	request := models.PrivacyRequest{
+		DriverID:    r.URL.Query().Get("driver_id"),
+		RequestedBy: r.Header.Get("X-Requested-By"),
+		Reason:      r.URL.Query().Get("reason"),
+	}
+	if request.RequestedBy == "" {
+		request.RequestedBy = "admin-api"
+	}
+
+	var (
+		response any
+		err      error
+	)
+	switch r.Method {
+	case http.MethodGet:
+		response, err = database.ExportDriverData(r.Context(), request)
+	case http.MethodDelete:
+		response, err = database.EraseDriverData(r.Context(), request)
+	default:
+		methodNotAllowed(w, r)
+		return
+	}
+	if err != nil {
+		databaseProblem(w, r, err, "Failed to process driver data request")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+// EndTripHandler handles DELETE /admin/trips/{tripID}, called by the trip service when a trip ends.
+// Riders tracking the trip get a trip_ended event and their streams close; the trip's tokens stop working.
+func EndTripHandler(w http.ResponseWriter, r *http.Request, tracker *trips.Tracker) {
+	if r.Method != http.MethodDelete {
+		methodNotAllowed(w, r)
+		return
+	}
+	if tracker == nil {
+		writeProblem(w, r, http.StatusForbidden, CodeDisabled, "Trip tracking is disabled")
+		return
+	}
+	tripID, ok := strings.CutPrefix(r.URL.Path, "/admin/trips/")
+	if !ok || tripID == "" || strings.Contains(tripID, "/") {
+		notFound(w, r, "Trip not found")
+		return
+	}
+
+	tracker.End(tripID)
+	w.WriteHeader(http.StatusNoContent)
+}
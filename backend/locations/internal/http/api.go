@@ -3,38 +3,60 @@ package http
 import (
 	"context"
 	"encoding/json"
-	"fmt"
-	"log"
+	"errors"
+	"expvar"
+	"log/slog"
 	"net/http"
+	"slices"
 	"time"
 
-	"github.com/google/uuid"
-
+	"locations/internal/auth"
+	"locations/internal/db"
+	"locations/internal/health"
+	"locations/internal/live"
+	"locations/internal/logging"
+	"locations/internal/metrics"
 	"locations/internal/models"
+	"locations/internal/openapi"
 	"locations/internal/producer"
+	"locations/internal/ratelimit"
+	"locations/internal/trips"
 )
 
 // LocationUpdateHandler handles POST requests to update location data.
 // It decodes the JSON request body into a LocationUpdate model, validates the data,
 // and uses a KafkaProducer to send the location update to a Kafka topic.
-func LocationUpdateHandler(w http.ResponseWriter, r *http.Request, kafkaProducer producer.KafkaProducer) {
+// Drivers may only report their own location. Updates beyond the client's or the driver's rate limit
+// get 429 Too Many Requests with Retry-After.
+func LocationUpdateHandler(w http.ResponseWriter, r *http.Request, kafkaProducer producer.LocationProducer, limits *ratelimit.Policy) {
+	if !allowClient(w, r, limits) {
+		return
+	}
+
 	var location models.LocationUpdate
 	err := json.NewDecoder(r.Body).Decode(&location)
 	if err != nil {
-		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Failed to decode request body")
 		return
 	}
 	defer r.Body.Close()
 
 	// Validate location data
 	if err := validateLocationData(location); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		invalidRequest(w, r, err)
+		return
+	}
+
+	if !authorize(w, r, func(claims *auth.Claims) bool { return claims.IsDriver(location.DriverID) }) {
+		return
+	}
+	if !allowDriver(w, r, limits, location.DriverID) {
 		return
 	}
 
 	err = kafkaProducer.ProduceLocationUpdate(r.Context(), location)
 	if err != nil {
-		http.Error(w, "Failed to produce Kafka message", http.StatusInternalServerError)
+		producerProblem(w, r, err)
 		return
 	}
 
@@ -44,81 +66,126 @@ func LocationUpdateHandler(w http.ResponseWriter, r *http.Request, kafkaProducer
 // GetLocationHandler handles GET requests to retrieve location data by ID.
 // It extracts the location ID from the query parameters, retrieves the location data from the database,
 // and encodes the location data into a JSON response.
-func GetLocationHandler(w http.ResponseWriter, r *http.Request, db Database) {
-	locationID := r.URL.Query().Get("id")
-	if locationID == "" {
-		http.Error(w, "Location ID is required", http.StatusBadRequest)
+// Callers who can't see any driver are refused before the lookup; a location of a driver the caller may not see
+// gets 404 Not Found like a missing one, so that the response doesn't reveal which IDs exist.
+func GetLocationHandler(w http.ResponseWriter, r *http.Request, database db.Database) {
+	if !authorize(w, r, canReadDrivers) {
+		return
+	}
+	if !requireParams(w, r, "id") {
 		return
 	}
+	locationID := r.URL.Query().Get("id")
 
-	location, err := db.GetLocationByID(r.Context(), locationID)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to get location: %v", err), http.StatusInternalServerError)
+	location, err := database.GetLocationByID(r.Context(), locationID)
+	if err != nil && !errors.Is(err, db.ErrNotFound) {
+		databaseProblem(w, r, err, "Failed to get location")
+		return
+	}
+	if claims, ok := auth.FromContext(r.Context()); errors.Is(err, db.ErrNotFound) || (ok && !claims.CanReadDriver(location.DriverID)) {
+		notFound(w, r, "Location not found")
 		return
 	}
 
+	// The ETag carries the document version; clients send it back in If-Match on PUT.
+	w.Header().Set("ETag", formatETag(location.Version))
+	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(location)
 }
 
 // UpdateLocationHandler handles PUT requests to modify existing location data.
 // It decodes the JSON request body into a LocationUpdate model, validates the data,
 // and updates the location data in the database using the provided ID.
-func UpdateLocationHandler(w http.ResponseWriter, r *http.Request, db Database) {
+// An If-Match header makes the update conditional on the version returned in the ETag of GET /location:
+// a stale version, or a tag that can't match one, gets 412 Precondition Failed, and an unknown ID gets 404 Not Found.
+// Every successful update returns the new version's ETag.
+func UpdateLocationHandler(w http.ResponseWriter, r *http.Request, database db.Database) {
 	var location models.LocationUpdate
 	err := json.NewDecoder(r.Body).Decode(&location)
 	if err != nil {
-		http.Error(w, "Failed to decode request body", http.StatusBadRequest)
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Failed to decode request body")
 		return
 	}
 	defer r.Body.Close()
 
-	locationID := r.URL.Query().Get("id")
-	if locationID == "" {
-		http.Error(w, "Location ID is required", http.StatusBadRequest)
+	if !requireParams(w, r, "id") {
 		return
 	}
+	locationID := r.URL.Query().Get("id")
 
 	// Validate location data
 	if err := validateLocationData(location); err != nil {
-		http.Error(w, err.Error(), http.StatusBadRequest)
+		invalidRequest(w, r, err)
 		return
 	}
 
-	err = db.UpdateLocation(r.Context(), locationID, location)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("Failed to update location: %v", err), http.StatusInternalServerError)
+	versions, err := parseIfMatch(r.Header.Get("If-Match"))
+	switch {
+	case errors.Is(err, errIfMatchFailed):
+		versionConflict(w, r)
+		return
+	case err != nil:
+		invalidRequest(w, r, err)
+		return
+	}
+	var expectedVersion int64
+	switch len(versions) {
+	case 0:
+	case 1:
+		expectedVersion = versions[0]
+	default:
+		// With several tags listed, the update is conditional on whichever of them is current.
+		current, err := database.GetLocationByID(r.Context(), locationID)
+		if err != nil {
+			databaseProblem(w, r, err, "Failed to get location")
+			return
+		}
+		if !slices.Contains(versions, current.Version) {
+			versionConflict(w, r)
+			return
+		}
+		expectedVersion = current.Version
+	}
+
+	result, err := database.UpdateLocation(r.Context(), locationID, location, expectedVersion)
+	switch {
+	case errors.Is(err, db.ErrNotFound):
+		notFound(w, r, "Location not found")
+		return
+	case errors.Is(err, db.ErrVersionConflict):
+		versionConflict(w, r)
+		return
+	case err != nil:
+		databaseProblem(w, r, err, "Failed to update location")
 		return
 	}
 
+	w.Header().Set("ETag", formatETag(result.Version))
 	w.WriteHeader(http.StatusOK)
 }
 
 // NearbyDriversHandler handles the request to retrieve nearby drivers based on longitude and latitude.
-func NearbyDriversHandler(w http.ResponseWriter, r *http.Request, db db.Database) {
+func NearbyDriversHandler(w http.ResponseWriter, r *http.Request, database db.Database) {
 	// Parse the request parameters (latitude and longitude).
 	latitude := r.URL.Query().Get("latitude")
 	longitude := r.URL.Query().Get("longitude")
 
 	// Validate the request parameters.
-	if latitude == "" || longitude == "" {
-		http.Error(w, "Latitude and longitude are required parameters", http.StatusBadRequest)
+	if !requireParams(w, r, "latitude", "longitude") {
 		return
 	}
 
-	// Convert latitude and longitude to appropriate data types (float64 or other).
-	// Perform any necessary validation or sanitization.
-
 	// Query the database for nearby drivers based on the provided latitude and longitude.
-	nearbyDrivers, err := db.GetNearbyDrivers(r.Context(), latitude, longitude)
+	nearbyDrivers, err := database.GetNearbyDrivers(r.Context(), latitude, longitude)
 	if err != nil {
-		http.Error(w, "Failed to retrieve nearby drivers", http.StatusInternalServerError)
+		databaseProblem(w, r, err, "Failed to retrieve nearby drivers")
 		return
 	}
 
 	// Marshal the retrieved nearby drivers into JSON format.
 	response, err := json.Marshal(nearbyDrivers)
 	if err != nil {
-		http.Error(w, "Failed to encode response", http.StatusInternalServerError)
+		internalError(w, r, "Failed to encode response", err)
 		return
 	}
 
@@ -130,53 +197,165 @@ func NearbyDriversHandler(w http.ResponseWriter, r *http.Request, db db.Database
 
 // RunHTTPServer starts an HTTP server with handlers for location data.
 // It listens for incoming HTTP requests and routes them to the appropriate handlers based on the request method.
-// The server runs in a separate goroutine and can be shut down gracefully when the context is canceled.
-func RunHTTPServer(ctx context.Context, addr string, kafkaProducer producer.KafkaProducer, db Database) error {
+// When ctx is canceled the server shuts down gracefully, returning nil once the requests in flight have finished.
+// Admin endpoints, /debug/vars and /status require adminToken as a bearer token and are disabled when it is empty.
+// Live position streams are served from hub.
+// Driver apps stream locations over a WebSocket after authenticating with driverAuth; nil disables streaming.
+// Browser pages may open those streams only from the service's own origin or one of streamOrigins.
+// Riders follow their trip's driver with tokens verified by tracker; nil disables trip tracking.
+// The location endpoints require tokens from the users service, checked by verifier: drivers report their own
+// location, ops staff and riders following their trip's driver read positions, and PUT needs the admin scope.
+// A nil verifier leaves them open.
+// Ingestion is rate limited per driver and per client by limits; nil leaves it unlimited.
+// /readyz and /status report on the dependencies checked by checker; nil has no checks.
+// Requests are counted and timed in instrumentation, which is served at /metrics; nil serves no metrics.
+// Handlers log to logger; nil logs to slog.Default().
+func RunHTTPServer(ctx context.Context, addr string, kafkaProducer producer.LocationProducer, database db.Database, adminToken string, hub *live.Hub, driverAuth DriverAuthenticator, streamOrigins []string, tracker *trips.Tracker, verifier *auth.Verifier, limits *ratelimit.Policy, checker *health.Checker, instrumentation *metrics.Metrics, logger *slog.Logger) error {
+	handler, err := NewRouter(ctx, kafkaProducer, database, adminToken, hub, driverAuth, streamOrigins, tracker, verifier, limits, checker, instrumentation, logger)
+	if err != nil {
+		return err
+	}
+
+	return serve(ctx, addr, handler, logger)
+}
+
+// RunProbeServer serves only /healthz, /readyz, /status and /metrics, for instances that run the consumer
+// without the API. Its parameters are as for RunHTTPServer.
+func RunProbeServer(ctx context.Context, addr string, adminToken string, checker *health.Checker, instrumentation *metrics.Metrics, logger *slog.Logger) error {
 	mux := http.NewServeMux()
-	mux.HandleFunc("/location", func(w http.ResponseWriter, r *http.Request) {
-		switch r.Method {
-		case http.MethodPost:
-			LocationUpdateHandler(w, r, kafkaProducer)
-		case http.MethodGet:
-			GetLocationHandler(w, r, db)
-		case http.MethodPut:
-			UpdateLocationHandler(w, r, db)
-		default:
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	})
-	mux.HandleFunc("/nearby", func(w http.ResponseWriter, r *http.Request) {
-		NearbyDriversHandler(w, r, db)
+	mux.HandleFunc("/healthz", HealthzHandler)
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ReadyzHandler(w, r, checker)
 	})
+	mux.HandleFunc("/status", requireAdminToken(adminToken, func(w http.ResponseWriter, r *http.Request) {
+		StatusHandler(w, r, checker)
+	}))
+	if instrumentation != nil {
+		mux.Handle("/metrics", instrumentation.Handler())
+	}
+
+	return serve(ctx, addr, withRequestID(withLogger(logger, instrumentRequests(instrumentation, mux, mux))), logger)
+}
 
+// serve serves handler on addr until ctx is canceled, then shuts down gracefully: it stops accepting
+// connections and returns nil once the requests in flight have finished, or after 5 seconds.
+func serve(ctx context.Context, addr string, handler http.Handler, logger *slog.Logger) error {
+	logger = logging.Or(logger)
 	server := http.Server{
-		Addr:    addr,
-		Handler: mux,
+		Addr:     addr,
+		Handler:  handler,
+		ErrorLog: slog.NewLogLogger(logger.Handler(), slog.LevelError),
 	}
 
+	shutdown := make(chan struct{})
 	go func() {
+		defer close(shutdown)
 		<-ctx.Done()
 		shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		server.Shutdown(shutdownCtx)
 	}()
 
-	fmt.Printf("HTTP server listening on %s\n", addr)
-	return server.ListenAndServe()
+	logger.Info("HTTP server listening", "addr", addr)
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	<-shutdown
+	return nil
 }
 
-func validateLocationData(location models.LocationUpdate) error {
-	if location.DriverID == "" {
-		return fmt.Errorf("driver ID is required")
-	}
-	if location.Latitude < -90 || location.Latitude > 90 {
-		return fmt.Errorf("latitude must be between -90 and 90")
+// NewRouter builds the handler RunHTTPServer serves, with its parameters as documented there.
+// Requests are validated against the OpenAPI document, served at /openapi.json, before they reach the handlers;
+// ctx ends long-lived streams. Errors are RFC 7807 problems carrying the request ID, which is also returned in
+// X-Request-ID. Requests are traced in spans that continue the caller's trace.
+func NewRouter(ctx context.Context, kafkaProducer producer.LocationProducer, database db.Database, adminToken string, hub *live.Hub, driverAuth DriverAuthenticator, streamOrigins []string, tracker *trips.Tracker, verifier *auth.Verifier, limits *ratelimit.Policy, checker *health.Checker, instrumentation *metrics.Metrics, logger *slog.Logger) (http.Handler, error) {
+	validator, err := openapi.NewValidator()
+	if err != nil {
+		return nil, err
 	}
-	if location.Longitude < -180 || location.Longitude > 180 {
-		return fmt.Errorf("longitude must be between -180 and 180")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/location", authenticate(verifier, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPost:
+			LocationUpdateHandler(w, r, kafkaProducer, limits)
+		case http.MethodGet:
+			GetLocationHandler(w, r, database)
+		case http.MethodPut:
+			if authorize(w, r, hasAdminScope) {
+				UpdateLocationHandler(w, r, database)
+			}
+		default:
+			methodNotAllowed(w, r)
+		}
+	}))
+	mux.HandleFunc("/location/batch", requireClaims(verifier, isDriverApp, func(w http.ResponseWriter, r *http.Request) {
+		LocationBatchHandler(w, r, kafkaProducer, limits)
+	}))
+	mux.HandleFunc("/location/stream", func(w http.ResponseWriter, r *http.Request) {
+		LocationStreamHandler(ctx, w, r, kafkaProducer, driverAuth, streamOrigins, limits)
+	})
+	mux.HandleFunc("/drivers/", authenticate(verifier, func(w http.ResponseWriter, r *http.Request) {
+		DriverLocationHandler(w, r, database)
+	}))
+	mux.HandleFunc("/trips/", func(w http.ResponseWriter, r *http.Request) {
+		TripTrackingHandler(ctx, w, r, database, hub, tracker)
+	})
+	mux.HandleFunc("/nearby", requireClaims(verifier, isOps, func(w http.ResponseWriter, r *http.Request) {
+		NearbyDriversHandler(w, r, database)
+	}))
+	mux.HandleFunc("/heatmap", requireClaims(verifier, isOps, func(w http.ResponseWriter, r *http.Request) {
+		HeatmapHandler(w, r, database)
+	}))
+	mux.HandleFunc("/live", authenticate(verifier, func(w http.ResponseWriter, r *http.Request) {
+		// Riders may follow their trip's driver; the stream of every driver, with no driver_id, is for ops staff.
+		driverID := r.URL.Query().Get("driver_id")
+		if authorize(w, r, func(claims *auth.Claims) bool { return claims.CanReadDriver(driverID) }) {
+			LivePositionsHandler(w, r, hub)
+		}
+	}))
+	// The expvar page shows the command line and memory statistics as well as the database's counters.
+	mux.HandleFunc("/debug/vars", requireAdminToken(adminToken, expvar.Handler().ServeHTTP))
+	mux.HandleFunc("/admin/driver-data", requireAdminToken(adminToken, func(w http.ResponseWriter, r *http.Request) {
+		DriverDataHandler(w, r, database)
+	}))
+	mux.HandleFunc("/admin/trips/", requireAdminToken(adminToken, func(w http.ResponseWriter, r *http.Request) {
+		EndTripHandler(w, r, tracker)
+	}))
+	// Probes and metrics are unauthenticated, so that the orchestrator and Prometheus can always reach them.
+	// The status page shows dependencies' errors and the build, so it is for admins.
+	mux.HandleFunc("/healthz", HealthzHandler)
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		ReadyzHandler(w, r, checker)
+	})
+	mux.HandleFunc("/status", requireAdminToken(adminToken, func(w http.ResponseWriter, r *http.Request) {
+		StatusHandler(w, r, checker)
+	}))
+	if instrumentation != nil {
+		mux.Handle("/metrics", instrumentation.Handler())
 	}
-	if location.Timestamp.IsZero() {
-		return fmt.Errorf("timestamp is required")
+	mux.HandleFunc("/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(openapi.Document())
+	})
+
+	return traceRequests(mux, withRequestID(withLogger(logger, instrumentRequests(instrumentation, mux, validateRequests(validator, mux))))), nil
+}
+
+// validateLocationData checks an incoming location update; see models.LocationUpdate.Validate.
+func validateLocationData(location models.LocationUpdate) error {
+	return location.Validate()
+}
+
+// requireParams replies 400 Bad Request naming the first of the query parameters names that is missing,
+// and reports whether all of them were given.
+func requireParams(w http.ResponseWriter, r *http.Request, names ...string) bool {
+	query := r.URL.Query()
+	for _, name := range names {
+		if query.Get(name) == "" {
+			invalidRequest(w, r, &openapi.FieldError{Field: name, Reason: "is required"})
+			return false
+		}
 	}
-	return nil
+	return true
 }
@@ -0,0 +1,101 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"locations/internal/auth"
+)
+
+// authenticate wraps a handler so that it only runs for requests with a valid token from the users service,
+// whose claims it stores in the request context for authorize. A nil verifier leaves the endpoint open,
+// for development setups without the users service.
+func authenticate(verifier *auth.Verifier, next http.HandlerFunc) http.HandlerFunc {
+	if verifier == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="locations"`)
+			writeProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "A bearer token is required")
+			return
+		}
+		claims, err := verifier.Verify(token)
+		if err != nil {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="locations", error="invalid_token"`)
+			writeProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "The bearer token is invalid or expired")
+			return
+		}
+		next(w, r.WithContext(auth.NewContext(r.Context(), claims)))
+	}
+}
+
+// requireClaims is authenticate for endpoints whose access depends only on the caller, not on the data.
+func requireClaims(verifier *auth.Verifier, allowed func(*auth.Claims) bool, next http.HandlerFunc) http.HandlerFunc {
+	return authenticate(verifier, func(w http.ResponseWriter, r *http.Request) {
+		if !authorize(w, r, allowed) {
+			return
+		}
+		next(w, r)
+	})
+}
+
+// authorize reports whether the caller's claims satisfy allowed, replying 403 Forbidden if they don't.
+// Requests to endpoints left open by authenticate carry no claims and are always allowed.
+func authorize(w http.ResponseWriter, r *http.Request, allowed func(*auth.Claims) bool) bool {
+	claims, ok := auth.FromContext(r.Context())
+	if !ok || allowed(claims) {
+		return true
+	}
+	writeProblem(w, r, http.StatusForbidden, CodeForbidden, "The token does not allow this request")
+	return false
+}
+
+// isOps allows operations staff.
+func isOps(claims *auth.Claims) bool {
+	return claims.HasRole(auth.RoleOps)
+}
+
+// canReadDrivers allows the callers who may see some driver's position: ops staff, and riders on a trip.
+// Which drivers they may see is checked once the driver is known.
+func canReadDrivers(claims *auth.Claims) bool {
+	return claims.HasRole(auth.RoleOps) || claims.HasRole(auth.RoleRider) && claims.Trip != nil
+}
+
+// isDriverApp allows any driver; which driver IDs they may report is checked per update.
+func isDriverApp(claims *auth.Claims) bool {
+	return claims.HasRole(auth.RoleDriver)
+}
+
+// hasAdminScope allows callers who may change stored history.
+func hasAdminScope(claims *auth.Claims) bool {
+	return claims.HasScope(auth.ScopeAdmin)
+}
+
+// JWTDriverTokens authenticates driver apps by users-service tokens with the driver role, whose subject is
+// the driver ID. Like HMACDriverTokens it accepts the access_token query parameter for WebSocket clients.
+type JWTDriverTokens struct {
+	verifier *auth.Verifier
+}
+
+// NewJWTDriverTokens creates an authenticator for tokens verified by verifier.
+func NewJWTDriverTokens(verifier *auth.Verifier) *JWTDriverTokens {
+	return &JWTDriverTokens{verifier: verifier}
+}
+
+// AuthenticateDriver verifies the request's token and returns its driver ID.
+func (t *JWTDriverTokens) AuthenticateDriver(r *http.Request) (string, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get("access_token")
+	}
+	claims, err := t.verifier.Verify(token)
+	if err != nil {
+		return "", err
+	}
+	if !claims.IsDriver(claims.Subject) {
+		return "", errInvalidDriverToken
+	}
+	return claims.Subject, nil
+}
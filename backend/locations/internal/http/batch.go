@@ -0,0 +1,217 @@
+package http
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"locations/internal/auth"
+	"locations/internal/models"
+	"locations/internal/producer"
+	"locations/internal/ratelimit"
+)
+
+const (
+	// maxBatchSize is the largest number of updates accepted in one batch request.
+	maxBatchSize = 1000
+	// maxBatchBodyBytes bounds the size of a batch request body.
+	maxBatchBodyBytes = 4 << 20
+)
+
+// errBatchTooLarge is returned while reading a batch with more than maxBatchSize items.
+var errBatchTooLarge = fmt.Errorf("batch must not contain more than %d updates", maxBatchSize)
+
+// BatchItemResult is the outcome for one update in a batch, identified by its position in the request.
+type BatchItemResult struct {
+	Index    int    `json:"index"`
+	DriverID string `json:"driver_id,omitempty"`
+	Accepted bool   `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// BatchResponse summarises a batch request.
+type BatchResponse struct {
+	Accepted int               `json:"accepted"`
+	Rejected int               `json:"rejected"`
+	Results  []BatchItemResult `json:"results"`
+}
+
+// LocationBatchHandler handles POST requests carrying many location updates, such as fixes a phone buffered
+// while offline. The body is either a JSON array or, with Content-Type application/x-ndjson, one update per line.
+// Each update is validated like a single POST /location; the valid ones are published in one batched write,
+// in request order, and the response reports the outcome of every item.
+// Drivers may only report their own location; updates for other drivers are rejected.
+// A batch counts as one request against the client's rate limit, and each update in it as one request against
+// its driver's; a driver's updates beyond what their limit allows are rejected, keeping the earliest in the batch.
+// The status is 200 when at least one update was published, 429 with Retry-After when none was published
+// only because of rate limits, and 400 when none was valid.
+func LocationBatchHandler(w http.ResponseWriter, r *http.Request, kafkaProducer producer.LocationProducer, limits *ratelimit.Policy) {
+	if r.Method != http.MethodPost {
+		methodNotAllowed(w, r)
+		return
+	}
+	if !allowClient(w, r, limits) {
+		return
+	}
+	defer r.Body.Close()
+	body := http.MaxBytesReader(w, r.Body, maxBatchBodyBytes)
+
+	var (
+		items [][]byte
+		err   error
+	)
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/x-ndjson", "application/ndjson":
+		items, err = readNDJSONBatch(body)
+	default:
+		items, err = readJSONArrayBatch(body)
+	}
+	var maxBytesErr *http.MaxBytesError
+	switch {
+	case errors.Is(err, errBatchTooLarge):
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, err.Error())
+		return
+	case errors.As(err, &maxBytesErr):
+		writeProblem(w, r, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, fmt.Sprintf("Batch body must not exceed %d bytes", maxBatchBodyBytes))
+		return
+	case err != nil:
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Failed to decode request body")
+		return
+	}
+	if len(items) == 0 {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Batch contains no updates")
+		return
+	}
+
+	claims, authenticated := auth.FromContext(r.Context())
+	response := BatchResponse{Results: make([]BatchItemResult, len(items))}
+	locations := make([]models.LocationUpdate, len(items))
+	// perDriver counts each driver's valid updates, which are charged to their rate limit together.
+	perDriver := map[string]int{}
+	for i, item := range items {
+		result := BatchItemResult{Index: i}
+
+		var location models.LocationUpdate
+		if err := json.Unmarshal(item, &location); err != nil {
+			result.Error = "invalid JSON"
+		} else if err := validateLocationData(location); err != nil {
+			result.DriverID = location.DriverID
+			result.Error = err.Error()
+		} else if authenticated && !claims.IsDriver(location.DriverID) {
+			result.DriverID = location.DriverID
+			result.Error = "driver ID does not match the authenticated driver"
+		} else {
+			result.DriverID = location.DriverID
+			result.Accepted = true
+			locations[i] = location
+			perDriver[location.DriverID]++
+		}
+		response.Results[i] = result
+	}
+
+	// granted is how many more of each driver's updates their rate limit admits.
+	granted := make(map[string]int, len(perDriver))
+	var (
+		rateLimited int
+		retryAfter  time.Duration
+	)
+	for driverID, n := range perDriver {
+		decision := limits.AllowDriverN(r.Context(), driverID, n)
+		granted[driverID] = decision.Granted
+		if !decision.Allowed && decision.RetryAfter > retryAfter {
+			retryAfter = decision.RetryAfter
+		}
+	}
+
+	valid := make([]models.LocationUpdate, 0, len(items))
+	for i := range response.Results {
+		result := &response.Results[i]
+		if result.Accepted {
+			if granted[result.DriverID] > 0 {
+				granted[result.DriverID]--
+				valid = append(valid, locations[i])
+			} else {
+				result.Accepted = false
+				result.Error = "rate limit exceeded"
+				rateLimited++
+			}
+		}
+		if result.Accepted {
+			response.Accepted++
+		} else {
+			response.Rejected++
+		}
+	}
+
+	if len(valid) > 0 {
+		if err := kafkaProducer.ProduceLocationUpdates(r.Context(), valid); err != nil {
+			producerProblem(w, r, err)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	switch {
+	case response.Accepted == 0 && rateLimited == response.Rejected:
+		w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+		w.WriteHeader(http.StatusTooManyRequests)
+	case response.Accepted == 0:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+	json.NewEncoder(w).Encode(response)
+}
+
+// readJSONArrayBatch splits a JSON array into its raw elements.
+func readJSONArrayBatch(body io.Reader) ([][]byte, error) {
+	decoder := json.NewDecoder(body)
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return nil, errors.New("batch must be a JSON array")
+	}
+
+	var items [][]byte
+	for decoder.More() {
+		if len(items) == maxBatchSize {
+			return nil, errBatchTooLarge
+		}
+		var item json.RawMessage
+		if err := decoder.Decode(&item); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+	if _, err := decoder.Token(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// readNDJSONBatch splits newline-delimited JSON into lines, skipping blank ones.
+// Lines are validated individually, so one malformed line only rejects that item.
+func readNDJSONBatch(body io.Reader) ([][]byte, error) {
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBatchBodyBytes)
+
+	var items [][]byte
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		if len(items) == maxBatchSize {
+			return nil, errBatchTooLarge
+		}
+		items = append(items, append([]byte(nil), line...))
+	}
+	return items, scanner.Err()
+}
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// errInvalidDriverToken is returned for a missing, malformed or forged driver token.
+var errInvalidDriverToken = errors.New("invalid driver token")
+
+// DriverAuthenticator identifies the driver making a request.
+type DriverAuthenticator interface {
+	// AuthenticateDriver returns the ID of the driver the request is authenticated as.
+	AuthenticateDriver(r *http.Request) (string, error)
+}
+
+// HMACDriverTokens authenticates drivers by tokens of the form <driverID>.<exp>.<signature>, where exp is when
+// the token expires in Unix seconds and the signature is the base64url HMAC-SHA256 of <driverID>.<exp> under a
+// shared secret. The token is read from the Authorization bearer header, or from the access_token query
+// parameter for clients that can't set headers on a WebSocket.
+type HMACDriverTokens struct {
+	secret []byte
+	now    func() time.Time
+}
+
+// NewHMACDriverTokens creates an authenticator for tokens signed with secret.
+func NewHMACDriverTokens(secret string) *HMACDriverTokens {
+	return NewHMACDriverTokensWithClock(secret, time.Now)
+}
+
+// NewHMACDriverTokensWithClock is NewHMACDriverTokens with a custom clock, for tests.
+func NewHMACDriverTokensWithClock(secret string, now func() time.Time) *HMACDriverTokens {
+	return &HMACDriverTokens{secret: []byte(secret), now: now}
+}
+
+// Issue returns a token for driverID that expires at expiresAt.
+func (t *HMACDriverTokens) Issue(driverID string, expiresAt time.Time) string {
+	payload := driverID + "." + strconv.FormatInt(expiresAt.Unix(), 10)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(t.sign(payload))
+}
+
+// AuthenticateDriver verifies the request's driver token and returns its driver ID.
+func (t *HMACDriverTokens) AuthenticateDriver(r *http.Request) (string, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get("access_token")
+	}
+
+	// Driver IDs may contain dots, so the token is split from the right.
+	i := strings.LastIndexByte(token, '.')
+	if i <= 0 {
+		return "", errInvalidDriverToken
+	}
+	payload := token[:i]
+	signature, err := base64.RawURLEncoding.DecodeString(token[i+1:])
+	if err != nil || !hmac.Equal(signature, t.sign(payload)) {
+		return "", errInvalidDriverToken
+	}
+	j := strings.LastIndexByte(payload, '.')
+	if j <= 0 {
+		return "", errInvalidDriverToken
+	}
+	expiresAt, err := strconv.ParseInt(payload[j+1:], 10, 64)
+	if err != nil || !t.now().Before(time.Unix(expiresAt, 0)) {
+		return "", errInvalidDriverToken
+	}
+	return payload[:j], nil
+}
+
+// sign computes the HMAC of a token's payload.
+func (t *HMACDriverTokens) sign(payload string) []byte {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,81 @@
+package http
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"locations/internal/auth"
+	"locations/internal/db"
+	"locations/internal/models"
+	"locations/internal/openapi"
+)
+
+// staleAfter is the age beyond which a driver's last fix is flagged as stale.
+const staleAfter = 2 * time.Minute
+
+// DriverLocationHandler handles GET /drivers/{driverID}/location, the driver's latest known position.
+// The response carries the fix's age and accuracy and flags it as stale after staleAfter.
+// Clients that can't use an old fix at all pass max_age, such as 30s, and get 410 Gone for anything older.
+// Unknown drivers get 404 Not Found. The caller must be allowed to see the driver.
+func DriverLocationHandler(w http.ResponseWriter, r *http.Request, database db.Database) {
+	if r.Method != http.MethodGet {
+		methodNotAllowed(w, r)
+		return
+	}
+
+	driverID, ok := parseDriverLocationPath(r.URL.Path)
+	if !ok {
+		notFound(w, r, "Driver not found")
+		return
+	}
+	if !authorize(w, r, func(claims *auth.Claims) bool { return claims.CanReadDriver(driverID) }) {
+		return
+	}
+
+	var maxAge time.Duration
+	if raw := r.URL.Query().Get("max_age"); raw != "" {
+		var err error
+		maxAge, err = time.ParseDuration(raw)
+		if err != nil || maxAge <= 0 {
+			invalidRequest(w, r, &openapi.FieldError{Field: "max_age", Reason: "must be a positive duration such as 30s"})
+			return
+		}
+	}
+
+	driver, err := database.GetDriverLocation(r.Context(), driverID)
+	if errors.Is(err, db.ErrNotFound) {
+		notFound(w, r, "Driver not found")
+		return
+	}
+	if err != nil {
+		databaseProblem(w, r, err, "Failed to get driver location")
+		return
+	}
+
+	now := time.Now().UTC()
+	if maxAge > 0 && now.Sub(driver.UpdatedAt) > maxAge {
+		writeProblem(w, r, http.StatusGone, CodeGone, "Driver's last known position is older than max_age")
+		return
+	}
+
+	position := models.NewDriverPosition(*driver, now, staleAfter)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Last-Modified", driver.UpdatedAt.UTC().Format(http.TimeFormat))
+	json.NewEncoder(w).Encode(position)
+}
+
+// parseDriverLocationPath extracts the driver ID from /drivers/{driverID}/location.
+func parseDriverLocationPath(path string) (string, bool) {
+	rest, ok := strings.CutPrefix(path, "/drivers/")
+	if !ok {
+		return "", false
+	}
+	driverID, ok := strings.CutSuffix(rest, "/location")
+	if !ok || driverID == "" || strings.Contains(driverID, "/") {
+		return "", false
+	}
+	return driverID, true
+}
@@ -0,0 +1,53 @@
+package http
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// errIfMatchFailed means an If-Match header lists no tag that could match a version, so the precondition fails.
+var errIfMatchFailed = errors.New("If-Match does not match the current version")
+
+// formatETag renders a document version as a strong entity tag.
+func formatETag(version int64) string {
+	return fmt.Sprintf("%q", strconv.FormatInt(version, 10))
+}
+
+// versionConflict replies 412 Precondition Failed to an update whose If-Match doesn't match the current version.
+func versionConflict(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusPreconditionFailed, CodeVersionConflict, "Location has been modified since it was retrieved")
+}
+
+// parseIfMatch extracts the versions listed in an If-Match header.
+// It returns none when the header is absent or "*", meaning the update is unconditional.
+// If-Match compares entity tags strongly, so weak tags, and tags that are not versions, never match and are
+// skipped; when nothing else is listed it returns errIfMatchFailed. A header that isn't a list of quoted
+// entity tags is malformed.
+func parseIfMatch(header string) ([]int64, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	var versions []int64
+	for _, tag := range strings.Split(header, ",") {
+		opaque, weak := strings.CutPrefix(strings.TrimSpace(tag), "W/")
+		unquoted, err := strconv.Unquote(opaque)
+		if err != nil || !strings.HasPrefix(opaque, `"`) {
+			return nil, fmt.Errorf("If-Match must be a list of quoted entity tags")
+		}
+		if weak {
+			continue
+		}
+		if version, err := strconv.ParseInt(unquoted, 10, 64); err == nil && version > 0 {
+			versions = append(versions, version)
+		}
+	}
+	if len(versions) == 0 {
+		return nil, errIfMatchFailed
+	}
+	return versions, nil
+}
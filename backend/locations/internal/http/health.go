@@ -0,0 +1,57 @@
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"locations/internal/health"
+)
+
+// readiness is the body of /readyz: the overall status and each dependency's.
+type readiness struct {
+	Status health.Status            `json:"status"`
+	Checks map[string]health.Status `json:"checks,omitempty"`
+}
+
+// HealthzHandler handles GET /healthz, which only says the process is serving requests.
+// Liveness probes use it; a failing dependency must not get the process restarted.
+func HealthzHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(readiness{Status: health.StatusUp})
+}
+
+// ReadyzHandler handles GET /readyz, which runs the checks and answers 503 Service Unavailable if any fails,
+// so that readiness probes take the instance out of service until its dependencies are back.
+// A nil checker has no checks, so the instance is always ready.
+func ReadyzHandler(w http.ResponseWriter, r *http.Request, checker *health.Checker) {
+	report := runChecks(r, checker)
+	body := readiness{Status: report.Status, Checks: make(map[string]health.Status, len(report.Checks))}
+	for name, result := range report.Checks {
+		body.Checks[name] = result.Status
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if report.Status != health.StatusUp {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(body)
+}
+
+// StatusHandler handles GET /status, the full report of every check with its latency, error and details,
+// and the build the instance runs. It answers 200 OK whatever the dependencies' state. The errors are the
+// dependencies' own, so the route is only for admins.
+func StatusHandler(w http.ResponseWriter, r *http.Request, checker *health.Checker) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(runChecks(r, checker))
+}
+
+// runChecks runs the checker's checks, or none for a nil checker.
+func runChecks(r *http.Request, checker *health.Checker) health.Report {
+	if checker == nil {
+		checker = health.NewChecker()
+	}
+	return checker.Check(r.Context())
+}
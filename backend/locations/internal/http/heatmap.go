@@ -0,0 +1,165 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"locations/internal/db"
+	"locations/internal/geo"
+	"locations/internal/models"
+	"locations/internal/openapi"
+)
+
+const (
+	// defaultHeatmapWindow is the "right now" window used when neither window nor since is given.
+	defaultHeatmapWindow = 5 * time.Minute
+	// maxHeatmapWindow bounds how much history one request can aggregate.
+	maxHeatmapWindow = 24 * time.Hour
+	// defaultHeatmapPrecision gives cells of roughly 1.2km x 0.6km.
+	defaultHeatmapPrecision = 6
+)
+
+// HeatmapHandler handles GET requests for driver density over a bounding box.
+// Query parameters:
+//   - min_lat, min_lng, max_lat, max_lng: the bounding box (required)
+//   - window: a duration such as 1h counting back from now, or since/until as RFC 3339 timestamps
+//   - precision: the geohash length of the cells, 1 to geo.MaxGeohashPrecision
+//   - format: json (default) or geojson
+func HeatmapHandler(w http.ResponseWriter, r *http.Request, database db.Database) {
+	query, err := parseHeatmapQuery(r, time.Now().UTC())
+	if err != nil {
+		invalidRequest(w, r, err)
+		return
+	}
+
+	cells, err := database.Heatmap(r.Context(), query)
+	if err != nil {
+		databaseProblem(w, r, err, "Failed to aggregate heatmap")
+		return
+	}
+
+	format := r.URL.Query().Get("format")
+	if format == "" && strings.Contains(r.Header.Get("Accept"), "application/geo+json") {
+		format = "geojson"
+	}
+	switch format {
+	case "", "json":
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]any{
+			"since":     query.Since,
+			"until":     query.Until,
+			"precision": query.Precision,
+			"cells":     cells,
+		})
+	case "geojson":
+		w.Header().Set("Content-Type", "application/geo+json")
+		json.NewEncoder(w).Encode(heatmapFeatureCollection(cells))
+	default:
+		invalidRequest(w, r, &openapi.FieldError{Field: "format", Reason: "must be json or geojson"})
+	}
+}
+
+// parseHeatmapQuery reads and validates the heatmap query parameters.
+func parseHeatmapQuery(r *http.Request, now time.Time) (models.HeatmapQuery, error) {
+	params := r.URL.Query()
+	query := models.HeatmapQuery{Precision: defaultHeatmapPrecision}
+
+	bounds := []struct {
+		name string
+		dest *float64
+	}{
+		{"min_lat", &query.MinLatitude},
+		{"min_lng", &query.MinLongitude},
+		{"max_lat", &query.MaxLatitude},
+		{"max_lng", &query.MaxLongitude},
+	}
+	for _, bound := range bounds {
+		value, err := strconv.ParseFloat(params.Get(bound.name), 64)
+		if err != nil {
+			return query, &openapi.FieldError{Field: bound.name, Reason: "must be a number"}
+		}
+		*bound.dest = value
+	}
+	if query.MinLatitude > query.MaxLatitude {
+		return query, &openapi.FieldError{Field: "min_lat", Reason: "must not exceed max_lat"}
+	}
+	if query.MinLongitude > query.MaxLongitude {
+		return query, &openapi.FieldError{Field: "min_lng", Reason: "must not exceed max_lng"}
+	}
+
+	if raw := params.Get("precision"); raw != "" {
+		precision, err := strconv.Atoi(raw)
+		if err != nil {
+			return query, &openapi.FieldError{Field: "precision", Reason: "must be an integer"}
+		}
+		query.Precision = precision
+	}
+
+	query.Until = now
+	if raw := params.Get("until"); raw != "" {
+		until, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return query, &openapi.FieldError{Field: "until", Reason: "must be an RFC 3339 timestamp"}
+		}
+		query.Until = until
+	}
+	switch {
+	case params.Get("since") != "":
+		since, err := time.Parse(time.RFC3339, params.Get("since"))
+		if err != nil {
+			return query, &openapi.FieldError{Field: "since", Reason: "must be an RFC 3339 timestamp"}
+		}
+		query.Since = since
+	case params.Get("window") != "":
+		window, err := time.ParseDuration(params.Get("window"))
+		if err != nil || window <= 0 {
+			return query, &openapi.FieldError{Field: "window", Reason: "must be a positive duration such as 1h"}
+		}
+		query.Since = query.Until.Add(-window)
+	default:
+		query.Since = query.Until.Add(-defaultHeatmapWindow)
+	}
+	if !query.Since.Before(query.Until) {
+		return query, &openapi.FieldError{Field: "since", Reason: "must be before until"}
+	}
+	if query.Until.Sub(query.Since) > maxHeatmapWindow {
+		return query, &openapi.FieldError{Field: "since", Reason: fmt.Sprintf("time window must not exceed %s", maxHeatmapWindow)}
+	}
+
+	return query, nil
+}
+
+// heatmapFeatureCollection renders cells as a GeoJSON FeatureCollection of cell polygons.
+func heatmapFeatureCollection(cells []models.HeatmapCell) map[string]any {
+	features := make([]map[string]any, 0, len(cells))
+	for _, cell := range cells {
+		bounds, err := geo.Decode(cell.Geohash)
+		if err != nil {
+			continue
+		}
+		ring := [][]float64{
+			{bounds.MinLng, bounds.MinLat},
+			{bounds.MaxLng, bounds.MinLat},
+			{bounds.MaxLng, bounds.MaxLat},
+			{bounds.MinLng, bounds.MaxLat},
+			{bounds.MinLng, bounds.MinLat},
+		}
+		features = append(features, map[string]any{
+			"type": "Feature",
+			"geometry": map[string]any{
+				"type":        "Polygon",
+				"coordinates": [][][]float64{ring},
+			},
+			"properties": map[string]any{
+				"geohash":          cell.Geohash,
+				"count":            cell.Count,
+				"distinct_drivers": cell.DistinctDrivers,
+			},
+		})
+	}
+	return map[string]any{"type": "FeatureCollection", "features": features}
+}
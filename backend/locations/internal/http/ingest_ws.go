@@ -0,0 +1,293 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"locations/internal/models"
+	"locations/internal/producer"
+	"locations/internal/ratelimit"
+)
+
+const (
+	// ingestWriteWait bounds how long a write to the client may take.
+	ingestWriteWait = 10 * time.Second
+	// ingestPongWait is how long the connection may be silent before it is considered dead.
+	ingestPongWait = 60 * time.Second
+	// ingestPingPeriod must be shorter than ingestPongWait so that pings keep a quiet connection alive.
+	ingestPingPeriod = ingestPongWait * 9 / 10
+	// ingestMaxFrameBytes bounds the size of one incoming frame.
+	ingestMaxFrameBytes = 4096
+	// ingestQueueSize is how many frames may wait to be published before new frames are refused.
+	ingestQueueSize = 64
+	// ingestReplyBuffer is how many replies may wait for the writer; it holds a full queue's worth of acks
+	// with room for rejections, so replies are only dropped when the client stops reading them.
+	ingestReplyBuffer = 4 * ingestQueueSize
+)
+
+var ingestUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	Error:           upgradeError,
+}
+
+// allowOrigins returns an origin check admitting requests without an Origin, as driver apps are native clients
+// that send none, requests from the service's own origin and requests from the allowed origins.
+// Any other browser page could otherwise open a stream with a token it got hold of.
+func allowOrigins(allowed []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+			return true
+		}
+		for _, allowedOrigin := range allowed {
+			if strings.EqualFold(origin, allowedOrigin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// ingestFrame is a location frame sent by a driver app. The driver is the authenticated one,
+// so a driver ID in the frame is only accepted if it matches.
+type ingestFrame struct {
+	Seq       uint64    `json:"seq"`
+	DriverID  string    `json:"driver_id,omitempty"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Timestamp time.Time `json:"timestamp"`
+	Accuracy  float64   `json:"accuracy,omitempty"`
+}
+
+// ingestReply acknowledges or rejects one frame. Rejections with Retry set are backpressure, rate limiting or
+// transient failures, and the app should resend the frame later, after RetryAfterMs if set; others will never be accepted.
+type ingestReply struct {
+	Type         string `json:"type"`
+	Seq          uint64 `json:"seq"`
+	Error        string `json:"error,omitempty"`
+	Retry        bool   `json:"retry,omitempty"`
+	RetryAfterMs int64  `json:"retry_after_ms,omitempty"`
+}
+
+// queuedUpdate is a validated frame waiting to be published.
+type queuedUpdate struct {
+	seq    uint64
+	update models.LocationUpdate
+}
+
+// LocationStreamHandler upgrades to a WebSocket on which an authenticated driver app streams location frames.
+// Every frame is answered with an "ack" or "nack" carrying its seq. Frames are validated like POST /location and
+// published in small batches; when publishing falls behind, frames are refused with retry set instead of
+// buffering without bound. The server pings every ingestPingPeriod and drops connections that stop answering.
+// The session ends with a going-away close frame when ctx, the server's context, is canceled.
+// Opening a stream counts against the client's rate limit and every frame against the driver's. Browsers may
+// only open one from the service's own origin or from allowedOrigins.
+func LocationStreamHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, kafkaProducer producer.LocationProducer, auth DriverAuthenticator, allowedOrigins []string, limits *ratelimit.Policy) {
+	if auth == nil {
+		writeProblem(w, r, http.StatusForbidden, CodeDisabled, "Location streaming is disabled")
+		return
+	}
+	driverID, err := auth.AuthenticateDriver(r)
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer realm="locations-driver"`)
+		writeProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "A valid driver token is required")
+		return
+	}
+	if !allowClient(w, r, limits) {
+		return
+	}
+
+	upgrader := ingestUpgrader
+	upgrader.CheckOrigin = allowOrigins(allowedOrigins)
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied to the client.
+		return
+	}
+	defer conn.Close()
+
+	replies := make(chan ingestReply, ingestReplyBuffer)
+	queue := make(chan queuedUpdate, ingestQueueSize)
+	published := make(chan struct{})
+	writerDone := make(chan struct{})
+
+	go func() {
+		defer close(writerDone)
+		writeIngestReplies(conn, replies, published, ctx.Done())
+		// Closing unblocks the reader if the writer gave up on a dead connection first.
+		conn.Close()
+	}()
+	go func() {
+		defer close(published)
+		publishIngestQueue(kafkaProducer, queue, replies)
+	}()
+
+	// The stream outlives the request's logging context, so its lines carry the request ID themselves.
+	logger := requestLogger(r).With("request_id", RequestIDFromContext(r.Context()), "driver_id", driverID)
+	readIngestFrames(ctx, conn, driverID, limits, queue, replies, logger)
+
+	// The publisher finishes what was accepted and the writer sends its acks before the connection closes.
+	close(queue)
+	<-writerDone
+}
+
+// readIngestFrames reads frames until the connection fails or ctx is canceled, validating and queueing them.
+// Connections that fail unexpectedly are logged to logger.
+func readIngestFrames(ctx context.Context, conn *websocket.Conn, driverID string, limits *ratelimit.Policy, queue chan<- queuedUpdate, replies chan<- ingestReply, logger *slog.Logger) {
+	conn.SetReadLimit(ingestMaxFrameBytes)
+	conn.SetReadDeadline(time.Now().Add(ingestPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(ingestPongWait))
+	})
+
+	// ReadMessage blocks, so a canceled context unblocks it by expiring the read deadline.
+	readerDone := make(chan struct{})
+	defer close(readerDone)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Now())
+		case <-readerDone:
+		}
+	}()
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() == nil && websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				logger.Warn("Location stream closed unexpectedly", "error", err)
+			}
+			return
+		}
+		conn.SetReadDeadline(time.Now().Add(ingestPongWait))
+
+		var frame ingestFrame
+		if err := json.Unmarshal(data, &frame); err != nil {
+			sendIngestReply(replies, ingestReply{Type: "nack", Error: "invalid JSON"})
+			continue
+		}
+		if frame.DriverID != "" && frame.DriverID != driverID {
+			sendIngestReply(replies, ingestReply{Type: "nack", Seq: frame.Seq, Error: "driver ID does not match the authenticated driver"})
+			continue
+		}
+
+		update := models.LocationUpdate{
+			DriverID:  driverID,
+			Latitude:  frame.Latitude,
+			Longitude: frame.Longitude,
+			Timestamp: frame.Timestamp,
+			Accuracy:  frame.Accuracy,
+		}
+		if err := validateLocationData(update); err != nil {
+			sendIngestReply(replies, ingestReply{Type: "nack", Seq: frame.Seq, Error: err.Error()})
+			continue
+		}
+		if decision := limits.AllowDriver(ctx, driverID); !decision.Allowed {
+			sendIngestReply(replies, ingestReply{Type: "nack", Seq: frame.Seq, Error: "rate limit exceeded", Retry: true, RetryAfterMs: decision.RetryAfter.Milliseconds()})
+			continue
+		}
+
+		select {
+		case queue <- queuedUpdate{seq: frame.Seq, update: update}:
+		default:
+			sendIngestReply(replies, ingestReply{Type: "nack", Seq: frame.Seq, Error: "server busy", Retry: true})
+		}
+	}
+}
+
+// publishIngestQueue publishes queued updates, batching whatever has accumulated, and acknowledges them.
+// It runs until queue is closed, so frames accepted before shutdown are still delivered.
+func publishIngestQueue(kafkaProducer producer.LocationProducer, queue <-chan queuedUpdate, replies chan<- ingestReply) {
+	for first := range queue {
+		batch := []queuedUpdate{first}
+	drain:
+		for len(batch) < ingestQueueSize {
+			select {
+			case next, ok := <-queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, next)
+			default:
+				break drain
+			}
+		}
+
+		updates := make([]models.LocationUpdate, len(batch))
+		for i, queued := range batch {
+			updates[i] = queued.update
+		}
+		// Each write is bounded so that shutdown can't hang on an unreachable broker.
+		publishCtx, cancel := context.WithTimeout(context.Background(), ingestWriteWait)
+		err := kafkaProducer.ProduceLocationUpdates(publishCtx, updates)
+		cancel()
+
+		for _, queued := range batch {
+			reply := ingestReply{Type: "ack", Seq: queued.seq}
+			if err != nil {
+				reply = ingestReply{Type: "nack", Seq: queued.seq, Error: "failed to publish", Retry: true}
+			}
+			sendIngestReply(replies, reply)
+		}
+	}
+}
+
+// writeIngestReplies is the connection's only writer. It sends replies and pings until the publisher is done,
+// then closes the session, with a going-away close frame if the server is shutting down.
+func writeIngestReplies(conn *websocket.Conn, replies <-chan ingestReply, published, shutdown <-chan struct{}) {
+	ticker := time.NewTicker(ingestPingPeriod)
+	defer ticker.Stop()
+
+	write := func(reply ingestReply) error {
+		conn.SetWriteDeadline(time.Now().Add(ingestWriteWait))
+		return conn.WriteJSON(reply)
+	}
+
+	for {
+		select {
+		case reply := <-replies:
+			if err := write(reply); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(ingestWriteWait)); err != nil {
+				return
+			}
+		case <-published:
+			// The publisher has sent its last replies; flush them so the app knows which frames were delivered.
+			for len(replies) > 0 {
+				if err := write(<-replies); err != nil {
+					return
+				}
+			}
+			code, text := websocket.CloseNormalClosure, ""
+			select {
+			case <-shutdown:
+				code, text = websocket.CloseGoingAway, "server shutting down"
+			default:
+			}
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, text), time.Now().Add(ingestWriteWait))
+			return
+		}
+	}
+}
+
+// sendIngestReply queues a reply for the writer. If the client isn't reading its replies the buffer fills up;
+// replies are then dropped rather than blocking ingestion, and the app resends whatever wasn't acknowledged.
+func sendIngestReply(replies chan<- ingestReply, reply ingestReply) {
+	select {
+	case replies <- reply:
+	default:
+	}
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"locations/internal/live"
+)
+
+// LivePositionsHandler streams live position changes as Server-Sent Events.
+// The optional driver_id query parameter limits the stream to a single driver.
+// It is meant for internal services that would otherwise poll GET /location.
+func LivePositionsHandler(w http.ResponseWriter, r *http.Request, hub *live.Hub) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		internalError(w, r, "Streaming is not supported", fmt.Errorf("%T is not an http.Flusher", w))
+		return
+	}
+
+	sub := hub.Subscribe(r.URL.Query().Get("driver_id"))
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case driver, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(driver)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "event: position\ndata: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
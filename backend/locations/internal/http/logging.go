@@ -0,0 +1,26 @@
+package http
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+
+	"locations/internal/logging"
+)
+
+type loggerKey struct{}
+
+// withLogger makes logger available to the handlers through requestLogger.
+func withLogger(logger *slog.Logger, next http.Handler) http.Handler {
+	logger = logging.Or(logger)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), loggerKey{}, logger)))
+	})
+}
+
+// requestLogger returns the logger handlers log with. Log with r's context, as in ErrorContext(r.Context(), ...),
+// so that the line carries the request and trace IDs.
+func requestLogger(r *http.Request) *slog.Logger {
+	logger, _ := r.Context().Value(loggerKey{}).(*slog.Logger)
+	return logging.Or(logger)
+}
@@ -0,0 +1,138 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"locations/internal/db"
+	"locations/internal/openapi"
+)
+
+// Problem codes. Each tells clients what went wrong in a way they can branch on, more precisely than the status.
+const (
+	CodeInvalidRequest      = "invalid_request"
+	CodeInvalidCoordinates  = "invalid_coordinates"
+	CodeUnauthorized        = "unauthorized"
+	CodeForbidden           = "forbidden"
+	CodeDisabled            = "disabled"
+	CodeNotFound            = "not_found"
+	CodeMethodNotAllowed    = "method_not_allowed"
+	CodeVersionConflict     = "version_conflict"
+	CodeGone                = "gone"
+	CodePayloadTooLarge     = "payload_too_large"
+	CodeRateLimited         = "rate_limited"
+	CodeUpstreamUnavailable = "upstream_unavailable"
+	CodeInternal            = "internal_error"
+)
+
+// problemContentType is the media type of Problem responses.
+const problemContentType = "application/problem+json"
+
+// Problem is an RFC 7807 error response. Its type is always about:blank, so the title is the status text;
+// Code is what clients should branch on.
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	Code      string `json:"code"`
+	RequestID string `json:"request_id,omitempty"`
+	// Errors lists the fields that failed validation.
+	Errors []openapi.FieldError `json:"errors,omitempty"`
+}
+
+// writeProblem replies with a Problem. detail is shown to clients, so it must not carry internal errors.
+func writeProblem(w http.ResponseWriter, r *http.Request, status int, code, detail string, fields ...openapi.FieldError) {
+	problem := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		Code:      code,
+		RequestID: RequestIDFromContext(r.Context()),
+		Errors:    fields,
+	}
+	w.Header().Set("Content-Type", problemContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+// invalidRequest replies 400 Bad Request for err, listing the field at fault when err is a *openapi.FieldError.
+// Invalid latitudes and longitudes get CodeInvalidCoordinates.
+func invalidRequest(w http.ResponseWriter, r *http.Request, err error) {
+	var fieldErr *openapi.FieldError
+	if !errors.As(err, &fieldErr) {
+		writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, err.Error())
+		return
+	}
+	code := CodeInvalidRequest
+	if isCoordinateField(fieldErr.Field) {
+		code = CodeInvalidCoordinates
+	}
+	writeProblem(w, r, http.StatusBadRequest, code, fieldErr.Error(), *fieldErr)
+}
+
+// coordinateFields are the parameters and properties holding a latitude or longitude.
+var coordinateFields = map[string]bool{
+	"latitude":  true,
+	"longitude": true,
+	"min_lat":   true,
+	"min_lng":   true,
+	"max_lat":   true,
+	"max_lng":   true,
+}
+
+// isCoordinateField reports whether field, possibly nested as in body.latitude, is a coordinate.
+func isCoordinateField(field string) bool {
+	return coordinateFields[field[strings.LastIndexByte(field, '.')+1:]]
+}
+
+// notFound replies 404 Not Found.
+func notFound(w http.ResponseWriter, r *http.Request, detail string) {
+	writeProblem(w, r, http.StatusNotFound, CodeNotFound, detail)
+}
+
+// methodNotAllowed replies 405 Method Not Allowed.
+func methodNotAllowed(w http.ResponseWriter, r *http.Request) {
+	writeProblem(w, r, http.StatusMethodNotAllowed, CodeMethodNotAllowed, r.Method+" is not supported on this endpoint")
+}
+
+// internalError logs err and replies 500 Internal Server Error without revealing it.
+func internalError(w http.ResponseWriter, r *http.Request, detail string, err error) {
+	requestLogger(r).ErrorContext(r.Context(), "Request failed", "path", r.URL.Path, "detail", detail, "error", err)
+	writeProblem(w, r, http.StatusInternalServerError, CodeInternal, detail)
+}
+
+// databaseProblem replies to a failed database call. Missing documents get 404 Not Found. While the database
+// is unreachable, or still failing with a transient error once retries are spent, clients get 503 so that they
+// back off and retry, rather than a generic 500.
+func databaseProblem(w http.ResponseWriter, r *http.Request, err error, detail string) {
+	switch {
+	case errors.Is(err, db.ErrNotFound), errors.Is(err, mongo.ErrNoDocuments):
+		notFound(w, r, "Not found")
+	case errors.Is(err, db.ErrUnavailable), errors.Is(err, context.DeadlineExceeded), db.IsTransientError(err):
+		writeProblem(w, r, http.StatusServiceUnavailable, CodeUpstreamUnavailable, "The database is unavailable, retry later")
+	default:
+		internalError(w, r, detail, err)
+	}
+}
+
+// producerProblem replies to a failed publish. Failures to reach Kafka are transient, so clients get 503
+// and retry the update.
+func producerProblem(w http.ResponseWriter, r *http.Request, err error) {
+	requestLogger(r).ErrorContext(r.Context(), "Request failed to publish", "path", r.URL.Path, "error", err)
+	writeProblem(w, r, http.StatusServiceUnavailable, CodeUpstreamUnavailable, "Location updates can't be published right now, retry later")
+}
+
+// upgradeError replies to a failed WebSocket handshake; it is the Error function of the upgraders.
+func upgradeError(w http.ResponseWriter, r *http.Request, status int, reason error) {
+	writeProblem(w, r, status, CodeInvalidRequest, reason.Error())
+}
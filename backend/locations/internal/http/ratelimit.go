@@ -0,0 +1,64 @@
+package http
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"locations/internal/ratelimit"
+)
+
+// clientAddr returns the address a request is rate limited by: the peer's IP, or with trusted
+// load balancers, the X-Forwarded-For entry the outermost of them added. A header too short to hold
+// that entry didn't come through all of them, so the peer's IP is used.
+func clientAddr(r *http.Request, limits *ratelimit.Policy) string {
+	if limits != nil && limits.TrustForwardedFor {
+		var entries []string
+		for _, header := range r.Header.Values("X-Forwarded-For") {
+			entries = append(entries, strings.Split(header, ",")...)
+		}
+		hops := max(limits.ForwardedForHops, 1)
+		if len(entries) >= hops {
+			if addr := strings.TrimSpace(entries[len(entries)-hops]); addr != "" {
+				return addr
+			}
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// allowClient applies the per-client limit to an ingestion request, replying 429 if it is exceeded.
+func allowClient(w http.ResponseWriter, r *http.Request, limits *ratelimit.Policy) bool {
+	decision := limits.AllowClient(r.Context(), clientAddr(r, limits))
+	if !decision.Allowed {
+		tooManyRequests(w, r, decision.RetryAfter)
+	}
+	return decision.Allowed
+}
+
+// allowDriver applies the per-driver limit to an update, replying 429 if it is exceeded.
+func allowDriver(w http.ResponseWriter, r *http.Request, limits *ratelimit.Policy, driverID string) bool {
+	decision := limits.AllowDriver(r.Context(), driverID)
+	if !decision.Allowed {
+		tooManyRequests(w, r, decision.RetryAfter)
+	}
+	return decision.Allowed
+}
+
+// tooManyRequests replies 429 Too Many Requests with Retry-After in whole seconds, rounded up.
+func tooManyRequests(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", retryAfterSeconds(retryAfter))
+	writeProblem(w, r, http.StatusTooManyRequests, CodeRateLimited, "Rate limit exceeded")
+}
+
+// retryAfterSeconds formats a Retry-After value, which is at least one second.
+func retryAfterSeconds(retryAfter time.Duration) string {
+	return strconv.Itoa(int(math.Max(1, math.Ceil(retryAfter.Seconds()))))
+}
@@ -0,0 +1,58 @@
+package http
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+
+	"locations/internal/logging"
+)
+
+// requestIDHeader carries the request ID in both directions.
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds request IDs taken from clients, which end up in logs.
+const maxRequestIDLength = 128
+
+// withRequestID gives every request an ID, returned in X-Request-ID and in problem responses so that a client's
+// report can be matched to the logs and traces. An ID set by a proxy in front of the service is kept.
+func withRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !validRequestID(id) {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+		trace.SpanFromContext(r.Context()).SetAttributes(attribute.String("http.request_id", id))
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// RequestIDFromContext returns the ID of the request ctx belongs to, or "" outside a request.
+func RequestIDFromContext(ctx context.Context) string {
+	return logging.RequestIDFromContext(ctx)
+}
+
+// validRequestID accepts IDs of printable ASCII without spaces, so that they can't forge log lines.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns 16 random bytes in hex.
+func newRequestID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
@@ -0,0 +1,39 @@
+package http
+
+import (
+	"net/http"
+
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// untracedPaths are polled by the orchestrator and Prometheus; tracing them would only bury the requests
+// worth following.
+var untracedPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// traceRequests serves each request in a server span named after its method and the mux pattern it matched,
+// continuing the caller's trace when the request carries a traceparent header. Spans go to the global tracer
+// provider, which records nothing until tracing is set up.
+func traceRequests(mux *http.ServeMux, next http.Handler) http.Handler {
+	return otelhttp.NewHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, route := mux.Handler(r); route != "" {
+			trace.SpanFromContext(r.Context()).SetAttributes(semconv.HTTPRoute(route))
+		}
+		next.ServeHTTP(w, r)
+	}), "http",
+		otelhttp.WithSpanNameFormatter(func(operation string, r *http.Request) string {
+			if _, route := mux.Handler(r); route != "" {
+				return r.Method + " " + route
+			}
+			return r.Method
+		}),
+		otelhttp.WithFilter(func(r *http.Request) bool {
+			return !untracedPaths[r.URL.Path]
+		}),
+	)
+}
@@ -0,0 +1,293 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+
+	"locations/internal/db"
+	"locations/internal/live"
+	"locations/internal/models"
+	"locations/internal/trips"
+)
+
+const (
+	// trackingMinInterval is the shortest time between two positions sent to a rider.
+	trackingMinInterval = time.Second
+	// trackingHeartbeat is how often an idle tracking stream sends a heartbeat.
+	trackingHeartbeat = 15 * time.Second
+	// shutdownReason is the close reason given when the server is stopping.
+	shutdownReason = "server shutting down"
+)
+
+var trackingUpgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// The trip token, not the browser origin, is what authorizes the stream.
+	CheckOrigin: func(r *http.Request) bool { return true },
+	Error:       upgradeError,
+}
+
+// trackingSink is where a tracking stream's events go: a Server-Sent Events response or a WebSocket.
+type trackingSink interface {
+	// send writes one event with a JSON payload.
+	send(event string, data any) error
+	// heartbeat tells the client, and any proxy in between, that the stream is alive.
+	heartbeat() error
+	// gone is closed when the client disconnects.
+	gone() <-chan struct{}
+	// close ends the stream, telling the client why if the transport allows.
+	close(reason string)
+}
+
+// TripTrackingHandler handles GET /trips/{tripID}/track, a stream of the trip's driver positions for a rider.
+// The client authenticates with a trip token, as a bearer token or in the access_token query parameter, which
+// EventSource clients need. The stream is Server-Sent Events, or a WebSocket when the request asks to upgrade.
+// It starts with the driver's current position and then sends at most one position per trackingMinInterval,
+// always the newest, with heartbeat events while the driver is quiet. It ends with a trip_ended event when the
+// trip service ends the trip, a token_expired event when the token runs out, or when ctx is canceled.
+func TripTrackingHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, database db.Database, hub *live.Hub, tracker *trips.Tracker) {
+	if tracker == nil {
+		writeProblem(w, r, http.StatusForbidden, CodeDisabled, "Trip tracking is disabled")
+		return
+	}
+	tripID, ok := parseTripPath(r.URL.Path, "/track")
+	if !ok {
+		notFound(w, r, "Trip not found")
+		return
+	}
+
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		token = r.URL.Query().Get("access_token")
+	}
+	claims, err := tracker.Verify(token)
+	switch {
+	case errors.Is(err, trips.ErrTripEnded):
+		writeProblem(w, r, http.StatusGone, CodeGone, "Trip has ended")
+		return
+	case err != nil:
+		w.Header().Set("WWW-Authenticate", `Bearer realm="locations-trip"`)
+		writeProblem(w, r, http.StatusUnauthorized, CodeUnauthorized, "A valid trip token is required")
+		return
+	case claims.TripID != tripID:
+		writeProblem(w, r, http.StatusForbidden, CodeForbidden, "Token is not valid for this trip")
+		return
+	}
+
+	var sink trackingSink
+	if websocket.IsWebSocketUpgrade(r) {
+		conn, err := trackingUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		sink = newWebSocketSink(conn)
+	} else {
+		sink, err = newSSESink(w, r)
+		if err != nil {
+			internalError(w, r, "Streaming is not supported", err)
+			return
+		}
+	}
+
+	streamTrip(ctx, sink, database, hub, tracker, claims)
+}
+
+// streamTrip feeds the sink until the trip ends, the token expires, the client leaves or ctx is canceled.
+func streamTrip(ctx context.Context, sink trackingSink, database db.Database, hub *live.Hub, tracker *trips.Tracker, claims *trips.Claims) {
+	sub := hub.Subscribe(claims.DriverID)
+	defer sub.Close()
+
+	var lastSent time.Time
+	sendPosition := func(driver models.Driver) error {
+		lastSent = time.Now()
+		return sink.send("position", models.NewDriverPosition(driver, lastSent.UTC(), staleAfter))
+	}
+
+	// Start with the current position so the rider isn't waiting for the driver's next fix.
+	if driver, err := database.GetDriverLocation(ctx, claims.DriverID); err == nil {
+		if sendPosition(*driver) != nil {
+			sink.close("")
+			return
+		}
+	}
+
+	tripDone, stopWaiting := tracker.Done(claims.TripID)
+	defer stopWaiting()
+	heartbeat := time.NewTicker(trackingHeartbeat)
+	defer heartbeat.Stop()
+	expiry := time.NewTimer(time.Until(claims.ExpiresAt))
+	defer expiry.Stop()
+
+	var (
+		pending  *models.Driver
+		throttle *time.Timer
+		release  <-chan time.Time
+	)
+	defer func() {
+		if throttle != nil {
+			throttle.Stop()
+		}
+	}()
+
+	for {
+		var err error
+		select {
+		case <-ctx.Done():
+			sink.close(shutdownReason)
+			return
+		case <-sink.gone():
+			sink.close("")
+			return
+		case <-tripDone:
+			sink.send("trip_ended", map[string]string{"trip_id": claims.TripID})
+			sink.close("trip ended")
+			return
+		case <-expiry.C:
+			sink.send("token_expired", map[string]string{"trip_id": claims.TripID})
+			sink.close("token expired")
+			return
+		case driver, ok := <-sub.C:
+			if !ok {
+				sink.close(shutdownReason)
+				return
+			}
+			// Positions arriving faster than the rate limit replace each other; only the newest is sent.
+			if wait := trackingMinInterval - time.Since(lastSent); wait > 0 {
+				pending = &driver
+				if release == nil {
+					throttle = time.NewTimer(wait)
+					release = throttle.C
+				}
+				continue
+			}
+			err = sendPosition(driver)
+		case <-release:
+			release = nil
+			if pending != nil {
+				err = sendPosition(*pending)
+				pending = nil
+			}
+		case <-heartbeat.C:
+			err = sink.heartbeat()
+		}
+		if err != nil {
+			sink.close("")
+			return
+		}
+	}
+}
+
+// parseTripPath extracts the trip ID from /trips/{tripID}<suffix>.
+func parseTripPath(path, suffix string) (string, bool) {
+	rest, ok := strings.CutPrefix(path, "/trips/")
+	if !ok {
+		return "", false
+	}
+	tripID, ok := strings.CutSuffix(rest, suffix)
+	if !ok || tripID == "" || strings.Contains(tripID, "/") {
+		return "", false
+	}
+	return tripID, true
+}
+
+// sseSink writes events to a Server-Sent Events response.
+type sseSink struct {
+	w       http.ResponseWriter
+	flusher http.Flusher
+	done    <-chan struct{}
+}
+
+func newSSESink(w http.ResponseWriter, r *http.Request) (*sseSink, error) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return nil, errors.New("streaming is not supported")
+	}
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+	return &sseSink{w: w, flusher: flusher, done: r.Context().Done()}, nil
+}
+
+func (s *sseSink) send(event string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(s.w, "event: %s\ndata: %s\n\n", event, payload); err != nil {
+		return err
+	}
+	s.flusher.Flush()
+	return nil
+}
+
+func (s *sseSink) heartbeat() error {
+	return s.send("heartbeat", map[string]string{})
+}
+
+func (s *sseSink) gone() <-chan struct{} {
+	return s.done
+}
+
+// close has nothing to do: returning from the handler ends the response.
+func (s *sseSink) close(string) {}
+
+// webSocketSink writes events as {"type": ..., "data": ...} messages.
+// A reader goroutine answers pings, handles pongs and notices when the client goes away.
+type webSocketSink struct {
+	conn *websocket.Conn
+	done chan struct{}
+}
+
+func newWebSocketSink(conn *websocket.Conn) *webSocketSink {
+	s := &webSocketSink{conn: conn, done: make(chan struct{})}
+
+	conn.SetReadLimit(512)
+	conn.SetReadDeadline(time.Now().Add(2 * trackingHeartbeat))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(2 * trackingHeartbeat))
+	})
+	go func() {
+		defer close(s.done)
+		for {
+			// Riders don't send anything; reading only processes control frames.
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	return s
+}
+
+func (s *webSocketSink) send(event string, data any) error {
+	s.conn.SetWriteDeadline(time.Now().Add(ingestWriteWait))
+	return s.conn.WriteJSON(map[string]any{"type": event, "data": data})
+}
+
+func (s *webSocketSink) heartbeat() error {
+	if err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(ingestWriteWait)); err != nil {
+		return err
+	}
+	return s.send("heartbeat", map[string]string{})
+}
+
+func (s *webSocketSink) gone() <-chan struct{} {
+	return s.done
+}
+
+func (s *webSocketSink) close(reason string) {
+	code := websocket.CloseNormalClosure
+	if reason == shutdownReason {
+		code = websocket.CloseGoingAway
+	}
+	s.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(ingestWriteWait))
+	s.conn.Close()
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"locations/internal/openapi"
+)
+
+// validateRequests checks requests against the OpenAPI document before passing them to next. Requests the
+// document doesn't allow get 400 Bad Request, methods it doesn't list 405 Method Not Allowed, and bodies over
+// openapi.MaxBodyBytes 413 Request Entity Too Large. Paths the document doesn't describe pass through to the
+// mux. Since this runs before authentication, only bodies the validator reads are buffered, and only up to
+// openapi.MaxBodyBytes; the others, such as batches, are left to their handlers.
+// The handlers still check what they use, so the document is an extra layer rather than the only one.
+func validateRequests(validator *openapi.Validator, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Body != nil && r.Body != http.NoBody && validator.ReadsBody(r) {
+			data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, openapi.MaxBodyBytes))
+			r.Body.Close()
+			var maxBytesErr *http.MaxBytesError
+			switch {
+			case errors.As(err, &maxBytesErr):
+				writeProblem(w, r, http.StatusRequestEntityTooLarge, CodePayloadTooLarge,
+					fmt.Sprintf("Request body must not exceed %d bytes", openapi.MaxBodyBytes))
+				return
+			case err != nil:
+				writeProblem(w, r, http.StatusBadRequest, CodeInvalidRequest, "Failed to read request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(data))
+		}
+
+		err := validator.Validate(r)
+		var fieldErr *openapi.FieldError
+		switch {
+		case err == nil, errors.Is(err, openapi.ErrUnknownRoute):
+			next.ServeHTTP(w, r)
+		case errors.Is(err, openapi.ErrMethodNotAllowed):
+			methodNotAllowed(w, r)
+		case errors.As(err, &fieldErr):
+			invalidRequest(w, r, fieldErr)
+		default:
+			internalError(w, r, "Failed to validate request", err)
+		}
+	})
+}
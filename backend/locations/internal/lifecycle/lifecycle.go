@@ -0,0 +1,61 @@
+// Package lifecycle runs the parts of the service together, so that they start and stop as one.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"locations/internal/logging"
+)
+
+// Component is a part of a role that runs until its context is canceled.
+type Component struct {
+	Name string
+	Run  func(ctx context.Context) error
+}
+
+// Run runs components until ctx is canceled or one of them fails, which stops the others, and returns
+// once all of them have returned. A component that returns before ctx is canceled has failed, even with nil.
+// It returns the first failure; errors after ctx is canceled are only logged, to logger or, if nil, slog.Default().
+func Run(ctx context.Context, logger *slog.Logger, components []Component) error {
+	logger = logging.Or(logger)
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		once     sync.Once
+		firstErr error
+	)
+	for _, c := range components {
+		c := c
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := c.Run(ctx)
+			if err == nil && ctx.Err() == nil {
+				err = errors.New("stopped unexpectedly")
+			}
+			if err == nil {
+				return
+			}
+			if ctx.Err() != nil {
+				logger.Error("Error stopping "+c.Name, "error", err)
+				return
+			}
+			once.Do(func() {
+				firstErr = fmt.Errorf("%s failed: %w", c.Name, err)
+				cancel()
+			})
+		}()
+	}
+	logger.Info("Running", "components", len(components))
+
+	<-ctx.Done()
+	logger.Info("Shutting down")
+	wg.Wait()
+	return firstErr
+}
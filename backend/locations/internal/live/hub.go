@@ -0,0 +1,247 @@
+package live
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"locations/internal/db"
+	"locations/internal/logging"
+	"locations/internal/models"
+)
+
+// subscriberBuffer is how many positions a subscriber can fall behind before updates to it are dropped.
+const subscriberBuffer = 64
+
+// DefaultPollInterval is how often the polling fallback asks the backend for changed positions.
+const DefaultPollInterval = 2 * time.Second
+
+// pollLookback is how far back polling starts, and pollOverlap how far before the last stored position each
+// poll reaches; see poll.
+const (
+	pollLookback = time.Minute
+	pollOverlap  = 5 * time.Second
+)
+
+// latestRetention is how long the hub remembers a driver's last position after publishing it. The copies it
+// drops arrive from its sources within seconds of each other, so drivers quiet for longer are forgotten.
+const latestRetention = 10 * time.Minute
+
+// Subscription receives live positions published to a Hub.
+type Subscription struct {
+	// C delivers positions; it is closed when the subscription is closed or the hub stops.
+	C <-chan models.Driver
+
+	hub      *Hub
+	ch       chan models.Driver
+	driverID string
+	once     sync.Once
+}
+
+// Close stops delivery to the subscription.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.remove(s)
+	})
+}
+
+// Hub fans live position changes out to in-process subscribers.
+// A slow subscriber never blocks the others: when its buffer is full, positions for it are dropped,
+// which is acceptable because the next position supersedes the missed one.
+// The hub can be fed from several sources at once, such as the in-process consumer and the database,
+// so a position that is not newer than the last one published for its driver is dropped as a duplicate.
+type Hub struct {
+	mu          sync.Mutex
+	subscribers map[*Subscription]struct{}
+	latest      map[string]latestPosition
+	closed      bool
+	now         func() time.Time
+	lastSweep   time.Time
+}
+
+// latestPosition is when a driver's last published position was taken and when the hub published it.
+type latestPosition struct {
+	updatedAt time.Time
+	published time.Time
+}
+
+// NewHub creates a Hub with no subscribers.
+func NewHub() *Hub {
+	return NewHubWithClock(time.Now)
+}
+
+// NewHubWithClock is NewHub with a custom clock, for tests.
+func NewHubWithClock(now func() time.Time) *Hub {
+	return &Hub{
+		subscribers: make(map[*Subscription]struct{}),
+		latest:      make(map[string]latestPosition),
+		now:         now,
+		lastSweep:   now(),
+	}
+}
+
+// Subscribe returns a subscription to positions for driverID, or for every driver when driverID is empty.
+func (h *Hub) Subscribe(driverID string) *Subscription {
+	ch := make(chan models.Driver, subscriberBuffer)
+	sub := &Subscription{C: ch, hub: h, ch: ch, driverID: driverID}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		close(ch)
+		return sub
+	}
+	h.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// Publish delivers a position to every matching subscriber, unless it is not newer than the driver's last one.
+func (h *Hub) Publish(driver models.Driver) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	now := h.now()
+	if now.Sub(h.lastSweep) >= time.Minute {
+		for driverID, latest := range h.latest {
+			if now.Sub(latest.published) > latestRetention {
+				delete(h.latest, driverID)
+			}
+		}
+		h.lastSweep = now
+	}
+
+	if latest, ok := h.latest[driver.DriverID]; ok && !driver.UpdatedAt.After(latest.updatedAt) {
+		return
+	}
+	h.latest[driver.DriverID] = latestPosition{updatedAt: driver.UpdatedAt, published: now}
+
+	for sub := range h.subscribers {
+		if sub.driverID != "" && sub.driverID != driver.DriverID {
+			continue
+		}
+		select {
+		case sub.ch <- driver:
+		default:
+		}
+	}
+}
+
+// PublishLocation publishes a stored location update as the driver's live position.
+// It lets the consumer feed the hub directly, without waiting for the database to report the change.
+func (h *Hub) PublishLocation(update models.LocationUpdate) {
+	h.Publish(models.Driver{
+		DriverID:  update.DriverID,
+		Location:  models.NewGeoPoint(update.Latitude, update.Longitude),
+		UpdatedAt: update.Timestamp,
+		Accuracy:  update.Accuracy,
+	})
+}
+
+// Run feeds the hub from the database until ctx is done, then closes every subscription.
+// Backends that implement db.LivePositionWatcher push changes as they happen; if the backend reports
+// that change streams are unsupported, or only implements db.LivePositionLister, the hub polls instead.
+// Failures of the feed are logged to logger; nil logs to slog.Default().
+func (h *Hub) Run(ctx context.Context, database db.Database, pollInterval time.Duration, logger *slog.Logger) error {
+	defer h.close()
+	logger = logging.Or(logger)
+
+	if watcher, ok := db.Find[db.LivePositionWatcher](database); ok {
+		err := watchWithRetry(ctx, watcher, h.Publish, logger)
+		if err == nil || !errors.Is(err, db.ErrChangeStreamsUnsupported) {
+			return err
+		}
+		logger.Warn("Change streams unavailable, falling back to polling for live positions")
+	}
+
+	lister, ok := db.Find[db.LivePositionLister](database)
+	if !ok {
+		return fmt.Errorf("database %T supports neither change streams nor polling for live positions", database)
+	}
+	return poll(ctx, lister, pollInterval, h.Publish, logger)
+}
+
+// watchWithRetry keeps a change stream open, reopening it after transient failures.
+func watchWithRetry(ctx context.Context, watcher db.LivePositionWatcher, publish func(models.Driver), logger *slog.Logger) error {
+	backoff := time.Second
+	for {
+		err := watcher.WatchLivePositions(ctx, publish)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if errors.Is(err, db.ErrChangeStreamsUnsupported) {
+			return err
+		}
+		logger.Warn("Live position change stream stopped, reopening", "error", err, "backoff", backoff)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		if backoff < 30*time.Second {
+			backoff *= 2
+		}
+	}
+}
+
+// poll asks the lister for positions stored since the last one seen, by the time the server stored them.
+// It starts pollLookback in the past, so that positions stored while the process restarted are published too,
+// and every query reaches back pollOverlap before the last one seen, for positions whose writes committed late.
+// Positions seen twice are dropped by Publish.
+func poll(ctx context.Context, lister db.LivePositionLister, interval time.Duration, publish func(models.Driver), logger *slog.Logger) error {
+	if interval <= 0 {
+		interval = DefaultPollInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	since := time.Now().UTC().Add(-pollLookback)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		drivers, err := lister.LivePositionsSince(ctx, since.Add(-pollOverlap))
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			logger.Error("Error polling live positions", "error", err)
+			continue
+		}
+		for _, driver := range drivers {
+			publish(driver)
+			if driver.StoredAt.After(since) {
+				since = driver.StoredAt
+			}
+		}
+	}
+}
+
+// remove detaches a subscription and closes its channel.
+func (h *Hub) remove(sub *Subscription) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[sub]; ok {
+		delete(h.subscribers, sub)
+		close(sub.ch)
+	}
+}
+
+// close detaches every subscription; later subscriptions are closed immediately.
+func (h *Hub) close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for sub := range h.subscribers {
+		delete(h.subscribers, sub)
+		close(sub.ch)
+	}
+	h.closed = true
+}
@@ -0,0 +1,126 @@
+// Package logging builds the service's structured logger. Every line logged with a request's context carries
+// the request and trace IDs, and coordinates are redacted unless the logger is at debug level.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Formats that Config.Format selects.
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+)
+
+// Redacted replaces the values of sensitive attributes.
+const Redacted = "[redacted]"
+
+// sensitiveKeys are attributes that locate a driver, logged only at debug level.
+var sensitiveKeys = map[string]bool{
+	"latitude":     true,
+	"longitude":    true,
+	"coordinates":  true,
+	"message_body": true,
+}
+
+// Config selects the logger's level and format.
+type Config struct {
+	// Level is the least severe level logged. At slog.LevelDebug coordinates are logged as they are.
+	Level slog.Level
+	// Format is FormatJSON or FormatText; empty means FormatJSON.
+	Format string
+}
+
+// ParseLevel parses a level name such as "debug", "info", "warn" or "error", case-insensitively.
+func ParseLevel(name string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(name)); err != nil {
+		return level, fmt.Errorf("invalid log level %q: %w", name, err)
+	}
+	return level, nil
+}
+
+// Validate checks that the format is known.
+func (c Config) Validate() error {
+	switch c.Format {
+	case "", FormatJSON, FormatText:
+		return nil
+	}
+	return fmt.Errorf("unknown log format %q", c.Format)
+}
+
+// New creates a logger writing to w.
+func New(w io.Writer, config Config) (*slog.Logger, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	options := &slog.HandlerOptions{Level: config.Level}
+	if config.Level > slog.LevelDebug {
+		options.ReplaceAttr = redact
+	}
+	var handler slog.Handler
+	if config.Format == FormatText {
+		handler = slog.NewTextHandler(w, options)
+	} else {
+		handler = slog.NewJSONHandler(w, options)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// Or returns logger, or slog.Default() if it is nil, for packages whose logger is optional.
+func Or(logger *slog.Logger) *slog.Logger {
+	if logger == nil {
+		return slog.Default()
+	}
+	return logger
+}
+
+// redact replaces the values of sensitive attributes.
+func redact(groups []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[a.Key] {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+type requestIDKey struct{}
+
+// WithRequestID returns ctx carrying the ID of the request it belongs to.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the ID of the request ctx belongs to, or "" outside a request.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// contextHandler adds the request ID and the trace and span IDs found in the context to each record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if id := RequestIDFromContext(ctx); id != "" {
+		record.AddAttrs(slog.String("request_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		record.AddAttrs(slog.String("trace_id", span.TraceID().String()), slog.String("span_id", span.SpanID().String()))
+	}
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
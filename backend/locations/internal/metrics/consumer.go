@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+
+	"locations/internal/consumer"
+)
+
+// MessageProcessor records processing time, outcomes and partition lag of the MessageProcessor it wraps.
+type MessageProcessor struct {
+	inner   consumer.MessageProcessor
+	metrics *Metrics
+}
+
+// NewMessageProcessor wraps inner.
+func NewMessageProcessor(inner consumer.MessageProcessor, metrics *Metrics) *MessageProcessor {
+	return &MessageProcessor{inner: inner, metrics: metrics}
+}
+
+// ProcessMessage processes msg with the wrapped processor. The lag is taken from the message's high watermark,
+// the end of its partition as of the fetch that returned it.
+func (p *MessageProcessor) ProcessMessage(ctx context.Context, msg kafka.Message) error {
+	partition := strconv.Itoa(msg.Partition)
+	lag := msg.HighWaterMark - msg.Offset - 1
+	if lag < 0 {
+		lag = 0
+	}
+	p.metrics.consumerLag.WithLabelValues(partition).Set(float64(lag))
+
+	start := time.Now()
+	err := p.inner.ProcessMessage(ctx, msg)
+	p.metrics.consumerDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		p.metrics.consumerFailed.WithLabelValues(partition).Inc()
+		return err
+	}
+	p.metrics.consumerProcessed.WithLabelValues(partition).Inc()
+	return nil
+}
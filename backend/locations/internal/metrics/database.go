@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"locations/internal/db"
+	"locations/internal/models"
+)
+
+// Database records the latency and failures of each operation of the Database it wraps.
+// Optional capabilities of the wrapped database are reached through Unwrap with db.Find.
+type Database struct {
+	inner   db.Database
+	metrics *Metrics
+}
+
+// NewDatabase wraps inner.
+func NewDatabase(inner db.Database, metrics *Metrics) *Database {
+	return &Database{inner: inner, metrics: metrics}
+}
+
+// Unwrap returns the wrapped database.
+func (d *Database) Unwrap() db.Database {
+	return d.inner
+}
+
+// InsertLocationUpdate is recorded under its method name, as are the other operations.
+func (d *Database) InsertLocationUpdate(ctx context.Context, update models.LocationUpdate) error {
+	return d.do("InsertLocationUpdate", func() error {
+		return d.inner.InsertLocationUpdate(ctx, update)
+	})
+}
+
+// GetLocationByID is recorded as "GetLocationByID".
+func (d *Database) GetLocationByID(ctx context.Context, id string) (*models.LocationUpdate, error) {
+	var location *models.LocationUpdate
+	err := d.do("GetLocationByID", func() (err error) {
+		location, err = d.inner.GetLocationByID(ctx, id)
+		return err
+	})
+	return location, err
+}
+
+// GetDriverLocation is recorded as "GetDriverLocation".
+func (d *Database) GetDriverLocation(ctx context.Context, driverID string) (*models.Driver, error) {
+	var driver *models.Driver
+	err := d.do("GetDriverLocation", func() (err error) {
+		driver, err = d.inner.GetDriverLocation(ctx, driverID)
+		return err
+	})
+	return driver, err
+}
+
+// UpdateLocation is recorded as "UpdateLocation".
+func (d *Database) UpdateLocation(ctx context.Context, id string, update models.LocationUpdate, expectedVersion int64) (*db.UpdateResult, error) {
+	var result *db.UpdateResult
+	err := d.do("UpdateLocation", func() (err error) {
+		result, err = d.inner.UpdateLocation(ctx, id, update, expectedVersion)
+		return err
+	})
+	return result, err
+}
+
+// GetNearbyDrivers is recorded as "GetNearbyDrivers".
+func (d *Database) GetNearbyDrivers(ctx context.Context, latitude, longitude string) ([]models.Driver, error) {
+	var drivers []models.Driver
+	err := d.do("GetNearbyDrivers", func() (err error) {
+		drivers, err = d.inner.GetNearbyDrivers(ctx, latitude, longitude)
+		return err
+	})
+	return drivers, err
+}
+
+// ExportDriverData is recorded as "ExportDriverData".
+func (d *Database) ExportDriverData(ctx context.Context, request models.PrivacyRequest) (*models.DriverDataExport, error) {
+	var export *models.DriverDataExport
+	err := d.do("ExportDriverData", func() (err error) {
+		export, err = d.inner.ExportDriverData(ctx, request)
+		return err
+	})
+	return export, err
+}
+
+// EraseDriverData is recorded as "EraseDriverData".
+func (d *Database) EraseDriverData(ctx context.Context, request models.PrivacyRequest) (*models.DriverErasureResult, error) {
+	var result *models.DriverErasureResult
+	err := d.do("EraseDriverData", func() (err error) {
+		result, err = d.inner.EraseDriverData(ctx, request)
+		return err
+	})
+	return result, err
+}
+
+// Heatmap is recorded as "Heatmap".
+func (d *Database) Heatmap(ctx context.Context, query models.HeatmapQuery) ([]models.HeatmapCell, error) {
+	var cells []models.HeatmapCell
+	err := d.do("Heatmap", func() (err error) {
+		cells, err = d.inner.Heatmap(ctx, query)
+		return err
+	})
+	return cells, err
+}
+
+// do runs one operation and records how long it took and whether it failed.
+func (d *Database) do(operation string, fn func() error) error {
+	start := time.Now()
+	err := fn()
+	d.metrics.databaseDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+	if failed(err) {
+		d.metrics.databaseErrors.WithLabelValues(operation).Inc()
+	}
+	return err
+}
+
+// failed reports whether err is a failure of the database, rather than an answer such as a missing document
+// or a version or ID conflict.
+func failed(err error) bool {
+	return err != nil && !errors.Is(err, db.ErrNotFound) && !errors.Is(err, db.ErrVersionConflict) &&
+		!errors.Is(err, db.ErrIDConflict) && !errors.Is(err, mongo.ErrNoDocuments)
+}
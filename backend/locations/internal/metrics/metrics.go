@@ -0,0 +1,170 @@
+// Package metrics exposes Prometheus metrics for the HTTP API, the Kafka producer and consumer, and the database.
+// The producer, consumer and database are instrumented by wrappers around their interfaces, so the
+// instrumented code doesn't know about metrics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace prefixes every metric name.
+const namespace = "locations"
+
+// Metrics holds the service's collectors and the registry they are exposed from.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpRequests *prometheus.CounterVec
+	httpDuration *prometheus.HistogramVec
+
+	publishDuration *prometheus.HistogramVec
+	publishFailures *prometheus.CounterVec
+	publishedTotal  prometheus.Counter
+
+	consumerProcessed *prometheus.CounterVec
+	consumerFailed    *prometheus.CounterVec
+	consumerDuration  prometheus.Histogram
+	consumerLag       *prometheus.GaugeVec
+
+	databaseDuration *prometheus.HistogramVec
+	databaseErrors   *prometheus.CounterVec
+
+	breakerState     prometheus.Gauge
+	databaseRetries  *prometheus.CounterVec
+	databaseRejected *prometheus.CounterVec
+}
+
+// New creates the collectors and registers them, along with the Go runtime and process collectors,
+// in a registry of their own.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		httpRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "requests_total",
+			Help:      "HTTP requests served, by route, method and status code.",
+		}, []string{"route", "method", "status"}),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Time to serve HTTP requests, by route, method and status code. Streams last as long as the client stays connected.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+
+		publishDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "producer",
+			Name:      "publish_duration_seconds",
+			Help:      "Time to publish location updates to Kafka, by operation.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		publishFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "producer",
+			Name:      "publish_failures_total",
+			Help:      "Failed publishes to Kafka, by operation.",
+		}, []string{"operation"}),
+		publishedTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "producer",
+			Name:      "published_messages_total",
+			Help:      "Location updates published to Kafka.",
+		}),
+
+		consumerProcessed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "consumer",
+			Name:      "messages_processed_total",
+			Help:      "Kafka messages processed successfully, by partition.",
+		}, []string{"partition"}),
+		consumerFailed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "consumer",
+			Name:      "messages_failed_total",
+			Help:      "Kafka messages that failed processing, by partition.",
+		}, []string{"partition"}),
+		consumerDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "consumer",
+			Name:      "processing_duration_seconds",
+			Help:      "Time to process a Kafka message.",
+			Buckets:   prometheus.DefBuckets,
+		}),
+		consumerLag: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "consumer",
+			Name:      "lag_messages",
+			Help:      "Messages left to consume on each partition as of the last message read from it.",
+		}, []string{"partition"}),
+
+		databaseDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "database",
+			Name:      "operation_duration_seconds",
+			Help:      "Time taken by database operations, by operation.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		databaseErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "database",
+			Name:      "operation_errors_total",
+			Help:      "Failed database operations, by operation. Missing documents are not failures.",
+		}, []string{"operation"}),
+		breakerState: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "database",
+			Name:      "breaker_state",
+			Help:      "State of the database circuit breaker: 0 closed, 1 open, 2 half-open.",
+		}),
+		databaseRetries: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "database",
+			Name:      "retries_total",
+			Help:      "Database operations attempted again after a transient failure, by operation.",
+		}, []string{"operation"}),
+		databaseRejected: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "database",
+			Name:      "rejected_total",
+			Help:      "Database operations failed fast by the open circuit breaker, by operation.",
+		}, []string{"operation"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpRequests, m.httpDuration,
+		m.publishDuration, m.publishFailures, m.publishedTotal,
+		m.consumerProcessed, m.consumerFailed, m.consumerDuration, m.consumerLag,
+		m.databaseDuration, m.databaseErrors,
+		m.breakerState, m.databaseRetries, m.databaseRejected,
+	)
+	return m
+}
+
+// Registry returns the registry the metrics are exposed from, for registering further collectors.
+func (m *Metrics) Registry() *prometheus.Registry {
+	return m.registry
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// ObserveHTTPRequest records a served HTTP request. route must be the pattern the request matched rather than
+// its path, so that IDs in paths don't create a series per ID.
+func (m *Metrics) ObserveHTTPRequest(route, method string, status int, duration time.Duration) {
+	labels := prometheus.Labels{"route": route, "method": method, "status": strconv.Itoa(status)}
+	m.httpRequests.With(labels).Inc()
+	m.httpDuration.With(labels).Observe(duration.Seconds())
+}
@@ -0,0 +1,46 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"locations/internal/models"
+	"locations/internal/producer"
+)
+
+// Producer records publish latency and failures of the LocationProducer it wraps.
+type Producer struct {
+	inner   producer.LocationProducer
+	metrics *Metrics
+}
+
+// NewProducer wraps inner.
+func NewProducer(inner producer.LocationProducer, metrics *Metrics) *Producer {
+	return &Producer{inner: inner, metrics: metrics}
+}
+
+// ProduceLocationUpdate publishes through the wrapped producer under the "single" operation.
+func (p *Producer) ProduceLocationUpdate(ctx context.Context, location models.LocationUpdate) error {
+	start := time.Now()
+	err := p.inner.ProduceLocationUpdate(ctx, location)
+	p.observe("single", 1, time.Since(start), err)
+	return err
+}
+
+// ProduceLocationUpdates publishes through the wrapped producer under the "batch" operation.
+func (p *Producer) ProduceLocationUpdates(ctx context.Context, locations []models.LocationUpdate) error {
+	start := time.Now()
+	err := p.inner.ProduceLocationUpdates(ctx, locations)
+	p.observe("batch", len(locations), time.Since(start), err)
+	return err
+}
+
+// observe records one publish of count messages.
+func (p *Producer) observe(operation string, count int, duration time.Duration, err error) {
+	p.metrics.publishDuration.WithLabelValues(operation).Observe(duration.Seconds())
+	if err != nil {
+		p.metrics.publishFailures.WithLabelValues(operation).Inc()
+		return
+	}
+	p.metrics.publishedTotal.Add(float64(count))
+}
@@ -0,0 +1,36 @@
+package metrics
+
+import (
+	"time"
+
+	"locations/internal/db"
+	"locations/internal/resilience"
+)
+
+// ResilienceObserver records the retries, rejections and breaker state of a db.ResilientDatabase.
+type ResilienceObserver struct {
+	metrics *Metrics
+}
+
+var _ db.ResilienceObserver = (*ResilienceObserver)(nil)
+
+// NewResilienceObserver returns an observer that records into metrics.
+func NewResilienceObserver(metrics *Metrics) *ResilienceObserver {
+	return &ResilienceObserver{metrics: metrics}
+}
+
+// ObserveOperation counts the attempts after the first as retries, and a call the breaker rejected,
+// which made no attempts, as a rejection. Latency and failures are recorded per attempt by Database.
+func (o *ResilienceObserver) ObserveOperation(operation string, attempts int, _ time.Duration, _ error) {
+	switch {
+	case attempts == 0:
+		o.metrics.databaseRejected.WithLabelValues(operation).Inc()
+	case attempts > 1:
+		o.metrics.databaseRetries.WithLabelValues(operation).Add(float64(attempts - 1))
+	}
+}
+
+// ObserveBreakerState sets the breaker state gauge.
+func (o *ResilienceObserver) ObserveBreakerState(state resilience.State) {
+	o.metrics.breakerState.Set(float64(state))
+}
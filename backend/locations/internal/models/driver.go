@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+// GeoPoint is a GeoJSON point. Coordinates are stored as [longitude, latitude],
+// which is the order MongoDB's 2dsphere index expects.
+type GeoPoint struct {
+	Type        string    `json:"type" bson:"type"`
+	Coordinates []float64 `json:"coordinates" bson:"coordinates"`
+}
+
+// NewGeoPoint builds a GeoJSON point from a latitude and longitude.
+func NewGeoPoint(latitude, longitude float64) GeoPoint {
+	return GeoPoint{Type: "Point", Coordinates: []float64{longitude, latitude}}
+}
+
+type Driver struct {
+	DriverID  string    `json:"driver_id" bson:"driver_id"`
+	Location  GeoPoint  `json:"location" bson:"location"`
+	UpdatedAt time.Time `json:"updated_at" bson:"updated_at"`
+	// Accuracy is the horizontal accuracy of the fix in meters, or 0 if unknown.
+	Accuracy float64 `json:"accuracy,omitempty" bson:"accuracy,omitempty"`
+	// StoredAt is when the server stored the position, by its own clock; polling for changes keys on it.
+	StoredAt time.Time `json:"-" bson:"stored_at,omitempty"`
+}
+
+// DriverPosition is a driver's latest known position as served to clients.
+type DriverPosition struct {
+	DriverID  string    `json:"driver_id"`
+	Latitude  float64   `json:"latitude"`
+	Longitude float64   `json:"longitude"`
+	Accuracy  float64   `json:"accuracy,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// AgeSeconds is how long before the response the fix was taken.
+	AgeSeconds float64 `json:"age_seconds"`
+	// Stale is set when the fix is older than the server's staleness threshold.
+	Stale bool `json:"stale"`
+}
+
+// NewDriverPosition describes a live position as of now, flagging it stale when older than staleAfter.
+func NewDriverPosition(driver Driver, now time.Time, staleAfter time.Duration) DriverPosition {
+	position := DriverPosition{
+		DriverID:  driver.DriverID,
+		Accuracy:  driver.Accuracy,
+		UpdatedAt: driver.UpdatedAt,
+	}
+	if len(driver.Location.Coordinates) == 2 {
+		position.Longitude, position.Latitude = driver.Location.Coordinates[0], driver.Location.Coordinates[1]
+	}
+	age := now.Sub(driver.UpdatedAt)
+	if age < 0 {
+		age = 0
+	}
+	position.AgeSeconds = age.Seconds()
+	position.Stale = age > staleAfter
+	return position
+}
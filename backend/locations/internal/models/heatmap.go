@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// HeatmapQuery selects the location updates aggregated into a heatmap.
+type HeatmapQuery struct {
+	MinLatitude  float64
+	MinLongitude float64
+	MaxLatitude  float64
+	MaxLongitude float64
+	Since        time.Time
+	Until        time.Time
+	// Precision is the geohash length of the cells.
+	Precision int
+}
+
+// HeatmapCell is the activity within one geohash cell.
+type HeatmapCell struct {
+	Geohash         string  `json:"geohash"`
+	Latitude        float64 `json:"latitude"`
+	Longitude       float64 `json:"longitude"`
+	Count           int64   `json:"count"`
+	DistinctDrivers int64   `json:"distinct_drivers"`
+}
@@ -1,11 +1,45 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
+
+// MaxClockSkew is how far ahead of the server's clock a device's timestamp may be. Positions are ordered by
+// their timestamps, so one from further in the future would hide every later fix of its driver.
+const MaxClockSkew = time.Minute
 
 type LocationUpdate struct {
-	ID        string    `json:"id,omitempty"`
-	DriverID  string    `json:"driver_id"`
-	Latitude  float64   `json:"latitude"`
-	Longitude float64   `json:"longitude"`
-	Timestamp time.Time `json:"timestamp"`
+	ID        string    `json:"id,omitempty" bson:"id,omitempty"`
+	DriverID  string    `json:"driver_id" bson:"driver_id"`
+	Latitude  float64   `json:"latitude" bson:"latitude"`
+	Longitude float64   `json:"longitude" bson:"longitude"`
+	Timestamp time.Time `json:"timestamp" bson:"timestamp"`
+	// Accuracy is the horizontal accuracy of the fix in meters as reported by the device, or 0 if unknown.
+	Accuracy float64 `json:"accuracy,omitempty" bson:"accuracy,omitempty"`
+	// Version is incremented on every update and backs the ETag/If-Match checks on PUT /location.
+	Version int64 `json:"version,omitempty" bson:"version"`
+}
+
+// Validate checks the fields every ingestion path requires, whether HTTP, WebSocket, gRPC or MQTT.
+func (l LocationUpdate) Validate() error {
+	if l.DriverID == "" {
+		return fmt.Errorf("driver ID is required")
+	}
+	if l.Latitude < -90 || l.Latitude > 90 {
+		return fmt.Errorf("latitude must be between -90 and 90")
+	}
+	if l.Longitude < -180 || l.Longitude > 180 {
+		return fmt.Errorf("longitude must be between -180 and 180")
+	}
+	if l.Timestamp.IsZero() {
+		return fmt.Errorf("timestamp is required")
+	}
+	if l.Timestamp.After(time.Now().Add(MaxClockSkew)) {
+		return fmt.Errorf("timestamp must not be in the future")
+	}
+	if l.Accuracy < 0 {
+		return fmt.Errorf("accuracy must not be negative")
+	}
+	return nil
 }
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// PrivacyRequest describes a data-subject request against a single driver's data.
+type PrivacyRequest struct {
+	DriverID    string `json:"driver_id"`
+	RequestedBy string `json:"requested_by"`
+	Reason      string `json:"reason,omitempty"`
+}
+
+// DriverDataExport is everything the service stores about a driver.
+type DriverDataExport struct {
+	DriverID     string           `json:"driver_id"`
+	ExportedAt   time.Time        `json:"exported_at"`
+	LivePosition *Driver          `json:"live_position,omitempty"`
+	History      []LocationUpdate `json:"history"`
+	// ArchivedHistory is the driver's history that has been moved to the archive.
+	ArchivedHistory []LocationUpdate `json:"archived_history,omitempty"`
+}
+
+// DriverErasureResult reports how many documents were removed for a driver.
+type DriverErasureResult struct {
+	DriverID            string    `json:"driver_id"`
+	ErasedAt            time.Time `json:"erased_at"`
+	HistoryDeleted      int64     `json:"history_deleted"`
+	LivePositionDeleted int64     `json:"live_position_deleted"`
+	// ArchivedDeleted counts the driver's updates purged from the archive.
+	ArchivedDeleted int64 `json:"archived_deleted"`
+}
@@ -0,0 +1,237 @@
+// Package mqttbridge ingests location updates from hardware trackers that publish over MQTT.
+package mqttbridge
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	mqtt "github.com/eclipse/paho.mqtt.golang"
+
+	"locations/internal/logging"
+	"locations/internal/models"
+	"locations/internal/ratelimit"
+)
+
+const (
+	// connectTimeout bounds how long Run waits for the first connection before giving up.
+	connectTimeout = 30 * time.Second
+	// disconnectQuiesce is how long, in milliseconds, in-flight work gets when the bridge disconnects.
+	disconnectQuiesce = 250
+	// maxRetryBackoff caps the wait between attempts to publish a message while Kafka is unavailable.
+	maxRetryBackoff = 30 * time.Second
+)
+
+// ErrInvalidMessage is returned for a message that can never be accepted, such as one with a malformed
+// payload or a topic outside the pattern. Such messages are logged and dropped.
+var ErrInvalidMessage = errors.New("invalid message")
+
+// ErrRateLimited is returned for a message beyond its driver's rate limit. Trackers can't be told to back off,
+// so such messages are dropped too.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// LocationProducer publishes location updates, such as *producer.KafkaProducer.
+type LocationProducer interface {
+	ProduceLocationUpdate(ctx context.Context, location models.LocationUpdate) error
+}
+
+// Config describes the broker connection and subscription.
+type Config struct {
+	// BrokerURL is the broker address, such as tcp://mqtt:1883, ssl://mqtt:8883 or ws://mqtt:8080/mqtt.
+	BrokerURL string
+	// ClientID identifies the bridge to the broker. The session is persistent, so it must be stable
+	// across restarts and unique among bridge instances.
+	ClientID string
+	Username string
+	Password string
+	// TopicPattern is the filter to subscribe to; its '+' level is the driver ID.
+	TopicPattern string
+	// QoS is the subscription's quality of service. At 1 or 2 the broker keeps messages while the bridge is away.
+	QoS byte
+	// Logger receives the bridge's logs; nil logs to slog.Default().
+	Logger *slog.Logger
+}
+
+// devicePayload is the JSON a tracker publishes. The driver ID comes from the topic; one in the payload
+// is only accepted if it matches. The timestamp is RFC 3339 or Unix seconds, and the time of receipt if
+// missing, since some trackers have no clock.
+type devicePayload struct {
+	DriverID  string          `json:"driver_id,omitempty"`
+	Latitude  *float64        `json:"latitude"`
+	Longitude *float64        `json:"longitude"`
+	Timestamp json.RawMessage `json:"timestamp,omitempty"`
+	Accuracy  float64         `json:"accuracy,omitempty"`
+}
+
+// Bridge maps MQTT messages to location updates and hands them to a LocationProducer.
+type Bridge struct {
+	pattern  topicPattern
+	producer LocationProducer
+	limits   *ratelimit.Policy
+	now      func() time.Time
+}
+
+// NewBridge creates a Bridge for messages on topics matching pattern, rate limited per driver by limits;
+// nil leaves them unlimited. All messages come through one broker connection, so there is no client limit.
+func NewBridge(pattern string, producer LocationProducer, limits *ratelimit.Policy) (*Bridge, error) {
+	parsed, err := parseTopicPattern(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &Bridge{pattern: parsed, producer: producer, limits: limits, now: time.Now}, nil
+}
+
+// HandleMessage maps one message to a location update, validates it and publishes it.
+// Messages that can never be accepted are reported with an error wrapping ErrInvalidMessage,
+// and messages over their driver's rate limit with ErrRateLimited.
+func (b *Bridge) HandleMessage(ctx context.Context, topic string, payload []byte) error {
+	update, err := b.parseMessage(topic, payload)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+	}
+	if decision := b.limits.AllowDriver(ctx, update.DriverID); !decision.Allowed {
+		return ErrRateLimited
+	}
+	if err := b.producer.ProduceLocationUpdate(ctx, update); err != nil {
+		return fmt.Errorf("failed to produce location update: %w", err)
+	}
+	return nil
+}
+
+// parseMessage builds the location update a message carries.
+func (b *Bridge) parseMessage(topic string, payload []byte) (models.LocationUpdate, error) {
+	driverID, ok := b.pattern.driverID(topic)
+	if !ok {
+		return models.LocationUpdate{}, fmt.Errorf("topic %q does not match %q", topic, b.pattern.filter)
+	}
+
+	var message devicePayload
+	if err := json.Unmarshal(payload, &message); err != nil {
+		return models.LocationUpdate{}, fmt.Errorf("payload is not valid JSON")
+	}
+	if message.DriverID != "" && message.DriverID != driverID {
+		return models.LocationUpdate{}, fmt.Errorf("driver ID in payload does not match the topic")
+	}
+	// Without pointers a missing coordinate would read as 0, which is a valid position.
+	if message.Latitude == nil || message.Longitude == nil {
+		return models.LocationUpdate{}, fmt.Errorf("latitude and longitude are required")
+	}
+	timestamp, err := parseTimestamp(message.Timestamp, b.now)
+	if err != nil {
+		return models.LocationUpdate{}, err
+	}
+
+	update := models.LocationUpdate{
+		DriverID:  driverID,
+		Latitude:  *message.Latitude,
+		Longitude: *message.Longitude,
+		Timestamp: timestamp,
+		Accuracy:  message.Accuracy,
+	}
+	if err := update.Validate(); err != nil {
+		return models.LocationUpdate{}, err
+	}
+	return update, nil
+}
+
+// parseTimestamp reads an RFC 3339 string or Unix seconds, falling back to now when the field is missing.
+func parseTimestamp(raw json.RawMessage, now func() time.Time) (time.Time, error) {
+	if len(raw) == 0 || bytes.Equal(raw, []byte("null")) {
+		return now().UTC(), nil
+	}
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		timestamp, err := time.Parse(time.RFC3339Nano, text)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("timestamp must be RFC 3339 or Unix seconds")
+		}
+		return timestamp.UTC(), nil
+	}
+	seconds, err := strconv.ParseFloat(string(raw), 64)
+	if err != nil || seconds <= 0 {
+		return time.Time{}, fmt.Errorf("timestamp must be RFC 3339 or Unix seconds")
+	}
+	return time.UnixMilli(int64(seconds * 1000)).UTC(), nil
+}
+
+// Run connects to the broker and bridges messages until ctx is canceled.
+// The session is persistent and messages are acknowledged only once they are published or dropped,
+// so when Kafka is unavailable the bridge stops taking messages and the broker holds them; messages not yet
+// acknowledged when the bridge stops are redelivered when it reconnects.
+func Run(ctx context.Context, config Config, producer LocationProducer, limits *ratelimit.Policy) error {
+	bridge, err := NewBridge(config.TopicPattern, producer, limits)
+	if err != nil {
+		return err
+	}
+	logger := logging.Or(config.Logger).With("broker", config.BrokerURL)
+
+	handler := func(_ mqtt.Client, message mqtt.Message) {
+		if bridge.handleWithRetry(ctx, message.Topic(), message.Payload(), logger) {
+			message.Ack()
+		}
+	}
+
+	options := mqtt.NewClientOptions().
+		AddBroker(config.BrokerURL).
+		SetClientID(config.ClientID).
+		SetUsername(config.Username).
+		SetPassword(config.Password).
+		SetCleanSession(false).
+		SetAutoReconnect(true).
+		SetAutoAckDisabled(true).
+		SetOnConnectHandler(func(client mqtt.Client) {
+			// Subscribing on every connect restores the subscription if the broker lost the session.
+			token := client.Subscribe(config.TopicPattern, config.QoS, handler)
+			if token.WaitTimeout(connectTimeout) && token.Error() != nil {
+				logger.Error("Failed to subscribe to MQTT topic", "topic", config.TopicPattern, "error", token.Error())
+			}
+		}).
+		SetConnectionLostHandler(func(_ mqtt.Client, err error) {
+			logger.Warn("MQTT connection lost", "error", err)
+		})
+
+	client := mqtt.NewClient(options)
+	token := client.Connect()
+	if !token.WaitTimeout(connectTimeout) {
+		return fmt.Errorf("failed to connect to MQTT broker %s: timed out", config.BrokerURL)
+	}
+	if err := token.Error(); err != nil {
+		return fmt.Errorf("failed to connect to MQTT broker %s: %w", config.BrokerURL, err)
+	}
+	logger.Info("MQTT bridge subscribed", "topic", config.TopicPattern)
+
+	<-ctx.Done()
+	client.Disconnect(disconnectQuiesce)
+	return nil
+}
+
+// handleWithRetry handles a message, retrying with backoff while publishing fails.
+// It reports whether the message is done with and may be acknowledged; it isn't if ctx was canceled first.
+func (b *Bridge) handleWithRetry(ctx context.Context, topic string, payload []byte, logger *slog.Logger) bool {
+	backoff := time.Second
+	for {
+		err := b.HandleMessage(ctx, topic, payload)
+		switch {
+		case err == nil:
+			return true
+		case errors.Is(err, ErrInvalidMessage), errors.Is(err, ErrRateLimited):
+			logger.WarnContext(ctx, "Dropping MQTT message", "topic", topic, "error", err)
+			return true
+		}
+
+		logger.WarnContext(ctx, "Retrying MQTT message", "topic", topic, "backoff", backoff, "error", err)
+		select {
+		case <-ctx.Done():
+			return false
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package mqttbridge
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DefaultTopicPattern is the topic filter hardware trackers publish to, with the driver ID as the wildcard level.
+const DefaultTopicPattern = "fleet/+/location"
+
+// topicPattern is an MQTT topic filter with exactly one single-level wildcard, which holds the driver ID.
+type topicPattern struct {
+	filter      string
+	levels      []string
+	driverLevel int
+}
+
+// parseTopicPattern checks that pattern is a filter the driver ID can be taken from unambiguously:
+// one '+' level and no '#', since a multi-level wildcard could swallow the ID.
+func parseTopicPattern(pattern string) (topicPattern, error) {
+	if pattern == "" {
+		return topicPattern{}, fmt.Errorf("topic pattern is required")
+	}
+	levels := strings.Split(pattern, "/")
+	driverLevel := -1
+	for i, level := range levels {
+		switch {
+		case level == "+":
+			if driverLevel >= 0 {
+				return topicPattern{}, fmt.Errorf("topic pattern %q must contain exactly one '+' level", pattern)
+			}
+			driverLevel = i
+		case strings.ContainsAny(level, "+#"):
+			return topicPattern{}, fmt.Errorf("topic pattern %q may only use '+' as a whole level", pattern)
+		}
+	}
+	if driverLevel < 0 {
+		return topicPattern{}, fmt.Errorf("topic pattern %q must contain a '+' level for the driver ID", pattern)
+	}
+	return topicPattern{filter: pattern, levels: levels, driverLevel: driverLevel}, nil
+}
+
+// driverID returns the driver ID in topic, or false if topic doesn't match the pattern.
+func (p topicPattern) driverID(topic string) (string, bool) {
+	levels := strings.Split(topic, "/")
+	if len(levels) != len(p.levels) {
+		return "", false
+	}
+	for i, level := range p.levels {
+		if i != p.driverLevel && levels[i] != level {
+			return "", false
+		}
+	}
+	driverID := levels[p.driverLevel]
+	return driverID, driverID != ""
+}
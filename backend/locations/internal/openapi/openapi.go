@@ -0,0 +1,184 @@
+// Package openapi holds the OpenAPI 3 document describing the locations HTTP API and validates incoming
+// requests against it, so that handlers receive only requests the document allows.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+var (
+	// ErrUnknownRoute is returned for requests to paths the document doesn't describe.
+	ErrUnknownRoute = errors.New("route is not in the OpenAPI document")
+	// ErrMethodNotAllowed is returned for requests with a method the document doesn't list for their path.
+	ErrMethodNotAllowed = errors.New("method not allowed")
+)
+
+// MaxBodyBytes bounds the size of a request body the validator reads, which is well above that of a single
+// location update. Larger bodies, such as location batches, are left to their handlers; see ReadsBody.
+const MaxBodyBytes = 64 << 10
+
+// bodyReadByHandler marks operations whose body Validate leaves alone, for their handler to bound and check
+// once the caller has been authenticated.
+const bodyReadByHandler = "x-body-read-by-handler"
+
+//go:embed openapi.json
+var document []byte
+
+func init() {
+	// Newline-delimited batches are validated as opaque text; the batch handler checks each line.
+	openapi3filter.RegisterBodyDecoder("application/x-ndjson", openapi3filter.FileBodyDecoder)
+	openapi3filter.RegisterBodyDecoder("application/ndjson", openapi3filter.FileBodyDecoder)
+	// GeoJSON responses, such as the heatmap's, are plain JSON.
+	openapi3filter.RegisterBodyDecoder("application/geo+json", openapi3filter.RegisteredBodyDecoder("application/json"))
+}
+
+// Document returns the OpenAPI document as JSON.
+func Document() []byte {
+	return document
+}
+
+// Load parses and checks the OpenAPI document.
+func Load() (*openapi3.T, error) {
+	doc, err := openapi3.NewLoader().LoadFromData(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI document: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	return doc, nil
+}
+
+// FieldError is a request that doesn't match the document, naming the offending part of it.
+type FieldError struct {
+	// Field is the parameter or body property at fault, such as latitude or body.timestamp, or empty when the
+	// request as a whole is at fault.
+	Field  string `json:"field,omitempty"`
+	Reason string `json:"reason"`
+}
+
+func (e *FieldError) Error() string {
+	if e.Field == "" {
+		return e.Reason
+	}
+	return e.Field + ": " + e.Reason
+}
+
+// Validator checks requests against the OpenAPI document.
+type Validator struct {
+	router  routers.Router
+	options *openapi3filter.Options
+}
+
+// NewValidator creates a Validator for the embedded document.
+func NewValidator() (*Validator, error) {
+	doc, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build OpenAPI router: %w", err)
+	}
+	return &Validator{
+		router: router,
+		options: &openapi3filter.Options{
+			// Authentication is left to the handlers, which know which tokens each endpoint takes.
+			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+			// Handlers apply their own defaults; filling them in here would hide which parameters were given.
+			SkipSettingDefaults: true,
+		},
+	}, nil
+}
+
+// ReadsBody reports whether Validate reads the body of r. The caller should then bound it by MaxBodyBytes.
+func (v *Validator) ReadsBody(r *http.Request) bool {
+	route, _, err := v.router.FindRoute(r)
+	return err == nil && !readByHandler(route)
+}
+
+// Validate checks a request. It returns ErrUnknownRoute for paths the document doesn't describe,
+// ErrMethodNotAllowed for methods it doesn't list, and a *FieldError for requests it doesn't allow.
+// Unless the operation leaves it to its handler, the body is read and replaced by a copy, so it should be
+// bounded by the caller.
+func (v *Validator) Validate(r *http.Request) error {
+	route, pathParams, err := v.router.FindRoute(r)
+	switch {
+	case errors.Is(err, routers.ErrPathNotFound):
+		return ErrUnknownRoute
+	case errors.Is(err, routers.ErrMethodNotAllowed):
+		return ErrMethodNotAllowed
+	case err != nil:
+		return fmt.Errorf("failed to find route: %w", err)
+	}
+
+	options := v.options
+	if readByHandler(route) {
+		withoutBody := *v.options
+		withoutBody.ExcludeRequestBody = true
+		options = &withoutBody
+	}
+	input := &openapi3filter.RequestValidationInput{
+		Request:    r,
+		PathParams: pathParams,
+		Route:      route,
+		Options:    options,
+	}
+	if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+		return fieldError(err)
+	}
+	return nil
+}
+
+// readByHandler reports whether route's operation is marked with bodyReadByHandler.
+func readByHandler(route *routers.Route) bool {
+	marked, _ := route.Operation.Extensions[bodyReadByHandler].(bool)
+	return marked
+}
+
+// fieldError condenses a validation error into the field at fault and what is wrong with it.
+func fieldError(err error) *FieldError {
+	var requestErr *openapi3filter.RequestError
+	if !errors.As(err, &requestErr) {
+		return &FieldError{Reason: err.Error()}
+	}
+
+	var field string
+	switch {
+	case requestErr.Parameter != nil:
+		field = requestErr.Parameter.Name
+	case requestErr.RequestBody != nil:
+		field = "body"
+	}
+
+	var schemaErr *openapi3.SchemaError
+	var parseErr *openapi3filter.ParseError
+	switch {
+	case errors.Is(requestErr.Err, openapi3filter.ErrInvalidRequired):
+		return &FieldError{Field: field, Reason: "is required"}
+	case errors.As(requestErr.Err, &schemaErr):
+		if pointer := schemaErr.JSONPointer(); len(pointer) > 0 {
+			field = strings.TrimPrefix(field+"."+strings.Join(pointer, "."), ".")
+		}
+		if schemaErr.SchemaField == "required" {
+			return &FieldError{Field: field, Reason: "is required"}
+		}
+		return &FieldError{Field: field, Reason: schemaErr.Reason}
+	case errors.As(requestErr.Err, &parseErr):
+		return &FieldError{Field: field, Reason: parseErr.Error()}
+	case requestErr.Reason != "":
+		return &FieldError{Field: field, Reason: requestErr.Reason}
+	default:
+		return &FieldError{Field: field, Reason: requestErr.Err.Error()}
+	}
+}
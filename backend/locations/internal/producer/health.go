@@ -0,0 +1,45 @@
+package producer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// CheckBrokers reports whether a broker is reachable and knows the topic, with the topic's partition count.
+// Brokers are tried in order until one answers.
+func (p *KafkaProducer) CheckBrokers(ctx context.Context) (map[string]any, error) {
+	err := errors.New("no brokers configured")
+	for _, broker := range p.writerConfig.Brokers {
+		var partitions []kafka.Partition
+		partitions, err = readPartitions(ctx, broker, p.writerConfig.Topic)
+		if err == nil {
+			return map[string]any{
+				"broker":     broker,
+				"topic":      p.writerConfig.Topic,
+				"partitions": len(partitions),
+			}, nil
+		}
+	}
+	return map[string]any{"topic": p.writerConfig.Topic}, err
+}
+
+// readPartitions asks broker for the topic's partitions.
+func readPartitions(ctx context.Context, broker, topic string) ([]kafka.Partition, error) {
+	conn, err := kafka.DialContext(ctx, "tcp", broker)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to broker %s: %w", broker, err)
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	partitions, err := conn.ReadPartitions(topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read partitions of %s from broker %s: %w", topic, broker, err)
+	}
+	return partitions, nil
+}
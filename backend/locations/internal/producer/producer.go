@@ -1,12 +1,17 @@
 package producer
 
 import (
-	"context" // Provides functionality to define a deadline or cancellation signal for operations.
+	"context"       // Provides functionality to define a deadline or cancellation signal for operations.
+	"crypto/rand"   // Generates IDs for updates published without one.
+	"encoding/hex"  // Encodes generated IDs.
 	"encoding/json" // Implements encoding and decoding of JSON.
 
-	"locations/internal/models" // Internal package for data models.
+	"github.com/segmentio/kafka-go"                    // Kafka library for Go.
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0" // OpenTelemetry semantic conventions.
+	"go.opentelemetry.io/otel/trace"                   // OpenTelemetry tracing API.
 
-	"github.com/segmentio/kafka-go" // Kafka library for Go.
+	"locations/internal/models"  // Internal package for data models.
+	"locations/internal/tracing" // Internal package for OpenTelemetry tracing.
 )
 
 // KafkaProducer encapsulates the Kafka writer configuration needed to send messages to a Kafka topic.
@@ -15,13 +20,14 @@ type KafkaProducer struct {
 }
 
 // NewKafkaProducer initializes a new KafkaProducer with the specified broker addresses and topic.
-// It configures the Kafka writer with a LeastBytes balancer to evenly distribute messages across partitions.
+// It configures the Kafka writer with a Hash balancer on the driver ID key, so that all updates from one driver
+// land on the same partition and are consumed in the order they were produced.
 func NewKafkaProducer(kafkaBrokers []string, topic string) *KafkaProducer {
 	return &KafkaProducer{
 		writerConfig: kafka.WriterConfig{
-			Brokers:  kafkaBrokers, // Kafka broker addresses.
-			Topic:    topic,        // Kafka topic for publishing messages.
-			Balancer: &kafka.LeastBytes{}, // Balancer for distributing messages across brokers.
+			Brokers:  kafkaBrokers,  // Kafka broker addresses.
+			Topic:    topic,         // Kafka topic for publishing messages.
+			Balancer: &kafka.Hash{}, // Balancer for distributing messages across partitions by key.
 		},
 	}
 }
@@ -29,22 +35,94 @@ func NewKafkaProducer(kafkaBrokers []string, topic string) *KafkaProducer {
 // ProduceLocationUpdate takes a LocationUpdate model and sends it to the configured Kafka topic.
 // It first converts the LocationUpdate into a JSON byte slice, then creates a Kafka message,
 // and finally writes the message to the Kafka topic using the Kafka writer.
-func (p *KafkaProducer) ProduceLocationUpdate(ctx context.Context, location models.LocationUpdate) error {
+// The write is traced in a producer span, whose context travels in the message headers to the consumer.
+func (p *KafkaProducer) ProduceLocationUpdate(ctx context.Context, location models.LocationUpdate) (err error) {
+	ctx, span := p.startSpan(ctx, 1)
+	span.SetAttributes(semconv.MessagingKafkaMessageKey(location.DriverID)) // The driver ID, to find a driver's updates.
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
 	writer := kafka.NewWriter(p.writerConfig) // Instantiate a new Kafka writer with the provided configuration.
-	defer writer.Close() // Ensure the writer is closed properly after message production.
+	defer writer.Close()                      // Ensure the writer is closed properly after message production.
 
-	locationBytes, err := json.Marshal(location) // Convert the LocationUpdate to JSON format.
+	locationBytes, err := json.Marshal(withID(location)) // Convert the LocationUpdate to JSON format.
 	if err != nil {
 		return err // If JSON marshaling fails, return the error.
 	}
 
 	// Construct a Kafka message with the JSON-encoded location update as the value.
 	message := kafka.Message{
-		Key:   nil, // No key is specified, as the message key is not used in this context.
-		Value: locationBytes, // The JSON-encoded location update.
+		Key:   []byte(location.DriverID), // The driver ID keeps each driver's updates on one partition.
+		Value: locationBytes,             // The JSON-encoded location update.
 	}
+	tracing.InjectMessage(ctx, &message) // Carry the trace context to the consumer.
 
 	// Write the constructed message to the Kafka topic.
 	// The context allows for timeout or cancellation of the message production.
 	return writer.WriteMessages(ctx, message)
 }
+
+// ProduceLocationUpdates sends several location updates to the Kafka topic in a single batched write.
+// Updates keep their order within each driver, since they are keyed by driver ID and written in sequence.
+// Either all messages are written or an error is returned; on error some may already have been written.
+// The batch is traced in one producer span, whose context every message carries.
+func (p *KafkaProducer) ProduceLocationUpdates(ctx context.Context, locations []models.LocationUpdate) (err error) {
+	if len(locations) == 0 {
+		return nil
+	}
+	ctx, span := p.startSpan(ctx, len(locations))
+	defer func() {
+		tracing.RecordError(span, err)
+		span.End()
+	}()
+
+	messages := make([]kafka.Message, len(locations))
+	for i, location := range locations {
+		locationBytes, err := json.Marshal(withID(location)) // Convert each LocationUpdate to JSON format.
+		if err != nil {
+			return err
+		}
+		messages[i] = kafka.Message{Key: []byte(location.DriverID), Value: locationBytes}
+		tracing.InjectMessage(ctx, &messages[i]) // Carry the trace context to the consumer.
+	}
+
+	writer := kafka.NewWriter(p.writerConfig) // Instantiate a new Kafka writer with the provided configuration.
+	defer writer.Close()                      // Ensure the writer is closed properly after message production.
+
+	// One call writes the whole batch; the writer groups the messages by partition.
+	return writer.WriteMessages(ctx, messages...)
+}
+
+// withID gives location a random ID unless the client chose one. Every published update then has an ID, which
+// the store keys history on, so consuming the same message again, as replay and redrive do, stores it once.
+func withID(location models.LocationUpdate) models.LocationUpdate {
+	if location.ID == "" {
+		var id [16]byte
+		rand.Read(id[:])
+		location.ID = hex.EncodeToString(id[:])
+	}
+	return location
+}
+
+// LocationProducer publishes location updates. KafkaProducer implements it; decorators such as the metrics
+// wrapper implement it around another LocationProducer.
+type LocationProducer interface {
+	// ProduceLocationUpdate publishes one location update.
+	ProduceLocationUpdate(ctx context.Context, location models.LocationUpdate) error
+	// ProduceLocationUpdates publishes several location updates in one write.
+	ProduceLocationUpdates(ctx context.Context, locations []models.LocationUpdate) error
+}
+
+// startSpan starts a producer span for publishing count messages to the topic.
+func (p *KafkaProducer) startSpan(ctx context.Context, count int) (context.Context, trace.Span) {
+	return tracing.Tracer().Start(ctx, p.writerConfig.Topic+" publish",
+		trace.WithSpanKind(trace.SpanKindProducer),
+		trace.WithAttributes(
+			semconv.MessagingSystem("kafka"),
+			semconv.MessagingOperationPublish,
+			semconv.MessagingDestinationName(p.writerConfig.Topic),
+			semconv.MessagingBatchMessageCount(count),
+		))
+}
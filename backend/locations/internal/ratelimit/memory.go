@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+)
+
+// sweepInterval is how often a MemoryLimiter forgets buckets that have refilled.
+const sweepInterval = time.Minute
+
+// bucket is one key's tokens as of updated.
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// MemoryLimiter keeps buckets in process, for single instances and tests.
+type MemoryLimiter struct {
+	limit     Limit
+	now       func() time.Time
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// NewMemoryLimiter creates a MemoryLimiter applying limit to every key.
+func NewMemoryLimiter(limit Limit) *MemoryLimiter {
+	return NewMemoryLimiterWithClock(limit, time.Now)
+}
+
+// NewMemoryLimiterWithClock is NewMemoryLimiter with a custom clock, for tests.
+func NewMemoryLimiterWithClock(limit Limit, now func() time.Time) *MemoryLimiter {
+	return &MemoryLimiter{limit: limit, now: now, buckets: map[string]*bucket{}, lastSweep: now()}
+}
+
+// Allow takes a token from key's bucket.
+func (m *MemoryLimiter) Allow(ctx context.Context, key string) (Decision, error) {
+	return m.AllowN(ctx, key, 1)
+}
+
+// AllowN takes n tokens from key's bucket, or as many whole tokens as it holds.
+func (m *MemoryLimiter) AllowN(ctx context.Context, key string, n int) (Decision, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.lastSweep) >= sweepInterval {
+		m.sweep(now)
+	}
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(m.limit.Burst), updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens = m.refill(b, now)
+	b.updated = now
+
+	granted := int(math.Min(float64(n), math.Floor(b.tokens)))
+	b.tokens -= float64(granted)
+	if granted < n {
+		return Decision{Granted: granted, RetryAfter: m.limit.retryAfter(b.tokens)}, nil
+	}
+	return Decision{Allowed: true, Granted: granted}, nil
+}
+
+// refill returns b's tokens as of now.
+func (m *MemoryLimiter) refill(b *bucket, now time.Time) float64 {
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed <= 0 {
+		return b.tokens
+	}
+	return math.Min(float64(m.limit.Burst), b.tokens+elapsed*m.limit.Rate)
+}
+
+// sweep drops full buckets; a missing bucket is treated as full, so nothing changes for their keys.
+func (m *MemoryLimiter) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if m.refill(b, now) >= float64(m.limit.Burst) {
+			delete(m.buckets, key)
+		}
+	}
+	m.lastSweep = now
+}
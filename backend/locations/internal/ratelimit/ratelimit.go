@@ -0,0 +1,117 @@
+// Package ratelimit limits how fast drivers and clients may send location updates, with token buckets
+// kept in process or in Redis so that the limits hold across replicas.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+
+	"locations/internal/logging"
+)
+
+// Default limits. A driver app reports every few seconds, so the driver limit leaves ample room for bursts
+// after a reconnect while stopping a runaway release; many drivers may share a carrier NAT address.
+var (
+	DefaultDriverLimit = Limit{Rate: 2, Burst: 10}
+	DefaultClientLimit = Limit{Rate: 50, Burst: 100}
+)
+
+// Limit is a token bucket: Burst requests may be made at once, refilled at Rate per second.
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// Validate checks that the bucket can ever allow a request.
+func (l Limit) Validate() error {
+	if l.Rate <= 0 || math.IsInf(l.Rate, 0) || math.IsNaN(l.Rate) {
+		return fmt.Errorf("rate must be positive")
+	}
+	if l.Burst < 1 {
+		return fmt.Errorf("burst must be at least 1")
+	}
+	return nil
+}
+
+// retryAfter is how long a bucket holding tokens takes to refill to one.
+func (l Limit) retryAfter(tokens float64) time.Duration {
+	return time.Duration(math.Ceil((1 - tokens) / l.Rate * float64(time.Second)))
+}
+
+// Decision is the outcome of a request for one or more tokens.
+type Decision struct {
+	// Allowed reports whether every token requested was granted.
+	Allowed bool
+	// Granted is how many of the tokens requested were granted.
+	Granted int
+	// RetryAfter is how long until a token is available, when the request wasn't allowed.
+	RetryAfter time.Duration
+}
+
+// Limiter hands out tokens from one bucket per key.
+type Limiter interface {
+	// Allow takes a token from key's bucket.
+	Allow(ctx context.Context, key string) (Decision, error)
+	// AllowN takes n tokens from key's bucket or, if it holds fewer, as many as it holds.
+	AllowN(ctx context.Context, key string, n int) (Decision, error)
+}
+
+// Policy limits ingestion by driver ID and by client address. Either limiter may be nil to leave that
+// dimension unlimited, and a nil Policy allows everything.
+type Policy struct {
+	drivers Limiter
+	clients Limiter
+	// TrustForwardedFor takes the client address from X-Forwarded-For, for replicas behind a load balancer.
+	// It must stay off when clients can reach the service directly, since they could set the header themselves.
+	TrustForwardedFor bool
+	// ForwardedForHops is how many proxies in front of the service append to X-Forwarded-For. The client is the
+	// entry that many from the right, the one the outermost proxy added; entries left of it are the client's own.
+	// Zero counts as one.
+	ForwardedForHops int
+	// Logger receives limiter failures; nil logs to slog.Default().
+	Logger *slog.Logger
+}
+
+// NewPolicy creates a Policy from a per-driver and a per-client limiter.
+func NewPolicy(drivers, clients Limiter) *Policy {
+	return &Policy{drivers: drivers, clients: clients}
+}
+
+// AllowDriver takes a token from driverID's bucket.
+func (p *Policy) AllowDriver(ctx context.Context, driverID string) Decision {
+	return p.AllowDriverN(ctx, driverID, 1)
+}
+
+// AllowDriverN takes a token from driverID's bucket for each of n updates, such as the driver's updates in
+// a batch. When the bucket holds fewer, Granted says how many of the updates may go through.
+func (p *Policy) AllowDriverN(ctx context.Context, driverID string, n int) Decision {
+	if p == nil {
+		return Decision{Allowed: true, Granted: n}
+	}
+	return p.allow(ctx, p.drivers, "driver:"+driverID, n)
+}
+
+// AllowClient takes a token from the bucket of the client at addr.
+func (p *Policy) AllowClient(ctx context.Context, addr string) Decision {
+	if p == nil {
+		return Decision{Allowed: true, Granted: 1}
+	}
+	return p.allow(ctx, p.clients, "client:"+addr, 1)
+}
+
+// allow asks limiter for n tokens. If the limiter fails, as when Redis is unreachable, the request is allowed:
+// losing the limits for a while is better than refusing all ingestion.
+func (p *Policy) allow(ctx context.Context, limiter Limiter, key string, n int) Decision {
+	if limiter == nil {
+		return Decision{Allowed: true, Granted: n}
+	}
+	decision, err := limiter.AllowN(ctx, key, n)
+	if err != nil {
+		logging.Or(p.Logger).WarnContext(ctx, "Rate limiter failed, allowing request", "key", key, "error", err)
+		return Decision{Allowed: true, Granted: n}
+	}
+	return decision
+}
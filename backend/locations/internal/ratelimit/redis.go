@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// tokenBucketScript takes up to ARGV[4] tokens from the bucket at KEYS[1] in one atomic step.
+// ARGV holds the rate per second, the burst, the current time in milliseconds and the tokens wanted.
+// It returns how many tokens were granted and, if fewer than wanted, the milliseconds until one is available.
+// Buckets expire once they would have refilled, so idle keys don't accumulate.
+var tokenBucketScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local now = tonumber(ARGV[3])
+local wanted = tonumber(ARGV[4])
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1])
+local updated = tonumber(state[2])
+if tokens == nil or updated == nil then
+	tokens = burst
+	updated = now
+end
+-- Replica clocks may disagree slightly; never refill backwards.
+if now > updated then
+	tokens = math.min(burst, tokens + (now - updated) * rate / 1000)
+	updated = now
+end
+
+local granted = math.min(wanted, math.floor(tokens))
+tokens = tokens - granted
+local wait = 0
+if granted < wanted then
+	wait = math.ceil((1 - tokens) * 1000 / rate)
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(updated))
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * 1000 / rate) + 1000)
+return {granted, wait}
+`)
+
+// RedisLimiter keeps buckets in Redis so that every replica draws from the same ones.
+// Time comes from the replicas' clocks, which are assumed to be roughly in sync.
+type RedisLimiter struct {
+	client redis.UniversalClient
+	prefix string
+	limit  Limit
+	now    func() time.Time
+}
+
+// NewRedisLimiter creates a RedisLimiter applying limit to every key, stored under prefix.
+func NewRedisLimiter(client redis.UniversalClient, prefix string, limit Limit) *RedisLimiter {
+	return &RedisLimiter{client: client, prefix: prefix, limit: limit, now: time.Now}
+}
+
+// Allow takes a token from key's bucket.
+func (l *RedisLimiter) Allow(ctx context.Context, key string) (Decision, error) {
+	return l.AllowN(ctx, key, 1)
+}
+
+// AllowN takes n tokens from key's bucket, or as many whole tokens as it holds.
+func (l *RedisLimiter) AllowN(ctx context.Context, key string, n int) (Decision, error) {
+	result, err := tokenBucketScript.Run(ctx, l.client, []string{l.prefix + key},
+		l.limit.Rate, l.limit.Burst, l.now().UnixMilli(), n,
+	).Int64Slice()
+	if err != nil {
+		return Decision{}, fmt.Errorf("failed to run token bucket script: %w", err)
+	}
+	if len(result) != 2 {
+		return Decision{}, fmt.Errorf("unexpected token bucket result %v", result)
+	}
+	granted := int(result[0])
+	if granted >= n {
+		return Decision{Allowed: true, Granted: granted}, nil
+	}
+	return Decision{Granted: granted, RetryAfter: time.Duration(math.Max(float64(result[1]), 1)) * time.Millisecond}, nil
+}
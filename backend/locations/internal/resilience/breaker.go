@@ -0,0 +1,134 @@
+package resilience
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrOpen is returned by Allow while the breaker is open.
+var ErrOpen = errors.New("circuit breaker is open")
+
+// State is the state of a circuit breaker.
+type State int
+
+const (
+	// Closed lets every call through and counts consecutive failures.
+	Closed State = iota
+	// Open rejects every call until the cool-down has passed.
+	Open
+	// HalfOpen lets a single probe call through to test whether the dependency has recovered.
+	HalfOpen
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case Open:
+		return "open"
+	case HalfOpen:
+		return "half-open"
+	default:
+		return "unknown"
+	}
+}
+
+// Breaker is a consecutive-failure circuit breaker.
+// After threshold failures in a row it opens and fails fast for coolDown, then lets one probe through;
+// a successful probe closes it again, a failed one re-opens it.
+type Breaker struct {
+	mu        sync.Mutex
+	threshold int
+	coolDown  time.Duration
+	state     State
+	failures  int
+	openedAt  time.Time
+	probing   bool
+	onChange  func(from, to State)
+	now       func() time.Time
+}
+
+// NewBreaker creates a closed breaker. onChange, if not nil, is called on every state transition.
+func NewBreaker(threshold int, coolDown time.Duration, onChange func(from, to State)) *Breaker {
+	if threshold <= 0 {
+		threshold = 1
+	}
+	return &Breaker{threshold: threshold, coolDown: coolDown, onChange: onChange, now: time.Now}
+}
+
+// State returns the breaker's current state.
+func (b *Breaker) State() State {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	return b.state
+}
+
+// Allow reports whether a call may proceed. Every allowed call must be followed by Record.
+func (b *Breaker) Allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.advance()
+	switch b.state {
+	case Open:
+		return ErrOpen
+	case HalfOpen:
+		if b.probing {
+			return ErrOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// Record reports the outcome of an allowed call. Only failures of the dependency itself should be
+// recorded as failures; a "not found" answer means the dependency is healthy.
+func (b *Breaker) Record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == HalfOpen {
+		b.probing = false
+		if failed {
+			b.transition(Open)
+		} else {
+			b.transition(Closed)
+		}
+		return
+	}
+
+	if !failed {
+		b.failures = 0
+		return
+	}
+	b.failures++
+	if b.state == Closed && b.failures >= b.threshold {
+		b.transition(Open)
+	}
+}
+
+// advance moves an open breaker to half-open once the cool-down has passed.
+func (b *Breaker) advance() {
+	if b.state == Open && b.now().Sub(b.openedAt) >= b.coolDown {
+		b.transition(HalfOpen)
+	}
+}
+
+// transition changes state and resets the counters that belong to it.
+func (b *Breaker) transition(to State) {
+	from := b.state
+	if from == to {
+		return
+	}
+	b.state = to
+	b.failures = 0
+	if to == Open {
+		b.openedAt = b.now()
+	}
+	if b.onChange != nil {
+		b.onChange(from, to)
+	}
+}
@@ -0,0 +1,34 @@
+package resilience
+
+import (
+	"context"
+	"math/rand"
+	"time"
+)
+
+// Retry calls fn until it succeeds, returns an error that retryable rejects, or attempts calls have been made.
+// Waits between attempts double from backoff, with jitter so that replicas retrying together spread out.
+// It returns the last error from fn, or the context's error if ctx ends while waiting.
+func Retry(ctx context.Context, attempts int, backoff time.Duration, retryable func(error) bool, fn func(ctx context.Context) error) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; ; attempt++ {
+		err = fn(ctx)
+		if err == nil || attempt >= attempts || !retryable(err) {
+			return err
+		}
+
+		wait := backoff << (attempt - 1)
+		if wait > 0 {
+			wait = wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(wait):
+		}
+	}
+}
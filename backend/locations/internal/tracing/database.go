@@ -0,0 +1,126 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+
+	"go.mongodb.org/mongo-driver/mongo"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+
+	"locations/internal/db"
+	"locations/internal/models"
+)
+
+// Database starts a child span for each operation of the Database it wraps.
+// Optional capabilities of the wrapped database are reached through Unwrap with db.Find.
+type Database struct {
+	inner db.Database
+}
+
+// NewDatabase wraps inner.
+func NewDatabase(inner db.Database) *Database {
+	return &Database{inner: inner}
+}
+
+// Unwrap returns the wrapped database.
+func (d *Database) Unwrap() db.Database {
+	return d.inner
+}
+
+// InsertLocationUpdate is traced as "db.InsertLocationUpdate", and the other operations likewise.
+func (d *Database) InsertLocationUpdate(ctx context.Context, update models.LocationUpdate) error {
+	return d.do(ctx, "InsertLocationUpdate", func(ctx context.Context) error {
+		return d.inner.InsertLocationUpdate(ctx, update)
+	})
+}
+
+// GetLocationByID is traced as "db.GetLocationByID".
+func (d *Database) GetLocationByID(ctx context.Context, id string) (*models.LocationUpdate, error) {
+	var location *models.LocationUpdate
+	err := d.do(ctx, "GetLocationByID", func(ctx context.Context) (err error) {
+		location, err = d.inner.GetLocationByID(ctx, id)
+		return err
+	})
+	return location, err
+}
+
+// GetDriverLocation is traced as "db.GetDriverLocation".
+func (d *Database) GetDriverLocation(ctx context.Context, driverID string) (*models.Driver, error) {
+	var driver *models.Driver
+	err := d.do(ctx, "GetDriverLocation", func(ctx context.Context) (err error) {
+		driver, err = d.inner.GetDriverLocation(ctx, driverID)
+		return err
+	})
+	return driver, err
+}
+
+// UpdateLocation is traced as "db.UpdateLocation".
+func (d *Database) UpdateLocation(ctx context.Context, id string, update models.LocationUpdate, expectedVersion int64) (*db.UpdateResult, error) {
+	var result *db.UpdateResult
+	err := d.do(ctx, "UpdateLocation", func(ctx context.Context) (err error) {
+		result, err = d.inner.UpdateLocation(ctx, id, update, expectedVersion)
+		return err
+	})
+	return result, err
+}
+
+// GetNearbyDrivers is traced as "db.GetNearbyDrivers".
+func (d *Database) GetNearbyDrivers(ctx context.Context, latitude, longitude string) ([]models.Driver, error) {
+	var drivers []models.Driver
+	err := d.do(ctx, "GetNearbyDrivers", func(ctx context.Context) (err error) {
+		drivers, err = d.inner.GetNearbyDrivers(ctx, latitude, longitude)
+		return err
+	})
+	return drivers, err
+}
+
+// ExportDriverData is traced as "db.ExportDriverData".
+func (d *Database) ExportDriverData(ctx context.Context, request models.PrivacyRequest) (*models.DriverDataExport, error) {
+	var export *models.DriverDataExport
+	err := d.do(ctx, "ExportDriverData", func(ctx context.Context) (err error) {
+		export, err = d.inner.ExportDriverData(ctx, request)
+		return err
+	})
+	return export, err
+}
+
+// EraseDriverData is traced as "db.EraseDriverData".
+func (d *Database) EraseDriverData(ctx context.Context, request models.PrivacyRequest) (*models.DriverErasureResult, error) {
+	var result *models.DriverErasureResult
+	err := d.do(ctx, "EraseDriverData", func(ctx context.Context) (err error) {
+		result, err = d.inner.EraseDriverData(ctx, request)
+		return err
+	})
+	return result, err
+}
+
+// Heatmap is traced as "db.Heatmap".
+func (d *Database) Heatmap(ctx context.Context, query models.HeatmapQuery) ([]models.HeatmapCell, error) {
+	var cells []models.HeatmapCell
+	err := d.do(ctx, "Heatmap", func(ctx context.Context) (err error) {
+		cells, err = d.inner.Heatmap(ctx, query)
+		return err
+	})
+	return cells, err
+}
+
+// do runs one operation in a child span of ctx's, marked failed if the database failed.
+func (d *Database) do(ctx context.Context, operation string, fn func(ctx context.Context) error) error {
+	ctx, span := Tracer().Start(ctx, "db."+operation, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(semconv.DBSystemMongoDB, semconv.DBOperation(operation)))
+	defer span.End()
+
+	err := fn(ctx)
+	if failed(err) {
+		RecordError(span, err)
+	}
+	return err
+}
+
+// failed reports whether err is a failure of the database, rather than an answer such as a missing document
+// or a version or ID conflict.
+func failed(err error) bool {
+	return err != nil && !errors.Is(err, db.ErrNotFound) && !errors.Is(err, db.ErrVersionConflict) &&
+		!errors.Is(err, db.ErrIDConflict) && !errors.Is(err, mongo.ErrNoDocuments)
+}
@@ -0,0 +1,55 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// headerCarrier exposes a Kafka message's headers to the propagator.
+type headerCarrier struct {
+	headers *[]kafka.Header
+}
+
+func (c headerCarrier) Get(key string) string {
+	for _, header := range *c.headers {
+		if header.Key == key {
+			return string(header.Value)
+		}
+	}
+	return ""
+}
+
+// Set replaces any header with the same key, so that re-publishing a message doesn't carry two parents.
+func (c headerCarrier) Set(key, value string) {
+	for i, header := range *c.headers {
+		if header.Key == key {
+			(*c.headers)[i].Value = []byte(value)
+			return
+		}
+	}
+	*c.headers = append(*c.headers, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+func (c headerCarrier) Keys() []string {
+	keys := make([]string, len(*c.headers))
+	for i, header := range *c.headers {
+		keys[i] = header.Key
+	}
+	return keys
+}
+
+var _ propagation.TextMapCarrier = headerCarrier{}
+
+// InjectMessage writes the trace context of ctx into msg's headers.
+func InjectMessage(ctx context.Context, msg *kafka.Message) {
+	otel.GetTextMapPropagator().Inject(ctx, headerCarrier{headers: &msg.Headers})
+}
+
+// ExtractMessage returns ctx carrying the trace context from msg's headers, so that spans started from it
+// continue the trace of whoever published msg.
+func ExtractMessage(ctx context.Context, msg kafka.Message) context.Context {
+	return otel.GetTextMapPropagator().Extract(ctx, headerCarrier{headers: &msg.Headers})
+}
@@ -0,0 +1,142 @@
+// Package tracing sets up OpenTelemetry tracing and carries trace context across Kafka, so that one location
+// update can be followed from the HTTP request that reported it, through Kafka, to MongoDB.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.21.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName names the tracer the service's own spans come from.
+const instrumentationName = "locations"
+
+// Exporters that Config.Exporter selects.
+const (
+	// ExporterNone records nothing.
+	ExporterNone = "none"
+	// ExporterOTLP sends spans to an OTLP collector over gRPC, configured with the standard
+	// OTEL_EXPORTER_OTLP_* environment variables.
+	ExporterOTLP = "otlp"
+	// ExporterStdout writes spans to stdout as JSON, for local use.
+	ExporterStdout = "stdout"
+	// ExporterFile writes spans as JSON to Config.File, for local use.
+	ExporterFile = "file"
+)
+
+// Config selects where spans go and how many traces are kept.
+type Config struct {
+	// Exporter is one of the Exporter constants; empty means ExporterNone.
+	Exporter string
+	// File is the path ExporterFile appends to.
+	File string
+	// ServiceName and ServiceVersion identify the service in traces. OTEL_SERVICE_NAME and
+	// OTEL_RESOURCE_ATTRIBUTES override them.
+	ServiceName    string
+	ServiceVersion string
+	// SampleRatio is the fraction of new traces recorded, between 0 and 1. Traces started upstream keep
+	// the caller's sampling decision.
+	SampleRatio float64
+}
+
+// Validate checks that the exporter is known and the ratio is a fraction.
+func (c Config) Validate() error {
+	switch c.Exporter {
+	case "", ExporterNone, ExporterOTLP, ExporterStdout:
+	case ExporterFile:
+		if c.File == "" {
+			return errors.New("the file exporter needs a file")
+		}
+	default:
+		return fmt.Errorf("unknown exporter %q", c.Exporter)
+	}
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("sample ratio %v is not between 0 and 1", c.SampleRatio)
+	}
+	return nil
+}
+
+// Setup installs the global tracer provider and the W3C trace context propagator. The returned function
+// flushes buffered spans and releases the exporter; call it on shutdown. With ExporterNone the provider is
+// left as the no-op default, but trace context from callers is still passed on.
+func Setup(ctx context.Context, config Config) (shutdown func(context.Context) error, err error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if config.Exporter == "" || config.Exporter == ExporterNone {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(config.ServiceName), semconv.ServiceVersion(config.ServiceVersion)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the service for tracing: %w", err)
+	}
+
+	var (
+		exporter sdktrace.SpanExporter
+		closer   io.Closer
+	)
+	switch config.Exporter {
+	case ExporterOTLP:
+		exporter, err = otlptracegrpc.New(ctx)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case ExporterFile:
+		var file *os.File
+		file, err = os.OpenFile(config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open trace file: %w", err)
+		}
+		closer = file
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(file))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", config.Exporter, err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if closer != nil {
+			err = errors.Join(err, closer.Close())
+		}
+		return err
+	}, nil
+}
+
+// Tracer returns the tracer for the service's own spans, from the global tracer provider.
+func Tracer() trace.Tracer {
+	return otel.Tracer(instrumentationName)
+}
+
+// RecordError marks span as failed with err, if err is not nil.
+func RecordError(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
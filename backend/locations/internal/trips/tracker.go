@@ -0,0 +1,182 @@
+package trips
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrInvalidToken is returned for a malformed or forged trip token.
+	ErrInvalidToken = errors.New("invalid trip token")
+	// ErrTokenExpired is returned for a trip token past its expiry.
+	ErrTokenExpired = errors.New("trip token expired")
+	// ErrTripEnded is returned for a token whose trip has been ended.
+	ErrTripEnded = errors.New("trip has ended")
+)
+
+// endedRetention is how long an ended trip is remembered. Tokens valid for longer than this after they were
+// issued are rejected, since they could outlive the record of their trip's end and be accepted again.
+const endedRetention = 24 * time.Hour
+
+// Claims is what a trip token grants: tracking DriverID for the duration of TripID.
+type Claims struct {
+	TripID    string    `json:"trip_id"`
+	DriverID  string    `json:"driver_id"`
+	RiderID   string    `json:"rider_id,omitempty"`
+	IssuedAt  time.Time `json:"iat"`
+	ExpiresAt time.Time `json:"exp"`
+}
+
+// Tracker authorizes riders to follow a trip's driver and tells subscriptions when the trip ends.
+// Trip tokens are issued by the trip service with a shared secret, as <base64url claims>.<base64url HMAC-SHA256>,
+// and may be valid for at most endedRetention after they are issued.
+// Ended trips are recorded in memory, so each instance has to be told; tokens should be short-lived
+// and refreshed during the trip so that an instance that missed the end still stops at expiry.
+type Tracker struct {
+	secret []byte
+
+	mu    sync.Mutex
+	ended map[string]time.Time
+	done  map[string]*waiter
+	now   func() time.Time
+}
+
+// waiter is the Done channel of a trip that hasn't ended yet, with the number of callers waiting on it.
+type waiter struct {
+	ch   chan struct{}
+	refs int
+}
+
+// NewTracker creates a Tracker verifying tokens signed with secret.
+func NewTracker(secret string) *Tracker {
+	return NewTrackerWithClock(secret, time.Now)
+}
+
+// NewTrackerWithClock is NewTracker with a custom clock, for tests.
+func NewTrackerWithClock(secret string, now func() time.Time) *Tracker {
+	return &Tracker{
+		secret: []byte(secret),
+		ended:  make(map[string]time.Time),
+		done:   make(map[string]*waiter),
+		now:    now,
+	}
+}
+
+// Issue signs claims into a token, issued now unless IssuedAt is set. The trip service normally does this;
+// it is here for tests and tooling.
+func (t *Tracker) Issue(claims Claims) (string, error) {
+	if claims.IssuedAt.IsZero() {
+		claims.IssuedAt = t.now()
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(t.sign(encoded)), nil
+}
+
+// Verify checks a token's signature and expiry and that its trip hasn't ended. Tokens without an issue time,
+// or valid for longer than endedRetention, are invalid.
+func (t *Tracker) Verify(token string) (*Claims, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, t.sign(encoded)) {
+		return nil, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	var claims Claims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.TripID == "" || claims.DriverID == "" {
+		return nil, ErrInvalidToken
+	}
+	if claims.IssuedAt.IsZero() || claims.ExpiresAt.Sub(claims.IssuedAt) > endedRetention {
+		return nil, ErrInvalidToken
+	}
+	if !t.now().Before(claims.ExpiresAt) {
+		return nil, ErrTokenExpired
+	}
+	if t.Ended(claims.TripID) {
+		return nil, ErrTripEnded
+	}
+	return &claims, nil
+}
+
+// End records that a trip has ended and closes its Done channel.
+func (t *Tracker) End(tripID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// Forget trips that ended longer ago than any token can live.
+	now := t.now()
+	for id, at := range t.ended {
+		if now.Sub(at) > endedRetention {
+			delete(t.ended, id)
+		}
+	}
+
+	t.ended[tripID] = now
+	if w, ok := t.done[tripID]; ok {
+		close(w.ch)
+		delete(t.done, tripID)
+	}
+}
+
+// Ended reports whether the trip has been ended.
+func (t *Tracker) Ended(tripID string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, ok := t.ended[tripID]
+	return ok
+}
+
+// Done returns a channel that is closed when the trip ends, and a function to call once the caller stops
+// waiting on it, so that trips nobody is waiting for any more aren't kept until they end, which they may never do.
+func (t *Tracker) Done(tripID string) (<-chan struct{}, func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if _, ok := t.ended[tripID]; ok {
+		done := make(chan struct{})
+		close(done)
+		return done, func() {}
+	}
+	w, ok := t.done[tripID]
+	if !ok {
+		w = &waiter{ch: make(chan struct{})}
+		t.done[tripID] = w
+	}
+	w.refs++
+
+	var once sync.Once
+	return w.ch, func() {
+		once.Do(func() {
+			t.mu.Lock()
+			defer t.mu.Unlock()
+			w.refs--
+			if w.refs == 0 && t.done[tripID] == w {
+				delete(t.done, tripID)
+			}
+		})
+	}
+}
+
+// sign computes the HMAC of an encoded payload.
+func (t *Tracker) sign(encoded string) []byte {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(encoded))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,302 @@
+package archive_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"locations/internal/archive"
+	"locations/internal/db"
+	"locations/internal/models"
+)
+
+// FakeHistory is an in-memory implementation of db.HistoryArchiver for testing purposes.
+// It stores each update as its JSON encoding.
+type FakeHistory struct {
+	updates  map[string]db.HistoryRecord
+	restored []models.LocationUpdate
+	erasures map[string]time.Time
+	// deleteCalls counts DeleteHistory calls.
+	deleteCalls int
+}
+
+func NewFakeHistory(updates ...models.LocationUpdate) *FakeHistory {
+	h := &FakeHistory{updates: make(map[string]db.HistoryRecord)}
+	for i, update := range updates {
+		document, _ := json.Marshal(update)
+		h.add(db.HistoryRecord{DriverID: update.DriverID, Timestamp: update.Timestamp, Document: document}, i)
+	}
+	return h
+}
+
+func (h *FakeHistory) add(record db.HistoryRecord, i int) {
+	record.Key = strconv.Itoa(i)
+	h.updates[record.Key] = record
+}
+
+func (h *FakeHistory) ScanHistoryBefore(ctx context.Context, cutoff time.Time, handle func(record db.HistoryRecord) error) error {
+	for i := 0; i < len(h.updates)+100; i++ {
+		record, ok := h.updates[strconv.Itoa(i)]
+		if !ok || !record.Timestamp.Before(cutoff) {
+			continue
+		}
+		if err := handle(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (h *FakeHistory) DeleteHistory(ctx context.Context, keys []string) (int64, error) {
+	h.deleteCalls++
+	for _, key := range keys {
+		delete(h.updates, key)
+	}
+	return int64(len(keys)), nil
+}
+
+func (h *FakeHistory) RestoreHistory(ctx context.Context, records []db.HistoryRecord) error {
+	for _, record := range records {
+		var update models.LocationUpdate
+		if err := json.Unmarshal(record.Document, &update); err != nil {
+			return err
+		}
+		h.updates[record.Key] = record
+		h.restored = append(h.restored, update)
+	}
+	return nil
+}
+
+func (h *FakeHistory) Erasures(ctx context.Context) (map[string]time.Time, error) {
+	return h.erasures, nil
+}
+
+func (h *FakeHistory) DecodeHistory(record db.HistoryRecord) (models.LocationUpdate, error) {
+	var update models.LocationUpdate
+	err := json.Unmarshal(record.Document, &update)
+	return update, err
+}
+
+// failingStore fails every write to a path containing fail.
+type failingStore struct {
+	archive.Store
+	fail string
+}
+
+func (s failingStore) Put(ctx context.Context, path string, r io.Reader, size int64) error {
+	if strings.Contains(path, s.fail) {
+		return errors.New("store unavailable")
+	}
+	return s.Store.Put(ctx, path, r, size)
+}
+
+func day(d int, hour int) time.Time {
+	return time.Date(2023, time.January, d, hour, 0, 0, 0, time.UTC)
+}
+
+func TestArchiveAndRestore(t *testing.T) {
+	history := NewFakeHistory(
+		models.LocationUpdate{DriverID: "1", Latitude: 35.7, Longitude: 51.4, Timestamp: day(1, 8)},
+		models.LocationUpdate{DriverID: "2", Latitude: 35.8, Longitude: 51.5, Timestamp: day(1, 9)},
+		models.LocationUpdate{DriverID: "1", Latitude: 35.9, Longitude: 51.6, Timestamp: day(2, 8)},
+		models.LocationUpdate{DriverID: "3", Latitude: 36.0, Longitude: 51.7, Timestamp: day(5, 8)},
+	)
+	store, err := archive.NewLocalStore(t.TempDir())
+	assert.NoError(t, err)
+	archiver := archive.NewArchiver(history, store, 4)
+
+	manifest, err := archiver.Archive(context.Background(), day(3, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), manifest.Records)
+	assert.Len(t, history.updates, 1, "only history after the cutoff stays in the database")
+
+	for _, entry := range manifest.Files {
+		assert.NotEmpty(t, entry.SHA256)
+		assert.Contains(t, []string{"2023-01-01", "2023-01-02"}, entry.Day)
+	}
+
+	manifests, err := archiver.Manifests(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, manifests, 1)
+
+	restored, err := archiver.Restore(context.Background(), day(2, 0), day(2, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), restored)
+	assert.Equal(t, "1", history.restored[0].DriverID)
+	assert.Equal(t, 35.9, history.restored[0].Latitude)
+}
+
+func TestArchive_NothingToArchive(t *testing.T) {
+	history := NewFakeHistory(models.LocationUpdate{DriverID: "1", Timestamp: day(5, 8)})
+	store, err := archive.NewLocalStore(t.TempDir())
+	assert.NoError(t, err)
+
+	manifest, err := archive.NewArchiver(history, store, 4).Archive(context.Background(), day(3, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), manifest.Records)
+
+	paths, err := store.List(context.Background(), "")
+	assert.NoError(t, err)
+	assert.Empty(t, paths)
+}
+
+func TestRestore_ChecksumMismatch(t *testing.T) {
+	history := NewFakeHistory(models.LocationUpdate{DriverID: "1", Timestamp: day(1, 8)})
+	store, err := archive.NewLocalStore(t.TempDir())
+	assert.NoError(t, err)
+	archiver := archive.NewArchiver(history, store, 4)
+
+	manifest, err := archiver.Archive(context.Background(), day(3, 0))
+	assert.NoError(t, err)
+
+	// Corrupt the archived file.
+	corrupt := []byte("not the archived data")
+	assert.NoError(t, store.Put(context.Background(), manifest.Files[0].Path, bytes.NewReader(corrupt), int64(len(corrupt))))
+
+	_, err = archiver.Restore(context.Background(), day(1, 0), day(1, 0))
+	assert.ErrorContains(t, err, "checksum mismatch")
+	assert.Empty(t, history.restored)
+}
+
+func TestArchive_DeletesEachDayOnceItIsInAManifest(t *testing.T) {
+	history := NewFakeHistory(
+		models.LocationUpdate{DriverID: "1", Timestamp: day(1, 8)},
+		models.LocationUpdate{DriverID: "2", Timestamp: day(1, 9)},
+		models.LocationUpdate{DriverID: "1", Timestamp: day(2, 8)},
+	)
+	local, err := archive.NewLocalStore(t.TempDir())
+	assert.NoError(t, err)
+	archiver := archive.NewArchiver(history, failingStore{Store: local, fail: "day=2023-01-02"}, 4)
+
+	_, err = archiver.Archive(context.Background(), day(3, 0))
+	assert.ErrorContains(t, err, "store unavailable")
+	assert.Len(t, history.updates, 1, "the first day is deleted before the second is uploaded")
+
+	manifests, err := archiver.Manifests(context.Background())
+	assert.NoError(t, err)
+	if assert.Len(t, manifests, 1) {
+		assert.Equal(t, int64(2), manifests[0].Records, "the manifest lists every deleted document")
+	}
+
+	restored, err := archiver.Restore(context.Background(), day(1, 0), day(1, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), restored)
+	assert.Len(t, history.updates, 3)
+}
+
+func TestArchive_DeletesInChunksPerDay(t *testing.T) {
+	history := NewFakeHistory(
+		models.LocationUpdate{DriverID: "1", Timestamp: day(1, 8)},
+		models.LocationUpdate{DriverID: "1", Timestamp: day(2, 8)},
+		models.LocationUpdate{DriverID: "1", Timestamp: day(3, 8)},
+	)
+	store, err := archive.NewLocalStore(t.TempDir())
+	assert.NoError(t, err)
+
+	_, err = archive.NewArchiver(history, store, 4).Archive(context.Background(), day(4, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, history.deleteCalls)
+	assert.Empty(t, history.updates)
+}
+
+func TestArchive_RunsInTheSameSecondKeepTheirFiles(t *testing.T) {
+	store, err := archive.NewLocalStore(t.TempDir())
+	assert.NoError(t, err)
+
+	first, err := archive.NewArchiver(NewFakeHistory(models.LocationUpdate{DriverID: "1", Timestamp: day(1, 8)}), store, 1).Archive(context.Background(), day(3, 0))
+	assert.NoError(t, err)
+	second, err := archive.NewArchiver(NewFakeHistory(models.LocationUpdate{DriverID: "1", Timestamp: day(1, 9)}), store, 1).Archive(context.Background(), day(3, 0))
+	assert.NoError(t, err)
+
+	assert.NotEqual(t, first.RunID, second.RunID)
+	assert.NotEqual(t, first.Files[0].Path, second.Files[0].Path)
+	manifests, err := archive.NewArchiver(NewFakeHistory(), store, 1).Manifests(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, manifests, 2)
+}
+
+func TestRestore_TwiceKeepsOneCopy(t *testing.T) {
+	history := NewFakeHistory(
+		models.LocationUpdate{DriverID: "1", Timestamp: day(1, 8)},
+		models.LocationUpdate{DriverID: "2", Timestamp: day(1, 9)},
+	)
+	store, err := archive.NewLocalStore(t.TempDir())
+	assert.NoError(t, err)
+	archiver := archive.NewArchiver(history, store, 4)
+
+	_, err = archiver.Archive(context.Background(), day(3, 0))
+	assert.NoError(t, err)
+	assert.Empty(t, history.updates)
+
+	for i := 0; i < 2; i++ {
+		restored, err := archiver.Restore(context.Background(), day(1, 0), day(1, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(2), restored)
+	}
+	assert.Len(t, history.updates, 2, "records are restored under their original keys")
+}
+
+func TestArchive_KeepsDocumentsAsStored(t *testing.T) {
+	encrypted := json.RawMessage(`{"driver_id":"1","enc":{"key_id":"k1","ct":"c2VhbGVk"}}`)
+	history := &FakeHistory{updates: make(map[string]db.HistoryRecord)}
+	history.add(db.HistoryRecord{DriverID: "1", Timestamp: day(1, 8), Document: encrypted}, 0)
+	store, err := archive.NewLocalStore(t.TempDir())
+	assert.NoError(t, err)
+	archiver := archive.NewArchiver(history, store, 4)
+
+	manifest, err := archiver.Archive(context.Background(), day(3, 0))
+	assert.NoError(t, err)
+
+	r, err := store.Get(context.Background(), manifest.Files[0].Path)
+	assert.NoError(t, err)
+	defer r.Close()
+	gz, err := gzip.NewReader(r)
+	assert.NoError(t, err)
+	var archived db.HistoryRecord
+	assert.NoError(t, json.NewDecoder(gz).Decode(&archived))
+	assert.JSONEq(t, string(encrypted), string(archived.Document))
+	assert.Equal(t, "0", archived.Key)
+
+	_, err = archiver.Restore(context.Background(), day(1, 0), day(1, 0))
+	assert.NoError(t, err)
+	if assert.Contains(t, history.updates, "0") {
+		assert.JSONEq(t, string(encrypted), string(history.updates["0"].Document), "restore writes the document back unchanged")
+	}
+}
+
+func TestRestore_SkipsDriversErasedSinceArchiving(t *testing.T) {
+	history := NewFakeHistory(
+		models.LocationUpdate{DriverID: "1", Timestamp: day(1, 8)},
+		models.LocationUpdate{DriverID: "2", Timestamp: day(1, 9)},
+		models.LocationUpdate{DriverID: "3", Timestamp: day(1, 10)},
+	)
+	store, err := archive.NewLocalStore(t.TempDir())
+	assert.NoError(t, err)
+	archiver := archive.NewArchiver(history, store, 4)
+
+	_, err = archiver.Archive(context.Background(), day(3, 0))
+	assert.NoError(t, err)
+
+	history.erasures = map[string]time.Time{
+		"1": day(5, 0),
+		// Erased before the archived update was recorded, so the update is not part of the erasure.
+		"3": day(1, 9),
+	}
+	restored, err := archiver.Restore(context.Background(), day(1, 0), day(1, 0))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), restored)
+	var drivers []string
+	for _, update := range history.restored {
+		drivers = append(drivers, update.DriverID)
+	}
+	assert.ElementsMatch(t, []string{"2", "3"}, drivers)
+}
@@ -0,0 +1,153 @@
+package archive_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"locations/internal/archive"
+	"locations/internal/db"
+	"locations/internal/models"
+)
+
+// deleteFailingStore fails deletes while failing is set.
+type deleteFailingStore struct {
+	archive.Store
+	failing bool
+}
+
+func (s *deleteFailingStore) Delete(ctx context.Context, path string) error {
+	if s.failing {
+		return errors.New("store unavailable")
+	}
+	return s.Store.Delete(ctx, path)
+}
+
+// archivedDrivers archives updates of drivers 1 and 2 on two days, with a single bucket so that they share files.
+func archivedDrivers(t *testing.T, store archive.Store) *archive.Archiver {
+	t.Helper()
+	history := NewFakeHistory(
+		models.LocationUpdate{DriverID: "1", Latitude: 35.7, Timestamp: day(1, 8)},
+		models.LocationUpdate{DriverID: "2", Latitude: 35.8, Timestamp: day(1, 9)},
+		models.LocationUpdate{DriverID: "1", Latitude: 35.9, Timestamp: day(2, 8)},
+	)
+	archiver := archive.NewArchiver(history, store, 1)
+	_, err := archiver.Archive(context.Background(), day(3, 0))
+	require.NoError(t, err)
+	return archiver
+}
+
+func TestDriverHistory_ReadsTheDriversArchivedUpdates(t *testing.T) {
+	store, err := archive.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	archiver := archivedDrivers(t, store)
+
+	history, err := archiver.DriverHistory(context.Background(), "1")
+	require.NoError(t, err)
+	require.Len(t, history, 2)
+	assert.Equal(t, 35.7, history[0].Latitude)
+	assert.Equal(t, 35.9, history[1].Latitude)
+
+	history, err = archiver.DriverHistory(context.Background(), "3")
+	require.NoError(t, err)
+	assert.Empty(t, history)
+}
+
+func TestPurgeDriver_RewritesTheFilesThatHoldTheDriver(t *testing.T) {
+	store, err := archive.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	archiver := archivedDrivers(t, store)
+
+	purged, err := archiver.PurgeDriver(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), purged)
+
+	history, err := archiver.DriverHistory(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Empty(t, history)
+	history, err = archiver.DriverHistory(context.Background(), "2")
+	require.NoError(t, err)
+	assert.Len(t, history, 1)
+
+	// The second day only held driver 1, so its file is gone; the first day's was replaced by a copy.
+	manifests, err := archiver.Manifests(context.Background())
+	require.NoError(t, err)
+	require.Len(t, manifests, 1)
+	assert.Equal(t, int64(1), manifests[0].Records)
+	require.Len(t, manifests[0].Files, 1)
+	assert.Empty(t, manifests[0].Purged)
+	files, err := store.List(context.Background(), "history/")
+	require.NoError(t, err)
+	assert.Equal(t, []string{manifests[0].Files[0].Path}, files, "the replaced files are deleted")
+
+	purged, err = archiver.PurgeDriver(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Zero(t, purged)
+}
+
+func TestPurgeDriver_FinishesAnInterruptedPurge(t *testing.T) {
+	local, err := archive.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	store := &deleteFailingStore{Store: local, failing: true}
+	archiver := archivedDrivers(t, store)
+
+	_, err = archiver.PurgeDriver(context.Background(), "1")
+	require.Error(t, err)
+	manifests, err := archiver.Manifests(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, manifests[0].Purged, 2, "the manifest remembers the files still to delete")
+
+	store.failing = false
+	_, err = archiver.PurgeDriver(context.Background(), "1")
+	require.NoError(t, err)
+	files, err := store.List(context.Background(), "history/")
+	require.NoError(t, err)
+	assert.Len(t, files, 1)
+}
+
+func TestPurgeDriver_WaitsForRunsInProgress(t *testing.T) {
+	store, err := archive.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	archiver := archivedDrivers(t, store)
+
+	// A run that has uploaded some days and is still going.
+	manifest, err := json.Marshal(archive.Manifest{RunID: "running", CreatedAt: time.Now().UTC().Add(-time.Hour), Buckets: 1})
+	require.NoError(t, err)
+	require.NoError(t, store.Put(context.Background(), "manifests/running.json", bytes.NewReader(manifest), int64(len(manifest))))
+
+	_, err = archiver.PurgeDriver(context.Background(), "1")
+	assert.ErrorContains(t, err, "in progress")
+}
+
+func TestDatabase_CoversArchivedHistory(t *testing.T) {
+	store, err := archive.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	memory := db.NewMemoryDB()
+	require.NoError(t, memory.InsertLocationUpdate(context.Background(), models.LocationUpdate{DriverID: "1", Latitude: 36, Timestamp: time.Now().UTC()}))
+	database := archive.NewDatabase(memory, archivedDrivers(t, store))
+
+	export, err := database.ExportDriverData(context.Background(), models.PrivacyRequest{DriverID: "1"})
+	require.NoError(t, err)
+	assert.Len(t, export.History, 1)
+	assert.Len(t, export.ArchivedHistory, 2)
+
+	result, err := database.EraseDriverData(context.Background(), models.PrivacyRequest{DriverID: "1"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.HistoryDeleted)
+	assert.Equal(t, int64(2), result.ArchivedDeleted)
+
+	export, err = database.ExportDriverData(context.Background(), models.PrivacyRequest{DriverID: "1"})
+	require.NoError(t, err)
+	assert.Empty(t, export.History)
+	assert.Empty(t, export.ArchivedHistory)
+
+	inner, ok := db.Find[*db.MemoryDB](database)
+	assert.True(t, ok)
+	assert.Same(t, memory, inner)
+}
@@ -0,0 +1,203 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"locations/internal/auth"
+)
+
+func newClaims(subject string, roles ...string) *auth.Claims {
+	return &auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			Issuer:    "users",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Roles: roles,
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, key any, keyID string, claims jwt.Claims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if keyID != "" {
+		token.Header["kid"] = keyID
+	}
+	signed, err := token.SignedString(key)
+	require.NoError(t, err)
+	return signed
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, data, 0o600))
+	return path
+}
+
+func TestVerify_HS256(t *testing.T) {
+	verifier, err := auth.NewVerifier(auth.Config{HMACSecret: []byte("secret"), Issuer: "users"})
+	require.NoError(t, err)
+
+	claims, err := verifier.Verify(sign(t, jwt.SigningMethodHS256, []byte("secret"), "", newClaims("driver-1", auth.RoleDriver)))
+	require.NoError(t, err)
+	assert.True(t, claims.IsDriver("driver-1"))
+	assert.False(t, claims.IsDriver("driver-2"))
+
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodHS256, []byte("other"), "", newClaims("driver-1", auth.RoleDriver)))
+	assert.True(t, errors.Is(err, auth.ErrInvalidToken))
+}
+
+func TestVerify_RejectsInvalidClaims(t *testing.T) {
+	verifier, err := auth.NewVerifier(auth.Config{HMACSecret: []byte("secret"), Issuer: "users", Audience: "locations"})
+	require.NoError(t, err)
+
+	expired := newClaims("driver-1")
+	expired.Audience = jwt.ClaimStrings{"locations"}
+	expired.ExpiresAt = jwt.NewNumericDate(time.Now().Add(-time.Hour))
+
+	noExpiry := newClaims("driver-1")
+	noExpiry.Audience = jwt.ClaimStrings{"locations"}
+	noExpiry.ExpiresAt = nil
+
+	wrongAudience := newClaims("driver-1")
+	wrongAudience.Audience = jwt.ClaimStrings{"billing"}
+
+	wrongIssuer := newClaims("driver-1")
+	wrongIssuer.Audience = jwt.ClaimStrings{"locations"}
+	wrongIssuer.Issuer = "someone-else"
+
+	for name, claims := range map[string]*auth.Claims{
+		"expired":        expired,
+		"no expiry":      noExpiry,
+		"wrong audience": wrongAudience,
+		"wrong issuer":   wrongIssuer,
+	} {
+		_, err := verifier.Verify(sign(t, jwt.SigningMethodHS256, []byte("secret"), "", claims))
+		assert.True(t, errors.Is(err, auth.ErrInvalidToken), name)
+	}
+}
+
+func TestVerify_RS256FromJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	jwks, err := json.Marshal(map[string]any{"keys": []map[string]string{
+		{
+			"kty": "RSA",
+			"kid": "users-2023",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		},
+		{"kty": "oct", "kid": "legacy", "k": base64.RawURLEncoding.EncodeToString([]byte("legacy-secret"))},
+	}})
+	require.NoError(t, err)
+	verifier, err := auth.NewVerifier(auth.Config{JWKSFile: writeFile(t, "jwks.json", jwks)})
+	require.NoError(t, err)
+
+	claims, err := verifier.Verify(sign(t, jwt.SigningMethodRS256, key, "users-2023", newClaims("ops-1", auth.RoleOps)))
+	require.NoError(t, err)
+	assert.True(t, claims.HasRole(auth.RoleOps))
+
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodHS256, []byte("legacy-secret"), "legacy", newClaims("ops-1", auth.RoleOps)))
+	assert.NoError(t, err)
+
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, key, "unknown", newClaims("ops-1", auth.RoleOps)))
+	assert.Error(t, err)
+	// The symmetric key must not verify RS256 tokens, nor the RSA key HS256 ones.
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodHS256, []byte("legacy-secret"), "users-2023", newClaims("ops-1", auth.RoleOps)))
+	assert.Error(t, err)
+}
+
+func TestVerify_RejectsRSAKeyUsedAsHMACSecret(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+
+	verifier, err := auth.NewVerifier(auth.Config{RSAPublicKeyFile: writeFile(t, "users.pem", publicPEM)})
+	require.NoError(t, err)
+
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, key, "", newClaims("driver-1", auth.RoleDriver)))
+	require.NoError(t, err)
+
+	// A forger who knows the public key signs with it as an HMAC secret.
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodHS256, publicPEM, "", newClaims("driver-1", auth.RoleDriver)))
+	assert.True(t, errors.Is(err, auth.ErrInvalidToken))
+}
+
+func TestVerify_KeyIDWithoutJWKSFallsBackToConfiguredKeys(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	publicPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	verifier, err := auth.NewVerifier(auth.Config{
+		HMACSecret:       []byte("secret"),
+		RSAPublicKeyFile: writeFile(t, "users.pem", publicPEM),
+	})
+	require.NoError(t, err)
+
+	// The users service names its key whether or not this service is given a JWKS.
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, key, "users-2024", newClaims("driver-1", auth.RoleDriver)))
+	assert.NoError(t, err)
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodHS256, []byte("secret"), "users-hmac", newClaims("driver-1", auth.RoleDriver)))
+	assert.NoError(t, err)
+
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodRS256, other, "users-2024", newClaims("driver-1", auth.RoleDriver)))
+	assert.True(t, errors.Is(err, auth.ErrInvalidToken))
+	// The fallback keeps keys to their algorithms.
+	_, err = verifier.Verify(sign(t, jwt.SigningMethodHS256, publicPEM, "users-2024", newClaims("driver-1", auth.RoleDriver)))
+	assert.True(t, errors.Is(err, auth.ErrInvalidToken))
+}
+
+func TestNewVerifier_RequiresKeys(t *testing.T) {
+	_, err := auth.NewVerifier(auth.Config{Issuer: "users"})
+	assert.Error(t, err)
+}
+
+func TestClaims_CanReadDriver(t *testing.T) {
+	rider := newClaims("rider-1", auth.RoleRider)
+	assert.False(t, rider.CanReadDriver("driver-1"))
+
+	rider.Trip = &auth.TripClaim{ID: "trip-1", DriverID: "driver-1"}
+	assert.True(t, rider.CanReadDriver("driver-1"))
+	assert.False(t, rider.CanReadDriver("driver-2"))
+	assert.False(t, rider.CanReadDriver(""))
+
+	ops := newClaims("ops-1", auth.RoleOps)
+	assert.True(t, ops.CanReadDriver("driver-2"))
+	assert.True(t, ops.CanReadDriver(""))
+
+	// A driver may only report as themselves; reading others needs another role.
+	driver := newClaims("driver-1", auth.RoleDriver)
+	assert.False(t, driver.CanReadDriver("driver-1"))
+}
+
+func TestClaims_HasScope(t *testing.T) {
+	claims := newClaims("admin-1")
+	claims.Scope = "locations:read locations:admin"
+	assert.True(t, claims.HasScope(auth.ScopeAdmin))
+	claims.Scope = "locations:read"
+	assert.False(t, claims.HasScope(auth.ScopeAdmin))
+}
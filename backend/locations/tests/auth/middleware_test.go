@@ -0,0 +1,236 @@
+package auth_test
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"locations/internal/auth"
+	"locations/internal/db"
+	locationsgrpc "locations/internal/grpc"
+	"locations/internal/grpc/locationspb"
+	locationshttp "locations/internal/http"
+	"locations/internal/live"
+	"locations/internal/models"
+	"locations/internal/producer"
+)
+
+const middlewareSecret = "middleware-secret"
+
+// tokens are signed for the callers the middleware tests use.
+type tokens struct {
+	driver, ops, rider, riderOfOther, riderWithoutTrip string
+}
+
+func newTokens(t *testing.T) tokens {
+	t.Helper()
+	key := []byte(middlewareSecret)
+	rider := func(driverID string) string {
+		claims := newClaims("rider-1", auth.RoleRider)
+		if driverID != "" {
+			claims.Trip = &auth.TripClaim{ID: "trip-1", DriverID: driverID}
+		}
+		return sign(t, jwt.SigningMethodHS256, key, "", claims)
+	}
+	return tokens{
+		driver:           sign(t, jwt.SigningMethodHS256, key, "", newClaims("driver-1", auth.RoleDriver)),
+		ops:              sign(t, jwt.SigningMethodHS256, key, "", newClaims("ops-1", auth.RoleOps)),
+		rider:            rider("driver-1"),
+		riderOfOther:     rider("driver-2"),
+		riderWithoutTrip: rider(""),
+	}
+}
+
+func newMiddlewareVerifier(t *testing.T) *auth.Verifier {
+	t.Helper()
+	verifier, err := auth.NewVerifier(auth.Config{HMACSecret: []byte(middlewareSecret), Issuer: "users"})
+	require.NoError(t, err)
+	return verifier
+}
+
+// newDatabase stores one update of driver-1, with ID loc-1.
+func newDatabase(t *testing.T) db.Database {
+	t.Helper()
+	database := db.NewMemoryDB()
+	require.NoError(t, database.InsertLocationUpdate(context.Background(), models.LocationUpdate{
+		ID:        "loc-1",
+		DriverID:  "driver-1",
+		Latitude:  35.7,
+		Longitude: 51.4,
+		Timestamp: time.Now().UTC().Add(-time.Minute),
+	}))
+	return database
+}
+
+func TestHTTPMiddlewareAuthorizesByClaims(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	verifier := newMiddlewareVerifier(t)
+	handler, err := locationshttp.NewRouter(ctx, &producer.KafkaProducer{}, newDatabase(t), "", live.NewHub(),
+		nil, nil, nil, verifier, nil, nil, nil, nil)
+	require.NoError(t, err)
+	callers := newTokens(t)
+
+	tests := []struct {
+		name   string
+		method string
+		target string
+		token  string
+		body   string
+		want   int
+	}{
+		{"no token", http.MethodGet, "/drivers/driver-1/location", "", "", http.StatusUnauthorized},
+		{"malformed token", http.MethodGet, "/drivers/driver-1/location", "not-a-jwt", "", http.StatusUnauthorized},
+		{"driver reads a driver", http.MethodGet, "/drivers/driver-1/location", callers.driver, "", http.StatusForbidden},
+		{"rider reads their trip's driver", http.MethodGet, "/drivers/driver-1/location", callers.rider, "", http.StatusOK},
+		{"rider reads another driver", http.MethodGet, "/drivers/driver-1/location", callers.riderOfOther, "", http.StatusForbidden},
+		{"ops reads any driver", http.MethodGet, "/drivers/driver-1/location", callers.ops, "", http.StatusOK},
+		{"driver reports for another driver", http.MethodPost, "/location", callers.driver, `{"driver_id":"driver-2","latitude":35.7,"longitude":51.4,"timestamp":"2024-05-01T12:00:00Z"}`, http.StatusForbidden},
+		{"location of the rider's driver", http.MethodGet, "/location?id=loc-1", callers.rider, "", http.StatusOK},
+		// Someone else's location and a missing one look the same, so callers can't probe which IDs exist.
+		{"location of another driver", http.MethodGet, "/location?id=loc-1", callers.riderOfOther, "", http.StatusNotFound},
+		{"missing location", http.MethodGet, "/location?id=loc-2", callers.riderOfOther, "", http.StatusNotFound},
+		{"location without a trip", http.MethodGet, "/location?id=loc-2", callers.riderWithoutTrip, "", http.StatusForbidden},
+		{"nearby as rider", http.MethodGet, "/nearby?latitude=35.7&longitude=51.4", callers.rider, "", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			r := httptest.NewRequest(tt.method, tt.target, body)
+			if tt.body != "" {
+				r.Header.Set("Content-Type", "application/json")
+			}
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, r)
+			assert.Equal(t, tt.want, recorder.Code, recorder.Body.String())
+		})
+	}
+}
+
+// newAuthenticatedClient serves a Server with the options of ServerOptions over an in-memory connection.
+func newAuthenticatedClient(t *testing.T, verifier *auth.Verifier) locationspb.LocationServiceClient {
+	t.Helper()
+
+	options, err := locationsgrpc.ServerOptions("", "", verifier)
+	require.NoError(t, err)
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(options...)
+	locationspb.RegisterLocationServiceServer(server, locationsgrpc.NewServer(&producer.KafkaProducer{}, newDatabase(t), live.NewHub(), nil))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return locationspb.NewLocationServiceClient(conn)
+}
+
+// withToken returns a context whose calls carry token.
+func withToken(token string) context.Context {
+	if token == "" {
+		return context.Background()
+	}
+	return metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+}
+
+func TestGRPCInterceptorsAuthorizeUnaryCalls(t *testing.T) {
+	client := newAuthenticatedClient(t, newMiddlewareVerifier(t))
+	callers := newTokens(t)
+	get := func(ctx context.Context) error {
+		_, err := client.GetDriverLocation(ctx, &locationspb.GetDriverLocationRequest{DriverId: "driver-1"})
+		return err
+	}
+	report := func(driverID string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			_, err := client.ReportLocation(ctx, &locationspb.LocationUpdate{DriverId: driverID, Latitude: 35.7, Longitude: 51.4, Timestamp: timestamppb.Now()})
+			return err
+		}
+	}
+	nearby := func(ctx context.Context) error {
+		_, err := client.FindNearbyDrivers(ctx, &locationspb.FindNearbyDriversRequest{Latitude: 35.7, Longitude: 51.4})
+		return err
+	}
+
+	tests := []struct {
+		name  string
+		call  func(ctx context.Context) error
+		token string
+		want  codes.Code
+	}{
+		{"no token", get, "", codes.Unauthenticated},
+		{"malformed token", get, "not-a-jwt", codes.Unauthenticated},
+		{"rider reads their trip's driver", get, callers.rider, codes.OK},
+		{"rider reads another driver", get, callers.riderOfOther, codes.PermissionDenied},
+		{"driver reads a driver", get, callers.driver, codes.PermissionDenied},
+		{"ops reads any driver", get, callers.ops, codes.OK},
+		{"driver reports for another driver", report("driver-2"), callers.driver, codes.PermissionDenied},
+		{"ops reports", report("driver-1"), callers.ops, codes.PermissionDenied},
+		{"nearby as driver", nearby, callers.driver, codes.PermissionDenied},
+		{"nearby as ops", nearby, callers.ops, codes.OK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, status.Code(tt.call(withToken(tt.token))))
+		})
+	}
+}
+
+func TestGRPCInterceptorsAuthorizeStreams(t *testing.T) {
+	client := newAuthenticatedClient(t, newMiddlewareVerifier(t))
+	callers := newTokens(t)
+
+	watch := func(token string) error {
+		ctx, cancel := context.WithTimeout(withToken(token), 5*time.Second)
+		defer cancel()
+		stream, err := client.WatchDriver(ctx, &locationspb.WatchDriverRequest{DriverId: "driver-1"})
+		require.NoError(t, err)
+		// The current position comes first for callers who may watch the driver.
+		_, err = stream.Recv()
+		return err
+	}
+	assert.Equal(t, codes.Unauthenticated, status.Code(watch("")))
+	assert.Equal(t, codes.PermissionDenied, status.Code(watch(callers.riderOfOther)))
+	assert.NoError(t, watch(callers.rider))
+
+	// Updates of other drivers in a report stream are rejected one by one.
+	stream, err := client.ReportLocations(withToken(callers.driver))
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&locationspb.LocationUpdate{DriverId: "driver-2", Latitude: 35.7, Longitude: 51.4, Timestamp: timestamppb.Now()}))
+	response, err := stream.CloseAndRecv()
+	require.NoError(t, err)
+	assert.Zero(t, response.Accepted)
+	require.Len(t, response.Rejected, 1)
+	assert.Equal(t, "driver ID does not match the token", response.Rejected[0].Error)
+
+	stream, err = client.ReportLocations(withToken(callers.ops))
+	require.NoError(t, err)
+	_, err = stream.CloseAndRecv()
+	assert.Equal(t, codes.PermissionDenied, status.Code(err))
+}
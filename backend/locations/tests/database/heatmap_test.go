@@ -0,0 +1,50 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"locations/internal/db"
+	"locations/internal/models"
+)
+
+func TestMemoryDBHeatmap_GroupsByCell(t *testing.T) {
+	database := db.NewMemoryDB()
+	now := time.Now().UTC()
+
+	updates := []models.LocationUpdate{
+		{DriverID: "1", Latitude: 35.7010, Longitude: 51.4010, Timestamp: now.Add(-time.Minute)},
+		{DriverID: "1", Latitude: 35.7011, Longitude: 51.4011, Timestamp: now.Add(-2 * time.Minute)},
+		{DriverID: "2", Latitude: 35.7012, Longitude: 51.4012, Timestamp: now.Add(-3 * time.Minute)},
+		{DriverID: "3", Latitude: 35.8000, Longitude: 51.5000, Timestamp: now.Add(-time.Minute)},
+		// Outside the time window.
+		{DriverID: "4", Latitude: 35.7000, Longitude: 51.4000, Timestamp: now.Add(-2 * time.Hour)},
+		// Outside the bounding box.
+		{DriverID: "5", Latitude: 10, Longitude: 10, Timestamp: now.Add(-time.Minute)},
+	}
+	for _, update := range updates {
+		assert.NoError(t, database.InsertLocationUpdate(context.Background(), update))
+	}
+
+	cells, err := database.Heatmap(context.Background(), models.HeatmapQuery{
+		MinLatitude:  35,
+		MinLongitude: 51,
+		MaxLatitude:  36,
+		MaxLongitude: 52,
+		Since:        now.Add(-time.Hour),
+		Until:        now,
+		Precision:    6,
+	})
+	assert.NoError(t, err)
+	assert.Len(t, cells, 2)
+
+	assert.Equal(t, int64(3), cells[0].Count)
+	assert.Equal(t, int64(2), cells[0].DistinctDrivers)
+	assert.Len(t, cells[0].Geohash, 6)
+
+	assert.Equal(t, int64(1), cells[1].Count)
+	assert.Equal(t, int64(1), cells[1].DistinctDrivers)
+}
@@ -0,0 +1,64 @@
+package database_test
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"locations/internal/db"
+	"locations/internal/models"
+)
+
+func TestMemoryDBInsertLocationUpdate_StoresEachIDOnce(t *testing.T) {
+	database := db.NewMemoryDB()
+	update := models.LocationUpdate{ID: "loc-1", DriverID: "1", Latitude: 35.7, Longitude: 51.4, Timestamp: time.Now().UTC()}
+
+	// Replay and redrive consume a message again, so the same update arrives twice.
+	require.NoError(t, database.InsertLocationUpdate(context.Background(), update))
+	require.NoError(t, database.InsertLocationUpdate(context.Background(), update))
+
+	export, err := database.ExportDriverData(context.Background(), models.PrivacyRequest{DriverID: "1"})
+	require.NoError(t, err)
+	assert.Len(t, export.History, 1)
+}
+
+func TestMemoryDBInsertLocationUpdate_RejectsAnIDHeldByAnotherUpdate(t *testing.T) {
+	database := db.NewMemoryDB()
+	update := models.LocationUpdate{ID: "loc-1", DriverID: "1", Latitude: 35.7, Longitude: 51.4, Timestamp: time.Now().UTC()}
+	require.NoError(t, database.InsertLocationUpdate(context.Background(), update))
+
+	other := update
+	other.DriverID = "2"
+	assert.ErrorIs(t, database.InsertLocationUpdate(context.Background(), other), db.ErrIDConflict)
+	later := update
+	later.Timestamp = update.Timestamp.Add(time.Second)
+	assert.ErrorIs(t, database.InsertLocationUpdate(context.Background(), later), db.ErrIDConflict)
+
+	stored, err := database.GetLocationByID(context.Background(), "loc-1")
+	require.NoError(t, err)
+	assert.Equal(t, "1", stored.DriverID)
+}
+
+func TestMongoDBInsertLocationUpdate_StoresEachIDOnce(t *testing.T) {
+	ctx := context.Background()
+	mongoDB := newTestMongoDB(t)
+	id := "history-test-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	update := models.LocationUpdate{ID: id, DriverID: id, Latitude: 35.7, Longitude: 51.4, Timestamp: time.Now().UTC()}
+	t.Cleanup(func() {
+		_, _ = mongoDB.EraseDriverData(context.Background(), models.PrivacyRequest{DriverID: id, RequestedBy: "test"})
+	})
+
+	require.NoError(t, mongoDB.InsertLocationUpdate(ctx, update))
+	require.NoError(t, mongoDB.InsertLocationUpdate(ctx, update))
+	other := update
+	other.DriverID = id + "-other"
+	assert.ErrorIs(t, mongoDB.InsertLocationUpdate(ctx, other), db.ErrIDConflict)
+
+	export, err := mongoDB.ExportDriverData(ctx, models.PrivacyRequest{DriverID: id, RequestedBy: "test"})
+	require.NoError(t, err)
+	assert.Len(t, export.History, 1)
+}
@@ -0,0 +1,168 @@
+package database_test
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"locations/internal/db"
+)
+
+func index(t *testing.T, unique bool, keys bson.D) *mongo.IndexSpecification {
+	t.Helper()
+	return &mongo.IndexSpecification{KeysDocument: mustMarshal(t, keys), Unique: &unique}
+}
+
+func TestHasUniqueIndex(t *testing.T) {
+	tests := []struct {
+		name    string
+		indexes []*mongo.IndexSpecification
+		want    bool
+	}{
+		{"no indexes", nil, false},
+		{"only _id", []*mongo.IndexSpecification{{KeysDocument: mustMarshal(t, bson.D{{Key: "_id", Value: 1}})}}, false},
+		{"unique driver_id", []*mongo.IndexSpecification{index(t, true, bson.D{{Key: "driver_id", Value: 1}})}, true},
+		{"driver_id not unique", []*mongo.IndexSpecification{index(t, false, bson.D{{Key: "driver_id", Value: 1}})}, false},
+		{"unique compound index", []*mongo.IndexSpecification{index(t, true, bson.D{{Key: "driver_id", Value: 1}, {Key: "updated_at", Value: 1}})}, false},
+		{"among others", []*mongo.IndexSpecification{
+			index(t, false, bson.D{{Key: "updated_at", Value: 1}}),
+			index(t, true, bson.D{{Key: "driver_id", Value: 1}}),
+		}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, db.HasUniqueIndex(tt.indexes, "driver_id"))
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, document bson.D) bson.Raw {
+	t.Helper()
+	raw, err := bson.Marshal(document)
+	assert.NoError(t, err)
+	return raw
+}
+
+func TestRunMigrations_RejectsInvalidSteps(t *testing.T) {
+	noop := func(ctx context.Context, database *mongo.Database) error { return nil }
+	tests := map[string][]db.Migration{
+		"out of order": {{Version: 2, Up: noop}, {Version: 1, Up: noop}},
+		"repeated":     {{Version: 1, Up: noop}, {Version: 1, Up: noop}},
+		"no up step":   {{Version: 1}},
+	}
+	for name, migrations := range tests {
+		t.Run(name, func(t *testing.T) {
+			// Steps are checked before the database is touched.
+			assert.Error(t, db.RunMigrations(context.Background(), nil, migrations, time.Minute, nil))
+		})
+	}
+}
+
+// newTestMongoDatabase returns a database of its own on the MongoDB at MONGODB_TEST_URI, dropped after the
+// test, skipping the test without one.
+func newTestMongoDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+	client, err := mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
+	require.NoError(t, err)
+	database := client.Database("migrations_test_" + strconv.FormatInt(time.Now().UnixNano(), 36))
+	t.Cleanup(func() {
+		database.Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+	return database
+}
+
+// appliedVersions lists the versions recorded in the migrations collection.
+func appliedVersions(t *testing.T, database *mongo.Database) []int {
+	t.Helper()
+	cursor, err := database.Collection("migrations").Find(context.Background(), bson.M{"_id": bson.M{"$type": "number"}})
+	require.NoError(t, err)
+	var records []struct {
+		Version int `bson:"_id"`
+	}
+	require.NoError(t, cursor.All(context.Background(), &records))
+	versions := []int{}
+	for _, record := range records {
+		versions = append(versions, record.Version)
+	}
+	return versions
+}
+
+func TestRunMigrations_AppliesEachStepOnce(t *testing.T) {
+	database := newTestMongoDatabase(t)
+	var mu sync.Mutex
+	var ran []int
+	step := func(version int) db.Migration {
+		return db.Migration{Version: version, Up: func(ctx context.Context, database *mongo.Database) error {
+			mu.Lock()
+			defer mu.Unlock()
+			ran = append(ran, version)
+			return nil
+		}}
+	}
+
+	require.NoError(t, db.RunMigrations(context.Background(), database, []db.Migration{step(1), step(2)}, time.Minute, nil))
+	require.NoError(t, db.RunMigrations(context.Background(), database, []db.Migration{step(1), step(2), step(3)}, time.Minute, nil))
+	assert.Equal(t, []int{1, 2, 3}, ran)
+	assert.ElementsMatch(t, []int{1, 2, 3}, appliedVersions(t, database))
+}
+
+func TestRunMigrations_RenewsTheLockDuringLongSteps(t *testing.T) {
+	database := newTestMongoDatabase(t)
+	var running, runs atomic.Int32
+	migrations := []db.Migration{{Version: 1, Up: func(ctx context.Context, database *mongo.Database) error {
+		runs.Add(1)
+		if running.Add(1) > 1 {
+			t.Error("the step ran in two runners at once")
+		}
+		defer running.Add(-1)
+		// Several times the lock's lifetime, which only renewal keeps from expiring.
+		time.Sleep(1500 * time.Millisecond)
+		return nil
+	}}}
+
+	// Replicas starting together.
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(t, db.RunMigrations(context.Background(), database, migrations, 300*time.Millisecond, nil))
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(1), runs.Load())
+}
+
+func TestRunMigrations_StopsWhenTheLockIsLost(t *testing.T) {
+	database := newTestMongoDatabase(t)
+	migrations := []db.Migration{
+		{Version: 1, Up: func(ctx context.Context, database *mongo.Database) error {
+			// Another runner takes the lock over, as when this one stalled past its expiry.
+			_, err := database.Collection("migrations").UpdateOne(ctx, bson.M{"_id": "migrations_lock"}, bson.M{"$set": bson.M{"owner": "another-instance"}})
+			return err
+		}},
+		{Version: 2, Up: func(ctx context.Context, database *mongo.Database) error {
+			t.Error("a step ran without the lock")
+			return nil
+		}},
+	}
+
+	err := db.RunMigrations(context.Background(), database, migrations, time.Minute, nil)
+	assert.ErrorContains(t, err, "lost the migration lock")
+	assert.Empty(t, appliedVersions(t, database), "a step applied without the lock is not recorded")
+}
@@ -0,0 +1,74 @@
+package database_test
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"locations/internal/archive"
+	"locations/internal/db"
+	"locations/internal/models"
+)
+
+// newTestMongoDB connects to the MongoDB at MONGODB_TEST_URI, skipping the test without one. Its data is
+// shared, so tests use driver IDs of their own.
+func newTestMongoDB(t *testing.T) *db.MongoDB {
+	t.Helper()
+	uri := os.Getenv("MONGODB_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGODB_TEST_URI is not set")
+	}
+	mongoDB, err := db.NewMongoDB(uri)
+	require.NoError(t, err)
+	t.Cleanup(func() { mongoDB.Close() })
+	require.NoError(t, mongoDB.Migrate(context.Background()))
+	return mongoDB
+}
+
+func TestMongoDBPrivacy_CoversArchivedHistory(t *testing.T) {
+	ctx := context.Background()
+	mongoDB := newTestMongoDB(t)
+	driverID := "privacy-test-" + strconv.FormatInt(time.Now().UnixNano(), 36)
+	old := time.Now().UTC().AddDate(0, 0, -400).Truncate(time.Millisecond)
+	for i, timestamp := range []time.Time{old, old.Add(time.Hour), time.Now().UTC().Truncate(time.Millisecond)} {
+		require.NoError(t, mongoDB.InsertLocationUpdate(ctx, models.LocationUpdate{
+			ID:        driverID + "-" + strconv.Itoa(i),
+			DriverID:  driverID,
+			Latitude:  35.7,
+			Longitude: 51.4,
+			Timestamp: timestamp,
+		}))
+	}
+
+	store, err := archive.NewLocalStore(t.TempDir())
+	require.NoError(t, err)
+	archiver := archive.NewArchiver(mongoDB, store, archive.DefaultBuckets)
+	_, err = archiver.Archive(ctx, old.Add(2*time.Hour))
+	require.NoError(t, err)
+	database := archive.NewDatabase(mongoDB, archiver)
+
+	export, err := database.ExportDriverData(ctx, models.PrivacyRequest{DriverID: driverID, RequestedBy: "test"})
+	require.NoError(t, err)
+	assert.NotNil(t, export.LivePosition)
+	assert.Len(t, export.History, 1)
+	require.Len(t, export.ArchivedHistory, 2)
+	assert.Equal(t, driverID+"-0", export.ArchivedHistory[0].ID)
+	assert.Equal(t, 35.7, export.ArchivedHistory[0].Latitude)
+
+	result, err := database.EraseDriverData(ctx, models.PrivacyRequest{DriverID: driverID, RequestedBy: "test"})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.HistoryDeleted)
+	assert.Equal(t, int64(1), result.LivePositionDeleted)
+	assert.Equal(t, int64(2), result.ArchivedDeleted)
+
+	export, err = database.ExportDriverData(ctx, models.PrivacyRequest{DriverID: driverID, RequestedBy: "test"})
+	require.NoError(t, err)
+	assert.Nil(t, export.LivePosition)
+	assert.Empty(t, export.History)
+	assert.Empty(t, export.ArchivedHistory)
+}
@@ -0,0 +1,45 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"locations/internal/db"
+	"locations/internal/models"
+)
+
+func TestMemoryDBEraseDriverData_RemovesOnlyThatDriver(t *testing.T) {
+	database := db.NewMemoryDB()
+	now := time.Now().UTC()
+	for _, update := range []models.LocationUpdate{
+		{DriverID: "1", Latitude: 35.7, Longitude: 51.4, Timestamp: now.Add(-2 * time.Minute)},
+		{DriverID: "1", Latitude: 35.8, Longitude: 51.5, Timestamp: now.Add(-time.Minute)},
+		{DriverID: "2", Latitude: 35.9, Longitude: 51.6, Timestamp: now},
+	} {
+		assert.NoError(t, database.InsertLocationUpdate(context.Background(), update))
+	}
+
+	result, err := database.EraseDriverData(context.Background(), models.PrivacyRequest{DriverID: "1", RequestedBy: "ops"})
+	assert.NoError(t, err)
+	assert.Equal(t, "1", result.DriverID)
+	assert.Equal(t, int64(2), result.HistoryDeleted)
+	assert.Equal(t, int64(1), result.LivePositionDeleted)
+
+	export, err := database.ExportDriverData(context.Background(), models.PrivacyRequest{DriverID: "1"})
+	assert.NoError(t, err)
+	assert.Nil(t, export.LivePosition)
+	assert.Empty(t, export.History)
+
+	export, err = database.ExportDriverData(context.Background(), models.PrivacyRequest{DriverID: "2"})
+	assert.NoError(t, err)
+	assert.NotNil(t, export.LivePosition)
+	assert.Len(t, export.History, 1)
+}
+
+func TestMemoryDBEraseDriverData_RequiresDriverID(t *testing.T) {
+	_, err := db.NewMemoryDB().EraseDriverData(context.Background(), models.PrivacyRequest{})
+	assert.Error(t, err)
+}
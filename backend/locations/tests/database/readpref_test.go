@@ -0,0 +1,29 @@
+package database_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"locations/internal/db"
+)
+
+func TestReadRoutingConfig_DefaultIsValid(t *testing.T) {
+	assert.NoError(t, db.DefaultReadRoutingConfig().Validate())
+}
+
+func TestReadRoutingConfig_RejectsPrimaryOnlyOperations(t *testing.T) {
+	for _, operation := range []string{"GetLocationByID", "UpdateLocation", "InsertLocationUpdate"} {
+		config := db.ReadRoutingConfig{SecondaryOperations: []string{operation}}
+		assert.Error(t, config.Validate(), operation)
+	}
+}
+
+func TestReadRoutingConfig_RejectsShortMaxStaleness(t *testing.T) {
+	config := db.ReadRoutingConfig{SecondaryOperations: []string{"Heatmap"}, MaxStaleness: 30 * time.Second}
+	assert.Error(t, config.Validate())
+
+	config.MaxStaleness = 0
+	assert.NoError(t, config.Validate())
+}
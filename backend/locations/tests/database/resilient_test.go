@@ -0,0 +1,77 @@
+package database_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"locations/internal/db"
+	"locations/internal/models"
+	"locations/internal/resilience"
+)
+
+// SlowDatabase is a MemoryDB whose reads block until their context is done, as during a failover.
+type SlowDatabase struct {
+	*db.MemoryDB
+	calls int
+}
+
+func (s *SlowDatabase) GetLocationByID(ctx context.Context, id string) (*models.LocationUpdate, error) {
+	s.calls++
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func testResilienceConfig() db.ResilienceConfig {
+	return db.ResilienceConfig{
+		Timeout:          10 * time.Millisecond,
+		MaxAttempts:      2,
+		RetryBackoff:     time.Millisecond,
+		FailureThreshold: 2,
+		OpenDuration:     time.Hour,
+	}
+}
+
+func TestResilientDatabase_TimesOutRetriesAndOpens(t *testing.T) {
+	inner := &SlowDatabase{MemoryDB: db.NewMemoryDB()}
+	database := db.NewResilientDatabase(inner, testResilienceConfig(), nil)
+
+	for i := 0; i < 2; i++ {
+		_, err := database.GetLocationByID(context.Background(), "1")
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+	}
+	assert.Equal(t, 4, inner.calls, "each read is attempted MaxAttempts times")
+	assert.Equal(t, resilience.Open, database.BreakerState())
+
+	// While open, calls fail fast without reaching the backend.
+	_, err := database.GetLocationByID(context.Background(), "1")
+	assert.ErrorIs(t, err, db.ErrUnavailable)
+	assert.Equal(t, 4, inner.calls)
+
+	stats := database.Stats()["GetLocationByID"]
+	assert.Equal(t, int64(3), stats.Calls)
+	assert.Equal(t, int64(2), stats.Retries)
+	assert.Equal(t, int64(1), stats.Rejected)
+}
+
+func TestResilientDatabase_NotFoundIsNotAFailure(t *testing.T) {
+	database := db.NewResilientDatabase(db.NewMemoryDB(), testResilienceConfig(), nil)
+
+	for i := 0; i < 5; i++ {
+		_, err := database.GetLocationByID(context.Background(), "missing")
+		assert.ErrorIs(t, err, db.ErrNotFound)
+	}
+	assert.Equal(t, resilience.Closed, database.BreakerState())
+	assert.Equal(t, int64(0), database.Stats()["GetLocationByID"].Retries)
+}
+
+func TestFind_ReachesCapabilityThroughDecorator(t *testing.T) {
+	database := db.NewResilientDatabase(db.NewMemoryDB(), testResilienceConfig(), nil)
+
+	_, ok := db.Find[db.LivePositionLister](database)
+	assert.True(t, ok)
+	_, ok = db.Find[db.HistoryArchiver](database)
+	assert.False(t, ok)
+}
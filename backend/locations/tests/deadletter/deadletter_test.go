@@ -0,0 +1,274 @@
+package deadletter_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"locations/internal/consumer"
+)
+
+// fakeReader serves messages from a channel. Once the channel is closed and drained, reads fail as if the
+// brokers were lost.
+type fakeReader struct {
+	messages chan kafka.Message
+
+	mu        sync.Mutex
+	committed []kafka.Message
+	closed    bool
+}
+
+func newFakeReader(messages ...kafka.Message) *fakeReader {
+	r := &fakeReader{messages: make(chan kafka.Message, len(messages))}
+	for _, msg := range messages {
+		r.messages <- msg
+	}
+	return r
+}
+
+func (r *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case msg, ok := <-r.messages:
+		if !ok {
+			return kafka.Message{}, errors.New("connection lost")
+		}
+		return msg, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (r *fakeReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	msg, err := r.FetchMessage(ctx)
+	if err != nil {
+		return msg, err
+	}
+	return msg, r.CommitMessages(ctx, msg)
+}
+
+func (r *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.committed = append(r.committed, msgs...)
+	return nil
+}
+
+func (r *fakeReader) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closed = true
+	return nil
+}
+
+func (r *fakeReader) committedOffsets() []int64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	offsets := make([]int64, len(r.committed))
+	for i, msg := range r.committed {
+		offsets[i] = msg.Offset
+	}
+	return offsets
+}
+
+// fakeWriter keeps what is written, or fails with err if it is set.
+type fakeWriter struct {
+	err error
+
+	mu      sync.Mutex
+	written []kafka.Message
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	if w.err != nil {
+		return w.err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.written = append(w.written, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) Close() error {
+	return nil
+}
+
+func (w *fakeWriter) messages() []kafka.Message {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return append([]kafka.Message(nil), w.written...)
+}
+
+// failingProcessor fails every message whose value is "bad".
+type failingProcessor struct{}
+
+func (failingProcessor) ProcessMessage(ctx context.Context, msg kafka.Message) error {
+	if string(msg.Value) == "bad" {
+		return errors.New("invalid location update")
+	}
+	return nil
+}
+
+func header(msg kafka.Message, key string) string {
+	for _, h := range msg.Headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func TestConsumerDeadLettersFailedMessages(t *testing.T) {
+	reader := newFakeReader(
+		kafka.Message{Topic: "locations", Partition: 1, Offset: 7, Key: []byte("driver-1"), Value: []byte("good")},
+		kafka.Message{Topic: "locations", Partition: 1, Offset: 8, Key: []byte("driver-2"), Value: []byte("bad"),
+			Headers: []kafka.Header{{Key: consumer.HeaderRedriveCount, Value: []byte("1")}}},
+	)
+	close(reader.messages)
+	writer := &fakeWriter{}
+
+	err := consumer.RunConsumer(context.Background(), reader, "locations", failingProcessor{}, consumer.NewMonitor(),
+		consumer.NewDeadLetterQueueWithWriter(writer), nil)
+	assert.ErrorContains(t, err, "consumer stopped")
+	assert.True(t, reader.closed)
+	assert.Equal(t, []int64{7, 8}, reader.committedOffsets(), "both the processed and the dead-lettered message are committed")
+
+	deadLetters := writer.messages()
+	require.Len(t, deadLetters, 1)
+	msg := deadLetters[0]
+	assert.Equal(t, "driver-2", string(msg.Key))
+	assert.Equal(t, "invalid location update", header(msg, consumer.HeaderDeadLetterError))
+	assert.Equal(t, "locations", header(msg, consumer.HeaderDeadLetterTopic))
+	assert.Equal(t, "1", header(msg, consumer.HeaderDeadLetterPartition))
+	assert.Equal(t, "8", header(msg, consumer.HeaderDeadLetterOffset))
+	assert.Equal(t, "1", header(msg, consumer.HeaderRedriveCount), "the redrive count survives dead-lettering")
+}
+
+func TestConsumerLeavesMessagesItCannotDeadLetterUncommitted(t *testing.T) {
+	reader := newFakeReader(
+		kafka.Message{Topic: "locations", Partition: 1, Offset: 7, Value: []byte("good")},
+		kafka.Message{Topic: "locations", Partition: 1, Offset: 8, Value: []byte("bad")},
+		kafka.Message{Topic: "locations", Partition: 1, Offset: 9, Value: []byte("good")},
+	)
+	monitor := consumer.NewMonitor()
+
+	err := consumer.RunConsumer(context.Background(), reader, "locations", failingProcessor{}, monitor,
+		consumer.NewDeadLetterQueueWithWriter(&fakeWriter{err: errors.New("brokers unreachable")}), nil)
+	assert.ErrorContains(t, err, "consumer stopped")
+	assert.Equal(t, []int64{7}, reader.committedOffsets(), "the failed message is delivered again after a restart")
+	assert.Len(t, reader.messages, 1, "the loop stops at the message it could not keep")
+	_, err = monitor.Check(0)(context.Background())
+	assert.ErrorContains(t, err, "brokers unreachable")
+}
+
+func TestMonitorForgetsLagOfPartitionsNoLongerRead(t *testing.T) {
+	var mu sync.Mutex
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	monitor := consumer.NewMonitorWithClock(func() time.Time {
+		mu.Lock()
+		defer mu.Unlock()
+		return now
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	reader := newFakeReader(kafka.Message{Topic: "locations", Partition: 3, Offset: 0, HighWaterMark: 1000, Value: []byte("good")})
+	go consumer.RunConsumer(ctx, reader, "locations", failingProcessor{}, monitor, nil, nil)
+
+	require.Eventually(t, func() bool {
+		details, _ := monitor.Check(100)(context.Background())
+		return details["lag"] == int64(999)
+	}, 5*time.Second, time.Millisecond)
+	_, err := monitor.Check(100)(context.Background())
+	assert.ErrorContains(t, err, "999 messages behind")
+
+	// A rebalance gave partition 3 to another consumer, so this one stops reading it.
+	mu.Lock()
+	now = now.Add(10 * time.Minute)
+	mu.Unlock()
+	details, err := monitor.Check(100)(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), details["lag"])
+	assert.Empty(t, details["partition_lag"])
+}
+
+func TestConsumerStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- consumer.RunConsumer(ctx, newFakeReader(), "locations", failingProcessor{}, nil, nil, nil)
+	}()
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("the consumer did not stop")
+	}
+}
+
+// deadLettered is a message on partition 0 of the dead-letter topic.
+func deadLettered(offset int64, redrives string) kafka.Message {
+	headers := []kafka.Header{
+		{Key: "traceparent", Value: []byte("00-trace")},
+		{Key: consumer.HeaderDeadLetterError, Value: []byte("database unavailable")},
+		{Key: consumer.HeaderDeadLetterOffset, Value: []byte("42")},
+	}
+	if redrives != "" {
+		headers = append(headers, kafka.Header{Key: consumer.HeaderRedriveCount, Value: []byte(redrives)})
+	}
+	return kafka.Message{Partition: 0, Offset: offset, Key: []byte("driver-1"), Value: []byte("update"), Headers: headers}
+}
+
+func TestRedriveStopsAtTheEndItSawAtStart(t *testing.T) {
+	// Offsets 2 and 3 were dead-lettered again after the redrive began, as they are while the fault persists.
+	reader := newFakeReader(deadLettered(0, ""), deadLettered(1, "1"), deadLettered(2, "1"), deadLettered(3, "2"))
+	writer := &fakeWriter{}
+
+	result, err := consumer.RedriveMessages(context.Background(), reader, writer, map[int]int64{0: 2}, time.Minute, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, consumer.RedriveResult{Redriven: 2}, result)
+	assert.Equal(t, []int64{0, 1}, reader.committedOffsets())
+
+	republished := writer.messages()
+	require.Len(t, republished, 2)
+	assert.Equal(t, []kafka.Header{
+		{Key: "traceparent", Value: []byte("00-trace")},
+		{Key: consumer.HeaderRedriveCount, Value: []byte("1")},
+	}, republished[0].Headers)
+	assert.Equal(t, "2", header(republished[1], consumer.HeaderRedriveCount))
+}
+
+func TestRedriveSkipsMessagesRedrivenTooOften(t *testing.T) {
+	reader := newFakeReader(deadLettered(0, "3"), deadLettered(1, "2"))
+	writer := &fakeWriter{}
+
+	result, err := consumer.RedriveMessages(context.Background(), reader, writer, map[int]int64{0: 2}, time.Minute, 3, nil)
+	require.NoError(t, err)
+	assert.Equal(t, consumer.RedriveResult{Redriven: 1, Skipped: 1}, result)
+	assert.Equal(t, []int64{0, 1}, reader.committedOffsets(), "skipped messages are committed so the next redrive moves on")
+	require.Len(t, writer.messages(), 1)
+	assert.Equal(t, "3", header(writer.messages()[0], consumer.HeaderRedriveCount))
+}
+
+func TestRedriveReturnsWhenIdle(t *testing.T) {
+	// Partition 1 was drained by an earlier redrive, so nothing more is read from it.
+	reader := newFakeReader(deadLettered(4, ""))
+	writer := &fakeWriter{}
+
+	start := time.Now()
+	result, err := consumer.RedriveMessages(context.Background(), reader, writer, map[int]int64{0: 5, 1: 3}, 50*time.Millisecond, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, consumer.RedriveResult{Redriven: 1}, result)
+	assert.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestRedriveOfAnEmptyTopicReturnsAtOnce(t *testing.T) {
+	result, err := consumer.RedriveMessages(context.Background(), newFakeReader(), &fakeWriter{}, map[int]int64{0: 0}, time.Minute, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, consumer.RedriveResult{}, result)
+}
@@ -0,0 +1,94 @@
+package fieldcrypt_test
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"locations/internal/fieldcrypt"
+)
+
+func newKeys(t *testing.T, ids ...string) map[string][]byte {
+	keys := make(map[string][]byte, len(ids))
+	for _, id := range ids {
+		key, err := fieldcrypt.GenerateKey()
+		require.NoError(t, err)
+		keys[id] = key
+	}
+	return keys
+}
+
+func TestCipher_SealOpen(t *testing.T) {
+	cipher := fieldcrypt.NewCipher(fieldcrypt.NewLocalKeyProvider("k1", newKeys(t, "k1")))
+
+	envelope, err := cipher.Seal([]byte("35.7,51.4"), []byte("driver-1"))
+	require.NoError(t, err)
+	assert.Equal(t, "k1", envelope.KeyID)
+	assert.NotContains(t, string(envelope.Ciphertext), "35.7")
+
+	plaintext, err := cipher.Open(envelope, []byte("driver-1"))
+	require.NoError(t, err)
+	assert.Equal(t, "35.7,51.4", string(plaintext))
+}
+
+func TestCipher_OpenWithWrongAssociatedData(t *testing.T) {
+	cipher := fieldcrypt.NewCipher(fieldcrypt.NewLocalKeyProvider("k1", newKeys(t, "k1")))
+
+	envelope, err := cipher.Seal([]byte("35.7,51.4"), []byte("driver-1"))
+	require.NoError(t, err)
+
+	_, err = cipher.Open(envelope, []byte("driver-2"))
+	assert.ErrorIs(t, err, fieldcrypt.ErrDecrypt)
+}
+
+func TestCipher_RewrapAfterRotation(t *testing.T) {
+	keys := newKeys(t, "k1", "k2")
+	old := fieldcrypt.NewCipher(fieldcrypt.NewLocalKeyProvider("k1", keys))
+	envelope, err := old.Seal([]byte("35.7,51.4"), nil)
+	require.NoError(t, err)
+
+	rotated := fieldcrypt.NewCipher(fieldcrypt.NewLocalKeyProvider("k2", keys))
+	rewrapped, changed, err := rotated.Rewrap(envelope)
+	require.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "k2", rewrapped.KeyID)
+	assert.Equal(t, envelope.Ciphertext, rewrapped.Ciphertext)
+
+	// Once rewrapped, the old key is no longer needed.
+	retired := fieldcrypt.NewCipher(fieldcrypt.NewLocalKeyProvider("k2", map[string][]byte{"k2": keys["k2"]}))
+	plaintext, err := retired.Open(rewrapped, nil)
+	require.NoError(t, err)
+	assert.Equal(t, "35.7,51.4", string(plaintext))
+
+	_, changed, err = rotated.Rewrap(rewrapped)
+	require.NoError(t, err)
+	assert.False(t, changed)
+}
+
+func TestLoadKeyfile(t *testing.T) {
+	keys := newKeys(t, "2024-01")
+	path := filepath.Join(t.TempDir(), "keys.json")
+	content := fmt.Sprintf(`{"current": "2024-01", "keys": {"2024-01": %q}}`, base64.StdEncoding.EncodeToString(keys["2024-01"]))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	provider, err := fieldcrypt.LoadKeyfile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "2024-01", provider.CurrentKeyID())
+
+	key, err := provider.Key("2024-01")
+	require.NoError(t, err)
+	assert.Equal(t, keys["2024-01"], key)
+}
+
+func TestLoadKeyfile_MissingCurrentKey(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"current": "2024-02", "keys": {}}`), 0o600))
+
+	_, err := fieldcrypt.LoadKeyfile(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,46 @@
+package geo_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"locations/internal/geo"
+)
+
+func TestEncode_KnownGeohash(t *testing.T) {
+	assert.Equal(t, "u4pruydqq", geo.Encode(57.64911, 10.40744, 9))
+	assert.Equal(t, "u4pru", geo.Encode(57.64911, 10.40744, 5))
+}
+
+func TestEncode_Poles(t *testing.T) {
+	// Points on the north pole and antimeridian fall in the last cell rather than off the grid.
+	assert.Equal(t, "zzzzzz", geo.Encode(90, 180, 6))
+	assert.Equal(t, "000000", geo.Encode(-90, -180, 6))
+}
+
+func TestDecode_ContainsEncodedPoint(t *testing.T) {
+	bounds, err := geo.Decode(geo.Encode(35.6892, 51.3890, 7))
+	assert.NoError(t, err)
+
+	assert.LessOrEqual(t, bounds.MinLat, 35.6892)
+	assert.GreaterOrEqual(t, bounds.MaxLat, 35.6892)
+	assert.LessOrEqual(t, bounds.MinLng, 51.3890)
+	assert.GreaterOrEqual(t, bounds.MaxLng, 51.3890)
+}
+
+func TestDecode_InvalidCharacter(t *testing.T) {
+	_, err := geo.Decode("u4pa")
+	assert.Error(t, err)
+}
+
+func TestCellGeohash_MatchesEncode(t *testing.T) {
+	latIndex, lngIndex := geo.CellIndex(-33.8688, 151.2093, 6)
+	assert.Equal(t, geo.Encode(-33.8688, 151.2093, 6), geo.CellGeohash(latIndex, lngIndex, 6))
+}
+
+func TestDistanceMeters(t *testing.T) {
+	// One degree of latitude is roughly 111km.
+	assert.InDelta(t, 111195, geo.DistanceMeters(0, 0, 1, 0), 100)
+	assert.Equal(t, 0.0, geo.DistanceMeters(35.7, 51.4, 35.7, 51.4))
+}
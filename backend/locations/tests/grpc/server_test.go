@@ -0,0 +1,161 @@
+package grpc_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"locations/internal/db"
+	locationsgrpc "locations/internal/grpc"
+	"locations/internal/grpc/locationspb"
+	"locations/internal/live"
+	"locations/internal/models"
+	"locations/internal/producer"
+)
+
+// newClient serves a Server over an in-memory connection and returns a client for it.
+func newClient(t *testing.T, database db.Database, hub *live.Hub) locationspb.LocationServiceClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	server := grpc.NewServer()
+	locationspb.RegisterLocationServiceServer(server, locationsgrpc.NewServer(&producer.KafkaProducer{}, database, hub, nil))
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return locationspb.NewLocationServiceClient(conn)
+}
+
+func TestGetDriverLocation(t *testing.T) {
+	ctx := context.Background()
+	database := db.NewMemoryDB()
+	client := newClient(t, database, live.NewHub())
+
+	updatedAt := time.Now().UTC().Truncate(time.Millisecond)
+	require.NoError(t, database.InsertLocationUpdate(ctx, models.LocationUpdate{
+		DriverID:  "driver-1",
+		Latitude:  35.7,
+		Longitude: 51.4,
+		Timestamp: updatedAt,
+		Accuracy:  8,
+	}))
+
+	position, err := client.GetDriverLocation(ctx, &locationspb.GetDriverLocationRequest{DriverId: "driver-1"})
+	require.NoError(t, err)
+	assert.Equal(t, "driver-1", position.DriverId)
+	assert.Equal(t, 35.7, position.Latitude)
+	assert.Equal(t, 51.4, position.Longitude)
+	assert.Equal(t, 8.0, position.Accuracy)
+	assert.True(t, updatedAt.Equal(position.UpdatedAt.AsTime()))
+	assert.False(t, position.Stale)
+}
+
+func TestGetDriverLocation_NotFound(t *testing.T) {
+	client := newClient(t, db.NewMemoryDB(), live.NewHub())
+
+	_, err := client.GetDriverLocation(context.Background(), &locationspb.GetDriverLocationRequest{DriverId: "unknown"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+// failingDB fails live position reads with err.
+type failingDB struct {
+	*db.MemoryDB
+	err error
+}
+
+func (f failingDB) GetDriverLocation(ctx context.Context, driverID string) (*models.Driver, error) {
+	return nil, f.err
+}
+
+func TestGetDriverLocation_DatabaseErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want codes.Code
+	}{
+		{"unavailable", fmt.Errorf("read: %w", db.ErrUnavailable), codes.Unavailable},
+		{"transient", fmt.Errorf("read: %w", mongo.CommandError{Code: 189, Name: "PrimarySteppedDown"}), codes.Unavailable},
+		{"internal", errors.New("decode failed"), codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := newClient(t, failingDB{MemoryDB: db.NewMemoryDB(), err: tt.err}, live.NewHub())
+
+			_, err := client.GetDriverLocation(context.Background(), &locationspb.GetDriverLocationRequest{DriverId: "driver-1"})
+			assert.Equal(t, tt.want, status.Code(err))
+		})
+	}
+}
+
+func TestFindNearbyDrivers_InvalidCoordinates(t *testing.T) {
+	client := newClient(t, db.NewMemoryDB(), live.NewHub())
+
+	_, err := client.FindNearbyDrivers(context.Background(), &locationspb.FindNearbyDriversRequest{Latitude: 91, Longitude: 0})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestReportLocation_InvalidUpdate(t *testing.T) {
+	client := newClient(t, db.NewMemoryDB(), live.NewHub())
+
+	_, err := client.ReportLocation(context.Background(), &locationspb.LocationUpdate{DriverId: "driver-1", Latitude: 120})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+}
+
+func TestWatchDriver_StreamsHubUpdates(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	hub := live.NewHub()
+	client := newClient(t, db.NewMemoryDB(), hub)
+
+	stream, err := client.WatchDriver(ctx, &locationspb.WatchDriverRequest{DriverId: "driver-1"})
+	require.NoError(t, err)
+
+	// The subscription is registered once the server has handled the call, so keep publishing until it arrives.
+	received := make(chan *locationspb.DriverPosition, 1)
+	go func() {
+		position, err := stream.Recv()
+		if err == nil {
+			received <- position
+		}
+	}()
+	ticker := time.NewTicker(10 * time.Millisecond)
+	defer ticker.Stop()
+	for i := 0; ; i++ {
+		select {
+		case position := <-received:
+			assert.Equal(t, "driver-1", position.DriverId)
+			assert.Equal(t, 35.7, position.Latitude)
+			return
+		case <-ticker.C:
+			hub.PublishLocation(models.LocationUpdate{
+				DriverID:  "driver-1",
+				Latitude:  35.7,
+				Longitude: 51.4,
+				Timestamp: time.Now().UTC().Add(time.Duration(i) * time.Millisecond),
+			})
+		case <-ctx.Done():
+			t.Fatal("no position was streamed")
+		}
+	}
+}
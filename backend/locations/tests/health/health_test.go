@@ -0,0 +1,112 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"locations/internal/consumer"
+	"locations/internal/health"
+)
+
+func TestChecker_UpWhenEveryCheckPasses(t *testing.T) {
+	checker := health.NewChecker()
+	checker.Register("mongodb", health.Ping(func(ctx context.Context) error { return nil }))
+	checker.Register("kafka", func(ctx context.Context) (map[string]any, error) {
+		return map[string]any{"partitions": 3}, nil
+	})
+
+	report := checker.Check(context.Background())
+
+	assert.Equal(t, health.StatusUp, report.Status)
+	require.Len(t, report.Checks, 2)
+	assert.Equal(t, health.StatusUp, report.Checks["mongodb"].Status)
+	assert.Equal(t, map[string]any{"partitions": 3}, report.Checks["kafka"].Details)
+	assert.NotEmpty(t, report.Build.GoVersion)
+}
+
+func TestChecker_DownWhenAnyCheckFails(t *testing.T) {
+	checker := health.NewChecker()
+	checker.Register("mongodb", health.Ping(func(ctx context.Context) error { return nil }))
+	checker.Register("kafka", func(ctx context.Context) (map[string]any, error) {
+		return nil, errors.New("broker unreachable")
+	})
+
+	report := checker.Check(context.Background())
+
+	assert.Equal(t, health.StatusDown, report.Status)
+	assert.Equal(t, health.StatusUp, report.Checks["mongodb"].Status)
+	assert.Equal(t, health.StatusDown, report.Checks["kafka"].Status)
+	assert.Equal(t, "broker unreachable", report.Checks["kafka"].Error)
+}
+
+func TestChecker_HangingCheckIsCutShort(t *testing.T) {
+	checker := health.NewChecker()
+	checker.Register("mongodb", health.Ping(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	report := checker.Check(ctx)
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, health.StatusDown, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks["mongodb"].Error)
+}
+
+func TestChecker_NoChecksIsUp(t *testing.T) {
+	report := health.NewChecker().Check(context.Background())
+
+	assert.Equal(t, health.StatusUp, report.Status)
+	assert.Empty(t, report.Checks)
+}
+
+func TestCachingChecker_ReusesReportsForTheTTL(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	checker := health.NewCachingCheckerWithClock(2*time.Second, func() time.Time { return now })
+	calls := 0
+	checker.Register("mongodb", health.Ping(func(ctx context.Context) error {
+		calls++
+		return nil
+	}))
+
+	checker.Check(context.Background())
+	now = now.Add(time.Second)
+	report := checker.Check(context.Background())
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 1.0, report.UptimeSeconds)
+
+	now = now.Add(time.Second)
+	checker.Check(context.Background())
+	assert.Equal(t, 2, calls)
+}
+
+func TestCachingChecker_DoesNotReuseReportsCutShort(t *testing.T) {
+	checker := health.NewCachingChecker(time.Minute)
+	calls := 0
+	checker.Register("mongodb", health.Ping(func(ctx context.Context) error {
+		calls++
+		return ctx.Err()
+	}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, health.StatusDown, checker.Check(ctx).Status)
+	assert.Equal(t, health.StatusUp, checker.Check(context.Background()).Status)
+	assert.Equal(t, 2, calls)
+}
+
+func TestMonitor_DownBeforeConsumerStarts(t *testing.T) {
+	details, err := consumer.NewMonitor().Check(consumer.DefaultMaxLag)(context.Background())
+
+	assert.ErrorContains(t, err, "has not started")
+	assert.Equal(t, false, details["running"])
+	assert.EqualValues(t, 0, details["lag"])
+}
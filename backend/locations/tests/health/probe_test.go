@@ -0,0 +1,75 @@
+package health_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"locations/internal/health"
+	locationshttp "locations/internal/http"
+	"locations/internal/metrics"
+)
+
+// freeAddr returns a local address nothing listens on.
+func freeAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	require.NoError(t, listener.Close())
+	return addr
+}
+
+func TestProbeServer(t *testing.T) {
+	checker := health.NewChecker()
+	checker.Register("kafka", func(ctx context.Context) (map[string]any, error) {
+		return nil, errors.New("dial tcp 10.0.0.7:9092: connection refused")
+	})
+	addr := freeAddr(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- locationshttp.RunProbeServer(ctx, addr, "admin-token", checker, metrics.New(), nil)
+	}()
+
+	get := func(path, token string) *http.Response {
+		t.Helper()
+		request, err := http.NewRequest(http.MethodGet, "http://"+addr+path, nil)
+		require.NoError(t, err)
+		if token != "" {
+			request.Header.Set("Authorization", "Bearer "+token)
+		}
+		response, err := http.DefaultClient.Do(request)
+		require.NoError(t, err)
+		response.Body.Close()
+		return response
+	}
+	require.Eventually(t, func() bool {
+		response, err := http.Get("http://" + addr + "/healthz")
+		if err != nil {
+			return false
+		}
+		response.Body.Close()
+		return response.StatusCode == http.StatusOK
+	}, 5*time.Second, 10*time.Millisecond)
+
+	assert.Equal(t, http.StatusServiceUnavailable, get("/readyz", "").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, get("/status", "").StatusCode, "the status page shows dependencies' errors")
+	assert.Equal(t, http.StatusOK, get("/status", "admin-token").StatusCode)
+	assert.Equal(t, http.StatusOK, get("/metrics", "").StatusCode)
+	assert.Equal(t, http.StatusNotFound, get("/location?id=loc-1", "").StatusCode, "the API is not served")
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(10 * time.Second):
+		t.Fatal("the probe server did not stop")
+	}
+}
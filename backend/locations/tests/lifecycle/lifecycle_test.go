@@ -0,0 +1,79 @@
+package lifecycle_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"locations/internal/lifecycle"
+)
+
+// untilCanceled is a component that runs until its context is canceled, counting how many have stopped.
+func untilCanceled(name string, stopped *atomic.Int32) lifecycle.Component {
+	return lifecycle.Component{Name: name, Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		stopped.Add(1)
+		return nil
+	}}
+}
+
+// run runs components, failing the test if Run doesn't return.
+func run(t *testing.T, ctx context.Context, components ...lifecycle.Component) error {
+	t.Helper()
+	done := make(chan error, 1)
+	go func() { done <- lifecycle.Run(ctx, nil, components) }()
+	select {
+	case err := <-done:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return")
+		return nil
+	}
+}
+
+func TestRunStopsEveryComponentWhenCanceled(t *testing.T) {
+	var stopped atomic.Int32
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+
+	err := run(t, ctx, untilCanceled("HTTP server", &stopped), untilCanceled("consumer", &stopped))
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), stopped.Load())
+}
+
+func TestRunStopsTheOthersWhenOneFails(t *testing.T) {
+	var stopped atomic.Int32
+	failing := lifecycle.Component{Name: "consumer", Run: func(ctx context.Context) error {
+		return errors.New("brokers unreachable")
+	}}
+
+	err := run(t, context.Background(), untilCanceled("HTTP server", &stopped), failing)
+	assert.EqualError(t, err, "consumer failed: brokers unreachable")
+	assert.Equal(t, int32(1), stopped.Load())
+}
+
+func TestRunTreatsAnEarlyReturnAsAFailure(t *testing.T) {
+	var stopped atomic.Int32
+	quitting := lifecycle.Component{Name: "MQTT bridge", Run: func(ctx context.Context) error {
+		return nil
+	}}
+
+	err := run(t, context.Background(), untilCanceled("HTTP server", &stopped), quitting)
+	assert.EqualError(t, err, "MQTT bridge failed: stopped unexpectedly")
+	assert.Equal(t, int32(1), stopped.Load())
+}
+
+func TestRunOnlyLogsErrorsWhileStopping(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(10*time.Millisecond, cancel)
+	unclean := lifecycle.Component{Name: "gRPC server", Run: func(ctx context.Context) error {
+		<-ctx.Done()
+		return errors.New("connections cut off")
+	}}
+
+	assert.NoError(t, run(t, ctx, unclean))
+}
@@ -0,0 +1,136 @@
+package live_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"locations/internal/db"
+	"locations/internal/live"
+	"locations/internal/models"
+)
+
+func TestHubPublish_FiltersByDriver(t *testing.T) {
+	hub := live.NewHub()
+
+	all := hub.Subscribe("")
+	defer all.Close()
+	one := hub.Subscribe("driver-1")
+	defer one.Close()
+
+	hub.Publish(models.Driver{DriverID: "driver-2"})
+	hub.Publish(models.Driver{DriverID: "driver-1"})
+
+	assert.Equal(t, "driver-2", (<-all.C).DriverID)
+	assert.Equal(t, "driver-1", (<-all.C).DriverID)
+	assert.Equal(t, "driver-1", (<-one.C).DriverID)
+	assert.Len(t, one.C, 0)
+}
+
+func TestHubRun_PollsBackendWithoutChangeStreams(t *testing.T) {
+	database := db.NewMemoryDB()
+	hub := live.NewHub()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	sub := hub.Subscribe("driver-1")
+	done := make(chan error)
+	go func() {
+		done <- hub.Run(ctx, database, 10*time.Millisecond, nil)
+	}()
+
+	update := models.LocationUpdate{
+		DriverID:  "driver-1",
+		Latitude:  35.7,
+		Longitude: 51.4,
+		Timestamp: time.Now().UTC().Add(time.Second),
+	}
+	assert.NoError(t, database.InsertLocationUpdate(ctx, update))
+
+	select {
+	case driver := <-sub.C:
+		assert.Equal(t, "driver-1", driver.DriverID)
+		assert.Equal(t, []float64{51.4, 35.7}, driver.Location.Coordinates)
+	case <-time.After(time.Second):
+		assert.Fail(t, "no live position was published")
+	}
+
+	// Stopping the hub closes its subscriptions.
+	cancel()
+	assert.NoError(t, <-done)
+	_, ok := <-sub.C
+	assert.False(t, ok)
+}
+
+func TestHubRun_PollingKeysOnWhenPositionsWereStored(t *testing.T) {
+	database := db.NewMemoryDB()
+	hub := live.NewHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Stored while the process was restarting, and a fix from a device whose clock is a day ahead.
+	require.NoError(t, database.InsertLocationUpdate(ctx, models.LocationUpdate{DriverID: "driver-1", Latitude: 35.7, Longitude: 51.4, Timestamp: time.Now().UTC()}))
+	require.NoError(t, database.InsertLocationUpdate(ctx, models.LocationUpdate{DriverID: "driver-2", Latitude: 35.7, Longitude: 51.4, Timestamp: time.Now().UTC().Add(24 * time.Hour)}))
+
+	sub := hub.Subscribe("")
+	defer sub.Close()
+	go hub.Run(ctx, database, 10*time.Millisecond, nil)
+
+	received := map[string]bool{}
+	receive := func() {
+		select {
+		case driver := <-sub.C:
+			received[driver.DriverID] = true
+		case <-time.After(time.Second):
+			require.Fail(t, "no live position was published", "received %v", received)
+		}
+	}
+	receive()
+	receive()
+	assert.Equal(t, map[string]bool{"driver-1": true, "driver-2": true}, received)
+
+	// The future fix doesn't hold back positions stored after it.
+	require.NoError(t, database.InsertLocationUpdate(ctx, models.LocationUpdate{DriverID: "driver-3", Latitude: 35.7, Longitude: 51.4, Timestamp: time.Now().UTC()}))
+	receive()
+	assert.True(t, received["driver-3"])
+}
+
+func TestHubPublish_DropsPositionsNotNewerThanLast(t *testing.T) {
+	hub := live.NewHub()
+	sub := hub.Subscribe("driver-1")
+	defer sub.Close()
+
+	now := time.Now().UTC()
+	hub.PublishLocation(models.LocationUpdate{DriverID: "driver-1", Latitude: 35.7, Longitude: 51.4, Timestamp: now})
+	hub.Publish(models.Driver{DriverID: "driver-1", Location: models.NewGeoPoint(35.7, 51.4), UpdatedAt: now})
+	hub.Publish(models.Driver{DriverID: "driver-1", UpdatedAt: now.Add(-time.Second)})
+
+	assert.Equal(t, now, (<-sub.C).UpdatedAt)
+	assert.Len(t, sub.C, 0)
+}
+
+func TestHubPublish_ForgetsQuietDrivers(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	hub := live.NewHubWithClock(func() time.Time { return now })
+	sub := hub.Subscribe("driver-1")
+	defer sub.Close()
+
+	position := models.Driver{DriverID: "driver-1", Location: models.NewGeoPoint(35.7, 51.4), UpdatedAt: now}
+	hub.Publish(position)
+	<-sub.C
+
+	// A copy of the position within minutes is a duplicate.
+	now = now.Add(5 * time.Minute)
+	hub.Publish(position)
+	assert.Len(t, sub.C, 0)
+
+	// Once the driver has been quiet for long enough, the hub no longer remembers their last position.
+	now = now.Add(10 * time.Minute)
+	hub.Publish(models.Driver{DriverID: "driver-2", UpdatedAt: now})
+	hub.Publish(position)
+	assert.Len(t, sub.C, 1)
+}
@@ -0,0 +1,123 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+
+	"locations/internal/consumer"
+	"locations/internal/db"
+	"locations/internal/logging"
+)
+
+// newLogger returns a JSON logger at level and the lines it writes, decoded.
+func newLogger(t *testing.T, level slog.Level) (*slog.Logger, func() []map[string]any) {
+	t.Helper()
+	var buf bytes.Buffer
+	logger, err := logging.New(&buf, logging.Config{Level: level})
+	require.NoError(t, err)
+
+	return logger, func() []map[string]any {
+		var lines []map[string]any
+		decoder := json.NewDecoder(&buf)
+		for decoder.More() {
+			var line map[string]any
+			require.NoError(t, decoder.Decode(&line))
+			lines = append(lines, line)
+		}
+		return lines
+	}
+}
+
+func TestCoordinatesAreRedactedAboveDebug(t *testing.T) {
+	logger, lines := newLogger(t, slog.LevelInfo)
+	logger.Info("Stored location", "driver_id", "driver-1", "latitude", 35.7, "longitude", 51.4)
+
+	logged := lines()
+	require.Len(t, logged, 1)
+	assert.Equal(t, "driver-1", logged[0]["driver_id"])
+	assert.Equal(t, logging.Redacted, logged[0]["latitude"])
+	assert.Equal(t, logging.Redacted, logged[0]["longitude"])
+}
+
+func TestCoordinatesAreLoggedAtDebug(t *testing.T) {
+	logger, lines := newLogger(t, slog.LevelDebug)
+	logger.Debug("Stored location", "latitude", 35.7)
+
+	logged := lines()
+	require.Len(t, logged, 1)
+	assert.Equal(t, 35.7, logged[0]["latitude"])
+}
+
+func TestLinesCarryRequestAndTraceIDs(t *testing.T) {
+	logger, lines := newLogger(t, slog.LevelInfo)
+	traceID, err := trace.TraceIDFromHex("4bf92f3577b34da6a3ce929d0e0e4736")
+	require.NoError(t, err)
+	spanID, err := trace.SpanIDFromHex("00f067aa0ba902b7")
+	require.NoError(t, err)
+	ctx := trace.ContextWithSpanContext(context.Background(), trace.NewSpanContext(trace.SpanContextConfig{
+		TraceID: traceID,
+		SpanID:  spanID,
+	}))
+	ctx = logging.WithRequestID(ctx, "req-123")
+
+	logger.With("component", "test").InfoContext(ctx, "Handled request")
+	logger.Info("No request")
+
+	logged := lines()
+	require.Len(t, logged, 2)
+	assert.Equal(t, "req-123", logged[0]["request_id"])
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", logged[0]["trace_id"])
+	assert.Equal(t, "00f067aa0ba902b7", logged[0]["span_id"])
+	assert.Equal(t, "test", logged[0]["component"])
+	assert.NotContains(t, logged[1], "request_id")
+	assert.NotContains(t, logged[1], "trace_id")
+}
+
+func TestMessageBodiesOnlyAppearAtDebug(t *testing.T) {
+	msg := kafka.Message{Partition: 3, Offset: 42, Value: []byte(`{"driver_id": "driver-1", "latitude": 35.7`)}
+
+	logger, lines := newLogger(t, slog.LevelInfo)
+	require.Error(t, consumer.NewKafkaMessageProcessor(db.NewMemoryDB(), nil, logger).ProcessMessage(context.Background(), msg))
+	logged := lines()
+	require.Len(t, logged, 1)
+	assert.Equal(t, "Failed to parse location update", logged[0]["msg"])
+	assert.Equal(t, float64(3), logged[0]["partition"])
+	assert.Equal(t, float64(42), logged[0]["offset"])
+	assert.NotContains(t, logged[0], "message_body")
+
+	logger, lines = newLogger(t, slog.LevelDebug)
+	require.Error(t, consumer.NewKafkaMessageProcessor(db.NewMemoryDB(), nil, logger).ProcessMessage(context.Background(), msg))
+	logged = lines()
+	require.Len(t, logged, 2)
+	assert.Equal(t, string(msg.Value), logged[0]["message_body"])
+}
+
+func TestParseLevel(t *testing.T) {
+	level, err := logging.ParseLevel("DEBUG")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelDebug, level)
+
+	level, err = logging.ParseLevel("warn")
+	require.NoError(t, err)
+	assert.Equal(t, slog.LevelWarn, level)
+
+	_, err = logging.ParseLevel("verbose")
+	assert.Error(t, err)
+}
+
+func TestConfigValidation(t *testing.T) {
+	assert.NoError(t, logging.Config{}.Validate())
+	assert.NoError(t, logging.Config{Format: logging.FormatText}.Validate())
+	assert.Error(t, logging.Config{Format: "xml"}.Validate())
+
+	_, err := logging.New(&bytes.Buffer{}, logging.Config{Format: "xml"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,161 @@
+package metrics_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"locations/internal/db"
+	locationshttp "locations/internal/http"
+	"locations/internal/live"
+	"locations/internal/metrics"
+	"locations/internal/models"
+	"locations/internal/producer"
+)
+
+// scrape returns what Prometheus would read from m.
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	m.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	return recorder.Body.String()
+}
+
+func TestHTTPRequestsAreRecordedByRoute(t *testing.T) {
+	m := metrics.New()
+	database := db.NewMemoryDB()
+	require.NoError(t, database.InsertLocationUpdate(context.Background(), models.LocationUpdate{ID: "loc-1", DriverID: "driver-1"}))
+	handler, err := locationshttp.NewRouter(context.Background(), &producer.KafkaProducer{}, database, "", live.NewHub(),
+		nil, nil, nil, nil, nil, nil, m, nil)
+	require.NoError(t, err)
+
+	for _, target := range []string{"/location?id=loc-1", "/drivers/driver-1/location", "/drivers/driver-2/location", "/nowhere"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	body := recorder.Body.String()
+
+	assert.Contains(t, body, `locations_http_requests_total{method="GET",route="/location",status="200"} 1`)
+	assert.Contains(t, body, `locations_http_requests_total{method="GET",route="/drivers/",status="200"} 1`)
+	assert.Contains(t, body, `locations_http_requests_total{method="GET",route="/drivers/",status="404"} 1`)
+	assert.Contains(t, body, `locations_http_requests_total{method="GET",route="unmatched",status="404"} 1`)
+	assert.Contains(t, body, `locations_http_request_duration_seconds_count{method="GET",route="/location",status="200"} 1`)
+	// IDs never become labels.
+	assert.NotContains(t, body, "driver-1")
+}
+
+// stubProducer returns err from every publish.
+type stubProducer struct {
+	err error
+}
+
+func (p stubProducer) ProduceLocationUpdate(ctx context.Context, location models.LocationUpdate) error {
+	return p.err
+}
+
+func (p stubProducer) ProduceLocationUpdates(ctx context.Context, locations []models.LocationUpdate) error {
+	return p.err
+}
+
+func TestProducerRecordsPublishes(t *testing.T) {
+	m := metrics.New()
+	ctx := context.Background()
+
+	require.NoError(t, metrics.NewProducer(stubProducer{}, m).ProduceLocationUpdates(ctx, make([]models.LocationUpdate, 3)))
+	require.Error(t, metrics.NewProducer(stubProducer{err: errors.New("broker down")}, m).ProduceLocationUpdate(ctx, models.LocationUpdate{}))
+
+	body := scrape(t, m)
+	assert.Contains(t, body, "locations_producer_published_messages_total 3")
+	assert.Contains(t, body, `locations_producer_publish_failures_total{operation="single"} 1`)
+	assert.Contains(t, body, `locations_producer_publish_duration_seconds_count{operation="batch"} 1`)
+	assert.Contains(t, body, `locations_producer_publish_duration_seconds_count{operation="single"} 1`)
+}
+
+// stubProcessor fails messages whose value is "bad".
+type stubProcessor struct{}
+
+func (stubProcessor) ProcessMessage(ctx context.Context, msg kafka.Message) error {
+	if string(msg.Value) == "bad" {
+		return errors.New("invalid message")
+	}
+	return nil
+}
+
+func TestMessageProcessorRecordsOutcomesAndLag(t *testing.T) {
+	m := metrics.New()
+	processor := metrics.NewMessageProcessor(stubProcessor{}, m)
+	ctx := context.Background()
+
+	require.NoError(t, processor.ProcessMessage(ctx, kafka.Message{Partition: 2, Offset: 5, HighWaterMark: 10}))
+	require.Error(t, processor.ProcessMessage(ctx, kafka.Message{Partition: 2, Offset: 6, HighWaterMark: 10, Value: []byte("bad")}))
+	require.NoError(t, processor.ProcessMessage(ctx, kafka.Message{Partition: 0, Offset: 9, HighWaterMark: 10}))
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `locations_consumer_messages_processed_total{partition="2"} 1`)
+	assert.Contains(t, body, `locations_consumer_messages_failed_total{partition="2"} 1`)
+	assert.Contains(t, body, `locations_consumer_lag_messages{partition="2"} 3`)
+	assert.Contains(t, body, `locations_consumer_lag_messages{partition="0"} 0`)
+	assert.Contains(t, body, "locations_consumer_processing_duration_seconds_count 3")
+}
+
+func TestDatabaseRecordsOperations(t *testing.T) {
+	m := metrics.New()
+	database := metrics.NewDatabase(db.NewMemoryDB(), m)
+	ctx := context.Background()
+
+	require.NoError(t, database.InsertLocationUpdate(ctx, models.LocationUpdate{ID: "loc-1", DriverID: "driver-1"}))
+	_, err := database.GetDriverLocation(ctx, "driver-2")
+	require.ErrorIs(t, err, db.ErrNotFound)
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `locations_database_operation_duration_seconds_count{operation="InsertLocationUpdate"} 1`)
+	assert.Contains(t, body, `locations_database_operation_duration_seconds_count{operation="GetDriverLocation"} 1`)
+	// A missing driver is an answer, not a failure of the database.
+	assert.NotContains(t, body, `locations_database_operation_errors_total{operation="GetDriverLocation"}`)
+
+	// Capabilities of the wrapped database stay reachable.
+	_, ok := db.Find[*db.MemoryDB](database)
+	assert.True(t, ok)
+}
+
+// unavailableDatabase is a MemoryDB whose reads time out, as during a failover.
+type unavailableDatabase struct {
+	*db.MemoryDB
+}
+
+func (unavailableDatabase) GetLocationByID(ctx context.Context, id string) (*models.LocationUpdate, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestResilienceObserverRecordsRetriesRejectionsAndBreakerState(t *testing.T) {
+	m := metrics.New()
+	database := db.NewResilientDatabase(unavailableDatabase{db.NewMemoryDB()}, db.ResilienceConfig{
+		Timeout:          10 * time.Millisecond,
+		MaxAttempts:      2,
+		RetryBackoff:     time.Millisecond,
+		FailureThreshold: 1,
+		OpenDuration:     time.Hour,
+	}, metrics.NewResilienceObserver(m))
+	assert.Contains(t, scrape(t, m), "locations_database_breaker_state 0")
+
+	_, err := database.GetLocationByID(context.Background(), "loc-1")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	_, err = database.GetLocationByID(context.Background(), "loc-1")
+	require.ErrorIs(t, err, db.ErrUnavailable)
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `locations_database_retries_total{operation="GetLocationByID"} 1`)
+	assert.Contains(t, body, `locations_database_rejected_total{operation="GetLocationByID"} 1`)
+	assert.Contains(t, body, "locations_database_breaker_state 1")
+}
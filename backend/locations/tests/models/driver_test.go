@@ -0,0 +1,35 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"locations/internal/models"
+)
+
+func TestNewDriverPosition(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	driver := models.Driver{
+		DriverID:  "driver-1",
+		Location:  models.NewGeoPoint(35.7, 51.4),
+		UpdatedAt: now.Add(-30 * time.Second),
+		Accuracy:  12,
+	}
+
+	position := models.NewDriverPosition(driver, now, time.Minute)
+
+	assert.Equal(t, 35.7, position.Latitude)
+	assert.Equal(t, 51.4, position.Longitude)
+	assert.Equal(t, 12.0, position.Accuracy)
+	assert.Equal(t, 30.0, position.AgeSeconds)
+	assert.False(t, position.Stale)
+}
+
+func TestNewDriverPosition_Stale(t *testing.T) {
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	driver := models.Driver{DriverID: "driver-1", Location: models.NewGeoPoint(35.7, 51.4), UpdatedAt: now.Add(-5 * time.Minute)}
+
+	assert.True(t, models.NewDriverPosition(driver, now, time.Minute).Stale)
+}
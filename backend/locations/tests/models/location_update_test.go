@@ -0,0 +1,23 @@
+package models_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"locations/internal/models"
+)
+
+func TestLocationUpdateValidate_Timestamp(t *testing.T) {
+	update := models.LocationUpdate{DriverID: "driver-1", Latitude: 35.7, Longitude: 51.4}
+
+	update.Timestamp = time.Now().Add(-time.Hour)
+	assert.NoError(t, update.Validate(), "late fixes are accepted")
+
+	update.Timestamp = time.Now().Add(models.MaxClockSkew / 2)
+	assert.NoError(t, update.Validate(), "a device clock slightly ahead is tolerated")
+
+	update.Timestamp = time.Now().Add(24 * time.Hour)
+	assert.EqualError(t, update.Validate(), "timestamp must not be in the future")
+}
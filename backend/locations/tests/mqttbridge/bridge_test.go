@@ -0,0 +1,174 @@
+package mqttbridge_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	mochi "github.com/mochi-mqtt/server/v2"
+	"github.com/mochi-mqtt/server/v2/hooks/auth"
+	"github.com/mochi-mqtt/server/v2/listeners"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"locations/internal/models"
+	"locations/internal/mqttbridge"
+)
+
+// fakeProducer records the updates it is given.
+type fakeProducer struct {
+	mu       sync.Mutex
+	updates  []models.LocationUpdate
+	produced chan models.LocationUpdate
+}
+
+func newFakeProducer() *fakeProducer {
+	return &fakeProducer{produced: make(chan models.LocationUpdate, 16)}
+}
+
+func (p *fakeProducer) ProduceLocationUpdate(ctx context.Context, location models.LocationUpdate) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.updates = append(p.updates, location)
+	select {
+	case p.produced <- location:
+	default:
+	}
+	return nil
+}
+
+// startBroker runs an embedded MQTT broker for the test and returns its address.
+func startBroker(t *testing.T) (*mochi.Server, string) {
+	t.Helper()
+
+	// Reserve a free port for the broker's listener.
+	reserved, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := reserved.Addr().String()
+	require.NoError(t, reserved.Close())
+
+	logger := zerolog.Nop()
+	server := mochi.New(&mochi.Options{Logger: &logger})
+	require.NoError(t, server.AddHook(new(auth.AllowHook), nil))
+	require.NoError(t, server.AddListener(listeners.NewTCP("tcp", addr, nil)))
+	require.NoError(t, server.Serve())
+	t.Cleanup(func() { server.Close() })
+	return server, addr
+}
+
+func TestNewBridge_RejectsAmbiguousPatterns(t *testing.T) {
+	for _, pattern := range []string{"", "fleet/location", "fleet/+/+/location", "fleet/#", "fleet/dri+ver/location"} {
+		_, err := mqttbridge.NewBridge(pattern, newFakeProducer(), nil)
+		assert.Error(t, err, pattern)
+	}
+}
+
+func TestHandleMessage(t *testing.T) {
+	producer := newFakeProducer()
+	bridge, err := mqttbridge.NewBridge(mqttbridge.DefaultTopicPattern, producer, nil)
+	require.NoError(t, err)
+
+	err = bridge.HandleMessage(context.Background(), "fleet/driver-1/location",
+		[]byte(`{"latitude": 35.7, "longitude": 51.4, "timestamp": "2023-10-01T12:00:00Z", "accuracy": 5}`))
+	require.NoError(t, err)
+	err = bridge.HandleMessage(context.Background(), "fleet/driver-2/location",
+		[]byte(`{"latitude": 0, "longitude": 0, "timestamp": 1696161600}`))
+	require.NoError(t, err)
+
+	require.Len(t, producer.updates, 2)
+	assert.Equal(t, models.LocationUpdate{
+		DriverID:  "driver-1",
+		Latitude:  35.7,
+		Longitude: 51.4,
+		Timestamp: time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC),
+		Accuracy:  5,
+	}, producer.updates[0])
+	assert.Equal(t, "driver-2", producer.updates[1].DriverID)
+	assert.Equal(t, time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC), producer.updates[1].Timestamp)
+}
+
+func TestHandleMessage_DefaultsTimestampToReceipt(t *testing.T) {
+	producer := newFakeProducer()
+	bridge, err := mqttbridge.NewBridge(mqttbridge.DefaultTopicPattern, producer, nil)
+	require.NoError(t, err)
+
+	before := time.Now().UTC()
+	require.NoError(t, bridge.HandleMessage(context.Background(), "fleet/driver-1/location", []byte(`{"latitude": 35.7, "longitude": 51.4}`)))
+
+	require.Len(t, producer.updates, 1)
+	assert.False(t, producer.updates[0].Timestamp.Before(before))
+}
+
+func TestHandleMessage_RejectsInvalidMessages(t *testing.T) {
+	producer := newFakeProducer()
+	bridge, err := mqttbridge.NewBridge(mqttbridge.DefaultTopicPattern, producer, nil)
+	require.NoError(t, err)
+
+	tests := map[string]struct {
+		topic   string
+		payload string
+	}{
+		"topic outside pattern": {"fleet/driver-1/status", `{"latitude": 35.7, "longitude": 51.4}`},
+		"empty driver ID":       {"fleet//location", `{"latitude": 35.7, "longitude": 51.4}`},
+		"malformed JSON":        {"fleet/driver-1/location", `{"latitude":`},
+		"missing longitude":     {"fleet/driver-1/location", `{"latitude": 35.7}`},
+		"latitude out of range": {"fleet/driver-1/location", `{"latitude": 95, "longitude": 51.4}`},
+		"bad timestamp":         {"fleet/driver-1/location", `{"latitude": 35.7, "longitude": 51.4, "timestamp": "yesterday"}`},
+		"driver ID mismatch":    {"fleet/driver-1/location", `{"driver_id": "driver-2", "latitude": 35.7, "longitude": 51.4}`},
+		"negative accuracy":     {"fleet/driver-1/location", `{"latitude": 35.7, "longitude": 51.4, "accuracy": -1}`},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := bridge.HandleMessage(context.Background(), tt.topic, []byte(tt.payload))
+			assert.True(t, errors.Is(err, mqttbridge.ErrInvalidMessage), err)
+		})
+	}
+	assert.Empty(t, producer.updates)
+}
+
+func TestRun_BridgesBrokerMessages(t *testing.T) {
+	broker, addr := startBroker(t)
+	producer := newFakeProducer()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- mqttbridge.Run(ctx, mqttbridge.Config{
+			BrokerURL:    "tcp://" + addr,
+			ClientID:     "locations-test",
+			TopicPattern: mqttbridge.DefaultTopicPattern,
+			QoS:          1,
+		}, producer, nil)
+	}()
+
+	// The subscription is made once the bridge has connected, so keep publishing until a message comes through.
+	ticker := time.NewTicker(20 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(5 * time.Second)
+wait:
+	for {
+		select {
+		case update := <-producer.produced:
+			assert.Equal(t, "truck-7", update.DriverID)
+			assert.Equal(t, 35.7, update.Latitude)
+			break wait
+		case <-ticker.C:
+			require.NoError(t, broker.Publish("fleet/truck-7/status", []byte(`{"latitude": 1, "longitude": 1}`), false, 1))
+			require.NoError(t, broker.Publish("fleet/truck-7/location", []byte(`{"latitude": 35.7, "longitude": 51.4}`), false, 1))
+		case <-timeout:
+			t.Fatal("no message was bridged")
+		}
+	}
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("bridge did not stop")
+	}
+}
@@ -0,0 +1,161 @@
+package openapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	locationshttp "locations/internal/http"
+	"locations/internal/models"
+	"locations/internal/ratelimit"
+)
+
+// recordingProducer keeps what is published instead of sending it to Kafka.
+type recordingProducer struct {
+	mu        sync.Mutex
+	published []models.LocationUpdate
+}
+
+func (p *recordingProducer) ProduceLocationUpdate(ctx context.Context, location models.LocationUpdate) error {
+	return p.ProduceLocationUpdates(ctx, []models.LocationUpdate{location})
+}
+
+func (p *recordingProducer) ProduceLocationUpdates(ctx context.Context, locations []models.LocationUpdate) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.published = append(p.published, locations...)
+	return nil
+}
+
+// updates returns what has been published so far.
+func (p *recordingProducer) updates() []models.LocationUpdate {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]models.LocationUpdate(nil), p.published...)
+}
+
+// postBatch sends items as a JSON array to the batch handler.
+func postBatch(t *testing.T, limits *ratelimit.Policy, items ...string) (*httptest.ResponseRecorder, locationshttp.BatchResponse, *recordingProducer) {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	kafkaProducer := &recordingProducer{}
+	r := httptest.NewRequest(http.MethodPost, "/location/batch", strings.NewReader("["+strings.Join(items, ",")+"]"))
+	r.Header.Set("Content-Type", "application/json")
+	locationshttp.LocationBatchHandler(recorder, r, kafkaProducer, limits)
+
+	var response locationshttp.BatchResponse
+	if recorder.Header().Get("Content-Type") == "application/json" {
+		require.NoError(t, json.NewDecoder(recorder.Body).Decode(&response))
+	}
+	return recorder, response, kafkaProducer
+}
+
+func driverLimits(burst int) *ratelimit.Policy {
+	return ratelimit.NewPolicy(ratelimit.NewMemoryLimiter(ratelimit.Limit{Rate: 0.001, Burst: burst}), nil)
+}
+
+func TestLocationBatchStatuses(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []string
+		// exhausted empties driver-1's bucket before the batch.
+		exhausted bool
+		want      int
+		accepted  int
+		rejected  int
+	}{
+		{"all published", []string{update("driver-1", 35.7), update("driver-2", 35.8)}, false, http.StatusOK, 2, 0},
+		{"some invalid", []string{update("driver-1", 35.7), update("driver-2", 95), `"not an update"`}, false, http.StatusOK, 1, 2},
+		{"some over the limit", []string{update("driver-1", 35.7), update("driver-1", 35.8), update("driver-1", 35.9)}, false, http.StatusOK, 2, 1},
+		{"none valid", []string{update("driver-1", 95), `"not an update"`}, false, http.StatusBadRequest, 0, 2},
+		{"all over the limit", []string{update("driver-1", 35.7), update("driver-1", 35.8)}, true, http.StatusTooManyRequests, 0, 2},
+		{"invalid and over the limit", []string{update("driver-1", 35.7), update("driver-2", 95)}, true, http.StatusBadRequest, 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limits := driverLimits(2)
+			if tt.exhausted {
+				limits.AllowDriverN(context.Background(), "driver-1", 2)
+			}
+
+			recorder, response, kafkaProducer := postBatch(t, limits, tt.items...)
+			assert.Equal(t, tt.want, recorder.Code, recorder.Body.String())
+			assert.Equal(t, tt.accepted, response.Accepted)
+			assert.Equal(t, tt.rejected, response.Rejected)
+			assert.Len(t, response.Results, len(tt.items))
+			assert.Len(t, kafkaProducer.published, tt.accepted)
+			if tt.want == http.StatusTooManyRequests {
+				assert.NotEmpty(t, recorder.Header().Get("Retry-After"))
+			}
+		})
+	}
+}
+
+func TestLocationBatchChargesEachUpdateToItsDriver(t *testing.T) {
+	items := make([]string, 5)
+	for i := range items {
+		items[i] = update("driver-1", 35.1+float64(i)/10)
+	}
+	items = append(items, update("driver-2", 35.7))
+
+	limits := driverLimits(3)
+	recorder, response, kafkaProducer := postBatch(t, limits, items...)
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, 4, response.Accepted)
+	assert.Equal(t, 2, response.Rejected)
+
+	// The earliest of driver-1's updates go through, up to the bucket's burst.
+	for i, result := range response.Results {
+		switch {
+		case i < 3 || i == 5:
+			assert.True(t, result.Accepted, i)
+		default:
+			assert.False(t, result.Accepted, i)
+			assert.Equal(t, "rate limit exceeded", result.Error)
+		}
+	}
+	assert.Len(t, kafkaProducer.published, 4)
+	assert.InDelta(t, 35.1, kafkaProducer.published[0].Latitude, 1e-9)
+
+	// driver-1's bucket is now empty, so their next batch is refused outright.
+	recorder, _, _ = postBatch(t, limits, items[:2]...)
+	assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+}
+
+func TestClientLimitKeysOnTheAddressTheProxiesSaw(t *testing.T) {
+	post := func(limits *ratelimit.Policy, forwardedFor ...string) int {
+		recorder := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodPost, "/location/batch", strings.NewReader("["+update("driver-1", 35.7)+"]"))
+		r.Header.Set("Content-Type", "application/json")
+		for _, value := range forwardedFor {
+			r.Header.Add("X-Forwarded-For", value)
+		}
+		locationshttp.LocationBatchHandler(recorder, r, &recordingProducer{}, limits)
+		return recorder.Code
+	}
+	newLimits := func(hops int) *ratelimit.Policy {
+		limits := ratelimit.NewPolicy(nil, ratelimit.NewMemoryLimiter(ratelimit.Limit{Rate: 0.001, Burst: 1}))
+		limits.TrustForwardedFor = true
+		limits.ForwardedForHops = hops
+		return limits
+	}
+
+	// Entries left of the one the proxy added are the client's own, so changing them doesn't reset its bucket.
+	limits := newLimits(1)
+	assert.Equal(t, http.StatusOK, post(limits, "10.0.0.1, 203.0.113.7"))
+	assert.Equal(t, http.StatusTooManyRequests, post(limits, "10.0.0.2, 203.0.113.7"))
+	assert.Equal(t, http.StatusTooManyRequests, post(limits, "10.0.0.3", "203.0.113.7"))
+	assert.Equal(t, http.StatusOK, post(limits, "203.0.113.8"))
+
+	// Behind two proxies the inner one's entry is the outer proxy's address.
+	limits = newLimits(2)
+	assert.Equal(t, http.StatusOK, post(limits, "10.0.0.1, 203.0.113.7, 192.168.0.2"))
+	assert.Equal(t, http.StatusTooManyRequests, post(limits, "10.0.0.2, 203.0.113.7, 192.168.0.2"))
+}
@@ -0,0 +1,67 @@
+package openapi_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// put sends PUT /location for id as an admin, with ifMatch as its If-Match header unless it is empty.
+func (f *fixture) put(t *testing.T, id, ifMatch string) *httptest.ResponseRecorder {
+	t.Helper()
+	c := specCase{method: http.MethodPut, target: "/location?id=" + id, token: f.admin, contentType: "application/json", body: update("driver-1", 35.8)}
+	if ifMatch != "" {
+		c.header = http.Header{"If-Match": {ifMatch}}
+	}
+	recorder := httptest.NewRecorder()
+	f.handler.ServeHTTP(recorder, c.request(context.Background()))
+	return recorder
+}
+
+func TestETagRoundTrips(t *testing.T) {
+	f := newFixture(t)
+
+	recorder := httptest.NewRecorder()
+	f.handler.ServeHTTP(recorder, specCase{method: http.MethodGet, target: "/location?id=loc-1", token: f.ops}.request(context.Background()))
+	require.Equal(t, http.StatusOK, recorder.Code)
+	etag := recorder.Header().Get("ETag")
+	assert.Equal(t, `"1"`, etag)
+
+	recorder = f.put(t, "loc-1", etag)
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+	assert.Equal(t, `"2"`, recorder.Header().Get("ETag"))
+
+	recorder = f.put(t, "loc-1", "")
+	require.Equal(t, http.StatusOK, recorder.Code, "an update without If-Match is unconditional")
+	assert.Equal(t, `"3"`, recorder.Header().Get("ETag"), "and still returns the new version")
+
+	recorder = f.put(t, "loc-1", `"1", "3"`)
+	require.Equal(t, http.StatusOK, recorder.Code, "any listed tag may match")
+	assert.Equal(t, `"4"`, recorder.Header().Get("ETag"))
+
+	recorder = httptest.NewRecorder()
+	f.handler.ServeHTTP(recorder, specCase{method: http.MethodGet, target: "/location?id=loc-1", token: f.ops}.request(context.Background()))
+	assert.Equal(t, `"4"`, recorder.Header().Get("ETag"))
+}
+
+func TestIfMatchThatCannotMatchFailsThePrecondition(t *testing.T) {
+	f := newFixture(t)
+
+	for _, ifMatch := range []string{`"2"`, `W/"1"`, `"0"`, `"-1"`, `"abc"`, `"2", W/"1"`} {
+		recorder := f.put(t, "loc-1", ifMatch)
+		problem := decodeProblem(t, recorder, http.StatusPreconditionFailed)
+		assert.Equal(t, "version_conflict", problem.Code, ifMatch)
+	}
+
+	decodeProblem(t, f.put(t, "loc-1", `1`), http.StatusBadRequest)
+	decodeProblem(t, f.put(t, "loc-9", `"1"`), http.StatusNotFound)
+	decodeProblem(t, f.put(t, "loc-9", `"1", "2"`), http.StatusNotFound)
+
+	stored, err := f.database.GetLocationByID(context.Background(), "loc-1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), stored.Version, "no failed precondition wrote anything")
+}
@@ -0,0 +1,144 @@
+package openapi_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	locationshttp "locations/internal/http"
+)
+
+const driverTokenSecret = "driver-secret"
+
+// ingestReply is the reply to a frame, as a driver app decodes it.
+type ingestReply struct {
+	Type  string `json:"type"`
+	Seq   uint64 `json:"seq"`
+	Error string `json:"error"`
+}
+
+// newIngestServer serves the location stream with HMAC driver tokens, admitting browser pages from allowedOrigins.
+func newIngestServer(t *testing.T, allowedOrigins ...string) (*httptest.Server, *recordingProducer) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	kafkaProducer := &recordingProducer{}
+	tokens := locationshttp.NewHMACDriverTokens(driverTokenSecret)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locationshttp.LocationStreamHandler(ctx, w, r, kafkaProducer, tokens, allowedOrigins, nil)
+	}))
+	t.Cleanup(func() {
+		cancel()
+		server.Close()
+	})
+	return server, kafkaProducer
+}
+
+// dialIngest opens a stream with token, sending origin if set.
+func dialIngest(t *testing.T, server *httptest.Server, token, origin string) (*websocket.Conn, *http.Response, error) {
+	t.Helper()
+	header := http.Header{}
+	if token != "" {
+		header.Set("Authorization", "Bearer "+token)
+	}
+	if origin != "" {
+		header.Set("Origin", origin)
+	}
+	conn, response, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), header)
+	if conn != nil {
+		t.Cleanup(func() { conn.Close() })
+	}
+	return conn, response, err
+}
+
+func driverToken(driverID string, expiresAt time.Time) string {
+	return locationshttp.NewHMACDriverTokens(driverTokenSecret).Issue(driverID, expiresAt)
+}
+
+func TestLocationStreamAcknowledgesFrames(t *testing.T) {
+	server, kafkaProducer := newIngestServer(t)
+	conn, _, err := dialIngest(t, server, driverToken("driver-1", time.Now().Add(time.Hour)), "")
+	require.NoError(t, err)
+
+	frames := []map[string]any{
+		{"seq": 1, "latitude": 35.7, "longitude": 51.4, "timestamp": time.Now().UTC()},
+		{"seq": 2, "latitude": 95, "longitude": 51.4, "timestamp": time.Now().UTC()},
+		{"seq": 3, "driver_id": "driver-2", "latitude": 35.7, "longitude": 51.4, "timestamp": time.Now().UTC()},
+	}
+	for _, frame := range frames {
+		require.NoError(t, conn.WriteJSON(frame))
+	}
+
+	replies := map[uint64]ingestReply{}
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+	for len(replies) < len(frames) {
+		var reply ingestReply
+		require.NoError(t, conn.ReadJSON(&reply))
+		replies[reply.Seq] = reply
+	}
+	assert.Equal(t, "ack", replies[1].Type)
+	assert.Equal(t, "nack", replies[2].Type)
+	assert.Equal(t, "nack", replies[3].Type)
+	assert.Contains(t, replies[3].Error, "authenticated driver")
+
+	published := kafkaProducer.updates()
+	if assert.Len(t, published, 1) {
+		assert.Equal(t, "driver-1", published[0].DriverID)
+		assert.Equal(t, 35.7, published[0].Latitude)
+	}
+}
+
+func TestLocationStreamRequiresAValidDriverToken(t *testing.T) {
+	server, _ := newIngestServer(t)
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"missing", ""},
+		{"expired", driverToken("driver-1", time.Now().Add(-time.Minute))},
+		{"foreign", locationshttp.NewHMACDriverTokens("other").Issue("driver-1", time.Now().Add(time.Hour))},
+		{"expiry changed", strings.Replace(driverToken("driver-1", time.Unix(1700000000, 0)), "1700000000", "4102444800", 1)},
+		{"without expiry", "driver-1.c2lnbmF0dXJl"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, response, err := dialIngest(t, server, tt.token, "")
+			require.ErrorIs(t, err, websocket.ErrBadHandshake)
+			assert.Equal(t, http.StatusUnauthorized, response.StatusCode)
+		})
+	}
+}
+
+func TestLocationStreamChecksTheOrigin(t *testing.T) {
+	server, _ := newIngestServer(t, "https://ops.example.com")
+	token := driverToken("driver-1", time.Now().Add(time.Hour))
+
+	_, response, err := dialIngest(t, server, token, "https://evil.example.com")
+	require.ErrorIs(t, err, websocket.ErrBadHandshake)
+	assert.Equal(t, http.StatusForbidden, response.StatusCode)
+
+	for _, origin := range []string{"", "https://ops.example.com", server.URL} {
+		_, _, err := dialIngest(t, server, token, origin)
+		assert.NoError(t, err, origin)
+	}
+}
+
+func TestHMACDriverTokensExpire(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tokens := locationshttp.NewHMACDriverTokensWithClock(driverTokenSecret, func() time.Time { return now })
+	r := httptest.NewRequest(http.MethodGet, "/location/stream?access_token="+tokens.Issue("driver.with.dots", now.Add(time.Hour)), nil)
+
+	driverID, err := tokens.AuthenticateDriver(r)
+	require.NoError(t, err)
+	assert.Equal(t, "driver.with.dots", driverID)
+
+	now = now.Add(time.Hour)
+	_, err = tokens.AuthenticateDriver(r)
+	assert.Error(t, err)
+}
@@ -0,0 +1,111 @@
+package openapi_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"locations/internal/db"
+	locationshttp "locations/internal/http"
+	"locations/internal/live"
+	"locations/internal/models"
+	"locations/internal/producer"
+)
+
+func decodeProblem(t *testing.T, recorder *httptest.ResponseRecorder, status int) locationshttp.Problem {
+	t.Helper()
+	require.Equal(t, status, recorder.Code, recorder.Body.String())
+	assert.Equal(t, "application/problem+json", recorder.Header().Get("Content-Type"))
+
+	var problem locationshttp.Problem
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+	assert.Equal(t, "about:blank", problem.Type)
+	assert.Equal(t, http.StatusText(status), problem.Title)
+	assert.Equal(t, status, problem.Status)
+	return problem
+}
+
+func TestProblemsCarryTheRequestID(t *testing.T) {
+	f := newFixture(t)
+
+	r := httptest.NewRequest(http.MethodGet, "/location?id=loc-404", nil)
+	r.Header.Set("Authorization", "Bearer "+f.ops)
+	r.Header.Set("X-Request-ID", "req-123")
+	recorder := httptest.NewRecorder()
+	f.handler.ServeHTTP(recorder, r)
+
+	problem := decodeProblem(t, recorder, http.StatusNotFound)
+	assert.Equal(t, locationshttp.CodeNotFound, problem.Code)
+	assert.Equal(t, "/location", problem.Instance)
+	assert.Equal(t, "req-123", problem.RequestID)
+	assert.Equal(t, "req-123", recorder.Header().Get("X-Request-ID"))
+}
+
+func TestRequestIDIsGeneratedWhenMissingOrUnsafe(t *testing.T) {
+	f := newFixture(t)
+
+	for _, given := range []string{"", "forged\nline"} {
+		r := httptest.NewRequest(http.MethodGet, "/location", nil)
+		r.Header.Set("Authorization", "Bearer "+f.ops)
+		r.Header.Set("X-Request-ID", given)
+		recorder := httptest.NewRecorder()
+		f.handler.ServeHTTP(recorder, r)
+
+		problem := decodeProblem(t, recorder, http.StatusBadRequest)
+		assert.Len(t, problem.RequestID, 32)
+		assert.Equal(t, problem.RequestID, recorder.Header().Get("X-Request-ID"))
+	}
+}
+
+// failingDB fails every call with err.
+type failingDB struct {
+	*db.MemoryDB
+	err error
+}
+
+func (d failingDB) GetLocationByID(ctx context.Context, id string) (*models.LocationUpdate, error) {
+	return nil, d.err
+}
+
+func (d failingDB) ExportDriverData(ctx context.Context, request models.PrivacyRequest) (*models.DriverDataExport, error) {
+	return nil, d.err
+}
+
+func TestDatabaseErrorsMapToStatuses(t *testing.T) {
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"no documents", fmt.Errorf("failed to find driver: %w", mongo.ErrNoDocuments), http.StatusNotFound, locationshttp.CodeNotFound},
+		{"unavailable", fmt.Errorf("export: %w", db.ErrUnavailable), http.StatusServiceUnavailable, locationshttp.CodeUpstreamUnavailable},
+		{"deadline", context.DeadlineExceeded, http.StatusServiceUnavailable, locationshttp.CodeUpstreamUnavailable},
+		{"transient", fmt.Errorf("export: %w", mongo.CommandError{Code: 189, Name: "PrimarySteppedDown"}), http.StatusServiceUnavailable, locationshttp.CodeUpstreamUnavailable},
+		{"internal", fmt.Errorf("connection to mongodb://secret@db:27017 reset"), http.StatusInternalServerError, locationshttp.CodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler, err := locationshttp.NewRouter(context.Background(), &producer.KafkaProducer{},
+				failingDB{MemoryDB: db.NewMemoryDB(), err: tt.err}, adminToken, live.NewHub(), nil, nil, nil, nil, nil, nil, nil, nil)
+			require.NoError(t, err)
+
+			r := httptest.NewRequest(http.MethodGet, "/admin/driver-data?driver_id=driver-1", nil)
+			r.Header.Set("Authorization", "Bearer "+adminToken)
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, r)
+
+			problem := decodeProblem(t, recorder, tt.status)
+			assert.Equal(t, tt.code, problem.Code)
+			// Internal errors are logged, never shown to clients.
+			assert.NotContains(t, recorder.Body.String(), "mongodb://")
+		})
+	}
+}
@@ -0,0 +1,379 @@
+package openapi_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"locations/internal/auth"
+	"locations/internal/db"
+	"locations/internal/health"
+	locationshttp "locations/internal/http"
+	"locations/internal/live"
+	"locations/internal/metrics"
+	"locations/internal/models"
+	"locations/internal/openapi"
+	"locations/internal/producer"
+	"locations/internal/ratelimit"
+	"locations/internal/trips"
+)
+
+const (
+	jwtSecret   = "secret"
+	adminToken  = "admin-token"
+	tripSecret  = "trip-secret"
+	streamAfter = 100 * time.Millisecond
+)
+
+// fixture is a router over in-memory dependencies, with tokens for each kind of caller.
+type fixture struct {
+	handler  http.Handler
+	database *db.MemoryDB
+	tracker  *trips.Tracker
+	// brokerDown fails the fixture's kafka check.
+	brokerDown *atomic.Bool
+	driver     string
+	ops        string
+	admin      string
+	rider      string
+	trip       string
+}
+
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+
+	verifier, err := auth.NewVerifier(auth.Config{HMACSecret: []byte(jwtSecret), Issuer: "users"})
+	require.NoError(t, err)
+	database := db.NewMemoryDB()
+	tracker := trips.NewTracker(tripSecret)
+	// The drivers' limit is low enough for a test to exhaust it.
+	limits := ratelimit.NewPolicy(ratelimit.NewMemoryLimiter(ratelimit.Limit{Rate: 0.001, Burst: 1}), nil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	brokerDown := &atomic.Bool{}
+	checker := health.NewChecker()
+	checker.Register("kafka", func(ctx context.Context) (map[string]any, error) {
+		if brokerDown.Load() {
+			return nil, errors.New("broker unreachable")
+		}
+		return map[string]any{"partitions": 3}, nil
+	})
+
+	// Nothing listens on the broker's address, so publishing fails once the request times out.
+	kafkaProducer := producer.NewKafkaProducer([]string{"127.0.0.1:1"}, "locations")
+	handler, err := locationshttp.NewRouter(ctx, kafkaProducer, database, adminToken, live.NewHub(),
+		locationshttp.NewJWTDriverTokens(verifier), nil, tracker, verifier, limits, checker, metrics.New(), nil)
+	require.NoError(t, err)
+
+	require.NoError(t, database.InsertLocationUpdate(ctx, models.LocationUpdate{
+		ID:        "loc-1",
+		DriverID:  "driver-1",
+		Latitude:  35.7,
+		Longitude: 51.4,
+		Timestamp: time.Now().UTC().Add(-time.Minute),
+		Accuracy:  8,
+	}))
+
+	tripToken, err := tracker.Issue(trips.Claims{TripID: "trip-1", DriverID: "driver-1", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	return &fixture{
+		handler:    handler,
+		database:   database,
+		tracker:    tracker,
+		brokerDown: brokerDown,
+		driver:     sign(t, "driver-1", auth.RoleDriver),
+		ops:        sign(t, "ops-1", auth.RoleOps),
+		admin:      signScope(t, "ops-1", auth.ScopeAdmin, auth.RoleOps),
+		rider:      sign(t, "rider-1", auth.RoleRider),
+		trip:       tripToken,
+	}
+}
+
+func sign(t *testing.T, subject string, roles ...string) string {
+	return signScope(t, subject, "", roles...)
+}
+
+func signScope(t *testing.T, subject, scope string, roles ...string) string {
+	t.Helper()
+	claims := &auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   subject,
+			Issuer:    "users",
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		},
+		Roles: roles,
+		Scope: scope,
+	}
+	signed, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(jwtSecret))
+	require.NoError(t, err)
+	return signed
+}
+
+// specCase is one request and the status the handlers must answer it with.
+type specCase struct {
+	name        string
+	method      string
+	target      string
+	token       string
+	contentType string
+	body        string
+	header      http.Header
+	want        int
+	// before, if set, runs before the request is sent.
+	before func()
+}
+
+func (c specCase) request(ctx context.Context) *http.Request {
+	var body io.Reader
+	if c.body != "" {
+		body = strings.NewReader(c.body)
+	}
+	r := httptest.NewRequest(c.method, c.target, body).WithContext(ctx)
+	for name, values := range c.header {
+		r.Header[name] = values
+	}
+	if c.token != "" {
+		r.Header.Set("Authorization", "Bearer "+c.token)
+	}
+	if c.contentType != "" {
+		r.Header.Set("Content-Type", c.contentType)
+	}
+	return r
+}
+
+func update(driverID string, latitude float64) string {
+	return fmt.Sprintf(`{"driver_id":%q,"latitude":%v,"longitude":51.4,"timestamp":"2024-05-01T12:00:00Z"}`, driverID, latitude)
+}
+
+// TestHandlersMatchSpec sends requests covering every operation in the OpenAPI document and checks that each
+// response, status included, is one the document describes. A handler that drifts from the document fails it.
+func TestHandlersMatchSpec(t *testing.T) {
+	doc, err := openapi.Load()
+	require.NoError(t, err)
+	router, err := gorillamux.NewRouter(doc)
+	require.NoError(t, err)
+
+	f := newFixture(t)
+	cases := []specCase{
+		{name: "report", method: http.MethodPost, target: "/location", token: f.driver, contentType: "application/json", body: update("driver-1", 35.7), want: http.StatusServiceUnavailable},
+		{name: "report without token", method: http.MethodPost, target: "/location", contentType: "application/json", body: update("driver-1", 35.7), want: http.StatusUnauthorized},
+		{name: "report for another driver", method: http.MethodPost, target: "/location", token: f.driver, contentType: "application/json", body: update("driver-2", 35.7), want: http.StatusForbidden},
+		{name: "report out of range", method: http.MethodPost, target: "/location", token: f.driver, contentType: "application/json", body: update("driver-1", 95), want: http.StatusBadRequest},
+		{name: "report over limit", method: http.MethodPost, target: "/location", token: f.driver, contentType: "application/json", body: update("driver-1", 35.7), want: http.StatusTooManyRequests},
+		{name: "get", method: http.MethodGet, target: "/location?id=loc-1", token: f.ops, want: http.StatusOK},
+		{name: "get without id", method: http.MethodGet, target: "/location", token: f.ops, want: http.StatusBadRequest},
+		{name: "get unknown", method: http.MethodGet, target: "/location?id=loc-2", token: f.ops, want: http.StatusNotFound},
+		{name: "get as rider", method: http.MethodGet, target: "/location?id=loc-1", token: f.rider, want: http.StatusForbidden},
+		{name: "put", method: http.MethodPut, target: "/location?id=loc-1", token: f.admin, contentType: "application/json", body: update("driver-1", 35.8), header: http.Header{"If-Match": {`"1"`}}, want: http.StatusOK},
+		{name: "put stale", method: http.MethodPut, target: "/location?id=loc-1", token: f.admin, contentType: "application/json", body: update("driver-1", 35.9), header: http.Header{"If-Match": {`"1"`}}, want: http.StatusPreconditionFailed},
+		{name: "put without scope", method: http.MethodPut, target: "/location?id=loc-1", token: f.ops, contentType: "application/json", body: update("driver-1", 35.8), want: http.StatusForbidden},
+		{name: "method not allowed", method: http.MethodDelete, target: "/location?id=loc-1", token: f.ops, want: http.StatusMethodNotAllowed},
+		{name: "batch", method: http.MethodPost, target: "/location/batch", token: sign(t, "driver-3", auth.RoleDriver), contentType: "application/json", body: `[` + update("driver-3", 35.7) + `,"not an update"]`, want: http.StatusServiceUnavailable},
+		{name: "batch invalid", method: http.MethodPost, target: "/location/batch", token: f.driver, contentType: "application/x-ndjson", body: update("driver-1", 95) + "\n", want: http.StatusBadRequest},
+		{name: "batch over limit", method: http.MethodPost, target: "/location/batch", token: f.driver, contentType: "application/json", body: `[` + update("driver-1", 35.7) + `]`, want: http.StatusTooManyRequests},
+		{name: "batch as ops", method: http.MethodPost, target: "/location/batch", token: f.ops, contentType: "application/json", body: `[]`, want: http.StatusForbidden},
+		{name: "stream without token", method: http.MethodGet, target: "/location/stream", want: http.StatusUnauthorized},
+		{name: "stream without upgrade", method: http.MethodGet, target: "/location/stream?access_token=" + f.driver, want: http.StatusBadRequest},
+		{name: "driver", method: http.MethodGet, target: "/drivers/driver-1/location", token: f.ops, want: http.StatusOK},
+		{name: "driver too old", method: http.MethodGet, target: "/drivers/driver-1/location?max_age=1s", token: f.ops, want: http.StatusGone},
+		{name: "driver unknown", method: http.MethodGet, target: "/drivers/driver-9/location", token: f.ops, want: http.StatusNotFound},
+		{name: "track", method: http.MethodGet, target: "/trips/trip-1/track", token: f.trip, want: http.StatusOK},
+		{name: "track another trip", method: http.MethodGet, target: "/trips/trip-2/track", token: f.trip, want: http.StatusForbidden},
+		{name: "nearby", method: http.MethodGet, target: "/nearby?latitude=35.7&longitude=51.4", token: f.ops, want: http.StatusOK},
+		{name: "nearby out of range", method: http.MethodGet, target: "/nearby?latitude=135.7&longitude=51.4", token: f.ops, want: http.StatusBadRequest},
+		{name: "nearby as driver", method: http.MethodGet, target: "/nearby?latitude=35.7&longitude=51.4", token: f.driver, want: http.StatusForbidden},
+		{name: "heatmap", method: http.MethodGet, target: "/heatmap?min_lat=35&min_lng=51&max_lat=36&max_lng=52&window=1h", token: f.ops, want: http.StatusOK},
+		{name: "heatmap geojson", method: http.MethodGet, target: "/heatmap?min_lat=35&min_lng=51&max_lat=36&max_lng=52&window=1h&format=geojson", token: f.ops, want: http.StatusOK},
+		{name: "heatmap inverted box", method: http.MethodGet, target: "/heatmap?min_lat=36&min_lng=51&max_lat=35&max_lng=52", token: f.ops, want: http.StatusBadRequest},
+		{name: "heatmap bad precision", method: http.MethodGet, target: "/heatmap?min_lat=35&min_lng=51&max_lat=36&max_lng=52&precision=12", token: f.ops, want: http.StatusBadRequest},
+		{name: "live", method: http.MethodGet, target: "/live?driver_id=driver-1", token: f.ops, want: http.StatusOK},
+		{name: "live as rider", method: http.MethodGet, target: "/live", token: f.rider, want: http.StatusForbidden},
+		{name: "export", method: http.MethodGet, target: "/admin/driver-data?driver_id=driver-1", token: adminToken, want: http.StatusOK},
+		{name: "export without driver", method: http.MethodGet, target: "/admin/driver-data", token: adminToken, want: http.StatusBadRequest},
+		{name: "erase", method: http.MethodDelete, target: "/admin/driver-data?driver_id=driver-1", token: adminToken, header: http.Header{"X-Requested-By": {"privacy-team"}}, want: http.StatusOK},
+		{name: "erase unauthorized", method: http.MethodDelete, target: "/admin/driver-data?driver_id=driver-1", token: f.ops, want: http.StatusUnauthorized},
+		{name: "end trip", method: http.MethodDelete, target: "/admin/trips/trip-1", token: adminToken, want: http.StatusNoContent},
+		{name: "track ended trip", method: http.MethodGet, target: "/trips/trip-1/track", token: f.trip, want: http.StatusGone},
+		{name: "openapi", method: http.MethodGet, target: "/openapi.json", want: http.StatusOK},
+		{name: "healthz", method: http.MethodGet, target: "/healthz", want: http.StatusOK},
+		{name: "readyz", method: http.MethodGet, target: "/readyz", want: http.StatusOK},
+		{name: "status", method: http.MethodGet, target: "/status", token: adminToken, want: http.StatusOK},
+		{name: "status without admin token", method: http.MethodGet, target: "/status", token: f.ops, want: http.StatusUnauthorized},
+		{name: "metrics", method: http.MethodGet, target: "/metrics", want: http.StatusOK},
+		{name: "debug vars", method: http.MethodGet, target: "/debug/vars", token: adminToken, want: http.StatusOK},
+		{name: "debug vars without admin token", method: http.MethodGet, target: "/debug/vars", token: f.ops, want: http.StatusUnauthorized},
+		{name: "readyz with broker down", method: http.MethodGet, target: "/readyz", want: http.StatusServiceUnavailable, before: func() { f.brokerDown.Store(true) }},
+		{name: "status with broker down", method: http.MethodGet, target: "/status", token: adminToken, want: http.StatusOK},
+	}
+
+	covered := map[string]bool{}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			// Streams run until the request is canceled; publishing gives up with it too.
+			ctx, cancel := context.WithTimeout(context.Background(), streamAfter)
+			defer cancel()
+			if c.before != nil {
+				c.before()
+			}
+			r := c.request(ctx)
+			recorder := httptest.NewRecorder()
+			f.handler.ServeHTTP(recorder, r)
+			require.Equal(t, c.want, recorder.Code, recorder.Body.String())
+
+			route, pathParams, err := router.FindRoute(c.request(context.Background()))
+			if c.want == http.StatusMethodNotAllowed {
+				require.ErrorIs(t, err, routers.ErrMethodNotAllowed)
+				return
+			}
+			require.NoError(t, err)
+			covered[route.Operation.OperationID] = true
+
+			response := recorder.Result()
+			input := &openapi3filter.ResponseValidationInput{
+				RequestValidationInput: &openapi3filter.RequestValidationInput{
+					Request:    r,
+					PathParams: pathParams,
+					Route:      route,
+				},
+				Status: response.StatusCode,
+				Header: response.Header,
+				Options: &openapi3filter.Options{
+					IncludeResponseStatus: true,
+					// Event streams are text/event-stream, which has no schema to check.
+					ExcludeResponseBody: response.Header.Get("Content-Type") == "text/event-stream",
+				},
+			}
+			input.SetBodyBytes(recorder.Body.Bytes())
+			assert.NoError(t, openapi3filter.ValidateResponse(context.Background(), input))
+		})
+	}
+
+	for _, path := range doc.Paths.InMatchingOrder() {
+		for method, operation := range doc.Paths.Find(path).Operations() {
+			assert.True(t, covered[operation.OperationID], "%s %s is not exercised", method, path)
+		}
+	}
+}
+
+// TestDocumentIsServed checks that /openapi.json serves the document the requests are validated against.
+func TestDocumentIsServed(t *testing.T) {
+	f := newFixture(t)
+	recorder := httptest.NewRecorder()
+	f.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+
+	require.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+	assert.True(t, bytes.Equal(openapi.Document(), recorder.Body.Bytes()))
+
+	var doc openapi3.T
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &doc))
+	assert.Equal(t, "Locations API", doc.Info.Title)
+}
+
+// TestValidationErrorsNameTheField checks that rejected requests say which field was wrong and how.
+func TestValidationErrorsNameTheField(t *testing.T) {
+	f := newFixture(t)
+
+	tests := []struct {
+		name   string
+		target string
+		body   string
+		code   string
+		field  string
+		reason string
+	}{
+		{"missing parameter", "/nearby?latitude=35.7", "", locationshttp.CodeInvalidCoordinates, "longitude", "is required"},
+		{"parameter out of range", "/nearby?latitude=95&longitude=51.4", "", locationshttp.CodeInvalidCoordinates, "latitude", "number must be at most 90"},
+		{"missing property", "/location", `{"latitude":35.7,"longitude":51.4,"timestamp":"2024-05-01T12:00:00Z"}`, locationshttp.CodeInvalidRequest, "body.driver_id", "is required"},
+		{"property out of range", "/location", update("driver-1", 95), locationshttp.CodeInvalidCoordinates, "body.latitude", "number must be at most 90"},
+		{"malformed timestamp", "/location", `{"driver_id":"driver-1","latitude":35.7,"longitude":51.4,"timestamp":"yesterday"}`, locationshttp.CodeInvalidRequest, "body.timestamp", ""},
+		{"inverted bounding box", "/heatmap?min_lat=36&min_lng=51&max_lat=35&max_lng=52", "", locationshttp.CodeInvalidCoordinates, "min_lat", "must not exceed max_lat"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := http.MethodGet
+			var body io.Reader
+			if tt.body != "" {
+				method = http.MethodPost
+				body = strings.NewReader(tt.body)
+			}
+			r := httptest.NewRequest(method, tt.target, body)
+			r.Header.Set("Content-Type", "application/json")
+			r.Header.Set("Authorization", "Bearer "+f.ops)
+			recorder := httptest.NewRecorder()
+			f.handler.ServeHTTP(recorder, r)
+
+			problem := decodeProblem(t, recorder, http.StatusBadRequest)
+			assert.Equal(t, tt.code, problem.Code)
+			require.Len(t, problem.Errors, 1)
+			assert.Equal(t, tt.field, problem.Errors[0].Field)
+			if tt.reason != "" {
+				assert.Equal(t, tt.reason, problem.Errors[0].Reason)
+			}
+		})
+	}
+}
+
+// TestHandlersValidateWhatTheDocumentAllows checks that the handlers reject updates the document's formats let
+// through, such as the zero time, rather than relying on the document alone.
+func TestHandlersValidateWhatTheDocumentAllows(t *testing.T) {
+	f := newFixture(t)
+	body := `{"driver_id":"driver-1","latitude":35.7,"longitude":51.4,"timestamp":"0001-01-01T00:00:00Z"}`
+
+	for _, c := range []specCase{
+		{method: http.MethodPost, target: "/location", token: f.driver, contentType: "application/json", body: body},
+		{method: http.MethodPut, target: "/location?id=loc-1", token: f.admin, contentType: "application/json", body: body},
+	} {
+		recorder := httptest.NewRecorder()
+		f.handler.ServeHTTP(recorder, c.request(context.Background()))
+		problem := decodeProblem(t, recorder, http.StatusBadRequest)
+		assert.Contains(t, problem.Detail, "timestamp", c.method)
+	}
+
+	stored, err := f.database.GetLocationByID(context.Background(), "loc-1")
+	require.NoError(t, err)
+	assert.False(t, stored.Timestamp.IsZero())
+}
+
+// TestBodiesAreBoundedBeforeAuthentication checks that the validator only buffers small bodies, and leaves
+// batches to their handler, which reads them once the caller is authenticated.
+func TestBodiesAreBoundedBeforeAuthentication(t *testing.T) {
+	f := newFixture(t)
+	large := `{"driver_id":"driver-1","pad":"` + strings.Repeat("x", openapi.MaxBodyBytes) + `"}`
+
+	recorder := httptest.NewRecorder()
+	f.handler.ServeHTTP(recorder, specCase{method: http.MethodPost, target: "/location", contentType: "application/json", body: large}.request(context.Background()))
+	decodeProblem(t, recorder, http.StatusRequestEntityTooLarge)
+
+	recorder = httptest.NewRecorder()
+	f.handler.ServeHTTP(recorder, specCase{method: http.MethodPost, target: "/location/batch", contentType: "application/json", body: "[" + large + "]"}.request(context.Background()))
+	decodeProblem(t, recorder, http.StatusUnauthorized)
+}
+
+// TestUndocumentedPathsPassThrough checks that paths outside the document still reach the server's mux,
+// rather than being refused by the validator.
+func TestUndocumentedPathsPassThrough(t *testing.T) {
+	f := newFixture(t)
+	recorder := httptest.NewRecorder()
+	f.handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+}
@@ -0,0 +1,189 @@
+package openapi_test
+
+import (
+	"bufio"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"locations/internal/db"
+	locationshttp "locations/internal/http"
+	"locations/internal/live"
+	"locations/internal/models"
+	"locations/internal/trips"
+)
+
+// trackingServer serves trip tracking for driver-1, whose position is stored, with the handler's dependencies.
+type trackingServer struct {
+	*httptest.Server
+	hub     *live.Hub
+	tracker *trips.Tracker
+}
+
+func newTrackingServer(t *testing.T, tracker *trips.Tracker) *trackingServer {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	database := db.NewMemoryDB()
+	require.NoError(t, database.InsertLocationUpdate(ctx, models.LocationUpdate{DriverID: "driver-1", Latitude: 35.7, Longitude: 51.4, Timestamp: time.Now().UTC()}))
+	hub := live.NewHub()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		locationshttp.TripTrackingHandler(ctx, w, r, database, hub, tracker)
+	}))
+	t.Cleanup(func() {
+		cancel()
+		server.Close()
+	})
+	return &trackingServer{Server: server, hub: hub, tracker: tracker}
+}
+
+func (s *trackingServer) token(t *testing.T, tripID string, ttl time.Duration) string {
+	t.Helper()
+	token, err := s.tracker.Issue(trips.Claims{TripID: tripID, DriverID: "driver-1", RiderID: "rider-1", ExpiresAt: time.Now().Add(ttl)})
+	require.NoError(t, err)
+	return token
+}
+
+// publishUntilReceived publishes newer positions for driver-1 until received reports that one was streamed.
+func (s *trackingServer) publishUntilReceived(t *testing.T, received <-chan struct{}) {
+	t.Helper()
+	deadline := time.After(5 * time.Second)
+	for {
+		s.hub.Publish(models.Driver{DriverID: "driver-1", Location: models.NewGeoPoint(35.8, 51.5), UpdatedAt: time.Now().UTC()})
+		select {
+		case <-received:
+			return
+		case <-deadline:
+			require.Fail(t, "no position was streamed")
+		case <-time.After(50 * time.Millisecond):
+		}
+	}
+}
+
+// sseEvents reads the names of Server-Sent Events from body into a channel, closing it when the stream ends.
+func sseEvents(body *bufio.Scanner) <-chan string {
+	events := make(chan string, 16)
+	go func() {
+		defer close(events)
+		for body.Scan() {
+			if event, ok := strings.CutPrefix(body.Text(), "event: "); ok {
+				events <- event
+			}
+		}
+	}()
+	return events
+}
+
+func nextEvent(t *testing.T, events <-chan string) string {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "no event was streamed")
+		return ""
+	}
+}
+
+func TestTripTrackingStreamsPositionsUntilTheTripEnds(t *testing.T) {
+	server := newTrackingServer(t, trips.NewTracker(tripSecret))
+	token := server.token(t, "trip-1", time.Hour)
+
+	r, err := http.NewRequest(http.MethodGet, server.URL+"/trips/trip-1/track", nil)
+	require.NoError(t, err)
+	r.Header.Set("Authorization", "Bearer "+token)
+	response, err := http.DefaultClient.Do(r)
+	require.NoError(t, err)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+
+	events := sseEvents(bufio.NewScanner(response.Body))
+	assert.Equal(t, "position", nextEvent(t, events), "the stream starts with the current position")
+
+	// Positions are throttled to one a second, so the next one may take a while.
+	received := make(chan struct{})
+	go func() {
+		defer close(received)
+		for event := range events {
+			if event == "position" {
+				return
+			}
+		}
+	}()
+	server.publishUntilReceived(t, received)
+
+	server.tracker.End("trip-1")
+	for event := range events {
+		if event == "trip_ended" {
+			return
+		}
+	}
+	assert.Fail(t, "the stream ended without a trip_ended event")
+}
+
+func TestTripTrackingOverWebSocketEndsWhenTheTokenExpires(t *testing.T) {
+	server := newTrackingServer(t, trips.NewTracker(tripSecret))
+	token := server.token(t, "trip-1", 500*time.Millisecond)
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"/trips/trip-1/track?access_token="+token, nil)
+	require.NoError(t, err)
+	defer conn.Close()
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(5*time.Second)))
+
+	var message struct {
+		Type string `json:"type"`
+	}
+	require.NoError(t, conn.ReadJSON(&message))
+	assert.Equal(t, "position", message.Type)
+
+	for message.Type != "token_expired" {
+		require.NoError(t, conn.ReadJSON(&message))
+	}
+	_, _, err = conn.ReadMessage()
+	assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), err)
+}
+
+func TestTripTrackingRejects(t *testing.T) {
+	tracker := trips.NewTracker(tripSecret)
+	server := newTrackingServer(t, tracker)
+	ended := server.token(t, "trip-ended", time.Hour)
+	tracker.End("trip-ended")
+
+	tests := []struct {
+		name   string
+		target string
+		token  string
+		want   int
+	}{
+		{"no token", "/trips/trip-1/track", "", http.StatusUnauthorized},
+		{"forged token", "/trips/trip-1/track", "e30.c2ln", http.StatusUnauthorized},
+		{"expired token", "/trips/trip-1/track", server.token(t, "trip-1", -time.Minute), http.StatusUnauthorized},
+		{"another trip's token", "/trips/trip-2/track", server.token(t, "trip-1", time.Hour), http.StatusForbidden},
+		{"ended trip", "/trips/trip-ended/track", ended, http.StatusGone},
+		{"malformed path", "/trips/trip-1/extra/track", server.token(t, "trip-1", time.Hour), http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.token != "" {
+				r.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			locationshttp.TripTrackingHandler(context.Background(), recorder, r, db.NewMemoryDB(), live.NewHub(), tracker)
+			decodeProblem(t, recorder, tt.want)
+		})
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		locationshttp.TripTrackingHandler(context.Background(), recorder, httptest.NewRequest(http.MethodGet, "/trips/trip-1/track", nil), db.NewMemoryDB(), live.NewHub(), nil)
+		decodeProblem(t, recorder, http.StatusForbidden)
+	})
+}
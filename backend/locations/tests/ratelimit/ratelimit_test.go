@@ -0,0 +1,151 @@
+package ratelimit_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"locations/internal/ratelimit"
+)
+
+func TestMemoryLimiter_RefillsOverTime(t *testing.T) {
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	limiter := ratelimit.NewMemoryLimiterWithClock(ratelimit.Limit{Rate: 2, Burst: 3}, func() time.Time { return now })
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		decision, err := limiter.Allow(ctx, "driver-1")
+		require.NoError(t, err)
+		assert.True(t, decision.Allowed)
+	}
+	decision, err := limiter.Allow(ctx, "driver-1")
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 500*time.Millisecond, decision.RetryAfter)
+
+	// Other keys have their own buckets.
+	decision, err = limiter.Allow(ctx, "driver-2")
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	now = now.Add(500 * time.Millisecond)
+	decision, err = limiter.Allow(ctx, "driver-1")
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	decision, err = limiter.Allow(ctx, "driver-1")
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+}
+
+func TestMemoryLimiter_ForgetsRefilledBuckets(t *testing.T) {
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	limiter := ratelimit.NewMemoryLimiterWithClock(ratelimit.Limit{Rate: 1, Burst: 1}, func() time.Time { return now })
+	ctx := context.Background()
+
+	decision, _ := limiter.Allow(ctx, "driver-1")
+	assert.True(t, decision.Allowed)
+
+	// After the sweep the bucket is gone, which must behave exactly like a full one.
+	now = now.Add(2 * time.Minute)
+	decision, _ = limiter.Allow(ctx, "driver-1")
+	assert.True(t, decision.Allowed)
+	decision, _ = limiter.Allow(ctx, "driver-1")
+	assert.False(t, decision.Allowed)
+}
+
+func TestRedisLimiter_SharesBucketsBetweenInstances(t *testing.T) {
+	server := miniredis.RunT(t)
+	ctx := context.Background()
+	limit := ratelimit.Limit{Rate: 0.5, Burst: 2}
+
+	// Two replicas with their own clients draw from the same bucket.
+	first := ratelimit.NewRedisLimiter(redis.NewClient(&redis.Options{Addr: server.Addr()}), "test:", limit)
+	second := ratelimit.NewRedisLimiter(redis.NewClient(&redis.Options{Addr: server.Addr()}), "test:", limit)
+
+	decision, err := first.Allow(ctx, "driver-1")
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	decision, err = second.Allow(ctx, "driver-1")
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	decision, err = first.Allow(ctx, "driver-1")
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.InDelta(t, 2*time.Second, decision.RetryAfter, float64(100*time.Millisecond))
+
+	decision, err = second.Allow(ctx, "driver-2")
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	assert.True(t, server.Exists("test:driver-1"))
+	assert.Greater(t, server.TTL("test:driver-1"), time.Duration(0))
+}
+
+func TestPolicy_AllowsWhenLimiterFails(t *testing.T) {
+	server := miniredis.RunT(t)
+	limiter := ratelimit.NewRedisLimiter(redis.NewClient(&redis.Options{Addr: server.Addr()}), "test:", ratelimit.Limit{Rate: 1, Burst: 1})
+	policy := ratelimit.NewPolicy(limiter, nil)
+	server.Close()
+
+	assert.True(t, policy.AllowDriver(context.Background(), "driver-1").Allowed)
+	assert.True(t, policy.AllowClient(context.Background(), "10.0.0.1").Allowed)
+}
+
+func TestPolicy_NilAllowsEverything(t *testing.T) {
+	var policy *ratelimit.Policy
+	assert.True(t, policy.AllowDriver(context.Background(), "driver-1").Allowed)
+	assert.True(t, policy.AllowClient(context.Background(), "10.0.0.1").Allowed)
+}
+
+func TestLimit_Validate(t *testing.T) {
+	assert.NoError(t, ratelimit.DefaultDriverLimit.Validate())
+	assert.NoError(t, ratelimit.DefaultClientLimit.Validate())
+	assert.Error(t, ratelimit.Limit{Rate: 0, Burst: 1}.Validate())
+	assert.Error(t, ratelimit.Limit{Rate: 1, Burst: 0}.Validate())
+}
+
+func TestMemoryLimiter_AllowNGrantsWhatTheBucketHolds(t *testing.T) {
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	limiter := ratelimit.NewMemoryLimiterWithClock(ratelimit.Limit{Rate: 1, Burst: 3}, func() time.Time { return now })
+	ctx := context.Background()
+
+	decision, err := limiter.AllowN(ctx, "driver-1", 5)
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 3, decision.Granted)
+	assert.Equal(t, time.Second, decision.RetryAfter)
+
+	decision, err = limiter.AllowN(ctx, "driver-1", 1)
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Zero(t, decision.Granted)
+
+	now = now.Add(2 * time.Second)
+	decision, err = limiter.AllowN(ctx, "driver-1", 2)
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 2, decision.Granted)
+}
+
+func TestRedisLimiter_AllowNGrantsWhatTheBucketHolds(t *testing.T) {
+	server := miniredis.RunT(t)
+	limiter := ratelimit.NewRedisLimiter(redis.NewClient(&redis.Options{Addr: server.Addr()}), "test:", ratelimit.Limit{Rate: 1, Burst: 3})
+	ctx := context.Background()
+
+	decision, err := limiter.AllowN(ctx, "driver-1", 2)
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+	assert.Equal(t, 2, decision.Granted)
+
+	decision, err = limiter.AllowN(ctx, "driver-1", 4)
+	require.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, 1, decision.Granted)
+	assert.InDelta(t, time.Second, decision.RetryAfter, float64(100*time.Millisecond))
+}
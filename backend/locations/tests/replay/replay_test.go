@@ -0,0 +1,114 @@
+package replay_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"locations/internal/consumer"
+)
+
+func TestParseReplayStart(t *testing.T) {
+	start, err := consumer.ParseReplayStart("2024-03-01T12:30:00+02:00")
+	require.NoError(t, err)
+	assert.True(t, start.Time.Equal(time.Date(2024, 3, 1, 10, 30, 0, 0, time.UTC)))
+
+	start, err = consumer.ParseReplayStart("2024-03-01")
+	require.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC), start.Time, "a date means midnight UTC")
+
+	start, err = consumer.ParseReplayStart("1500")
+	require.NoError(t, err)
+	assert.True(t, start.Time.IsZero())
+	assert.Equal(t, int64(1500), start.Offset)
+
+	start, err = consumer.ParseReplayStart("0")
+	require.NoError(t, err)
+	assert.Equal(t, consumer.ReplayStart{}, start, "offset 0 replays from the start")
+}
+
+func TestParseReplayStartRejectsOtherValues(t *testing.T) {
+	for _, value := range []string{"", "-1", "yesterday", "2024-13-01", "24h"} {
+		_, err := consumer.ParseReplayStart(value)
+		assert.Error(t, err, value)
+	}
+}
+
+// partitionReader serves a partition's messages, then nothing, like a partition whose last offsets are
+// transaction markers.
+type partitionReader struct {
+	messages chan kafka.Message
+}
+
+func newPartitionReader(offsets ...int64) *partitionReader {
+	r := &partitionReader{messages: make(chan kafka.Message, len(offsets))}
+	for _, offset := range offsets {
+		r.messages <- kafka.Message{Offset: offset, Value: []byte("update")}
+	}
+	return r
+}
+
+func (r *partitionReader) ReadMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case msg := <-r.messages:
+		return msg, nil
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (r *partitionReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	return r.ReadMessage(ctx)
+}
+
+func (r *partitionReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	return nil
+}
+
+func (r *partitionReader) Close() error {
+	return nil
+}
+
+// countingProcessor records the offsets it processes.
+type countingProcessor struct {
+	offsets []int64
+}
+
+func (p *countingProcessor) ProcessMessage(ctx context.Context, msg kafka.Message) error {
+	p.offsets = append(p.offsets, msg.Offset)
+	return nil
+}
+
+func TestReplayMessagesStopsAtTheLastOffset(t *testing.T) {
+	processor := &countingProcessor{}
+	result, err := consumer.ReplayMessages(context.Background(), newPartitionReader(3, 4, 5, 6), 5, time.Minute, processor, nil)
+	require.NoError(t, err)
+	assert.Equal(t, consumer.ReplayResult{Processed: 2}, result)
+	assert.Equal(t, []int64{3, 4}, processor.offsets)
+}
+
+func TestReplayMessagesLeavesMessagesAfterTheEnd(t *testing.T) {
+	// Offset 4 is a transaction marker, so the next message is already past the end.
+	processor := &countingProcessor{}
+	result, err := consumer.ReplayMessages(context.Background(), newPartitionReader(3, 5), 5, time.Minute, processor, nil)
+	require.NoError(t, err)
+	assert.Equal(t, consumer.ReplayResult{Processed: 1}, result)
+}
+
+func TestReplayMessagesStopsWhenTheLastOffsetIsNeverRead(t *testing.T) {
+	processor := &countingProcessor{}
+	start := time.Now()
+	result, err := consumer.ReplayMessages(context.Background(), newPartitionReader(3), 5, 50*time.Millisecond, processor, nil)
+	require.NoError(t, err)
+	assert.Equal(t, consumer.ReplayResult{Processed: 1}, result)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = consumer.ReplayMessages(ctx, newPartitionReader(), 5, time.Minute, processor, nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
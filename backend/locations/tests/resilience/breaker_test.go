@@ -0,0 +1,83 @@
+package resilience_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"locations/internal/resilience"
+)
+
+func TestBreaker_OpensAfterThreshold(t *testing.T) {
+	var transitions []resilience.State
+	breaker := resilience.NewBreaker(2, time.Hour, func(from, to resilience.State) {
+		transitions = append(transitions, to)
+	})
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, breaker.Allow())
+		breaker.Record(true)
+	}
+
+	assert.Equal(t, resilience.Open, breaker.State())
+	assert.ErrorIs(t, breaker.Allow(), resilience.ErrOpen)
+	assert.Equal(t, []resilience.State{resilience.Open}, transitions)
+}
+
+func TestBreaker_SuccessResetsFailures(t *testing.T) {
+	breaker := resilience.NewBreaker(2, time.Hour, nil)
+
+	breaker.Record(true)
+	breaker.Record(false)
+	breaker.Record(true)
+
+	assert.Equal(t, resilience.Closed, breaker.State())
+}
+
+func TestBreaker_HalfOpenProbe(t *testing.T) {
+	breaker := resilience.NewBreaker(1, 10*time.Millisecond, nil)
+	assert.NoError(t, breaker.Allow())
+	breaker.Record(true)
+	assert.Equal(t, resilience.Open, breaker.State())
+
+	time.Sleep(20 * time.Millisecond)
+
+	// Only one probe is let through while half-open.
+	assert.NoError(t, breaker.Allow())
+	assert.ErrorIs(t, breaker.Allow(), resilience.ErrOpen)
+
+	breaker.Record(false)
+	assert.Equal(t, resilience.Closed, breaker.State())
+}
+
+func TestRetry_StopsOnNonRetryableError(t *testing.T) {
+	permanent := errors.New("permanent")
+	calls := 0
+
+	err := resilience.Retry(context.Background(), 5, time.Millisecond, func(err error) bool {
+		return !errors.Is(err, permanent)
+	}, func(ctx context.Context) error {
+		calls++
+		if calls == 1 {
+			return errors.New("transient")
+		}
+		return permanent
+	})
+
+	assert.ErrorIs(t, err, permanent)
+	assert.Equal(t, 2, calls)
+}
+
+func TestRetry_BoundedAttempts(t *testing.T) {
+	calls := 0
+	err := resilience.Retry(context.Background(), 3, time.Millisecond, func(error) bool { return true }, func(ctx context.Context) error {
+		calls++
+		return errors.New("transient")
+	})
+
+	assert.Error(t, err)
+	assert.Equal(t, 3, calls)
+}
@@ -0,0 +1,141 @@
+package tracing_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"locations/internal/consumer"
+	"locations/internal/db"
+	locationshttp "locations/internal/http"
+	"locations/internal/live"
+	"locations/internal/models"
+	"locations/internal/producer"
+	"locations/internal/tracing"
+)
+
+// record installs a tracer provider that keeps every span, and returns where they end up.
+func record(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { otel.SetTracerProvider(previous) })
+	return recorder
+}
+
+// spanNamed returns the ended span called name.
+func spanNamed(t *testing.T, recorder *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, span := range recorder.Ended() {
+		if span.Name() == name {
+			return span
+		}
+	}
+	require.Failf(t, "span not recorded", "no span named %q", name)
+	return nil
+}
+
+func TestMessageHeadersCarryTheTrace(t *testing.T) {
+	record(t)
+	ctx, span := tracing.Tracer().Start(context.Background(), "publish")
+	defer span.End()
+
+	msg := kafka.Message{Headers: []kafka.Header{{Key: "traceparent", Value: []byte("stale")}}}
+	tracing.InjectMessage(ctx, &msg)
+
+	require.Len(t, msg.Headers, 1, "an existing traceparent is replaced, not duplicated")
+	extracted := trace.SpanContextFromContext(tracing.ExtractMessage(context.Background(), msg))
+	assert.Equal(t, span.SpanContext().TraceID(), extracted.TraceID())
+	assert.Equal(t, span.SpanContext().SpanID(), extracted.SpanID())
+	assert.True(t, extracted.IsRemote())
+}
+
+func TestProcessingContinuesThePublishersTrace(t *testing.T) {
+	recorder := record(t)
+	publishCtx, publish := tracing.Tracer().Start(context.Background(), "publish")
+	value, err := json.Marshal(models.LocationUpdate{ID: "loc-1", DriverID: "driver-1", Timestamp: time.Now().UTC()})
+	require.NoError(t, err)
+	msg := kafka.Message{Topic: "locations", Key: []byte("driver-1"), Value: value}
+	tracing.InjectMessage(publishCtx, &msg)
+	publish.End()
+
+	processor := consumer.NewKafkaMessageProcessor(tracing.NewDatabase(db.NewMemoryDB()), nil, nil)
+	require.NoError(t, processor.ProcessMessage(tracing.ExtractMessage(context.Background(), msg), msg))
+
+	processing := spanNamed(t, recorder, "ProcessMessage")
+	insert := spanNamed(t, recorder, "db.InsertLocationUpdate")
+	assert.Equal(t, publish.SpanContext().TraceID(), processing.SpanContext().TraceID())
+	assert.Equal(t, publish.SpanContext().SpanID(), processing.Parent().SpanID())
+	assert.Equal(t, trace.SpanKindConsumer, processing.SpanKind())
+	assert.Equal(t, processing.SpanContext().SpanID(), insert.Parent().SpanID())
+}
+
+func TestFailedProcessingIsRecorded(t *testing.T) {
+	recorder := record(t)
+
+	processor := consumer.NewKafkaMessageProcessor(tracing.NewDatabase(db.NewMemoryDB()), nil, nil)
+	require.Error(t, processor.ProcessMessage(context.Background(), kafka.Message{Value: []byte("not json")}))
+
+	processing := spanNamed(t, recorder, "ProcessMessage")
+	assert.Equal(t, codes.Error, processing.Status().Code)
+	assert.Len(t, processing.Events(), 1)
+}
+
+func TestMissingDocumentsAreNotErrors(t *testing.T) {
+	recorder := record(t)
+
+	_, err := tracing.NewDatabase(db.NewMemoryDB()).GetDriverLocation(context.Background(), "driver-2")
+	require.ErrorIs(t, err, db.ErrNotFound)
+
+	assert.Equal(t, codes.Unset, spanNamed(t, recorder, "db.GetDriverLocation").Status().Code)
+}
+
+func TestHTTPRequestsContinueTheCallersTrace(t *testing.T) {
+	recorder := record(t)
+	database := db.NewMemoryDB()
+	require.NoError(t, database.InsertLocationUpdate(context.Background(), models.LocationUpdate{ID: "loc-1", DriverID: "driver-1"}))
+	handler, err := locationshttp.NewRouter(context.Background(), &producer.KafkaProducer{}, tracing.NewDatabase(database), "",
+		live.NewHub(), nil, nil, nil, nil, nil, nil, nil, nil)
+	require.NoError(t, err)
+
+	const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+	r := httptest.NewRequest(http.MethodGet, "/location?id=loc-1", nil)
+	r.Header.Set("traceparent", traceparent)
+	r.Header.Set("X-Request-ID", "req-123")
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, r)
+	require.Equal(t, http.StatusOK, response.Code)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	server := spanNamed(t, recorder, "GET /location")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", server.SpanContext().TraceID().String())
+	assert.Equal(t, trace.SpanKindServer, server.SpanKind())
+	assert.Contains(t, server.Attributes(), attribute.String("http.request_id", "req-123"))
+	assert.Equal(t, server.SpanContext().SpanID(), spanNamed(t, recorder, "db.GetLocationByID").Parent().SpanID())
+	// Probes aren't traced.
+	assert.Len(t, recorder.Ended(), 2)
+}
+
+func TestConfigValidation(t *testing.T) {
+	assert.NoError(t, tracing.Config{}.Validate())
+	assert.NoError(t, tracing.Config{Exporter: tracing.ExporterOTLP, SampleRatio: 0.1}.Validate())
+	assert.Error(t, tracing.Config{Exporter: tracing.ExporterFile}.Validate())
+	assert.Error(t, tracing.Config{Exporter: "jaeger"}.Validate())
+	assert.Error(t, tracing.Config{Exporter: tracing.ExporterStdout, SampleRatio: 2}.Validate())
+}
@@ -0,0 +1,111 @@
+package trips_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"locations/internal/trips"
+)
+
+func TestTracker_IssueAndVerify(t *testing.T) {
+	tracker := trips.NewTracker("secret")
+	token, err := tracker.Issue(trips.Claims{TripID: "trip-1", DriverID: "driver-1", RiderID: "rider-1", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	claims, err := tracker.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, "trip-1", claims.TripID)
+	assert.Equal(t, "driver-1", claims.DriverID)
+}
+
+func TestTracker_RejectsForeignAndExpiredTokens(t *testing.T) {
+	tracker := trips.NewTracker("secret")
+
+	foreign, err := trips.NewTracker("other").Issue(trips.Claims{TripID: "trip-1", DriverID: "driver-1", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+	_, err = tracker.Verify(foreign)
+	assert.ErrorIs(t, err, trips.ErrInvalidToken)
+
+	expired, err := tracker.Issue(trips.Claims{TripID: "trip-1", DriverID: "driver-1", ExpiresAt: time.Now().Add(-time.Minute)})
+	require.NoError(t, err)
+	_, err = tracker.Verify(expired)
+	assert.ErrorIs(t, err, trips.ErrTokenExpired)
+}
+
+func TestTracker_EndClosesDoneAndRevokesTokens(t *testing.T) {
+	tracker := trips.NewTracker("secret")
+	token, err := tracker.Issue(trips.Claims{TripID: "trip-1", DriverID: "driver-1", ExpiresAt: time.Now().Add(time.Hour)})
+	require.NoError(t, err)
+
+	done, stopWaiting := tracker.Done("trip-1")
+	defer stopWaiting()
+	tracker.End("trip-1")
+
+	select {
+	case <-done:
+	default:
+		assert.Fail(t, "Done was not closed when the trip ended")
+	}
+	_, err = tracker.Verify(token)
+	assert.ErrorIs(t, err, trips.ErrTripEnded)
+}
+
+func TestTracker_ForgetsTripsNobodyWaitsFor(t *testing.T) {
+	tracker := trips.NewTracker("secret")
+
+	first, stopFirst := tracker.Done("trip-1")
+	second, stopSecond := tracker.Done("trip-1")
+	assert.Equal(t, first, second, "callers waiting on a trip share its channel")
+
+	stopFirst()
+	stopFirst()
+	again, stopAgain := tracker.Done("trip-1")
+	assert.Equal(t, first, again, "the trip is kept while anyone waits on it")
+	stopSecond()
+	stopAgain()
+
+	// With nobody left waiting the trip was forgotten, so the next caller gets a new channel.
+	fresh, stopFresh := tracker.Done("trip-1")
+	defer stopFresh()
+	assert.NotEqual(t, first, fresh)
+
+	tracker.End("trip-1")
+	select {
+	case <-fresh:
+	default:
+		assert.Fail(t, "Done was not closed when the trip ended")
+	}
+}
+
+func TestTracker_RejectsTokensOutlivingEndedTrips(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tracker := trips.NewTrackerWithClock("secret", func() time.Time { return now })
+
+	longLived, err := tracker.Issue(trips.Claims{TripID: "trip-1", DriverID: "driver-1", ExpiresAt: now.Add(48 * time.Hour)})
+	require.NoError(t, err)
+	_, err = tracker.Verify(longLived)
+	assert.ErrorIs(t, err, trips.ErrInvalidToken)
+
+	// Tokens must say when they were issued.
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"trip_id":"trip-1","driver_id":"driver-1","exp":"2024-05-01T13:00:00Z"}`))
+	mac := hmac.New(sha256.New, []byte("secret"))
+	mac.Write([]byte(payload))
+	_, err = tracker.Verify(payload + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)))
+	assert.ErrorIs(t, err, trips.ErrInvalidToken)
+
+	// A token lives no longer than its trip's end is remembered.
+	token, err := tracker.Issue(trips.Claims{TripID: "trip-1", DriverID: "driver-1", ExpiresAt: now.Add(24 * time.Hour)})
+	require.NoError(t, err)
+	tracker.End("trip-1")
+	now = now.Add(25 * time.Hour)
+	tracker.End("trip-2")
+	assert.False(t, tracker.Ended("trip-1"), "the ended trip has been forgotten")
+	_, err = tracker.Verify(token)
+	assert.ErrorIs(t, err, trips.ErrTokenExpired)
+}